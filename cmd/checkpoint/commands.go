@@ -0,0 +1,2366 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/daemon"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/doctor"
+	"checkpoint/pkg/dupes"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/installer"
+	"checkpoint/pkg/metrics"
+	"checkpoint/pkg/notify"
+	"checkpoint/pkg/output"
+	"checkpoint/pkg/search"
+	"checkpoint/pkg/service"
+	"checkpoint/pkg/snapshot"
+	"checkpoint/pkg/ui"
+)
+
+// Exit codes shared by all subcommands, following the usual
+// "0 ok / 1 warning / 2 error" convention so scripts can branch on them
+const (
+	exitOK      = 0
+	exitWarning = 1
+	exitError   = 2
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"list", "[--details] [--sort KEY] [--type T,...] [--fs FS,...] [--min-used N] [--mount GLOB] [--preset NAME] [--output FORMAT]", "List scanned disks in the technical table", cmdList},
+		{"groups", "[--simple] [--reclaimable] [--output FORMAT]", "Show drives grouped like \"My Computer\"", cmdGroups},
+		{"stats", "[--output FORMAT]", "Show the storage summary", cmdStats},
+		{"unmounted", "[--output FORMAT]", "List disks that have a filesystem but are not mounted", cmdUnmounted},
+		{"exec", "[--drive D:] -- cmd [args...]", "Run a command on a selected drive", cmdExec},
+		{"usage", "[--output FORMAT] <path>", "Show which drive holds a path and how full it is", cmdUsage},
+		{"analyze", "[--apparent] [--cross-fs] [--top N] [--workers N] [--timeout D] [--output FORMAT] [path|drive]", "Show which folders and files fill a drive or directory", cmdAnalyze},
+		{"history", "[--record] [--output FORMAT] [drive|path]", "Show how much each filesystem grew over the last day, week and month", cmdHistory},
+		{"reclaim", "[--output FORMAT] [drive|path]", "Show how much space caches, trash and old logs take and could free", cmdReclaim},
+		{"clean", "[--dry-run] [--yes] [--delete] [--only KIND,...] [--output FORMAT] [drive|path]", "Free the space reclaim finds, moving files to the trash", cmdClean},
+		{"files", "[--min-size SIZE] [--stale AGE] [--owner USER] [--ext EXT,...] [--older-than AGE] [--top N] [--move-to DIR|--trash [--pick N,...] [--yes]] [--output FORMAT] [drive|path...]", "Find large files and files unused for months, grouped by folder and type", cmdFiles},
+		{"types", "[--no-sniff] [--cross-fs] [--output FORMAT] [drive|path...]", "Show how much space videos, images, documents, code and archives take on each drive", cmdTypes},
+		{"snapshot", "[save|list|delete] [--min-size SIZE] [--cross-fs] [--output FORMAT] [drive|path|id...]", "Save how much space each folder below a path takes, to compare later", cmdSnapshot},
+		{"diff", "[--top N] [--all] [--since AGE] [--output FORMAT] <snapshot|path> [snapshot|now]", "Show which folders and files grew, shrank, appeared or disappeared between two snapshots", cmdDiff},
+		{"trash", "[list|restore|delete|empty] [--yes] [--output FORMAT] [drive|path|item...]", "List, restore and permanently delete trashed files on every drive", cmdTrash},
+		{"dupes", "[--min-size SIZE] [--cross-fs] [--workers N] [--no-cache] [--top N] [--action hardlink|reflink|trash [--dry-run] [--yes]] [--output FORMAT] [drive|path...]", "Find duplicate files and replace them with links or move them to the trash", cmdDupes},
+		{"doctor", "[--only CHECK,...] [--list] [--output FORMAT]", "Look for common storage problems and explain how to fix them", cmdDoctor},
+		{"check", "[--thresholds FILE] [drive|path...]", "Check filesystems against thresholds like a Nagios or Icinga plugin", cmdCheck},
+		{"serve", "--metrics ADDR [--interval 30s] [--timeout 2m]", "Serve Prometheus metrics, e.g. on :9108", cmdServe},
+		{"daemon", "[--socket PATH] [--interval 1m] [--notify]", "Keep a live disk inventory and answer queries on a Unix socket", cmdDaemon},
+		{"service", "install|uninstall|status [flags]", "Install systemd user timers that record history and check thresholds", cmdService},
+		{"watch", "[--interval 5s] [--count N] [--technical] [--output ndjson]", "Redraw the drive view periodically", cmdWatch},
+	}
+}
+
+// run dispatches to a subcommand, falling back to the interactive interface
+func run(args []string) int {
+	if len(args) == 0 {
+		return runInteractive(args)
+	}
+
+	switch args[0] {
+	case "-h", "--help", "help":
+		printUsage(os.Stdout)
+		return exitOK
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return runInteractive(args)
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("❌ Unknown command: %s", args[0])))
+	printUsage(os.Stderr)
+	return exitError
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: checkpoint [--classic] [--refresh 5s]")
+	fmt.Fprintln(w, "       checkpoint <command> [flags]")
+	fmt.Fprintln(w, "\nWithout a command the full-screen interface is started. --classic (or a")
+	fmt.Fprintln(w, "terminal that is not interactive) uses the numbered menu instead.")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nExit codes: 0 ok, 1 warning (a drive is past a warning threshold), 2 error.")
+	fmt.Fprintln(w, "check uses the monitoring plugin codes: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN.")
+	fmt.Fprintln(w, "Reports (plain, markdown, html) and structured output (json, yaml, csv, ndjson)")
+	fmt.Fprintln(w, "are selected with --output.")
+	fmt.Fprintln(w, "Run 'checkpoint <command> -h' for command flags.")
+}
+
+// newFlagSet creates a flag set whose help text matches the command table
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(fs.Output(), "Usage: checkpoint %s %s\n\n%s\n", c.name, c.args, c.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses args and reports whether the command should continue.
+// When it should not, the returned code is the exit status to use.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitError, false
+	}
+	return exitOK, true
+}
+
+// outputFlag registers --output and its -o shorthand
+func outputFlag(fs *flag.FlagSet) *string {
+	out := fs.String("output", string(output.FormatText), "output format: text, plain, markdown, html, json, yaml, csv or ndjson")
+	fs.StringVar(out, "o", string(output.FormatText), "shorthand for --output")
+	return out
+}
+
+// filterOptions holds the sorting and filtering flags of the disk table
+type filterOptions struct {
+	sort        *string
+	reverse     *bool
+	types       *string
+	filesystems *string
+	minUsed     *float64
+	mount       *string
+	mountRegexp *string
+	preset      *string
+	savePreset  *string
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterOptions {
+	return &filterOptions{
+		sort:        fs.String("sort", "", "sort by size, used, available, type or mount"),
+		reverse:     fs.Bool("reverse", false, "reverse the sort order"),
+		types:       fs.String("type", "", "only these disk types, e.g. physical,lvm"),
+		filesystems: fs.String("fs", "", "only these filesystems, e.g. ext4,xfs"),
+		minUsed:     fs.Float64("min-used", 0, "only disks at least this percent full"),
+		mount:       fs.String("mount", "", "only mount points matching a glob, e.g. '/mnt/*'"),
+		mountRegexp: fs.String("mount-regex", "", "only mount points matching a regular expression"),
+		preset:      fs.String("preset", "", "start from a saved or built-in filter preset (physical, network, full, largest)"),
+		savePreset:  fs.String("save-preset", "", "save the resulting filter under this name"),
+	}
+}
+
+// build combines the preset with the flags given explicitly on the command line
+func (o *filterOptions) build(fs *flag.FlagSet) (disk.Filter, error) {
+	var f disk.Filter
+	if *o.preset != "" {
+		presets, err := disk.LoadPresets()
+		if err != nil {
+			return f, err
+		}
+		preset, ok := presets[*o.preset]
+		if !ok {
+			return f, fmt.Errorf("unknown preset %q", *o.preset)
+		}
+		f = preset
+	}
+
+	var err error
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "sort":
+			f.Sort = disk.SortKey(*o.sort)
+		case "reverse":
+			f.Reverse = *o.reverse
+		case "type":
+			f.Types, err = disk.ParseTypes(*o.types)
+		case "fs":
+			f.Filesystems = disk.ParseList(*o.filesystems)
+		case "min-used":
+			f.MinUsedPercent = *o.minUsed
+		case "mount":
+			f.MountGlob = *o.mount
+		case "mount-regex":
+			f.MountRegexp = *o.mountRegexp
+		}
+	})
+	if err != nil {
+		return f, err
+	}
+	if err := f.Validate(); err != nil {
+		return f, err
+	}
+
+	if *o.savePreset != "" {
+		if err := disk.SavePreset(*o.savePreset, f); err != nil {
+			return f, err
+		}
+		fmt.Fprintln(os.Stderr, infoStyle.Render(fmt.Sprintf("💾 Saved filter preset %q", *o.savePreset)))
+	}
+	return f, nil
+}
+
+// scanManager creates a manager and runs the initial scan
+func scanManager() (*disk.Manager, error) {
+	dm := disk.NewManager()
+	if err := dm.ScanDisks(); err != nil {
+		return nil, err
+	}
+	// Losing a history sample should not cost the user their answer
+	if err := recordHistory(dm); err != nil {
+		fmt.Fprintln(os.Stderr, infoStyle.Render("⚠️  "+err.Error()))
+	}
+	return dm, nil
+}
+
+// recordHistory adds a scan to the usage history
+func recordHistory(dm *disk.Manager) error {
+	store, err := history.Open()
+	if err == nil {
+		err = store.Record(dm.GetDisks(), dm.LastScan())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record usage history: %v", err)
+	}
+	return nil
+}
+
+// friendlyOptions are the terminal options with the usage history and trash
+// size of the groups, so drive cards can show their trend. It also fills in
+// the groups' forecasts.
+func friendlyOptions(groups []disk.DriveGroup) ui.Options {
+	opts := ui.DetectOptions(os.Stdout)
+	if store, err := history.Open(); err == nil {
+		opts.History = store.ForGroups(groups)
+		history.AddForecasts(groups, opts.History)
+	}
+	opts.Trash = map[string]uint64{}
+	for _, g := range groups {
+		if size, _ := disk.TrashSize(disk.GroupTrashDirs(g)); size > 0 && len(g.Disks) > 0 {
+			opts.Trash[g.Disks[0].MountPoint] = size
+		}
+	}
+	thresholds().Apply(groups, history.Forecasts(opts.History))
+	return opts
+}
+
+// thresholds loads the alert thresholds once per run. A broken config file
+// is reported and the defaults are used instead.
+var thresholds = sync.OnceValue(func() *disk.ThresholdConfig {
+	c, err := disk.LoadThresholds()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, infoStyle.Render("⚠️  "+err.Error()))
+	}
+	return c
+})
+
+// diskForecasts predicts each disk from its usage history, keyed by mount point
+func diskForecasts(disks []disk.Disk) map[string]*disk.Forecast {
+	store, err := history.Open()
+	if err != nil {
+		return nil
+	}
+	return history.Forecasts(store.ForDisks(disks))
+}
+
+// render draws a view and turns a failure into an exit code
+func render(format output.Format, draw func(r ui.Renderer) error) int {
+	return renderWith(format, ui.DetectOptions(os.Stdout), draw)
+}
+
+// renderWith is render with explicit terminal options
+func renderWith(format output.Format, opts ui.Options, draw func(r ui.Renderer) error) int {
+	r, err := ui.NewRenderer(string(format), os.Stdout, opts)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if err := draw(r); err != nil {
+		return fail("Error writing output: %v", err)
+	}
+	return exitOK
+}
+
+// emit writes data in a structured format to stdout
+func emit(format output.Format, kind string, data interface{}) int {
+	if err := output.Write(os.Stdout, format, kind, data); err != nil {
+		return fail("Error writing output: %v", err)
+	}
+	return exitOK
+}
+
+func fail(format string, args ...interface{}) int {
+	fmt.Fprintln(os.Stderr, errorStyle.Render("❌ "+fmt.Sprintf(format, args...)))
+	return exitError
+}
+
+// diskStatus returns exitWarning when any real filesystem is past a
+// threshold. A filter that matched nothing is not a warning.
+func diskStatus(disks []disk.Disk) int {
+	forecasts := diskForecasts(disks)
+	for _, d := range disks {
+		if thresholds().For(d).Check(d, forecasts[d.MountPoint]).Level > disk.LevelOK {
+			return exitWarning
+		}
+	}
+	return exitOK
+}
+
+func cmdList(args []string) int {
+	fs := newFlagSet("list")
+	details := fs.Bool("details", false, "show filesystem, used space and inodes")
+	filterOpts := addFilterFlags(fs)
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	filter, err := filterOpts.build(fs)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return fail("Error scanning disks: %v", err)
+	}
+	disks, err := filter.Apply(dm.GetDisks())
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	code := exitOK
+	if format.Structured() {
+		code = emit(format, output.KindDisks, disks)
+	} else {
+		code = render(format, func(r ui.Renderer) error {
+			return r.Disks(disks, *details)
+		})
+	}
+	if code != exitOK {
+		return code
+	}
+	return diskStatus(disks)
+}
+
+func cmdGroups(args []string) int {
+	fs := newFlagSet("groups")
+	simple := fs.Bool("simple", false, "show a compact table instead of drive cards")
+	reclaimable := fs.Bool("reclaimable", false, "measure caches, trash and old logs and mark them on the drive cards")
+	types := fs.Bool("types", false, "measure the space each file type takes and split the drive cards' bars by it (reads every folder)")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return fail("Error scanning disks: %v", err)
+	}
+	groups := disk.GroupDisks(dm.GetDisks())
+	opts := friendlyOptions(groups)
+	if *reclaimable {
+		opts.Reclaimable = cleanup.Analyze(context.Background(), cleanup.Options{Disks: dm.GetDisks()}).ByMount()
+	}
+	if *types && !format.Structured() {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		drives, err := measureFileTypes(ctx, groups, filetypes.Options{Sniff: true})
+		stop()
+		if err != nil {
+			return fail("%v", err)
+		}
+		opts.FileTypes = map[string]filetypes.Drive{}
+		for i, d := range drives {
+			if len(groups[i].Disks) > 0 {
+				opts.FileTypes[groups[i].Disks[0].MountPoint] = d
+			}
+		}
+	}
+	code := exitOK
+	if format.Structured() {
+		code = emit(format, output.KindGroups, groups)
+	} else {
+		code = renderWith(format, opts, func(r ui.Renderer) error {
+			if *simple {
+				return r.SimpleDiskList(groups)
+			}
+			return r.FriendlyDisks(groups)
+		})
+	}
+	if code != exitOK {
+		return code
+	}
+	return diskStatus(dm.GetDisks())
+}
+
+func cmdStats(args []string) int {
+	fs := newFlagSet("stats")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return fail("Error scanning disks: %v", err)
+	}
+	code := exitOK
+	if format.Structured() {
+		code = emit(format, output.KindStats, dm.GetStats())
+	} else {
+		code = render(format, func(r ui.Renderer) error {
+			return r.Summary(dm.GetStats(), dm.GetDisks())
+		})
+	}
+	if code != exitOK {
+		return code
+	}
+	return diskStatus(dm.GetDisks())
+}
+
+func cmdUnmounted(args []string) int {
+	fs := newFlagSet("unmounted")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	unmounted, err := disk.ScanUnmountedDisks()
+	if err != nil {
+		return fail("Error scanning unmounted disks: %v", err)
+	}
+	if format.Structured() {
+		return emit(format, output.KindUnmounted, unmounted)
+	}
+	if len(unmounted) == 0 {
+		fmt.Println(infoStyle.Render("💿 No unmounted disks found"))
+		return exitOK
+	}
+	for _, ud := range unmounted {
+		fmt.Printf("%s %s (%s, %s)\n", successStyle.Render("•"), ud.Device, ui.FormatBytes(ud.Size), ud.Filesystem)
+		if ud.Label != "" {
+			fmt.Printf("   Label: %s\n", ud.Label)
+		}
+	}
+	return exitOK
+}
+
+func cmdExec(args []string) int {
+	fs := newFlagSet("exec")
+	drive := fs.String("drive", "", "target drive letter (C:, D:, ...), drive name or mount point")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitError
+	}
+
+	var targetDisk *disk.Disk
+	if *drive != "" {
+		dm, err := scanManager()
+		if err != nil {
+			return fail("Error scanning disks: %v", err)
+		}
+		group, err := disk.FindGroup(disk.GroupDisks(dm.GetDisks()), *drive)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if len(group.Disks) == 0 {
+			return fail("Drive %s has no mounted locations", group.Name)
+		}
+		targetDisk = &group.Disks[0]
+	}
+
+	if err := installer.ExecuteArgs(fs.Args(), targetDisk); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return fail("Command exited with status %d", exitErr.ExitCode())
+		}
+		return fail("Error executing command: %v", err)
+	}
+	return exitOK
+}
+
+func cmdUsage(args []string) int {
+	fs := newFlagSet("usage")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitError
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return fail("Error scanning disks: %v", err)
+	}
+	d, err := dm.DiskForPath(fs.Arg(0))
+	if err != nil {
+		return fail("%v", err)
+	}
+	code := exitOK
+	if format.Structured() {
+		code = emit(format, output.KindDisks, []disk.Disk{*d})
+	} else {
+		code = render(format, func(r ui.Renderer) error {
+			return r.PathUsage(fs.Arg(0), *d)
+		})
+	}
+	if code != exitOK {
+		return code
+	}
+	return diskStatus([]disk.Disk{*d})
+}
+
+func cmdAnalyze(args []string) int {
+	fs := newFlagSet("analyze")
+	apparent := fs.Bool("apparent", false, "sort by apparent size instead of space used on disk")
+	crossFS := fs.Bool("cross-fs", false, "descend into other filesystems mounted below the path")
+	top := fs.Int("top", 10, "number of largest files to list")
+	workers := fs.Int("workers", analyzer.DefaultWorkers, "directories read at once")
+	timeout := fs.Duration("timeout", 0, "stop after this long and show partial results (0 means no limit)")
+	treemap := fs.Int("treemap", 0, "draw a treemap this many lines tall instead of the table")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if *treemap > 0 && format != output.FormatText {
+		return fail("--treemap only works with text output")
+	}
+
+	root := "."
+	if fs.NArg() == 1 {
+		root, err = analyzeRoot(fs.Arg(0))
+		if err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	// Ctrl+C stops the scan and still prints what was measured so far
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	scanner := analyzer.NewScanner(root, analyzer.Options{CrossFilesystems: *crossFS, Workers: *workers})
+	done := make(chan struct{})
+	if term.IsTerminal(os.Stderr.Fd()) {
+		go showScanProgress(scanner, done)
+	}
+	result, err := scanner.Run(ctx)
+	close(done)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	report := result.Report(result.Root, *top, *apparent)
+	code := exitOK
+	switch {
+	case *treemap > 0:
+		if err := ui.WriteTreemap(os.Stdout, report, *treemap, ui.DetectOptions(os.Stdout)); err != nil {
+			code = fail("Error writing output: %v", err)
+		}
+	case format.Structured():
+		code = emit(format, output.KindAnalysis, report)
+	default:
+		code = render(format, func(r ui.Renderer) error {
+			return r.DirUsage(report)
+		})
+	}
+	if code == exitOK && result.Partial {
+		return exitWarning
+	}
+	return code
+}
+
+func cmdHistory(args []string) int {
+	fs := newFlagSet("history")
+	record := fs.Bool("record", false, "only scan and record a sample, for cron jobs and timers")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	dm := disk.NewManager()
+	if err := dm.ScanDisks(); err != nil {
+		return fail("failed to scan disks: %v", err)
+	}
+	if err := recordHistory(dm); err != nil {
+		return fail("%v", err)
+	}
+	if *record {
+		return exitOK
+	}
+
+	store, err := history.Open()
+	if err != nil {
+		return fail("%v", err)
+	}
+	var reports []history.Report
+	if fs.NArg() == 1 {
+		d, err := findDisk(dm, fs.Arg(0))
+		if err != nil {
+			return fail("%v", err)
+		}
+		samples, err := store.Samples(*d)
+		if err != nil {
+			return fail("%v", err)
+		}
+		reports = []history.Report{history.NewReport(store.Key(*d), samples)}
+	} else if reports, err = store.Reports(); err != nil {
+		return fail("%v", err)
+	}
+
+	if format.Structured() {
+		return emit(format, output.KindHistory, reports)
+	}
+	return render(format, func(r ui.Renderer) error {
+		return r.History(reports)
+	})
+}
+
+func cmdReclaim(args []string) int {
+	fs := newFlagSet("reclaim")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return fail("Error scanning disks: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report := cleanup.Analyze(ctx, cleanup.Options{Disks: dm.GetDisks()})
+	if ctx.Err() != nil {
+		return fail("interrupted")
+	}
+
+	groups := disk.GroupDisks(dm.GetDisks())
+	if fs.NArg() == 1 {
+		d, err := findDisk(dm, fs.Arg(0))
+		if err != nil {
+			return fail("%v", err)
+		}
+		report.Items, report.Total = report.ForMounts(d.MountPoint)
+		groups = groupsOn(groups, d.MountPoint)
+	}
+
+	if format.Structured() {
+		return emit(format, output.KindReclaim, report)
+	}
+	return render(format, func(r ui.Renderer) error {
+		return r.Reclaimable(report, groups)
+	})
+}
+
+func cmdClean(args []string) int {
+	fs := newFlagSet("clean")
+	dryRun := fs.Bool("dry-run", false, "only show what would be done")
+	yes := fs.Bool("yes", false, "do not ask before cleaning up or running sudo")
+	remove := fs.Bool("delete", false, "delete files for good instead of moving them to the trash")
+	only := fs.String("only", "", "clean up only these kinds: "+strings.Join(cleanup.KindNames(), ", "))
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	var kinds []string
+	if *only != "" {
+		kinds = strings.Split(*only, ",")
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return fail("Error scanning disks: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report := cleanup.Analyze(ctx, cleanup.Options{Disks: dm.GetDisks()})
+	if ctx.Err() != nil {
+		return fail("interrupted")
+	}
+	items := report.Items
+	if fs.NArg() == 1 {
+		d, err := findDisk(dm, fs.Arg(0))
+		if err != nil {
+			return fail("%v", err)
+		}
+		items, _ = report.ForMounts(d.MountPoint)
+	}
+	if items, err = cleanup.Select(items, kinds); err != nil {
+		return fail("%v", err)
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(os.Stderr, infoStyle.Render("Nothing to clean up"))
+		return exitOK
+	}
+
+	opts := cleanup.ExecOptions{DryRun: true, Delete: *remove}
+	show := func(results []cleanup.Result) int {
+		if format.Structured() {
+			return emit(format, output.KindCleanup, results)
+		}
+		return render(format, func(r ui.Renderer) error {
+			return r.Cleanup(results)
+		})
+	}
+	if *dryRun {
+		return show(cleanup.Execute(ctx, items, opts))
+	}
+
+	// Without --yes, show the plan and ask, which takes a terminal
+	if !*yes {
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			return fail("refusing to clean up without a terminal to ask on, pass --yes or --dry-run")
+		}
+		if code := show(cleanup.Execute(ctx, items, opts)); code != exitOK {
+			return code
+		}
+		if !ask("Clean up now?") {
+			return exitOK
+		}
+	}
+	opts.DryRun = false
+	opts.Confirm = func(item cleanup.Item, steps []string) bool {
+		if *yes {
+			return true
+		}
+		fmt.Fprintf(os.Stderr, "\n🔒 %s needs administrator rights:\n", item.Name)
+		for _, step := range steps {
+			fmt.Fprintf(os.Stderr, "   %s\n", step)
+		}
+		return ask("Run with sudo?")
+	}
+	results := cleanup.Execute(ctx, items, opts)
+	code := show(results)
+	for _, r := range results {
+		if r.Error != "" && code == exitOK {
+			code = exitError
+		}
+	}
+	return code
+}
+
+// stdin is shared by the questions so typed-ahead answers are not lost
+var stdin = bufio.NewReader(os.Stdin)
+
+// ask asks a yes or no question on the terminal, no by default
+func ask(question string) bool {
+	fmt.Fprint(os.Stderr, infoStyle.Render(question+" [y/N] "))
+	answer, _ := stdin.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// groupsOn keeps the groups with a filesystem mounted at mountPoint
+func groupsOn(groups []disk.DriveGroup, mountPoint string) []disk.DriveGroup {
+	var found []disk.DriveGroup
+	for _, g := range groups {
+		for _, d := range g.Disks {
+			if d.MountPoint == mountPoint {
+				found = append(found, g)
+				break
+			}
+		}
+	}
+	return found
+}
+
+func cmdTrash(args []string) int {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	fs := newFlagSet("trash")
+	var out *string
+	var yes *bool
+	switch action {
+	case "list":
+		out = outputFlag(fs)
+	case "restore":
+	case "delete", "empty":
+		yes = fs.Bool("yes", false, "do not ask before deleting")
+	default:
+		return fail("Unknown trash action %q, expected list, restore, delete or empty", action)
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return fail("Error scanning disks: %v", err)
+	}
+	groups := disk.GroupDisks(dm.GetDisks())
+	if action == "list" || action == "empty" {
+		if fs.NArg() > 1 {
+			fs.Usage()
+			return exitError
+		}
+		if fs.NArg() == 1 {
+			d, err := findDisk(dm, fs.Arg(0))
+			if err != nil {
+				return fail("%v", err)
+			}
+			groups = groupsOn(groups, d.MountPoint)
+		}
+	} else if fs.NArg() == 0 {
+		return fail("Name the items to %s, as listed by 'checkpoint trash list'", action)
+	}
+	items, err := trashItems(groups)
+	if err != nil {
+		return fail("Error reading the trash: %v", err)
+	}
+
+	switch action {
+	case "list":
+		format, err := output.ParseFormat(*out)
+		if err != nil {
+			return fail("%v", err)
+		}
+		if format.Structured() {
+			return emit(format, output.KindTrash, items)
+		}
+		return render(format, func(r ui.Renderer) error {
+			return r.Trash(items)
+		})
+	case "empty":
+		if len(items) == 0 {
+			fmt.Println("The trash is empty")
+			return exitOK
+		}
+	default:
+		if items, err = pickTrash(items, fs.Args()); err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	if action != "restore" && !*yes {
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			return fail("refusing to delete without a terminal to ask on, pass --yes")
+		}
+		var size uint64
+		for _, item := range items {
+			size += item.Size
+		}
+		if !ask(fmt.Sprintf("Permanently delete %d items (%s) from the trash?", len(items), ui.FormatBytes(size))) {
+			return exitOK
+		}
+	}
+	code := exitOK
+	for _, item := range items {
+		if action == "restore" {
+			err = disk.RestoreTrash(item)
+		} else {
+			err = disk.DeleteTrash(item)
+		}
+		if err != nil {
+			code = fail("%v", err)
+		} else if action == "restore" {
+			fmt.Println(successStyle.Render("✅ Restored " + item.Path))
+		}
+	}
+	if action != "restore" && code == exitOK {
+		noun := "items"
+		if len(items) == 1 {
+			noun = "item"
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("✅ Deleted %d %s", len(items), noun)))
+	}
+	return code
+}
+
+// trashItems lists the user's trash on the groups' drives, each item
+// tagged with the first mount point of its drive
+func trashItems(groups []disk.DriveGroup) ([]disk.TrashItem, error) {
+	seen := map[string]bool{}
+	all := []disk.TrashItem{}
+	for _, g := range groups {
+		var dirs []string
+		for _, dir := range disk.GroupTrashDirs(g) {
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+		items, err := disk.ListTrash(dirs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range items {
+			items[i].MountPoint = g.Disks[0].MountPoint
+		}
+		all = append(all, items...)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].DeletedAt.After(all[j].DeletedAt) })
+	return all, nil
+}
+
+// pickTrash finds the items named on the command line, by their name in
+// the trash or the path they were deleted from. A path deleted more than
+// once is ambiguous and has to be given by name.
+func pickTrash(items []disk.TrashItem, refs []string) ([]disk.TrashItem, error) {
+	var picked []disk.TrashItem
+	for _, ref := range refs {
+		path := ref
+		if abs, err := filepath.Abs(ref); err == nil {
+			path = abs
+		}
+		var found []disk.TrashItem
+		for _, item := range items {
+			if item.Name == ref || item.Path == path {
+				found = append(found, item)
+			}
+		}
+		switch len(found) {
+		case 0:
+			return nil, fmt.Errorf("%s is not in the trash", ref)
+		case 1:
+			picked = append(picked, found[0])
+		default:
+			names := make([]string, len(found))
+			for i, item := range found {
+				names[i] = fmt.Sprintf("%s (deleted %s)", item.Name, item.DeletedAt.Format("2006-01-02 15:04"))
+			}
+			return nil, fmt.Errorf("%s matches %d items in the trash, pick one by name: %s", ref, len(found), strings.Join(names, ", "))
+		}
+	}
+	return picked, nil
+}
+
+func cmdFiles(args []string) int {
+	fs := newFlagSet("files")
+	minSize := fs.String("min-size", "1G", "report files at least this big, e.g. 500M (0 turns it off)")
+	stale := fs.String("stale", "6m", "report files not read or modified for this long, e.g. 90d, 6m or 1y (0 turns it off)")
+	owner := fs.String("owner", "", "only files of this user name or uid")
+	ext := fs.String("ext", "", "only files with these extensions, e.g. iso,qcow2,vdi")
+	olderThan := fs.String("older-than", "", "only files last modified longer ago than this, e.g. 30d")
+	crossFS := fs.Bool("cross-fs", false, "descend into other filesystems mounted below the paths")
+	top := fs.Int("top", 50, "number of files, folders and types to show (0 shows all)")
+	moveTo := fs.String("move-to", "", "move the picked files into this directory, e.g. on another drive")
+	toTrash := fs.Bool("trash", false, "move the picked files to the trash")
+	pick := fs.String("pick", "", "the files to move, by their number in the list, e.g. 1-3,7 or all")
+	yes := fs.Bool("yes", false, "do not ask which files or whether to go ahead; without --pick, all listed files")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	acting := *moveTo != "" || *toTrash
+	if *moveTo != "" && *toTrash {
+		return fail("--move-to and --trash cannot be combined")
+	}
+	if acting && format.Structured() {
+		return fail("--move-to and --trash show a list to pick from, which needs a text format")
+	}
+
+	opts := search.Options{Owner: *owner, CrossFilesystems: *crossFS}
+	if opts.MinSize, err = parseSize(*minSize); err != nil {
+		return fail("%v", err)
+	}
+	if opts.StaleAfter, err = search.ParseAge(*stale); err != nil {
+		return fail("%v", err)
+	}
+	if *olderThan != "" {
+		if opts.OlderThan, err = search.ParseAge(*olderThan); err != nil {
+			return fail("%v", err)
+		}
+	}
+	if *ext != "" {
+		opts.Extensions = strings.Split(*ext, ",")
+	}
+	opts.Roots = []string{"."}
+	if fs.NArg() > 0 {
+		opts.Roots = nil
+		for _, ref := range fs.Args() {
+			root, err := analyzeRoot(ref)
+			if err != nil {
+				return fail("%v", err)
+			}
+			opts.Roots = append(opts.Roots, root)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if term.IsTerminal(os.Stderr.Fd()) {
+		fmt.Fprint(os.Stderr, "🔍 Searching... (Ctrl+C to stop)")
+	}
+	report, err := search.Find(ctx, opts)
+	if term.IsTerminal(os.Stderr.Fd()) {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	if err != nil {
+		return fail("%v", err)
+	}
+	report = report.Top(*top)
+
+	if !acting {
+		var code int
+		if format.Structured() {
+			code = emit(format, output.KindFiles, report)
+		} else {
+			code = render(format, func(r ui.Renderer) error {
+				return r.Files(report)
+			})
+		}
+		if code == exitOK && report.Partial {
+			return exitWarning
+		}
+		return code
+	}
+	if report.Partial {
+		return fail("interrupted, nothing was moved")
+	}
+	if len(report.Files) == 0 {
+		fmt.Println("Nothing found")
+		return exitOK
+	}
+
+	// Pick from the numbered list, on the command line or by asking
+	choice := *pick
+	if choice == "" && *yes {
+		choice = "all"
+	}
+	if !*yes {
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			return fail("refusing to move files without a terminal to ask on, pass --yes")
+		}
+		if code := render(format, func(r ui.Renderer) error { return r.Files(report) }); code != exitOK {
+			return code
+		}
+	}
+	if choice == "" {
+		fmt.Fprint(os.Stderr, infoStyle.Render("Which files? (e.g. 1-3,7 or all, nothing to cancel) "))
+		answer, _ := stdin.ReadString('\n')
+		if choice = strings.TrimSpace(answer); choice == "" {
+			return exitOK
+		}
+	}
+	picked, err := parsePicks(choice, len(report.Files))
+	if err != nil {
+		return fail("%v", err)
+	}
+	var size uint64
+	for _, i := range picked {
+		size += report.Files[i].Size
+	}
+	where := "the trash"
+	if *moveTo != "" {
+		where = *moveTo
+	}
+	if !*yes && !ask(fmt.Sprintf("Move %d files (%s) to %s?", len(picked), ui.FormatBytes(size), where)) {
+		return exitOK
+	}
+
+	code := exitOK
+	for _, i := range picked {
+		f := report.Files[i]
+		if *toTrash {
+			err = disk.MoveToTrash(f.Path)
+		} else {
+			var dest string
+			if dest, err = search.Move(f.Path, *moveTo); err == nil {
+				fmt.Println(successStyle.Render("✅ Moved " + f.Path + " to " + dest))
+				continue
+			}
+		}
+		if err != nil {
+			code = fail("%v", err)
+			continue
+		}
+		fmt.Println(successStyle.Render("✅ Moved " + f.Path + " to the trash"))
+	}
+	return code
+}
+
+// parsePicks parses a selection such as "1-3,7" or "all" from a list of n
+// numbered items into indexes
+func parsePicks(s string, n int) ([]int, error) {
+	if strings.EqualFold(strings.TrimSpace(s), "all") {
+		picks := make([]int, n)
+		for i := range picks {
+			picks[i] = i
+		}
+		return picks, nil
+	}
+	seen := map[int]bool{}
+	var picks []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(from))
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(strings.TrimSpace(to))
+		}
+		if err != nil || first < 1 || last > n || first > last {
+			return nil, fmt.Errorf("invalid pick %q, expected numbers from 1 to %d such as 1-3,7", part, n)
+		}
+		for i := first; i <= last; i++ {
+			if !seen[i] {
+				seen[i] = true
+				picks = append(picks, i-1)
+			}
+		}
+	}
+	if len(picks) == 0 {
+		return nil, fmt.Errorf("nothing picked")
+	}
+	return picks, nil
+}
+
+func cmdTypes(args []string) int {
+	fs := newFlagSet("types")
+	noSniff := fs.Bool("no-sniff", false, "classify by extension only, without reading the first bytes of other files")
+	crossFS := fs.Bool("cross-fs", false, "descend into other filesystems mounted below the paths")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	opts := filetypes.Options{Sniff: !*noSniff, CrossFilesystems: *crossFS}
+
+	// Drives are measured whole, paths only below themselves
+	var groups []disk.DriveGroup
+	var paths, drives []string
+	for _, ref := range fs.Args() {
+		if _, err := os.Stat(ref); err == nil {
+			paths = append(paths, ref)
+		} else {
+			drives = append(drives, ref)
+		}
+	}
+	if fs.NArg() == 0 || len(drives) > 0 {
+		dm, err := scanManager()
+		if err != nil {
+			return fail("Error scanning disks: %v", err)
+		}
+		all := disk.GroupDisks(dm.GetDisks())
+		if fs.NArg() == 0 {
+			groups = all
+		}
+		for _, ref := range drives {
+			group, err := disk.FindGroup(all, ref)
+			if err != nil {
+				return fail("%s is neither a path nor a drive: %v", ref, err)
+			}
+			groups = append(groups, *group)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	measured, err := measureFileTypes(ctx, groups, opts)
+	if err != nil {
+		return fail("%v", err)
+	}
+	for _, path := range paths {
+		d, err := measureTypes(ctx, path, []string{path}, opts)
+		if err != nil {
+			return fail("%v", err)
+		}
+		d.Name = path
+		measured = append(measured, d)
+	}
+
+	code := exitOK
+	if format.Structured() {
+		code = emit(format, output.KindFileTypes, measured)
+	} else {
+		code = render(format, func(r ui.Renderer) error {
+			return r.FileTypes(measured)
+		})
+	}
+	if code == exitOK && ctx.Err() != nil {
+		return exitWarning
+	}
+	return code
+}
+
+// measureFileTypes totals the space by file type on the mount points of
+// each group, in the order of the groups. Once ctx is cancelled the
+// remaining drives are left empty and marked partial.
+func measureFileTypes(ctx context.Context, groups []disk.DriveGroup, opts filetypes.Options) ([]filetypes.Drive, error) {
+	drives := []filetypes.Drive{}
+	for _, g := range groups {
+		var roots []string
+		for _, d := range g.Disks {
+			roots = append(roots, d.MountPoint)
+		}
+		d, err := measureTypes(ctx, g.Name, roots, opts)
+		if err != nil {
+			return nil, err
+		}
+		d.Name, d.SizeBytes, d.UsedBytes = g.Name, g.TotalSize, g.TotalUsed
+		drives = append(drives, d)
+	}
+	return drives, nil
+}
+
+// measureTypes measures one drive or path, saying so on a terminal
+func measureTypes(ctx context.Context, name string, roots []string, opts filetypes.Options) (filetypes.Drive, error) {
+	progress := term.IsTerminal(os.Stderr.Fd())
+	if progress {
+		fmt.Fprintf(os.Stderr, "🔍 Measuring %s... (Ctrl+C to stop)", name)
+	}
+	d, err := filetypes.Measure(ctx, fileTypeClassifier(), roots, opts)
+	if progress {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	return d, err
+}
+
+// fileTypeClassifier loads the file categories once per run. A broken
+// config file is reported and the built-in categories are used instead.
+var fileTypeClassifier = sync.OnceValue(func() *filetypes.Classifier {
+	categories, err := filetypes.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, infoStyle.Render("⚠️  "+err.Error()))
+	}
+	c, _ := filetypes.NewClassifier(categories)
+	return c
+})
+
+func cmdSnapshot(args []string) int {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	fs := newFlagSet("snapshot")
+	var out, minSize *string
+	var crossFS *bool
+	switch action {
+	case "list":
+		out = outputFlag(fs)
+	case "save":
+		minSize = fs.String("min-size", "1M", "list directories and files of at least this size one by one, e.g. 100K or 1G")
+		crossFS = fs.Bool("cross-fs", false, "descend into other filesystems mounted below the path")
+	case "delete":
+	default:
+		return fail("Unknown snapshot action %q, expected save, list or delete", action)
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	store, err := snapshot.Open()
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	switch action {
+	case "save":
+		if fs.NArg() > 1 {
+			fs.Usage()
+			return exitError
+		}
+		min, err := parseSize(*minSize)
+		if err != nil {
+			return fail("%v", err)
+		}
+		ref := "."
+		if fs.NArg() == 1 {
+			ref = fs.Arg(0)
+		}
+		root, err := snapshotRoot(ref)
+		if err != nil {
+			return fail("%v", err)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		snap, err := takeSnapshot(ctx, root, min, *crossFS)
+		if err != nil {
+			return fail("%v", err)
+		}
+		id, err := store.Save(snap)
+		if err != nil {
+			return fail("%v", err)
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("📸 Saved snapshot %s of %s (%s, %d entries)",
+			id, snap.Root, ui.FormatBytes(snap.Size), len(snap.Entries))))
+		if snap.Partial {
+			fmt.Println(infoStyle.Render("⚠️  The scan was stopped early, so the snapshot is incomplete"))
+			return exitWarning
+		}
+		return exitOK
+	case "delete":
+		if fs.NArg() == 0 {
+			return fail("Name the snapshots to delete, as listed by 'checkpoint snapshot list'")
+		}
+		code := exitOK
+		for _, id := range fs.Args() {
+			if err := store.Delete(id); err != nil {
+				code = fail("%v", err)
+			} else {
+				fmt.Println(successStyle.Render("✅ Deleted " + id))
+			}
+		}
+		return code
+	}
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return exitError
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	root := ""
+	if fs.NArg() == 1 {
+		if root, err = snapshotRoot(fs.Arg(0)); err != nil {
+			return fail("%v", err)
+		}
+	}
+	snaps, err := store.List(root)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if format.Structured() {
+		return emit(format, output.KindSnapshots, snaps)
+	}
+	return render(format, func(r ui.Renderer) error {
+		return r.Snapshots(snaps)
+	})
+}
+
+func cmdDiff(args []string) int {
+	fs := newFlagSet("diff")
+	top := fs.Int("top", 30, "number of largest changes to list (0 lists all)")
+	all := fs.Bool("all", false, "also list directories that only changed by what changed below them")
+	since := fs.String("since", "", "compare with the snapshot of the path taken this long ago, e.g. 1w or 30d")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() == 0 || fs.NArg() > 2 || (*since != "" && fs.NArg() > 1) {
+		fs.Usage()
+		return exitError
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	store, err := snapshot.Open()
+	if err != nil {
+		return fail("%v", err)
+	}
+
+	var from snapshot.Snapshot
+	if *since != "" {
+		age, err := search.ParseAge(*since)
+		if err != nil {
+			return fail("%v", err)
+		}
+		root, err := snapshotRoot(fs.Arg(0))
+		if err != nil {
+			return fail("%v", err)
+		}
+		snaps, err := store.List(root)
+		if err != nil {
+			return fail("%v", err)
+		}
+		base, ok := snapshot.Baseline(snaps, time.Now().Add(-age))
+		if !ok {
+			return fail("No snapshot of %s yet, save one with 'checkpoint snapshot save %s'", root, fs.Arg(0))
+		}
+		if from, err = store.Load(base.ID); err != nil {
+			return fail("%v", err)
+		}
+	} else if from, err = loadSnapshot(store, fs.Arg(0)); err != nil {
+		return fail("%v", err)
+	}
+
+	// Without a second snapshot the path is scanned now, and not saved
+	var to snapshot.Snapshot
+	if fs.NArg() == 2 && fs.Arg(1) != "now" {
+		if to, err = loadSnapshot(store, fs.Arg(1)); err != nil {
+			return fail("%v", err)
+		}
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if to, err = takeSnapshot(ctx, from.Root, from.MinSize, false); err != nil {
+			return fail("%v", err)
+		}
+	}
+	if to.Taken.Before(from.Taken) {
+		from, to = to, from
+	}
+
+	diff, err := snapshot.Compare(from, to)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if !*all {
+		diff = diff.Collapse()
+	}
+	diff = diff.Top(*top)
+
+	code := exitOK
+	if format.Structured() {
+		code = emit(format, output.KindDiff, diff)
+	} else {
+		code = render(format, func(r ui.Renderer) error {
+			return r.Diff(diff)
+		})
+	}
+	if code == exitOK && (from.Partial || to.Partial) {
+		return exitWarning
+	}
+	return code
+}
+
+// snapshotRoot turns a path or drive into the absolute path snapshots of
+// it are saved under
+func snapshotRoot(ref string) (string, error) {
+	root, err := analyzeRoot(ref)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(root)
+}
+
+// loadSnapshot accepts a snapshot ID or file, or a directory or drive for
+// the latest snapshot of it
+func loadSnapshot(store *snapshot.Store, ref string) (snapshot.Snapshot, error) {
+	var loadErr error
+	if info, err := os.Stat(ref); err != nil || !info.IsDir() {
+		snap, err := store.Load(ref)
+		if err == nil || info != nil {
+			return snap, err
+		}
+		loadErr = err
+	}
+	root, err := snapshotRoot(ref)
+	if err != nil {
+		return snapshot.Snapshot{}, loadErr
+	}
+	snaps, err := store.List(root)
+	if err != nil {
+		return snapshot.Snapshot{}, err
+	}
+	if len(snaps) == 0 {
+		return snapshot.Snapshot{}, fmt.Errorf("no snapshot of %s yet, save one with 'checkpoint snapshot save %s'", root, ref)
+	}
+	return store.Load(snaps[0].ID)
+}
+
+// takeSnapshot scans root, showing progress on a terminal. Cancelling ctx
+// stops the scan and returns a partial snapshot.
+func takeSnapshot(ctx context.Context, root string, minSize uint64, crossFS bool) (snapshot.Snapshot, error) {
+	scanner := analyzer.NewScanner(root, analyzer.Options{CrossFilesystems: crossFS})
+	done := make(chan struct{})
+	if term.IsTerminal(os.Stderr.Fd()) {
+		go showScanProgress(scanner, done)
+	}
+	result, err := scanner.Run(ctx)
+	close(done)
+	if err != nil {
+		return snapshot.Snapshot{}, err
+	}
+	return snapshot.FromResult(result, minSize, time.Now()), nil
+}
+
+func cmdDupes(args []string) int {
+	fs := newFlagSet("dupes")
+	minSize := fs.String("min-size", "1M", "skip files smaller than this, e.g. 100K or 1G")
+	crossFS := fs.Bool("cross-fs", false, "descend into other filesystems mounted below the paths")
+	workers := fs.Int("workers", dupes.DefaultWorkers, "files hashed at once")
+	noCache := fs.Bool("no-cache", false, "hash every file again instead of using the hashes of earlier runs")
+	top := fs.Int("top", 20, "number of sets of duplicates to show (0 shows all)")
+	actionName := fs.String("action", "", "replace duplicates with a hardlink or reflink to one copy, or move them to the trash")
+	dryRun := fs.Bool("dry-run", false, "only show what the action would do")
+	yes := fs.Bool("yes", false, "do not ask before acting")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	minBytes, err := parseSize(*minSize)
+	if err != nil {
+		return fail("%v", err)
+	}
+	var action dupes.Action
+	if *actionName != "" {
+		if action, err = dupes.ParseAction(*actionName); err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	roots := []string{"."}
+	if fs.NArg() > 0 {
+		roots = nil
+		for _, ref := range fs.Args() {
+			root, err := analyzeRoot(ref)
+			if err != nil {
+				return fail("%v", err)
+			}
+			roots = append(roots, root)
+		}
+	}
+
+	opts := dupes.Options{Roots: roots, MinSize: minBytes, CrossFilesystems: *crossFS, Workers: *workers}
+	if !*noCache {
+		if path, err := dupes.DefaultCachePath(); err == nil {
+			opts.Cache = dupes.OpenCache(path)
+		}
+	}
+	// Ctrl+C stops the search and still shows the duplicates confirmed so
+	// far; the hashes computed are kept for the next run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	finder := dupes.NewFinder(opts)
+	done := make(chan struct{})
+	if term.IsTerminal(os.Stderr.Fd()) {
+		go showDupesProgress(finder, done)
+	}
+	report, err := finder.Run(ctx)
+	close(done)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if err := opts.Cache.Save(); err != nil {
+		fmt.Fprintln(os.Stderr, infoStyle.Render("⚠️  "+err.Error()))
+	}
+
+	if action == "" || report.GroupCount == 0 {
+		var code int
+		if format.Structured() {
+			code = emit(format, output.KindDupes, report.Top(*top))
+		} else {
+			code = render(format, func(r ui.Renderer) error {
+				return r.Duplicates(report.Top(*top))
+			})
+		}
+		if code == exitOK && report.Partial {
+			return exitWarning
+		}
+		return code
+	}
+	if report.Partial {
+		return fail("interrupted, nothing was changed")
+	}
+
+	showResults := func(results []dupes.Result) int {
+		if format.Structured() {
+			return emit(format, output.KindDedupe, results)
+		}
+		return render(format, func(r ui.Renderer) error {
+			return r.Dedupe(results)
+		})
+	}
+	plan := dupes.Apply(ctx, report.Groups, action, dupes.ApplyOptions{DryRun: true})
+	if *dryRun {
+		return showResults(plan)
+	}
+	if !*yes {
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			return fail("refusing to change files without a terminal to ask on, pass --yes or --dry-run")
+		}
+		if code := showResults(plan); code != exitOK {
+			return code
+		}
+		if !ask("Go ahead?") {
+			return exitOK
+		}
+	}
+	results := dupes.Apply(ctx, report.Groups, action, dupes.ApplyOptions{})
+	code := showResults(results)
+	for _, r := range results {
+		if r.Error != "" && code == exitOK {
+			code = exitError
+		}
+	}
+	return code
+}
+
+// parseSize parses a size such as "512K", "1.5G" or "2GiB", in powers of 1024
+func parseSize(s string) (uint64, error) {
+	amount, err := disk.ParseAmount(s, disk.AmountBytes)
+	if err != nil || amount < 0 {
+		return 0, fmt.Errorf("invalid size %q, expected e.g. 100K, 10M or 1G", s)
+	}
+	return uint64(amount), nil
+}
+
+// showDupesProgress keeps a one-line counter on stderr until done is closed
+func showDupesProgress(finder *dupes.Finder, done <-chan struct{}) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			return
+		case <-ticker.C:
+			p := finder.Progress()
+			line := fmt.Sprintf("🔍 Scanning... %d files", p.Files)
+			if p.Stage != "scanning" {
+				line = fmt.Sprintf("🔍 %s %d of %d files, %s read", strings.ToUpper(p.Stage[:1])+p.Stage[1:], p.Hashed, p.ToHash, ui.FormatBytes(p.Bytes))
+			}
+			fmt.Fprintf(os.Stderr, "\r\033[K%s (Ctrl+C to stop)", line)
+		}
+	}
+}
+
+func cmdDoctor(args []string) int {
+	fs := newFlagSet("doctor")
+	only := fs.String("only", "", "run only these checks, comma separated, see --list")
+	list := fs.Bool("list", false, "list the checks and exit")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitError
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	checks := doctor.Defaults()
+	if *list {
+		for _, c := range checks {
+			fmt.Printf("%-16s %s\n", c.Name, c.Title)
+		}
+		return exitOK
+	}
+	if *only != "" {
+		if checks, err = doctor.Select(checks, disk.ParseList(*only)); err != nil {
+			return fail("%v", err)
+		}
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return fail("Error scanning disks: %v", err)
+	}
+	report := doctor.Run(doctor.NewSystem(dm.GetDisks(), thresholds()), checks)
+
+	code := exitOK
+	if format.Structured() {
+		code = emit(format, output.KindDoctor, report)
+	} else {
+		code = render(format, func(r ui.Renderer) error {
+			return r.Doctor(report)
+		})
+	}
+	if code == exitOK && report.Problems > 0 {
+		return exitWarning
+	}
+	return code
+}
+
+func cmdCheck(args []string) int {
+	fs := newFlagSet("check")
+	file := fs.String("thresholds", "", "read thresholds from this file instead of thresholds.yaml in the config directory")
+	if code, ok := parseFlags(fs, args); !ok {
+		if code == exitOK {
+			return code
+		}
+		return int(disk.LevelUnknown)
+	}
+	// Monitoring systems read stdout and the exit code, so every failure
+	// is an UNKNOWN result rather than an error message
+	unknown := func(format string, args ...interface{}) int {
+		fmt.Printf("DISK UNKNOWN - "+format+"\n", args...)
+		return int(disk.LevelUnknown)
+	}
+
+	var cfg *disk.ThresholdConfig
+	var err error
+	if *file != "" {
+		if _, err := os.Stat(*file); err != nil {
+			return unknown("%v", err)
+		}
+		cfg, err = disk.LoadThresholdsFile(*file)
+	} else {
+		cfg, err = disk.LoadThresholds()
+	}
+	if err != nil {
+		return unknown("%v", err)
+	}
+
+	dm, err := scanManager()
+	if err != nil {
+		return unknown("failed to scan disks: %v", err)
+	}
+	disks := []disk.Disk{}
+	if fs.NArg() > 0 {
+		for _, ref := range fs.Args() {
+			d, err := findDisk(dm, ref)
+			if err != nil {
+				return unknown("%v", err)
+			}
+			disks = append(disks, *d)
+		}
+	} else {
+		disks = dm.GetDisks()
+	}
+
+	// One result per filesystem, worst first
+	type result struct {
+		disk  disk.Disk
+		alert disk.Alert
+	}
+	forecasts := diskForecasts(disks)
+	remounted := remountedReadOnly(dm.GetDisks())
+	results := []result{}
+	seen := map[string]bool{}
+	perf := []string{}
+	for _, d := range disks {
+		if !disk.Monitored(d) || seen[d.MountPoint] {
+			continue
+		}
+		seen[d.MountPoint] = true
+		t := cfg.For(d)
+		alert := t.Check(d, forecasts[d.MountPoint])
+		if remounted[d.Device] {
+			alert.Add(disk.Alert{Level: disk.LevelCritical, Reasons: []string{d.MountPoint + " remounted read-only"}})
+		}
+		results = append(results, result{d, alert})
+		perf = append(perf, perfData(d, t, forecasts[d.MountPoint])...)
+	}
+	if len(results) == 0 {
+		return unknown("no filesystems to check")
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].alert.Level > results[j].alert.Level
+	})
+
+	overall := disk.Alert{}
+	for _, r := range results {
+		overall.Add(r.alert)
+	}
+	summary := strings.Join(overall.Reasons, ", ")
+	if overall.Level == disk.LevelOK {
+		summary = fmt.Sprintf("%d filesystems within thresholds", len(results))
+		if len(results) == 1 {
+			summary = fmt.Sprintf("%s within thresholds", results[0].disk.MountPoint)
+		}
+	}
+	fmt.Printf("DISK %s - %s | %s\n", strings.ToUpper(overall.Level.String()), summary, strings.Join(perf, " "))
+	return int(overall.Level)
+}
+
+// remountedReadOnly lists the devices the doctor finds read-only although
+// they should be writable, which the kernel does after filesystem errors.
+// Every mount point of such a device is read-only.
+func remountedReadOnly(disks []disk.Disk) map[string]bool {
+	checks, err := doctor.Select(doctor.Defaults(), []string{"read-only"})
+	if err != nil {
+		return nil
+	}
+	report := doctor.Run(doctor.NewSystem(disks, nil), checks)
+	devices := map[string]bool{}
+	for _, res := range report.Results {
+		for _, f := range res.Findings {
+			for _, d := range disks {
+				if d.MountPoint == f.Subject {
+					devices[d.Device] = true
+				}
+			}
+		}
+	}
+	return devices
+}
+
+// perfData formats the performance data of a filesystem: used bytes with
+// the used percent and free bytes thresholds turned into bytes, free inodes
+// and the days until full of a growing forecast
+func perfData(d disk.Disk, t disk.Thresholds, f *disk.Forecast) []string {
+	label := strings.ReplaceAll(d.MountPoint, "'", "''")
+	usedAt := func(percent, free disk.Amount) string {
+		limit := -1.0
+		if percent > 0 {
+			limit = float64(percent) / 100 * float64(d.Size)
+		}
+		if free > 0 {
+			at := float64(d.Used+d.Available) - float64(free)
+			if at < 0 {
+				at = 0
+			}
+			if limit < 0 || at < limit {
+				limit = at
+			}
+		}
+		if limit < 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.0f", limit)
+	}
+	// Floors use the "alert below" range syntax
+	below := func(limit disk.Amount) string {
+		if limit <= 0 {
+			return ""
+		}
+		return strconv.FormatFloat(float64(limit), 'f', -1, 64) + ":"
+	}
+
+	perf := []string{fmt.Sprintf("'%s'=%dB;%s;%s;0;%d", label, d.Used,
+		usedAt(t.UsedPercent.Warning, t.FreeBytes.Warning),
+		usedAt(t.UsedPercent.Critical, t.FreeBytes.Critical), d.Size)}
+	if d.Inodes > 0 {
+		perf = append(perf, fmt.Sprintf("'%s inodes_free'=%d;%s;%s;0;%d", label, d.InodesFree,
+			below(t.FreeInodes.Warning), below(t.FreeInodes.Critical), d.Inodes))
+	}
+	if f != nil && f.Trend == disk.TrendGrowing && f.Confidence != disk.ConfidenceLow {
+		perf = append(perf, fmt.Sprintf("'%s days_to_full'=%.1f;%s;%s", label, f.DaysToFull,
+			below(t.DaysToFull.Warning), below(t.DaysToFull.Critical)))
+	}
+	return perf
+}
+
+// findDisk accepts a path, or a drive letter or name from the friendly view
+func findDisk(dm *disk.Manager, ref string) (*disk.Disk, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return dm.DiskForPath(ref)
+	}
+	group, err := disk.FindGroup(disk.GroupDisks(dm.GetDisks()), ref)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a path nor a drive: %v", ref, err)
+	}
+	if len(group.Disks) == 0 {
+		return nil, fmt.Errorf("drive %s has no mounted locations", group.Name)
+	}
+	return &group.Disks[0], nil
+}
+
+// analyzeRoot accepts a path, or a drive letter or name from the friendly view
+func analyzeRoot(ref string) (string, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return ref, nil
+	}
+	dm, err := scanManager()
+	if err != nil {
+		return "", fmt.Errorf("failed to scan disks: %v", err)
+	}
+	group, err := disk.FindGroup(disk.GroupDisks(dm.GetDisks()), ref)
+	if err != nil {
+		return "", fmt.Errorf("%s is neither a path nor a drive: %v", ref, err)
+	}
+	if len(group.Disks) == 0 {
+		return "", fmt.Errorf("drive %s has no mounted locations", group.Name)
+	}
+	return group.Disks[0].MountPoint, nil
+}
+
+// showScanProgress keeps a one-line counter on stderr until done is closed
+func showScanProgress(scanner *analyzer.Scanner, done <-chan struct{}) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			return
+		case <-ticker.C:
+			p := scanner.Progress()
+			fmt.Fprintf(os.Stderr, "\r\033[K🔍 Scanning... %d files, %d folders, %s (Ctrl+C to stop)",
+				p.Files, p.Dirs, ui.FormatBytes(p.Bytes))
+		}
+	}
+}
+
+func cmdServe(args []string) int {
+	fs := newFlagSet("serve")
+	addr := fs.String("metrics", "", "serve Prometheus metrics on this address, e.g. :9108 or 127.0.0.1:9108")
+	interval := fs.Duration("interval", metrics.DefaultInterval, "time between scans; scrapes get the last scan")
+	timeout := fs.Duration("timeout", metrics.DefaultTimeout, "count a scan that takes longer as failed, e.g. one stuck on a hanging mount")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *addr == "" {
+		fs.Usage()
+		return exitError
+	}
+	if *interval <= 0 || *timeout <= 0 {
+		return fail("Interval and timeout must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	exporter := metrics.NewExporter(metrics.Options{
+		Interval: *interval,
+		Timeout:  *timeout,
+		Scan: func() ([]disk.Disk, error) {
+			dm, err := scanManager()
+			if err != nil {
+				return nil, err
+			}
+			return dm.GetDisks(), nil
+		},
+	})
+	go exporter.Run(ctx)
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fail("%v", err)
+	}
+	server := &http.Server{Handler: exporter.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	errs := make(chan error, 1)
+	go func() { errs <- server.Serve(listener) }()
+	fmt.Fprintln(os.Stderr, infoStyle.Render(fmt.Sprintf("📡 Serving metrics on http://%s/metrics (Ctrl+C to stop)", listener.Addr())))
+
+	select {
+	case err := <-errs:
+		return fail("%v", err)
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil {
+		return fail("%v", err)
+	}
+	return exitOK
+}
+
+func cmdDaemon(args []string) int {
+	fs := newFlagSet("daemon")
+	socket := fs.String("socket", daemon.SocketPath(), "listen on this Unix socket")
+	interval := fs.Duration("interval", daemon.DefaultInterval, "time between rescans; mounting and unmounting also rescans")
+	notifications := fs.Bool("notify", false, "send desktop notifications about low space, new drives, read-only remounts, failing drives and degraded RAID")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *interval <= 0 {
+		return fail("Interval must be positive")
+	}
+
+	listener, err := daemon.Listen(*socket)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer os.Remove(*socket)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logf := func(format string, args ...interface{}) {
+		fmt.Fprintln(os.Stderr, infoStyle.Render("⚠️  "+fmt.Sprintf(format, args...)))
+	}
+	opts := daemon.Options{
+		Interval: *interval,
+		Scan: func(dm *disk.Manager) error {
+			if err := dm.ScanDisks(); err != nil {
+				return err
+			}
+			if err := recordHistory(dm); err != nil {
+				logf("%v", err)
+			}
+			return nil
+		},
+		Groups: func(disks []disk.Disk) []disk.DriveGroup {
+			groups, err := checkedGroups(disks)
+			if err != nil {
+				logf("%v", err)
+			}
+			return groups
+		},
+		OnChanges: func(changes []disk.MountChange) {
+			printMountChanges(os.Stderr, changes)
+		},
+		Logf: logf,
+	}
+	if *notifications {
+		opts.OnScan = notifier(ctx, logf)
+	}
+
+	server := daemon.NewServer(opts)
+	fmt.Fprintln(os.Stderr, infoStyle.Render(fmt.Sprintf("📡 Listening on %s (Ctrl+C to stop)", *socket)))
+	if err := server.Serve(ctx, listener); err != nil {
+		return fail("%v", err)
+	}
+	return exitOK
+}
+
+// checkedGroups groups disks with their forecasts and threshold alerts. The
+// thresholds are read on every call so edits apply without a restart; a
+// broken file is returned as the error along with groups checked against
+// the defaults.
+func checkedGroups(disks []disk.Disk) ([]disk.DriveGroup, error) {
+	groups := disk.GroupDisks(disks)
+	var samples map[string][]history.Sample
+	if store, err := history.Open(); err == nil {
+		samples = store.ForGroups(groups)
+		history.AddForecasts(groups, samples)
+	}
+	config, err := disk.LoadThresholds()
+	config.Apply(groups, history.Forecasts(samples))
+	return groups, err
+}
+
+// notifier sends desktop notifications about each scan of the daemon and
+// handles their buttons until ctx is done
+func notifier(ctx context.Context, logf func(format string, args ...interface{})) func(disks []disk.Disk) {
+	config, err := notify.LoadConfig()
+	if err != nil {
+		logf("%v", err)
+	}
+	n := notify.New(notify.Options{
+		Bus:      notify.GDBus{},
+		Config:   config,
+		OnAction: notificationAction(logf),
+		Logf:     logf,
+	})
+	go func() {
+		if err := n.Run(ctx); err != nil {
+			logf("notification buttons will not work: %v", err)
+		}
+	}()
+	monitor := notify.NewMonitor(notify.MonitorOptions{})
+	return func(disks []disk.Disk) {
+		// Threshold problems are already reported by the groups method
+		groups, _ := checkedGroups(disks)
+		n.Send(monitor.Observe(groups)...)
+	}
+}
+
+// printMountChanges lists what changed since the previous scan, a remount
+// read-only loudest
+func printMountChanges(w io.Writer, changes []disk.MountChange) {
+	for _, c := range changes {
+		if c.Kind == disk.ChangeReadOnly {
+			fmt.Fprintln(w, errorStyle.Render("⚠️  "+ui.DescribeMountChange(c)))
+		} else {
+			fmt.Fprintln(w, infoStyle.Render("🔄 "+ui.DescribeMountChange(c)))
+		}
+	}
+}
+
+// notificationAction opens checkpoint in a terminal for a notification
+// button: the drive view, or the folders filling the drive for "Clean up"
+func notificationAction(logf func(format string, args ...interface{})) func(action string, n notify.Notification) {
+	return func(action string, n notify.Notification) {
+		self, err := os.Executable()
+		if err != nil {
+			logf("failed to open checkpoint: %v", err)
+			return
+		}
+		command := []string{self}
+		if action == notify.ActionCleanup && n.MountPoint != "" {
+			command = append(command, "analyze", n.MountPoint)
+		}
+		if err := openTerminal(command); err != nil {
+			logf("failed to open checkpoint: %v", err)
+		}
+	}
+}
+
+// openTerminal runs command in a new terminal window: $TERMINAL, or the
+// first common terminal emulator that is installed
+func openTerminal(command []string) error {
+	terminals := []string{"x-terminal-emulator", "gnome-terminal", "konsole", "xfce4-terminal", "kitty", "alacritty", "xterm"}
+	if t := os.Getenv("TERMINAL"); t != "" {
+		terminals = append([]string{t}, terminals...)
+	}
+	for _, t := range terminals {
+		path, err := exec.LookPath(t)
+		if err != nil {
+			continue
+		}
+		// gnome-terminal takes the command after --, the others after -e
+		args := append([]string{"-e"}, command...)
+		if filepath.Base(path) == "gnome-terminal" {
+			args = append([]string{"--"}, command...)
+		}
+		cmd := exec.Command(path, args...)
+		if err := cmd.Start(); err != nil {
+			return fmt.Errorf("failed to start %s: %v", t, err)
+		}
+		go cmd.Wait()
+		return nil
+	}
+	return fmt.Errorf("no terminal emulator found, set $TERMINAL")
+}
+
+func cmdService(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		fs := newFlagSet("service")
+		code, ok := parseFlags(fs, args)
+		if ok {
+			fs.Usage()
+			code = exitError
+		}
+		return code
+	}
+
+	fs := newFlagSet("service")
+	var historySchedule, checkSchedule *string
+	var withDaemon *bool
+	switch args[0] {
+	case "install":
+		historySchedule = fs.String("history", service.DefaultHistorySchedule, "when to record usage history, as a systemd calendar expression")
+		checkSchedule = fs.String("check", service.DefaultCheckSchedule, "when to check thresholds, as a systemd calendar expression")
+		withDaemon = fs.Bool("daemon", false, "also run the daemon with desktop notifications")
+	case "uninstall":
+	case "status":
+	default:
+		return fail("Unknown service action %q, expected install, uninstall or status", args[0])
+	}
+	if code, ok := parseFlags(fs, args[1:]); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return fail("Unexpected argument: %s", fs.Arg(0))
+	}
+
+	dir, err := service.UnitDir()
+	if err != nil {
+		return fail("%v", err)
+	}
+	runner := service.ExecRunner{}
+	units := service.New(dir, runner)
+	failService := func(err error) int {
+		code := fail("%v", err)
+		if strings.Contains(err.Error(), "Failed to connect to bus") {
+			fmt.Fprintln(os.Stderr, "No systemd user session is reachable. Log in normally rather than with su or")
+			fmt.Fprintln(os.Stderr, "sudo, or set XDG_RUNTIME_DIR=/run/user/$(id -u).")
+		}
+		return code
+	}
+
+	switch args[0] {
+	case "install":
+		exe, err := os.Executable()
+		if err == nil {
+			exe, err = filepath.EvalSymlinks(exe)
+		}
+		if err != nil {
+			return fail("Failed to find the checkpoint executable: %v", err)
+		}
+		changes, err := units.Install(service.Options{
+			Executable:      exe,
+			HistorySchedule: *historySchedule,
+			CheckSchedule:   *checkSchedule,
+			Daemon:          *withDaemon,
+		})
+		printChanges(dir, changes)
+		if err != nil {
+			return failService(err)
+		}
+		fmt.Println(successStyle.Render("✅ Timers enabled, see 'checkpoint service status'"))
+		warnLinger(runner)
+	case "uninstall":
+		changes, err := units.Uninstall()
+		printChanges(dir, changes)
+		if err != nil {
+			return failService(err)
+		}
+		if len(changes) == 0 {
+			fmt.Println("Nothing installed")
+		}
+	case "status":
+		statuses, err := units.Status()
+		if err != nil {
+			return failService(err)
+		}
+		if len(statuses) == 0 {
+			fmt.Println("Not installed, run 'checkpoint service install'")
+			return exitOK
+		}
+		for _, s := range statuses {
+			fmt.Printf("%-28s %-9s %s", s.Unit, s.Enabled, s.Active)
+			if s.Result != "" && s.Result != "success" {
+				fmt.Printf(", last result %s", s.Result)
+			}
+			fmt.Println()
+			if s.LastRun != "" {
+				fmt.Printf("%-28s last run %s\n", "", s.LastRun)
+			}
+			if s.NextRun != "" {
+				fmt.Printf("%-28s next run %s\n", "", s.NextRun)
+			}
+		}
+		warnLinger(runner)
+	}
+	return exitOK
+}
+
+// printChanges lists what happened to each unit file
+func printChanges(dir string, changes []service.Change) {
+	for _, c := range changes {
+		fmt.Printf("%-10s %s\n", c.Action, filepath.Join(dir, c.Unit))
+	}
+}
+
+// warnLinger explains what it means that the user's units stop at logout
+func warnLinger(runner service.Runner) {
+	lingering, err := service.Lingering(runner)
+	if err != nil || lingering {
+		return
+	}
+	fmt.Println(infoStyle.Render("\n⚠️  Lingering is disabled for your user: systemd stops your user units when\n" +
+		"   you log out, so the timers only run while you are logged in. To keep them\n" +
+		"   running, e.g. on a server, enable lingering:\n" +
+		"     loginctl enable-linger"))
+}
+
+func cmdWatch(args []string) int {
+	fs := newFlagSet("watch")
+	interval := fs.Duration("interval", 5*time.Second, "time between rescans")
+	count := fs.Int("count", 0, "stop after N refreshes (0 runs until interrupted)")
+	technical := fs.Bool("technical", false, "show the technical table instead of drive cards")
+	out := outputFlag(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if *interval <= 0 {
+		return fail("Interval must be positive")
+	}
+	format, err := output.ParseFormat(*out)
+	if err != nil {
+		return fail("%v", err)
+	}
+	if format != output.FormatText && format != output.FormatNDJSON {
+		return fail("watch supports text and ndjson output")
+	}
+
+	status := exitOK
+	for i := 0; *count == 0 || i < *count; i++ {
+		if i > 0 {
+			time.Sleep(*interval)
+		}
+
+		dm, err := scanManager()
+		if err != nil {
+			return fail("Error scanning disks: %v", err)
+		}
+
+		status = diskStatus(dm.GetDisks())
+		if format == output.FormatNDJSON {
+			// One record per refresh, flushed line by line for consumers
+			if *technical {
+				err = output.Write(os.Stdout, format, output.KindDisks, dm.GetDisks())
+			} else {
+				groups := disk.GroupDisks(dm.GetDisks())
+				friendlyOptions(groups)
+				err = output.Write(os.Stdout, format, output.KindGroups, groups)
+			}
+			if err != nil {
+				return fail("Error writing output: %v", err)
+			}
+			continue
+		}
+
+		fmt.Print("\033[H\033[2J")
+		if *technical {
+			ui.DisplayDisks(dm.GetDisks(), false)
+		} else {
+			groups := disk.GroupDisks(dm.GetDisks())
+			ui.NewTerminalRenderer(os.Stdout, friendlyOptions(groups)).FriendlyDisks(groups)
+		}
+		fmt.Println(infoStyle.Render(fmt.Sprintf("🔄 Updated %s, every %s (Ctrl+C to stop)",
+			time.Now().Format("15:04:05"), *interval)))
+	}
+	return status
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"checkpoint/pkg/disk"
+)
+
+func TestDiskStatus(t *testing.T) {
+	// no thresholds file and no history, so the defaults apply
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	filesystem := func(mountPoint string, used uint64) disk.Disk {
+		return disk.Disk{MountPoint: mountPoint, Type: disk.TypePhysical, Filesystem: "ext4", Options: "rw", Size: 100 << 30, Used: used << 30, Available: (100 - used) << 30}
+	}
+	tests := []struct {
+		name  string
+		disks []disk.Disk
+		want  int
+	}{
+		// e.g. list --type network without network mounts
+		{"nothing matched", nil, exitOK},
+		{"healthy", []disk.Disk{filesystem("/", 40), filesystem("/home", 60)}, exitOK},
+		{"one past warning", []disk.Disk{filesystem("/", 40), filesystem("/home", 92)}, exitWarning},
+		{"past critical", []disk.Disk{filesystem("/", 97)}, exitWarning},
+		{"full disc image", []disk.Disk{{MountPoint: "/media/cdrom", Type: disk.TypePhysical, Filesystem: "iso9660", Options: "ro", Size: 4 << 30, Used: 4 << 30}}, exitOK},
+	}
+	for _, tt := range tests {
+		if got := diskStatus(tt.disks); got != tt.want {
+			t.Errorf("%s: diskStatus = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
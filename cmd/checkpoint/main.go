@@ -2,14 +2,19 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"checkpoint/pkg/client"
 	"checkpoint/pkg/disk"
 	"checkpoint/pkg/installer"
+	"checkpoint/pkg/tui"
 	"checkpoint/pkg/ui"
 )
 
@@ -27,6 +32,39 @@ var (
 )
 
 func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// runInteractive starts the full-screen interface, or the classic menu when
+// asked to or when stdin/stdout is not a terminal
+func runInteractive(args []string) int {
+	fs := flag.NewFlagSet("checkpoint", flag.ContinueOnError)
+	fs.Usage = func() { printUsage(fs.Output()) }
+	classic := fs.Bool("classic", false, "use the numbered menu instead of the full-screen interface")
+	refresh := fs.Duration("refresh", 5*time.Second, "live refresh interval of the full-screen interface (0 disables)")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		return fail("Unexpected argument: %s", fs.Arg(0))
+	}
+
+	if *classic || !term.IsTerminal(os.Stdin.Fd()) || !term.IsTerminal(os.Stdout.Fd()) {
+		return runMenu()
+	}
+	opts := tui.Options{Refresh: *refresh}
+	// Share the daemon's scans when one is running
+	if c := client.New(""); c.Available() {
+		opts.Client = c
+	}
+	if err := tui.Run(opts); err != nil {
+		return fail("Error running interface: %v", err)
+	}
+	return exitOK
+}
+
+// runMenu starts the interactive numbered menu used when no subcommand is given
+func runMenu() int {
 	dm := disk.NewManager()
 	scanner := bufio.NewScanner(os.Stdin)
 	showDetails := false
@@ -35,6 +73,8 @@ func main() {
 	// Initial scan
 	if err := dm.ScanDisks(); err != nil {
 		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Error scanning disks: %v", err)))
+	} else if err := recordHistory(dm); err != nil {
+		fmt.Println(infoStyle.Render("⚠️  " + err.Error()))
 	}
 
 	for {
@@ -45,7 +85,7 @@ func main() {
 		if friendlyView {
 			// Group disks for friendly view
 			groups := disk.GroupDisks(dm.GetDisks())
-			ui.DisplayFriendlyDisks(groups)
+			ui.NewTerminalRenderer(os.Stdout, friendlyOptions(groups)).FriendlyDisks(groups)
 		} else {
 			// Traditional view
 			stats := dm.GetStats()
@@ -82,7 +122,7 @@ func main() {
 			fmt.Println(infoStyle.Render(fmt.Sprintf("🖥️ Friendly view: %v", friendlyView)))
 		case "6":
 			fmt.Println(infoStyle.Render("👋 Exiting..."))
-			return
+			return exitOK
 		default:
 			fmt.Println(errorStyle.Render("❌ Invalid option"))
 		}
@@ -92,6 +132,8 @@ func main() {
 			scanner.Scan()
 		}
 	}
+
+	return exitOK
 }
 
 func displayEnhancedMenu(friendlyView bool) {
@@ -123,11 +165,10 @@ func handleAddDisk(dm *disk.Manager, scanner *bufio.Scanner) {
 	if len(unmounted) > 0 {
 		fmt.Println(infoStyle.Render("\n💿 Unmounted disks detected:"))
 		for i, ud := range unmounted {
-			fmt.Printf("%s%d.%s %s (%s, %s)\n", 
-				successStyle.Render(fmt.Sprintf("%d", i+1)),
-				successStyle.Render("."),
+			fmt.Printf("%s %s (%s, %s)\n",
+				successStyle.Render(fmt.Sprintf("%d.", i+1)),
 				ud.Device,
-				ud.Size,
+				ui.FormatBytes(ud.Size),
 				ud.Filesystem)
 			if ud.Label != "" {
 				fmt.Printf("   Label: %s\n", ud.Label)
@@ -240,6 +281,10 @@ func handleRescan(dm *disk.Manager) {
 	if err := dm.ScanDisks(); err != nil {
 		fmt.Println(errorStyle.Render(fmt.Sprintf("❌ Error rescanning disks: %v", err)))
 	} else {
+		if err := recordHistory(dm); err != nil {
+			fmt.Println(infoStyle.Render("⚠️  " + err.Error()))
+		}
 		fmt.Println(successStyle.Render("✅ Rescan completed"))
+		printMountChanges(os.Stdout, dm.Changes())
 	}
 }
\ No newline at end of file
@@ -0,0 +1,324 @@
+// Package analyzer measures how much space directories use, like du or ncdu.
+// It walks a tree with a bounded number of concurrent directory readers,
+// stays on one filesystem unless asked not to, and counts hard-linked files
+// once.
+package analyzer
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Defaults used when Options fields are zero
+const (
+	DefaultWorkers = 8
+	DefaultTop     = 20
+)
+
+// Options configures a scan
+type Options struct {
+	// CrossFilesystems descends into filesystems mounted below the root
+	CrossFilesystems bool
+	// Workers bounds how many directories are read at once
+	Workers int
+}
+
+// Node is a file or directory in the scanned tree. Directory sizes include
+// everything below them.
+type Node struct {
+	Name     string
+	Parent   *Node
+	Children []*Node
+	IsDir    bool
+	// Apparent is the size stat reports, Allocated the space the blocks
+	// take on disk; sparse and compressed files have Allocated < Apparent
+	Apparent  uint64
+	Allocated uint64
+	// Items counts the files and directories below a directory
+	Items int
+	// Hardlink marks a further link to a file that was already counted
+	Hardlink bool
+	// OtherFS marks a mount point of another filesystem that was not entered
+	OtherFS bool
+	Err     string
+}
+
+// Path returns the full path of the node
+func (n *Node) Path() string {
+	if n.Parent == nil {
+		return n.Name
+	}
+	return filepath.Join(n.Parent.Path(), n.Name)
+}
+
+// Size returns the apparent or the allocated size
+func (n *Node) Size(apparent bool) uint64 {
+	if apparent {
+		return n.Apparent
+	}
+	return n.Allocated
+}
+
+// Sorted returns the children, largest first
+func (n *Node) Sorted(apparent bool) []*Node {
+	children := append([]*Node(nil), n.Children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		a, b := children[i].Size(apparent), children[j].Size(apparent)
+		if a != b {
+			return a > b
+		}
+		return children[i].Name < children[j].Name
+	})
+	return children
+}
+
+// Result is a finished or cancelled scan
+type Result struct {
+	Root  *Node
+	Files int
+	Dirs  int
+	// Errors counts entries that could not be read
+	Errors int
+	// Partial is set when the scan was cancelled before it finished
+	Partial  bool
+	Duration time.Duration
+}
+
+// Progress is a snapshot of a running scan
+type Progress struct {
+	Files  int64
+	Dirs   int64
+	Bytes  uint64
+	Errors int64
+}
+
+type inode struct {
+	dev, ino uint64
+}
+
+// Scanner walks one directory tree. Progress may be called from other
+// goroutines while Run is in progress.
+type Scanner struct {
+	root string
+	opts Options
+	dev  uint64
+
+	files, dirs, errors atomic.Int64
+	bytes               atomic.Uint64
+
+	mu    sync.Mutex
+	links map[inode]bool
+
+	sem chan struct{}
+	wg  sync.WaitGroup
+}
+
+// NewScanner prepares a scan of root
+func NewScanner(root string, opts Options) *Scanner {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	return &Scanner{
+		root:  root,
+		opts:  opts,
+		links: make(map[inode]bool),
+		// The goroutine calling Run reads directories too
+		sem: make(chan struct{}, opts.Workers-1),
+	}
+}
+
+// Progress reports how far the scan got
+func (s *Scanner) Progress() Progress {
+	return Progress{
+		Files:  s.files.Load(),
+		Dirs:   s.dirs.Load(),
+		Bytes:  s.bytes.Load(),
+		Errors: s.errors.Load(),
+	}
+}
+
+// Run scans the tree. Cancelling ctx stops the scan early; the result then
+// holds what was measured so far and has Partial set.
+func (s *Scanner) Run(ctx context.Context) (*Result, error) {
+	start := time.Now()
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path %s: %v", s.root, err)
+	}
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(root, &st); err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %v", root, err)
+	}
+	s.dev = uint64(st.Dev)
+
+	node := &Node{Name: root}
+	s.measure(node, &st)
+	if node.IsDir {
+		s.dirs.Add(1)
+		s.walk(ctx, node, root)
+		s.wg.Wait()
+		total(node)
+	} else {
+		s.files.Add(1)
+	}
+
+	return &Result{
+		Root:     node,
+		Files:    int(s.files.Load()),
+		Dirs:     int(s.dirs.Load()),
+		Errors:   int(s.errors.Load()),
+		Partial:  ctx.Err() != nil,
+		Duration: time.Since(start),
+	}, nil
+}
+
+// walk reads one directory. Subdirectories go to a new goroutine while
+// there is a free worker slot and are read inline otherwise, which bounds
+// concurrency without risking a deadlock. Only the goroutine reading a
+// directory touches its node.
+func (s *Scanner) walk(ctx context.Context, dir *Node, path string) {
+	if ctx.Err() != nil {
+		return
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		dir.Err = err.Error()
+		s.errors.Add(1)
+	}
+
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return
+		}
+		child := &Node{Name: e.Name(), Parent: dir}
+		dir.Children = append(dir.Children, child)
+		childPath := filepath.Join(path, e.Name())
+
+		var st syscall.Stat_t
+		if err := syscall.Lstat(childPath, &st); err != nil {
+			child.Err = err.Error()
+			s.errors.Add(1)
+			continue
+		}
+		s.measure(child, &st)
+
+		if !child.IsDir {
+			s.files.Add(1)
+			continue
+		}
+		s.dirs.Add(1)
+		if !s.opts.CrossFilesystems && uint64(st.Dev) != s.dev {
+			child.OtherFS = true
+			continue
+		}
+
+		select {
+		case s.sem <- struct{}{}:
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer func() { <-s.sem }()
+				s.walk(ctx, child, childPath)
+			}()
+		default:
+			s.walk(ctx, child, childPath)
+		}
+	}
+}
+
+// measure fills in the sizes of one entry, leaving repeated hard links at
+// zero so they are counted once
+func (s *Scanner) measure(n *Node, st *syscall.Stat_t) {
+	n.IsDir = st.Mode&syscall.S_IFMT == syscall.S_IFDIR
+	if n.IsDir && !s.opts.CrossFilesystems && uint64(st.Dev) != s.dev {
+		// A mount point: its size belongs to the other filesystem
+		return
+	}
+	if !n.IsDir && st.Nlink > 1 {
+		key := inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+		s.mu.Lock()
+		seen := s.links[key]
+		s.links[key] = true
+		s.mu.Unlock()
+		if seen {
+			n.Hardlink = true
+			return
+		}
+	}
+	n.Apparent = uint64(st.Size)
+	n.Allocated = uint64(st.Blocks) * 512
+	s.bytes.Add(n.Allocated)
+}
+
+// total adds the children's sizes to every directory once the walk is done
+func total(n *Node) {
+	for _, c := range n.Children {
+		if c.IsDir {
+			total(c)
+			n.Items += c.Items
+		}
+		n.Apparent += c.Apparent
+		n.Allocated += c.Allocated
+		n.Items++
+	}
+}
+
+// Largest returns the n largest files below dir, largest first
+func Largest(dir *Node, n int, apparent bool) []*Node {
+	if n <= 0 {
+		n = DefaultTop
+	}
+	h := &fileHeap{apparent: apparent}
+	var visit func(*Node)
+	visit = func(node *Node) {
+		for _, c := range node.Children {
+			if c.IsDir {
+				visit(c)
+				continue
+			}
+			if c.Hardlink {
+				continue
+			}
+			if h.Len() < n {
+				heap.Push(h, c)
+			} else if c.Size(apparent) > h.nodes[0].Size(apparent) {
+				h.nodes[0] = c
+				heap.Fix(h, 0)
+			}
+		}
+	}
+	visit(dir)
+
+	files := make([]*Node, h.Len())
+	for i := len(files) - 1; i >= 0; i-- {
+		files[i] = heap.Pop(h).(*Node)
+	}
+	return files
+}
+
+// fileHeap is a min-heap by size, so the smallest kept file is dropped first
+type fileHeap struct {
+	nodes    []*Node
+	apparent bool
+}
+
+func (h fileHeap) Len() int { return len(h.nodes) }
+func (h fileHeap) Less(i, j int) bool {
+	return h.nodes[i].Size(h.apparent) < h.nodes[j].Size(h.apparent)
+}
+func (h fileHeap) Swap(i, j int)       { h.nodes[i], h.nodes[j] = h.nodes[j], h.nodes[i] }
+func (h *fileHeap) Push(x interface{}) { h.nodes = append(h.nodes, x.(*Node)) }
+func (h *fileHeap) Pop() interface{} {
+	last := h.nodes[len(h.nodes)-1]
+	h.nodes = h.nodes[:len(h.nodes)-1]
+	return last
+}
@@ -0,0 +1,82 @@
+package analyzer
+
+// Entry is one file or directory in a Report
+type Entry struct {
+	Path           string `json:"path" yaml:"path"`
+	IsDir          bool   `json:"is_dir" yaml:"is_dir"`
+	ApparentBytes  uint64 `json:"apparent_bytes" yaml:"apparent_bytes"`
+	AllocatedBytes uint64 `json:"allocated_bytes" yaml:"allocated_bytes"`
+	Items          int    `json:"items" yaml:"items"`
+	// OtherFilesystem marks a mount point that was not entered
+	OtherFilesystem bool   `json:"other_filesystem,omitempty" yaml:"other_filesystem,omitempty"`
+	Error           string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Report is a flat summary of a scanned directory for output and renderers:
+// its immediate children and the largest files below it, largest first
+type Report struct {
+	Root            string  `json:"root" yaml:"root"`
+	ApparentBytes   uint64  `json:"apparent_bytes" yaml:"apparent_bytes"`
+	AllocatedBytes  uint64  `json:"allocated_bytes" yaml:"allocated_bytes"`
+	Files           int     `json:"files" yaml:"files"`
+	Dirs            int     `json:"dirs" yaml:"dirs"`
+	Errors          int     `json:"errors" yaml:"errors"`
+	Partial         bool    `json:"partial" yaml:"partial"`
+	DurationSeconds float64 `json:"duration_seconds" yaml:"duration_seconds"`
+	// Apparent tells renderers which size to show and sort by
+	Apparent bool    `json:"apparent" yaml:"apparent"`
+	Entries  []Entry `json:"entries" yaml:"entries"`
+	Largest  []Entry `json:"largest_files" yaml:"largest_files"`
+}
+
+// Report summarises dir, which must belong to the result
+func (r *Result) Report(dir *Node, top int, apparent bool) Report {
+	report := Report{
+		Root:            dir.Path(),
+		ApparentBytes:   dir.Apparent,
+		AllocatedBytes:  dir.Allocated,
+		Files:           r.Files,
+		Dirs:            r.Dirs,
+		Errors:          r.Errors,
+		Partial:         r.Partial,
+		DurationSeconds: r.Duration.Seconds(),
+		Apparent:        apparent,
+		Entries:         []Entry{},
+		Largest:         []Entry{},
+	}
+	for _, n := range dir.Sorted(apparent) {
+		report.Entries = append(report.Entries, entry(n))
+	}
+	for _, n := range Largest(dir, top, apparent) {
+		report.Largest = append(report.Largest, entry(n))
+	}
+	return report
+}
+
+func entry(n *Node) Entry {
+	return Entry{
+		Path:            n.Path(),
+		IsDir:           n.IsDir,
+		ApparentBytes:   n.Apparent,
+		AllocatedBytes:  n.Allocated,
+		Items:           n.Items,
+		OtherFilesystem: n.OtherFS,
+		Error:           n.Err,
+	}
+}
+
+// Size returns the size the report is sorted by
+func (e Entry) Size(apparent bool) uint64 {
+	if apparent {
+		return e.ApparentBytes
+	}
+	return e.AllocatedBytes
+}
+
+// Size returns the total the report is sorted by
+func (r Report) Size() uint64 {
+	if r.Apparent {
+		return r.ApparentBytes
+	}
+	return r.AllocatedBytes
+}
@@ -0,0 +1,401 @@
+// Package cleanup finds space that can be freed safely: caches, trash,
+// package manager caches, old logs, old snap revisions and crash dumps.
+// Everything it lists is either recreated when needed or was thrown away
+// by the user already.
+package cleanup
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+// Kind is a family of reclaimable locations
+type Kind string
+
+const (
+	KindCache      Kind = "cache"
+	KindThumbnails Kind = "thumbnails"
+	KindTrash      Kind = "trash"
+	KindPackages   Kind = "packages"
+	KindJournal    Kind = "journal"
+	KindSnaps      Kind = "snaps"
+	KindCoreDumps  Kind = "coredumps"
+)
+
+// Kinds are every kind of item, in the order they are looked for
+var Kinds = []Kind{KindCache, KindThumbnails, KindTrash, KindPackages, KindJournal, KindSnaps, KindCoreDumps}
+
+// Item is one reclaimable location
+type Item struct {
+	Kind Kind   `json:"kind" yaml:"kind"`
+	Name string `json:"name" yaml:"name"`
+	// Explanation says what the files are and what removing them costs, for
+	// people who have never heard of a package cache
+	Explanation string `json:"explanation" yaml:"explanation"`
+	// Paths are removed to free the space, or, for directories marked with
+	// Contents, emptied
+	Paths []string `json:"paths,omitempty" yaml:"paths,omitempty"`
+	// Contents is set when the directories in Paths stay and only what is
+	// inside them goes
+	Contents bool `json:"contents,omitempty" yaml:"contents,omitempty"`
+	// Commands free the space the proper way instead, e.g. apt-get clean
+	Commands [][]string `json:"commands,omitempty" yaml:"commands,omitempty"`
+	// MountPoint is the filesystem the space is freed on
+	MountPoint string `json:"mount_point" yaml:"mount_point"`
+	Bytes      uint64 `json:"bytes" yaml:"bytes"`
+	Files      int    `json:"files" yaml:"files"`
+	// NeedsRoot is set when freeing the space takes administrator rights
+	NeedsRoot bool `json:"needs_root" yaml:"needs_root"`
+}
+
+// Report is everything reclaimable, largest first
+type Report struct {
+	Items []Item `json:"items" yaml:"items"`
+	Total uint64 `json:"total_bytes" yaml:"total_bytes"`
+}
+
+// ForMounts returns the items on any of the mount points, and their total
+func (r Report) ForMounts(mounts ...string) ([]Item, uint64) {
+	var items []Item
+	var total uint64
+	for _, item := range r.Items {
+		for _, m := range mounts {
+			if item.MountPoint == m {
+				items = append(items, item)
+				total += item.Bytes
+				break
+			}
+		}
+	}
+	return items, total
+}
+
+// ByMount sums the reclaimable bytes per mount point
+func (r Report) ByMount() map[string]uint64 {
+	sums := map[string]uint64{}
+	for _, item := range r.Items {
+		sums[item.MountPoint] += item.Bytes
+	}
+	return sums
+}
+
+// Options configure Analyze
+type Options struct {
+	// Disks are the scanned filesystems: each gets its trash checked and
+	// every item is attributed to one of them
+	Disks []disk.Disk
+	// Root is prepended to system paths such as /var/cache, for tests
+	Root string
+	// DisabledSnaps lists snap revisions that are no longer in use,
+	// from "snap list --all" by default
+	DisabledSnaps func() ([]SnapRevision, error)
+}
+
+// journalAge is how much of the system journal is kept
+const journalAge = 14 * 24 * time.Hour
+
+// Analyze sizes every reclaimable location that exists. Locations that
+// cannot be read are skipped; ctx stops a slow walk.
+func Analyze(ctx context.Context, opts Options) Report {
+	if opts.DisabledSnaps == nil {
+		opts.DisabledSnaps = disabledSnaps
+	}
+	sys := func(path string) string { return filepath.Join(opts.Root, path) }
+
+	var items []Item
+	add := func(item Item) {
+		if ctx.Err() != nil || item.Bytes == 0 {
+			return
+		}
+		items = append(items, item)
+	}
+
+	cache := cacheDir()
+	if cache != "" {
+		add(measure(ctx, Item{
+			Kind:        KindCache,
+			Name:        "Application caches",
+			Explanation: "Programs such as browsers keep downloaded and generated files here to start and load faster. They are recreated when needed; some programs may be slower the first time.",
+			Paths:       children(cache, "thumbnails"),
+		}))
+		add(measure(ctx, Item{
+			Kind:        KindThumbnails,
+			Name:        "Thumbnails",
+			Explanation: "Small previews of your pictures and videos made by the file manager. They are made again the next time you open a folder.",
+			Paths:       existing(filepath.Join(cache, "thumbnails"), filepath.Join(filepath.Dir(cache), ".thumbnails")),
+			Contents:    true,
+		}))
+	}
+
+	seen := map[uint64]bool{}
+	for _, d := range opts.Disks {
+		if !disk.Monitored(d) {
+			continue
+		}
+		info, err := os.Stat(d.MountPoint)
+		if err != nil || seen[deviceID(info)] {
+			continue
+		}
+		seen[deviceID(info)] = true
+		if dirs := disk.TrashDirs(d.MountPoint); len(dirs) > 0 {
+			add(measure(ctx, Item{
+				Kind:        KindTrash,
+				Name:        "Trash",
+				Explanation: "Files you deleted in the file manager wait here so they can be restored. Emptying the trash deletes them for good.",
+				Paths:       trashContents(dirs),
+				Contents:    true,
+			}))
+		}
+	}
+
+	packages := []struct {
+		dir     string
+		tool    string
+		command []string
+	}{
+		{"/var/cache/apt/archives", "apt-get", []string{"apt-get", "clean"}},
+		{"/var/cache/dnf", "dnf", []string{"dnf", "clean", "packages"}},
+		{"/var/cache/yum", "yum", []string{"yum", "clean", "packages"}},
+		{"/var/cache/pacman/pkg", "pacman", []string{"pacman", "-Sc", "--noconfirm"}},
+		{"/var/cache/zypp/packages", "zypper", []string{"zypper", "clean", "--all"}},
+	}
+	for _, p := range packages {
+		dir := sys(p.dir)
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		add(measure(ctx, Item{
+			Kind:        KindPackages,
+			Name:        "Package cache (" + p.tool + ")",
+			Explanation: "Copies of software packages that were downloaded to install or update programs. The installed programs do not need them; they are downloaded again if ever needed.",
+			Paths:       []string{dir},
+			Commands:    [][]string{p.command},
+			NeedsRoot:   !writable(dir),
+		}))
+	}
+
+	var journals []string
+	cutoff := time.Now().Add(-journalAge)
+	for _, dir := range []string{sys("/var/log/journal"), sys("/run/log/journal")} {
+		files, _ := filepath.Glob(filepath.Join(dir, "*", "*.journal*"))
+		for _, f := range files {
+			// Archived files carry an @ in their name or end in ~; the active
+			// ones are written to and never removed by vacuuming
+			name := filepath.Base(f)
+			if !strings.Contains(name, "@") && !strings.HasSuffix(name, "~") {
+				continue
+			}
+			if info, err := os.Stat(f); err == nil && info.ModTime().Before(cutoff) {
+				journals = append(journals, f)
+			}
+		}
+	}
+	if len(journals) > 0 {
+		add(measure(ctx, Item{
+			Kind:        KindJournal,
+			Name:        "Old system logs",
+			Explanation: "The system journal records what happened on the computer, which helps when something breaks. Entries older than two weeks are rarely looked at.",
+			Paths:       journals,
+			Commands:    [][]string{{"journalctl", "--vacuum-time=2weeks"}},
+			NeedsRoot:   !writable(filepath.Dir(journals[0])),
+		}))
+	}
+
+	if revisions, err := opts.DisabledSnaps(); err == nil && len(revisions) > 0 {
+		item := Item{
+			Kind:        KindSnaps,
+			Name:        "Old snap versions",
+			Explanation: "Snap keeps the previous versions of each app to go back if an update misbehaves. Removing them keeps the current versions working.",
+			NeedsRoot:   true,
+		}
+		for _, r := range revisions {
+			item.Paths = append(item.Paths, sys(filepath.Join("/var/lib/snapd/snaps", r.Name+"_"+r.Revision+".snap")))
+			item.Commands = append(item.Commands, []string{"snap", "remove", r.Name, "--revision=" + r.Revision})
+		}
+		add(measure(ctx, item))
+	}
+
+	for _, dir := range []string{sys("/var/lib/systemd/coredump"), sys("/var/crash")} {
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+		add(measure(ctx, Item{
+			Kind:        KindCoreDumps,
+			Name:        "Crash reports",
+			Explanation: "Memory dumps saved when a program crashed, for developers to find the bug. Unless you are reporting one, nobody will look at them.",
+			Paths:       children(dir),
+			NeedsRoot:   !writable(dir),
+		}))
+	}
+
+	report := Report{Items: items}
+	mounts := mountsByDevice(opts.Disks)
+	for i := range report.Items {
+		report.Items[i].MountPoint = mountOf(report.Items[i].Paths, mounts)
+		report.Total += report.Items[i].Bytes
+	}
+	sort.SliceStable(report.Items, func(i, j int) bool { return report.Items[i].Bytes > report.Items[j].Bytes })
+	return report
+}
+
+// cacheDir is $XDG_CACHE_HOME, defaulting to ~/.cache
+func cacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache")
+}
+
+// children lists the entries of dir, leaving out the names in skip
+func children(dir string, skip ...string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		skipped := false
+		for _, s := range skip {
+			skipped = skipped || e.Name() == s
+		}
+		if !skipped {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	return paths
+}
+
+// existing keeps the paths that exist
+func existing(paths ...string) []string {
+	var found []string
+	for _, p := range paths {
+		if _, err := os.Lstat(p); err == nil {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// trashContents are the files and info directories of trash directories,
+// emptied but kept as the trash specification expects
+func trashContents(dirs []string) []string {
+	var paths []string
+	for _, dir := range dirs {
+		paths = append(paths, existing(filepath.Join(dir, "files"), filepath.Join(dir, "info"))...)
+	}
+	return paths
+}
+
+// measure adds up the space allocated to the files below the item's paths.
+// It does not cross into other filesystems mounted below them.
+func measure(ctx context.Context, item Item) Item {
+	for _, root := range item.Paths {
+		info, err := os.Lstat(root)
+		if err != nil {
+			continue
+		}
+		dev := deviceID(info)
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return filepath.SkipAll
+			}
+			if err != nil {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() && path != root && deviceID(info) != dev {
+				return filepath.SkipDir
+			}
+			// Directories are small and emptied ones stay, so only files count
+			if d.IsDir() {
+				return nil
+			}
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				item.Bytes += uint64(st.Blocks) * 512
+			}
+			item.Files++
+			return nil
+		})
+	}
+	return item
+}
+
+func deviceID(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev)
+	}
+	return 0
+}
+
+// writable reports whether the current user may change dir
+func writable(dir string) bool {
+	return syscall.Access(dir, 2) == nil
+}
+
+// mountsByDevice maps device ids to the mount point of each filesystem
+func mountsByDevice(disks []disk.Disk) map[uint64]string {
+	mounts := map[uint64]string{}
+	for _, d := range disks {
+		if !disk.Monitored(d) {
+			continue
+		}
+		info, err := os.Stat(d.MountPoint)
+		if err != nil {
+			continue
+		}
+		if _, ok := mounts[deviceID(info)]; !ok {
+			mounts[deviceID(info)] = d.MountPoint
+		}
+	}
+	return mounts
+}
+
+// mountOf finds the filesystem holding the first path that exists
+func mountOf(paths []string, mounts map[uint64]string) string {
+	for _, p := range paths {
+		if info, err := os.Lstat(p); err == nil {
+			return mounts[deviceID(info)]
+		}
+	}
+	return ""
+}
+
+// SnapRevision is an installed snap revision
+type SnapRevision struct {
+	Name     string `json:"name" yaml:"name"`
+	Revision string `json:"revision" yaml:"revision"`
+}
+
+// disabledSnaps lists the revisions snap keeps only for rolling back
+func disabledSnaps() ([]SnapRevision, error) {
+	output, err := exec.Command("snap", "list", "--all").Output()
+	if err != nil {
+		return nil, err
+	}
+	var revisions []SnapRevision
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// Name  Version  Rev  Tracking  Publisher  Notes
+		if len(fields) >= 6 && strings.Contains(fields[len(fields)-1], "disabled") {
+			revisions = append(revisions, SnapRevision{Name: fields[0], Revision: fields[2]})
+		}
+	}
+	return revisions, nil
+}
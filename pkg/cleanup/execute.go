@@ -0,0 +1,247 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"checkpoint/pkg/disk"
+)
+
+// Result is what cleaning one item did, or would do in a dry run
+type Result struct {
+	Item Item `json:"item" yaml:"item"`
+	// Steps are the commands run and the paths trashed or deleted, such as
+	// "sudo apt-get clean" or "trash /home/me/.cache/pip"
+	Steps []string `json:"steps" yaml:"steps"`
+	// Freed is the space given back to the filesystem
+	Freed uint64 `json:"freed_bytes" yaml:"freed_bytes"`
+	// Trashed is the space moved to the trash, freed once it is emptied
+	Trashed uint64 `json:"trashed_bytes" yaml:"trashed_bytes"`
+	DryRun  bool   `json:"dry_run" yaml:"dry_run"`
+	// Skipped says why nothing was done, e.g. the user declined sudo
+	Skipped string `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ExecOptions configure Execute
+type ExecOptions struct {
+	// DryRun only says what would be done
+	DryRun bool
+	// Delete removes files for good instead of moving them to the trash
+	Delete bool
+	// Confirm is asked before anything runs as root, with the steps sudo
+	// will run; nil declines
+	Confirm func(item Item, steps []string) bool
+	// Run runs a command, os/exec attached to the terminal by default so
+	// sudo can ask for a password
+	Run func(args []string) error
+	// Trash moves a path to the trash, disk.MoveToTrash by default
+	Trash func(path string) error
+	// LookPath finds a command, exec.LookPath by default
+	LookPath func(name string) (string, error)
+}
+
+// Execute cleans up the items one after the other, the trash first. Files are moved to the
+// trash unless opts.Delete is set, except the trash itself, which is
+// emptied, and files only root may remove, which sudo deletes. Items that
+// have a proper tool, like apt-get clean, are cleaned with it.
+func Execute(ctx context.Context, items []Item, opts ExecOptions) []Result {
+	if opts.Run == nil {
+		opts.Run = runAttached
+	}
+	if opts.Trash == nil {
+		opts.Trash = disk.MoveToTrash
+	}
+	if opts.LookPath == nil {
+		opts.LookPath = exec.LookPath
+	}
+	// Empty the trash first, or it would take the files trashed just before
+	// with it
+	items = append([]Item(nil), items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Kind == KindTrash && items[j].Kind != KindTrash
+	})
+	results := make([]Result, 0, len(items))
+	for _, item := range items {
+		if ctx.Err() != nil {
+			results = append(results, Result{Item: item, DryRun: opts.DryRun, Skipped: "interrupted"})
+			continue
+		}
+		results = append(results, execute(ctx, item, opts))
+	}
+	return results
+}
+
+// how an item's files go
+const (
+	modeTrash  = "trash"
+	modeDelete = "delete"
+)
+
+func execute(ctx context.Context, item Item, opts ExecOptions) Result {
+	r := Result{Item: item, DryRun: opts.DryRun}
+	sudo := item.NeedsRoot && os.Geteuid() != 0
+
+	var commands [][]string
+	var targets []string
+	mode := modeTrash
+	switch {
+	case len(item.Commands) > 0:
+		commands = item.Commands
+	default:
+		targets = item.Paths
+		if item.Contents {
+			targets = nil
+			for _, p := range item.Paths {
+				targets = append(targets, children(p)...)
+			}
+		}
+		// Trashing the trash would go round in circles, and root's files
+		// cannot go to the user's trash
+		if opts.Delete || item.Kind == KindTrash || sudo {
+			mode = modeDelete
+		}
+		if sudo && len(targets) > 0 {
+			commands = [][]string{append([]string{"rm", "-rf", "--"}, targets...)}
+			targets = nil
+		}
+	}
+	if sudo {
+		elevated := make([][]string, len(commands))
+		for i, c := range commands {
+			elevated[i] = append([]string{"sudo"}, c...)
+		}
+		commands = elevated
+	}
+
+	for _, c := range commands {
+		r.Steps = append(r.Steps, strings.Join(c, " "))
+	}
+	for _, t := range targets {
+		r.Steps = append(r.Steps, mode+" "+t)
+	}
+	if len(r.Steps) == 0 {
+		r.Skipped = "nothing left to remove"
+		return r
+	}
+	for _, c := range commands {
+		if _, err := opts.LookPath(c[0]); err != nil {
+			r.Skipped = c[0] + " is not installed"
+			return r
+		}
+	}
+
+	if opts.DryRun {
+		if mode == modeTrash && len(targets) > 0 {
+			r.Trashed = item.Bytes
+		} else {
+			r.Freed = item.Bytes
+		}
+		return r
+	}
+	if sudo && (opts.Confirm == nil || !opts.Confirm(item, r.Steps)) {
+		r.Skipped = "needs administrator rights, not confirmed"
+		return r
+	}
+
+	var errs []string
+	for _, c := range commands {
+		if err := opts.Run(c); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", strings.Join(c, " "), err))
+			break
+		}
+	}
+	for _, t := range targets {
+		if ctx.Err() != nil {
+			errs = append(errs, "interrupted")
+			break
+		}
+		var err error
+		if mode == modeTrash {
+			err = opts.Trash(t)
+		} else {
+			err = os.RemoveAll(t)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		r.Error = errs[0]
+		if len(errs) > 1 {
+			r.Error += fmt.Sprintf(" (and %d more errors)", len(errs)-1)
+		}
+	}
+
+	// Whatever is no longer there is what the step gave back
+	left := measure(ctx, Item{Paths: existing(item.Paths...)}).Bytes
+	if left < item.Bytes {
+		if mode == modeTrash && len(targets) > 0 {
+			r.Trashed = item.Bytes - left
+		} else {
+			r.Freed = item.Bytes - left
+		}
+	}
+	return r
+}
+
+// runAttached runs a command on the terminal
+func runAttached(args []string) error {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// Totals adds up the space results freed and moved to the trash
+func Totals(results []Result) (freed, trashed uint64) {
+	for _, r := range results {
+		freed += r.Freed
+		trashed += r.Trashed
+	}
+	return freed, trashed
+}
+
+// Select keeps the items of the given kinds, all of them when kinds is
+// empty. Unknown kinds are an error.
+func Select(items []Item, kinds []string) ([]Item, error) {
+	if len(kinds) == 0 {
+		return items, nil
+	}
+	wanted := map[Kind]bool{}
+	for _, k := range kinds {
+		kind := Kind(strings.TrimSpace(k))
+		if !knownKind(kind) {
+			return nil, fmt.Errorf("unknown cleanup kind %q, expected one of %s", k, strings.Join(KindNames(), ", "))
+		}
+		wanted[kind] = true
+	}
+	var selected []Item
+	for _, item := range items {
+		if wanted[item.Kind] {
+			selected = append(selected, item)
+		}
+	}
+	return selected, nil
+}
+
+func knownKind(kind Kind) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// KindNames are the names of Kinds
+func KindNames() []string {
+	names := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		names[i] = string(k)
+	}
+	return names
+}
@@ -0,0 +1,176 @@
+// Package client talks to a running checkpoint daemon over its Unix socket.
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"checkpoint/pkg/daemon"
+	"checkpoint/pkg/disk"
+)
+
+// dialTimeout keeps a dead socket from holding up the caller
+const dialTimeout = time.Second
+
+// A daemon that accepts but stops answering must not hang the caller
+const (
+	callTimeout = 10 * time.Second
+	// rescanTimeout leaves time for a whole scan
+	rescanTimeout = 2 * time.Minute
+)
+
+// Client calls the daemon. Every call uses its own connection, so a Client
+// can be shared between goroutines.
+type Client struct {
+	path          string
+	timeout       time.Duration
+	rescanTimeout time.Duration
+}
+
+// New creates a client for the daemon listening on path, or on
+// daemon.SocketPath() when path is empty
+func New(path string) *Client {
+	if path == "" {
+		path = daemon.SocketPath()
+	}
+	return &Client{path: path, timeout: callTimeout, rescanTimeout: rescanTimeout}
+}
+
+// Path is the socket the client talks to
+func (c *Client) Path() string {
+	return c.path
+}
+
+// Available reports whether a daemon answers on the socket
+func (c *Client) Available() bool {
+	conn, err := net.DialTimeout("unix", c.path, dialTimeout)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// List returns the disks of the daemon's last scan
+func (c *Client) List() ([]disk.Disk, error) {
+	var disks []disk.Disk
+	return disks, c.call(daemon.MethodList, c.timeout, &disks)
+}
+
+// Groups returns the drive groups with forecasts and threshold alerts
+func (c *Client) Groups() ([]disk.DriveGroup, error) {
+	var groups []disk.DriveGroup
+	return groups, c.call(daemon.MethodGroups, c.timeout, &groups)
+}
+
+// Stats returns the storage summary of the daemon's last scan
+func (c *Client) Stats() (disk.DiskStats, error) {
+	var stats disk.DiskStats
+	return stats, c.call(daemon.MethodStats, c.timeout, &stats)
+}
+
+// Rescan asks the daemon to scan now and returns the new disks
+func (c *Client) Rescan() ([]disk.Disk, error) {
+	var disks []disk.Disk
+	return disks, c.call(daemon.MethodRescan, c.rescanTimeout, &disks)
+}
+
+// Subscribe streams an event after every scan of the daemon. The channel is
+// closed when ctx is done or the daemon goes away.
+func (c *Client) Subscribe(ctx context.Context) (<-chan daemon.Event, error) {
+	conn, reader, err := c.request(daemon.MethodSubscribe, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := readResponse(reader); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Events come whenever the daemon scans, however long that takes
+	conn.SetDeadline(time.Time{})
+
+	events := make(chan daemon.Event)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		defer close(events)
+		defer conn.Close()
+		for {
+			resp, err := readResponse(reader)
+			if err != nil {
+				return
+			}
+			if resp.Event == nil {
+				continue
+			}
+			select {
+			case events <- *resp.Event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// call sends one request and decodes its result into result, giving up
+// after timeout
+func (c *Client) call(method string, timeout time.Duration, result interface{}) error {
+	conn, reader, err := c.request(method, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	resp, err := readResponse(reader)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("invalid answer from daemon: %v", err)
+	}
+	return nil
+}
+
+// request connects and sends method. The connection gives up after
+// timeout.
+func (c *Client) request(method string, timeout time.Duration) (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("unix", c.path, dialTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to reach daemon: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := json.NewEncoder(conn).Encode(daemon.Request{ID: 1, Method: method}); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to send request: %v", err)
+	}
+	// Scan results can be large, so read whole lines rather than a
+	// bufio.Scanner with its token limit
+	return conn, bufio.NewReader(conn), nil
+}
+
+// readResponse reads one response and turns a daemon error into an error
+func readResponse(reader *bufio.Reader) (daemon.Response, error) {
+	var resp daemon.Response
+	line, err := reader.ReadBytes('\n')
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return resp, fmt.Errorf("the daemon did not answer in time")
+	}
+	if err != nil {
+		return resp, fmt.Errorf("failed to read answer: %v", err)
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return resp, fmt.Errorf("invalid answer from daemon: %v", err)
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
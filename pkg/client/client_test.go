@@ -0,0 +1,108 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"checkpoint/pkg/daemon"
+)
+
+// fakeDaemon listens on a socket and answers each request with answer,
+// which may block or return nothing
+func fakeDaemon(t *testing.T, answer func(conn net.Conn, req daemon.Request)) *Client {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var req daemon.Request
+				line, err := bufio.NewReader(conn).ReadBytes('\n')
+				if err != nil || json.Unmarshal(line, &req) != nil {
+					return
+				}
+				answer(conn, req)
+			}()
+		}
+	}()
+	c := New(path)
+	c.timeout = 50 * time.Millisecond
+	c.rescanTimeout = 50 * time.Millisecond
+	return c
+}
+
+func respond(conn net.Conn, resp daemon.Response) {
+	json.NewEncoder(conn).Encode(resp)
+}
+
+func TestCallAnswered(t *testing.T) {
+	c := fakeDaemon(t, func(conn net.Conn, req daemon.Request) {
+		respond(conn, daemon.Response{ID: req.ID, Result: json.RawMessage(`[{"mount_point":"/"}]`)})
+	})
+	disks, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(disks) != 1 || disks[0].MountPoint != "/" {
+		t.Errorf("got %+v", disks)
+	}
+}
+
+func TestCallGivesUpOnASilentDaemon(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	c := fakeDaemon(t, func(net.Conn, daemon.Request) { <-done })
+
+	for name, call := range map[string]func() error{
+		"list":      func() error { _, err := c.List(); return err },
+		"rescan":    func() error { _, err := c.Rescan(); return err },
+		"subscribe": func() error { _, err := c.Subscribe(context.Background()); return err },
+	} {
+		start := time.Now()
+		err := call()
+		if err == nil || !strings.Contains(err.Error(), "did not answer in time") {
+			t.Errorf("%s: error = %v", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s gave up after %s", name, elapsed)
+		}
+	}
+}
+
+func TestSubscribeWaitsForEventsPastTheTimeout(t *testing.T) {
+	c := fakeDaemon(t, func(conn net.Conn, req daemon.Request) {
+		respond(conn, daemon.Response{ID: req.ID})
+		// the next scan comes long after a call would have given up
+		time.Sleep(150 * time.Millisecond)
+		respond(conn, daemon.Response{ID: req.ID, Event: &daemon.Event{Type: daemon.EventScan, Reason: daemon.ReasonInterval}})
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event, ok := <-events:
+		if !ok || event.Reason != daemon.ReasonInterval {
+			t.Errorf("got %+v, %v", event, ok)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event")
+	}
+}
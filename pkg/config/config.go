@@ -0,0 +1,105 @@
+// Package config locates checkpoint's configuration and state files following
+// the XDG base directory specification and reads and writes them as YAML.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const appName = "checkpoint"
+
+// ConfigDir returns $XDG_CONFIG_HOME/checkpoint, defaulting to ~/.config/checkpoint
+func ConfigDir() (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// StateDir returns $XDG_STATE_HOME/checkpoint, defaulting to ~/.local/state/checkpoint
+func StateDir() (string, error) {
+	return xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// CacheDir returns $XDG_CACHE_HOME/checkpoint, defaulting to ~/.cache/checkpoint
+func CacheDir() (string, error) {
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+// RuntimeDir returns $XDG_RUNTIME_DIR/checkpoint for sockets, falling back
+// to a per-user directory under the system temporary directory
+func RuntimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName)
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", appName, os.Getuid()))
+}
+
+func xdgDir(env, fallback string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %v", err)
+	}
+	return filepath.Join(home, fallback, appName), nil
+}
+
+// Load reads the YAML config file name into v. A missing file is not an
+// error and leaves v untouched, so callers can fill in defaults first.
+func Load(name string, v interface{}) error {
+	dir, err := ConfigDir()
+	if err != nil {
+		return err
+	}
+	return LoadFile(filepath.Join(dir, name), v)
+}
+
+// LoadFile reads a YAML file at an explicit path, see Load
+func LoadFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return nil
+}
+
+// Save writes v as the YAML config file name, creating the directory
+func Save(name string, v interface{}) error {
+	dir, err := ConfigDir()
+	if err != nil {
+		return err
+	}
+	return SaveFile(filepath.Join(dir, name), v)
+}
+
+// SaveFile writes v as YAML to path. The file is replaced atomically so a
+// crash never leaves a half-written config behind.
+func SaveFile(path string, v interface{}) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package daemon keeps a live disk inventory and answers queries about it
+// over a Unix domain socket, so several tools can share one scan.
+//
+// The protocol is newline-delimited JSON. A client writes a Request per
+// line and reads a Response per line with the same ID. After a "subscribe"
+// request the connection only carries events, one Response with Event set
+// per line, until either side closes it.
+package daemon
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"time"
+
+	"checkpoint/pkg/config"
+	"checkpoint/pkg/disk"
+)
+
+// Methods a client can call
+const (
+	// MethodList returns the scanned disks, []disk.Disk
+	MethodList = "list"
+	// MethodGroups returns the drive groups of the friendly view, with
+	// forecasts and threshold alerts, []disk.DriveGroup
+	MethodGroups = "groups"
+	// MethodStats returns the storage summary, disk.DiskStats
+	MethodStats = "stats"
+	// MethodRescan scans right away and returns the new disks, []disk.Disk
+	MethodRescan = "rescan"
+	// MethodSubscribe streams an Event after every scan, and one listing
+	// the changes when the mount table changed
+	MethodSubscribe = "subscribe"
+)
+
+// Event types
+const (
+	EventScan  = "scan"
+	EventError = "error"
+	// EventChanges follows a scan that found filesystems mounted,
+	// unmounted, remounted or changed much in size since the previous one
+	EventChanges = "changes"
+)
+
+// Reasons a scan ran, see Event
+const (
+	ReasonStart    = "start"
+	ReasonInterval = "interval"
+	ReasonMounts   = "mounts"
+	ReasonRequest  = "request"
+)
+
+// Request is one call to the daemon
+type Request struct {
+	ID     int    `json:"id"`
+	Method string `json:"method"`
+}
+
+// Response answers the request with the same ID. Result holds the method's
+// data, Error says why there is none.
+type Response struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	Event  *Event          `json:"event,omitempty"`
+}
+
+// Event tells subscribers that a scan finished, or failed
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	// Reason is what started the scan: start, interval, mounts or request
+	Reason string      `json:"reason"`
+	Disks  []disk.Disk `json:"disks,omitempty"`
+	// Changes are what changed since the previous scan, see
+	// disk.CompareMounts
+	Changes []disk.MountChange `json:"changes,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// SocketPath is where the daemon listens by default,
+// $XDG_RUNTIME_DIR/checkpoint/daemon.sock
+func SocketPath() string {
+	return filepath.Join(config.RuntimeDir(), "daemon.sock")
+}
@@ -0,0 +1,347 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"checkpoint/pkg/config"
+	"checkpoint/pkg/disk"
+)
+
+// Defaults for Options
+const (
+	DefaultInterval = time.Minute
+	// mountSettle waits for a burst of mount changes to end before scanning
+	mountSettle = 500 * time.Millisecond
+)
+
+// Options configure a Server. Zero values use the defaults.
+type Options struct {
+	// Interval is the time between periodic rescans
+	Interval time.Duration
+	// Scan fills a fresh manager, dm.ScanDisks by default
+	Scan func(dm *disk.Manager) error
+	// Groups groups the disks for MethodGroups, disk.GroupDisks by default
+	Groups func(disks []disk.Disk) []disk.DriveGroup
+	// WatchMounts rescans when the mount table changes, disk.WatchMounts by
+	// default
+	WatchMounts func(ctx context.Context, changed func()) error
+	// OnScan runs after every successful scan, outside the server's lock
+	OnScan func(disks []disk.Disk)
+	// OnChanges runs after a scan that found changes since the previous
+	// one, after OnScan
+	OnChanges func(changes []disk.MountChange)
+	// Logf reports problems that do not stop the daemon
+	Logf func(format string, args ...interface{})
+}
+
+// Server keeps the live Manager and answers clients
+type Server struct {
+	opts Options
+
+	mu      sync.RWMutex
+	dm      *disk.Manager
+	scanned bool // dm holds a scan to compare the next with
+	subs    map[chan Event]bool
+	rescans chan chan error
+}
+
+// NewServer creates a server. Call Serve to scan and answer clients.
+func NewServer(opts Options) *Server {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Scan == nil {
+		opts.Scan = func(dm *disk.Manager) error { return dm.ScanDisks() }
+	}
+	if opts.Groups == nil {
+		opts.Groups = disk.GroupDisks
+	}
+	if opts.WatchMounts == nil {
+		opts.WatchMounts = disk.WatchMounts
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...interface{}) {}
+	}
+	return &Server{
+		opts:    opts,
+		dm:      disk.NewManager(),
+		subs:    map[chan Event]bool{},
+		rescans: make(chan chan error),
+	}
+}
+
+// Listen opens the socket at path. A socket left behind by a daemon that
+// died is replaced; one that still answers means a daemon is running.
+func Listen(path string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already running on %s", path)
+	}
+	dir := filepath.Dir(path)
+	_, err := os.Lstat(dir)
+	// The daemon's own directory, which in a shared temporary directory
+	// anybody could have created first, is for the daemon alone. Others,
+	// such as a home directory picked with --socket, only need to keep
+	// other users from replacing the socket.
+	own := errors.Is(err, os.ErrNotExist) || dir == config.RuntimeDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+	if err := checkPrivate(dir, own); err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+	// Disk inventories are nobody else's business, so the socket is created
+	// private rather than secured once others could already connect
+	mask := syscall.Umask(0o077)
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(mask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	return listener, nil
+}
+
+// checkPrivate makes sure dir is a directory of the current user that no
+// other user can write to, or with own, that only they can use
+func checkPrivate(dir string, own bool) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check %s: %v", dir, err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	switch {
+	case !info.IsDir():
+		return fmt.Errorf("%s is not a directory", dir)
+	case !ok || int(stat.Uid) != os.Getuid():
+		return fmt.Errorf("%s belongs to another user", dir)
+	case own && info.Mode().Perm() != 0o700:
+		return fmt.Errorf("%s can be used by other users (mode %o), expected 700", dir, info.Mode().Perm())
+	case info.Mode().Perm()&0o022 != 0:
+		return fmt.Errorf("%s can be written by other users (mode %o)", dir, info.Mode().Perm())
+	}
+	return nil
+}
+
+// Serve scans once, then answers clients on listener and keeps the
+// inventory current until ctx is done. It closes the listener.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	if err := s.scan(ReasonStart); err != nil {
+		s.opts.Logf("scan failed: %v", err)
+	}
+	go s.loop(ctx)
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to accept: %v", err)
+		}
+		go s.handle(ctx, conn)
+	}
+}
+
+// loop rescans periodically, after mount changes and on request
+func (s *Server) loop(ctx context.Context) {
+	mounts := make(chan struct{}, 1)
+	go func() {
+		err := s.opts.WatchMounts(ctx, func() {
+			select {
+			case mounts <- struct{}{}:
+			default:
+			}
+		})
+		if err != nil {
+			s.opts.Logf("%v; rescanning every %s only", err, s.opts.Interval)
+		}
+	}()
+
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.scan(ReasonInterval)
+		case <-mounts:
+			// Mounting often comes in bursts, e.g. a disk with several partitions
+			time.Sleep(mountSettle)
+			select {
+			case <-mounts:
+			default:
+			}
+			s.scan(ReasonMounts)
+		case done := <-s.rescans:
+			done <- s.scan(ReasonRequest)
+		}
+	}
+}
+
+// scan fills a fresh manager and swaps it in, so clients never see a
+// half-finished scan, then tells subscribers, and what changed since the
+// previous scan if anything did
+func (s *Server) scan(reason string) error {
+	dm := disk.NewManager()
+	err := s.opts.Scan(dm)
+	event := Event{Type: EventScan, Time: time.Now(), Reason: reason}
+	if err != nil {
+		event.Type, event.Error = EventError, err.Error()
+	} else {
+		event.Disks = dm.GetDisks()
+	}
+
+	s.mu.Lock()
+	events := []Event{event}
+	if err == nil {
+		if s.scanned {
+			if changes := disk.CompareMounts(s.dm.GetDisks(), event.Disks); len(changes) > 0 {
+				events = append(events, Event{Type: EventChanges, Time: event.Time, Reason: reason, Changes: changes})
+			}
+		}
+		s.dm, s.scanned = dm, true
+	}
+	for sub := range s.subs {
+		for _, e := range events {
+			// A subscriber that does not keep up misses events rather than
+			// holding up everyone else
+			select {
+			case sub <- e:
+			default:
+			}
+		}
+	}
+	s.mu.Unlock()
+	if err == nil && s.opts.OnScan != nil {
+		s.opts.OnScan(event.Disks)
+	}
+	if len(events) > 1 && s.opts.OnChanges != nil {
+		s.opts.OnChanges(events[1].Changes)
+	}
+	return err
+}
+
+func (s *Server) disks() []disk.Disk {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dm.GetDisks()
+}
+
+// handle answers requests on one connection until the client hangs up
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var req Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			encoder.Encode(Response{Error: fmt.Sprintf("invalid request: %v", err)})
+			continue
+		}
+		if req.Method == MethodSubscribe {
+			s.subscribe(ctx, conn, encoder, req.ID)
+			return
+		}
+		if err := encoder.Encode(s.answer(ctx, req)); err != nil {
+			return
+		}
+	}
+}
+
+// answer runs one request
+func (s *Server) answer(ctx context.Context, req Request) Response {
+	var result interface{}
+	switch req.Method {
+	case MethodList:
+		result = s.disks()
+	case MethodGroups:
+		result = s.opts.Groups(s.disks())
+	case MethodStats:
+		s.mu.RLock()
+		result = s.dm.GetStats()
+		s.mu.RUnlock()
+	case MethodRescan:
+		done := make(chan error, 1)
+		select {
+		case s.rescans <- done:
+		case <-ctx.Done():
+			return Response{ID: req.ID, Error: "daemon is shutting down"}
+		}
+		if err := <-done; err != nil {
+			return Response{ID: req.ID, Error: err.Error()}
+		}
+		result = s.disks()
+	default:
+		return Response{ID: req.ID, Error: fmt.Sprintf("unknown method %q", req.Method)}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return Response{ID: req.ID, Error: err.Error()}
+	}
+	return Response{ID: req.ID, Result: data}
+}
+
+// subscribe streams events until the client hangs up or ctx is done
+func (s *Server) subscribe(ctx context.Context, conn net.Conn, encoder *json.Encoder, id int) {
+	events := make(chan Event, 8)
+	s.mu.Lock()
+	s.subs[events] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, events)
+		s.mu.Unlock()
+	}()
+
+	if err := encoder.Encode(Response{ID: id, Result: json.RawMessage("true")}); err != nil {
+		return
+	}
+	// The client sends nothing more; a read returning means it hung up
+	gone := make(chan struct{})
+	go func() {
+		buf := make([]byte, 1)
+		for {
+			if _, err := conn.Read(buf); err != nil {
+				close(gone)
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-gone:
+			return
+		case event := <-events:
+			if err := encoder.Encode(Response{ID: id, Event: &event}); err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					s.opts.Logf("dropping subscriber: %v", err)
+				}
+				return
+			}
+		}
+	}
+}
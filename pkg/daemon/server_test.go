@@ -0,0 +1,97 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListenChecksTheSocketDirectory(t *testing.T) {
+	tests := []struct {
+		name string
+		// mode of the directory before Listen, 0 when it does not exist
+		mode    os.FileMode
+		runtime bool
+		error   string
+	}{
+		{name: "created", mode: 0},
+		{name: "home directory", mode: 0o755},
+		{name: "private", mode: 0o700},
+		{name: "group writable", mode: 0o775, error: "can be written by other users"},
+		{name: "world writable", mode: 0o777, error: "can be written by other users"},
+		{name: "own directory created", mode: 0, runtime: true},
+		{name: "own directory readable by others", mode: 0o755, runtime: true, error: "expected 700"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			t.Setenv("XDG_RUNTIME_DIR", root)
+			dir := filepath.Join(root, "sockets")
+			if tt.runtime {
+				dir = filepath.Join(root, "checkpoint")
+			}
+			if tt.mode != 0 {
+				if err := os.Mkdir(dir, 0o700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chmod(dir, tt.mode); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			listener, err := Listen(filepath.Join(dir, "daemon.sock"))
+			if tt.error != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.error) {
+					t.Errorf("error = %v, want %q", err, tt.error)
+				}
+				if listener != nil {
+					listener.Close()
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			info, err := os.Stat(filepath.Join(dir, "daemon.sock"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o700 {
+				t.Errorf("socket mode %o, want 700", perm)
+			}
+			if info, _ := os.Stat(dir); tt.mode == 0 && info.Mode().Perm() != 0o700 {
+				t.Errorf("created %s with mode %o, want 700", dir, info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestListenRefusesSymlinks(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", root)
+	target := filepath.Join(root, "elsewhere")
+	if err := os.Mkdir(target, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(root, "checkpoint")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Listen(filepath.Join(link, "daemon.sock")); err == nil || !strings.Contains(err.Error(), "is not a directory") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestListenRefusesARunningDaemon(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "daemon.sock")
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	if _, err := Listen(path); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Errorf("error = %v", err)
+	}
+}
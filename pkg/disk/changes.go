@@ -0,0 +1,189 @@
+package disk
+
+import (
+	"sort"
+	"strings"
+)
+
+// ChangeKind says how a mount point differs between two scans
+type ChangeKind string
+
+// Kinds of MountChange, in the order CompareMounts lists them
+const (
+	// ChangeReadOnly is a filesystem remounted read-only, which the kernel
+	// does on its own after filesystem errors
+	ChangeReadOnly  ChangeKind = "read_only"
+	ChangeUnmounted ChangeKind = "unmounted"
+	ChangeMounted   ChangeKind = "mounted"
+	ChangeReadWrite ChangeKind = "read_write"
+	ChangeOptions   ChangeKind = "options"
+	ChangeSize      ChangeKind = "size"
+)
+
+var changeOrder = map[ChangeKind]int{
+	ChangeReadOnly:  0,
+	ChangeUnmounted: 1,
+	ChangeMounted:   2,
+	ChangeReadWrite: 3,
+	ChangeOptions:   4,
+	ChangeSize:      5,
+}
+
+// Used space changes are significant from this share of the size, or from
+// this many bytes on large filesystems; the size itself from resizeShare
+const (
+	significantShare = 0.05
+	significantBytes = 10 << 30
+	resizeShare      = 0.01
+)
+
+// MountChange is one difference between two scans of the mount table
+type MountChange struct {
+	Kind       ChangeKind `json:"kind" yaml:"kind"`
+	MountPoint string     `json:"mount_point" yaml:"mount_point"`
+	Device     string     `json:"device" yaml:"device"`
+	Filesystem string     `json:"filesystem" yaml:"filesystem"`
+	// OptionsAdded and OptionsRemoved are the mount options that changed,
+	// for read_only, read_write and options
+	OptionsAdded   []string `json:"options_added,omitempty" yaml:"options_added,omitempty"`
+	OptionsRemoved []string `json:"options_removed,omitempty" yaml:"options_removed,omitempty"`
+	// The sizes before are 0 for mounted, those after 0 for unmounted
+	SizeBefore uint64 `json:"size_before_bytes" yaml:"size_before_bytes"`
+	SizeAfter  uint64 `json:"size_after_bytes" yaml:"size_after_bytes"`
+	UsedBefore uint64 `json:"used_before_bytes" yaml:"used_before_bytes"`
+	UsedAfter  uint64 `json:"used_after_bytes" yaml:"used_after_bytes"`
+}
+
+// CompareMounts lists what changed from one scan to the next: filesystems
+// mounted and unmounted, mount options that changed, such as a remount
+// read-only, and significant changes of size or used space. A mount point
+// that now holds another device counts as unmounted and mounted.
+func CompareMounts(before, after []Disk) []MountChange {
+	old := make(map[string]Disk, len(before))
+	for _, d := range before {
+		old[d.MountPoint] = d
+	}
+	changes := []MountChange{}
+	for _, d := range after {
+		prev, ok := old[d.MountPoint]
+		delete(old, d.MountPoint)
+		if ok && prev.Device != d.Device {
+			changes = append(changes, mountChange(ChangeUnmounted, prev, Disk{}))
+			ok = false
+		}
+		if !ok {
+			changes = append(changes, mountChange(ChangeMounted, Disk{}, d))
+			continue
+		}
+
+		added, removed := optionChanges(prev.Options, d.Options)
+		if len(added) > 0 || len(removed) > 0 {
+			kind := ChangeOptions
+			switch {
+			case IsReadOnly(d) && !IsReadOnly(prev):
+				kind = ChangeReadOnly
+			case IsReadOnly(prev) && !IsReadOnly(d):
+				kind = ChangeReadWrite
+			}
+			c := mountChange(kind, prev, d)
+			c.OptionsAdded, c.OptionsRemoved = added, removed
+			changes = append(changes, c)
+		}
+		if significantSize(prev, d) {
+			changes = append(changes, mountChange(ChangeSize, prev, d))
+		}
+	}
+	for _, d := range old {
+		changes = append(changes, mountChange(ChangeUnmounted, d, Disk{}))
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		a, b := changes[i], changes[j]
+		if a.Kind != b.Kind {
+			return changeOrder[a.Kind] < changeOrder[b.Kind]
+		}
+		return a.MountPoint < b.MountPoint
+	})
+	return changes
+}
+
+func mountChange(kind ChangeKind, before, after Disk) MountChange {
+	d := after
+	if kind == ChangeUnmounted {
+		d = before
+	}
+	return MountChange{
+		Kind:       kind,
+		MountPoint: d.MountPoint,
+		Device:     d.Device,
+		Filesystem: d.Filesystem,
+		SizeBefore: before.Size,
+		SizeAfter:  after.Size,
+		UsedBefore: before.Used,
+		UsedAfter:  after.Used,
+	}
+}
+
+// readOnlyFS are the filesystems that are read-only by design, such as
+// disc and snap images
+var readOnlyFS = map[string]bool{
+	"iso9660": true, "squashfs": true, "udf": true, "erofs": true, "cramfs": true,
+}
+
+// ReadOnlyByDesign tells whether a filesystem type can never be written,
+// so it always looks full
+func ReadOnlyByDesign(filesystem string) bool {
+	return readOnlyFS[filesystem]
+}
+
+// IsReadOnly tells whether a filesystem is mounted read-only
+func IsReadOnly(d Disk) bool {
+	for _, o := range strings.Split(d.Options, ",") {
+		if o == "ro" {
+			return true
+		}
+	}
+	return false
+}
+
+// optionChanges returns the mount options only in after and only in before
+func optionChanges(before, after string) (added, removed []string) {
+	old := map[string]bool{}
+	for _, o := range strings.Split(before, ",") {
+		old[o] = true
+	}
+	for _, o := range strings.Split(after, ",") {
+		if o != "" && !old[o] {
+			added = append(added, o)
+		}
+		delete(old, o)
+	}
+	for o := range old {
+		if o != "" {
+			removed = append(removed, o)
+		}
+	}
+	sort.Strings(removed)
+	return added, removed
+}
+
+// significantSize tells whether the size or used space of a filesystem
+// changed by enough to mention
+func significantSize(before, after Disk) bool {
+	size := max(before.Size, after.Size)
+	if size == 0 {
+		return false
+	}
+	if before.Size != after.Size && absDiff(before.Size, after.Size) >= uint64(resizeShare*float64(size)) {
+		return true
+	}
+	used := absDiff(before.Used, after.Used)
+	return used > 0 && (used >= uint64(significantShare*float64(size)) || used >= significantBytes)
+}
+
+func absDiff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
@@ -0,0 +1,298 @@
+package disk
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"checkpoint/pkg/config"
+)
+
+// SortKey orders a disk list
+type SortKey string
+
+const (
+	SortNone      SortKey = ""
+	SortSize      SortKey = "size"
+	SortUsed      SortKey = "used"
+	SortAvailable SortKey = "available"
+	SortType      SortKey = "type"
+	SortMount     SortKey = "mount"
+)
+
+// SortKeys lists the sort keys in the order the interactive view cycles them
+var SortKeys = []SortKey{SortNone, SortSize, SortUsed, SortAvailable, SortType, SortMount}
+
+// Filter narrows and orders a disk list. Zero fields match everything.
+type Filter struct {
+	Types          []DiskType `json:"types,omitempty" yaml:"types,omitempty"`
+	Filesystems    []string   `json:"filesystems,omitempty" yaml:"filesystems,omitempty"`
+	MinUsedPercent float64    `json:"min_used_percent,omitempty" yaml:"min_used_percent,omitempty"`
+	// MountGlob matches the whole mount point, or its last element when the
+	// pattern has no slash ("/mnt/*", "data*")
+	MountGlob   string  `json:"mount_glob,omitempty" yaml:"mount_glob,omitempty"`
+	MountRegexp string  `json:"mount_regexp,omitempty" yaml:"mount_regexp,omitempty"`
+	Sort        SortKey `json:"sort,omitempty" yaml:"sort,omitempty"`
+	// Reverse flips the order. Sizes sort largest first, text sorts A to Z.
+	Reverse bool `json:"reverse,omitempty" yaml:"reverse,omitempty"`
+}
+
+// IsZero reports whether the filter leaves a list untouched
+func (f Filter) IsZero() bool {
+	return len(f.Types) == 0 && len(f.Filesystems) == 0 && f.MinUsedPercent == 0 &&
+		f.MountGlob == "" && f.MountRegexp == "" && f.Sort == SortNone && !f.Reverse
+}
+
+// Describe summarises the filter for status lines
+func (f Filter) Describe() string {
+	parts := []string{}
+	if len(f.Types) > 0 {
+		types := make([]string, len(f.Types))
+		for i, t := range f.Types {
+			types[i] = string(t)
+		}
+		parts = append(parts, "type="+strings.Join(types, ","))
+	}
+	if len(f.Filesystems) > 0 {
+		parts = append(parts, "fs="+strings.Join(f.Filesystems, ","))
+	}
+	if f.MinUsedPercent > 0 {
+		parts = append(parts, fmt.Sprintf("used>=%.0f%%", f.MinUsedPercent))
+	}
+	if f.MountGlob != "" {
+		parts = append(parts, "mount="+f.MountGlob)
+	}
+	if f.MountRegexp != "" {
+		parts = append(parts, "mount=/"+f.MountRegexp+"/")
+	}
+	if f.Sort != SortNone {
+		order := "sort=" + string(f.Sort)
+		if f.Reverse {
+			order += " (reversed)"
+		}
+		parts = append(parts, order)
+	}
+	if len(parts) == 0 {
+		return "no filter"
+	}
+	return strings.Join(parts, " · ")
+}
+
+// Validate checks the patterns and names in the filter
+func (f Filter) Validate() error {
+	if _, err := f.compile(); err != nil {
+		return err
+	}
+	if f.MountGlob != "" {
+		if _, err := filepath.Match(f.MountGlob, ""); err != nil {
+			return fmt.Errorf("invalid mount glob %q: %v", f.MountGlob, err)
+		}
+	}
+	for _, t := range f.Types {
+		if !isKnownType(t) {
+			return fmt.Errorf("unknown disk type %q", t)
+		}
+	}
+	for _, k := range SortKeys {
+		if f.Sort == k {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown sort key %q (want size, used, available, type or mount)", f.Sort)
+}
+
+func (f Filter) compile() (*regexp.Regexp, error) {
+	if f.MountRegexp == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(f.MountRegexp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mount regexp %q: %v", f.MountRegexp, err)
+	}
+	return re, nil
+}
+
+// Apply returns the matching disks in the requested order. The input slice
+// is not modified.
+func (f Filter) Apply(disks []Disk) ([]Disk, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	re, _ := f.compile()
+
+	result := make([]Disk, 0, len(disks))
+	for _, d := range disks {
+		if f.matches(d, re) {
+			result = append(result, d)
+		}
+	}
+
+	if f.Sort != SortNone {
+		sort.SliceStable(result, func(i, j int) bool {
+			if f.Reverse {
+				return f.less(result[j], result[i])
+			}
+			return f.less(result[i], result[j])
+		})
+	}
+	return result, nil
+}
+
+func (f Filter) matches(d Disk, re *regexp.Regexp) bool {
+	if len(f.Types) > 0 && !containsType(f.Types, d.Type) {
+		return false
+	}
+	if len(f.Filesystems) > 0 && !containsFold(f.Filesystems, d.Filesystem) {
+		return false
+	}
+	if f.MinUsedPercent > 0 && UsedPercent(d) < f.MinUsedPercent {
+		return false
+	}
+	if f.MountGlob != "" {
+		target := d.MountPoint
+		if !strings.Contains(f.MountGlob, "/") {
+			target = filepath.Base(d.MountPoint)
+		}
+		if ok, _ := filepath.Match(f.MountGlob, target); !ok {
+			return false
+		}
+	}
+	if re != nil && !re.MatchString(d.MountPoint) {
+		return false
+	}
+	return true
+}
+
+func (f Filter) less(a, b Disk) bool {
+	switch f.Sort {
+	case SortSize:
+		return a.Size > b.Size
+	case SortUsed:
+		return UsedPercent(a) > UsedPercent(b)
+	case SortAvailable:
+		return a.Available > b.Available
+	case SortType:
+		return a.Type < b.Type
+	case SortMount:
+		return a.MountPoint < b.MountPoint
+	}
+	return false
+}
+
+// UsedPercent returns how full a disk is, 0 for disks without a size
+func UsedPercent(d Disk) float64 {
+	if d.Size == 0 {
+		return 0
+	}
+	return float64(d.Used) / float64(d.Size) * 100
+}
+
+// ParseTypes parses a comma separated list of disk types
+func ParseTypes(list string) ([]DiskType, error) {
+	types := []DiskType{}
+	for _, name := range ParseList(list) {
+		t := DiskType(strings.ToLower(name))
+		if !isKnownType(t) {
+			return nil, fmt.Errorf("unknown disk type %q", name)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// ParseList splits a comma separated flag value such as "ext4,xfs",
+// dropping empty items
+func ParseList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func isKnownType(t DiskType) bool {
+	switch t {
+	case TypePhysical, TypeLVM, TypeLoop, TypeBind, TypeNetwork,
+		TypeFUSE, TypePath, TypeManual, TypeSymlink:
+		return true
+	}
+	return false
+}
+
+func containsType(types []DiskType, t DiskType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, candidate := range list {
+		if strings.EqualFold(candidate, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// presetsFile holds the user's saved filter presets in the config directory
+const presetsFile = "filters.yaml"
+
+// BuiltinPresets are always available and can be overridden by saved presets
+var BuiltinPresets = map[string]Filter{
+	"physical": {Types: []DiskType{TypePhysical, TypeLVM}},
+	"network":  {Types: []DiskType{TypeNetwork, TypeFUSE}},
+	"full":     {MinUsedPercent: 90, Sort: SortUsed},
+	"largest":  {Sort: SortSize},
+}
+
+// LoadPresets returns the built-in presets merged with the saved ones
+func LoadPresets() (map[string]Filter, error) {
+	presets := make(map[string]Filter, len(BuiltinPresets))
+	for name, f := range BuiltinPresets {
+		presets[name] = f
+	}
+
+	saved := map[string]Filter{}
+	if err := config.Load(presetsFile, &saved); err != nil {
+		return presets, err
+	}
+	for name, f := range saved {
+		presets[name] = f
+	}
+	return presets, nil
+}
+
+// PresetNames returns preset names in a stable order
+func PresetNames(presets map[string]Filter) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SavePreset stores a filter under name, replacing any preset of that name
+func SavePreset(name string, f Filter) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("preset name is empty")
+	}
+	if err := f.Validate(); err != nil {
+		return err
+	}
+
+	saved := map[string]Filter{}
+	if err := config.Load(presetsFile, &saved); err != nil {
+		return err
+	}
+	saved[name] = f
+	return config.Save(presetsFile, saved)
+}
@@ -0,0 +1,31 @@
+package disk
+
+import "time"
+
+// Trends of a filesystem's used space
+const (
+	TrendGrowing   = "growing"
+	TrendFlat      = "flat"
+	TrendShrinking = "shrinking"
+)
+
+// Confidence levels of a forecast
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+// Forecast estimates when a filesystem will run out of space. It is worked
+// out from the usage history, so it is only set by callers that have one.
+type Forecast struct {
+	Trend string `json:"trend" yaml:"trend"`
+	// BytesPerDay is the growth rate, negative when usage shrinks
+	BytesPerDay float64 `json:"bytes_per_day" yaml:"bytes_per_day"`
+	// DaysToFull and FullAt are only set while usage grows
+	DaysToFull float64    `json:"days_to_full,omitempty" yaml:"days_to_full,omitempty"`
+	FullAt     *time.Time `json:"full_at,omitempty" yaml:"full_at,omitempty"`
+	Confidence string     `json:"confidence" yaml:"confidence"`
+	// HistoryDays is how much history the estimate is based on
+	HistoryDays float64 `json:"history_days" yaml:"history_days"`
+}
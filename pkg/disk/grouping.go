@@ -7,15 +7,19 @@ import (
 
 // DriveGroup represents a logical grouping of disks
 type DriveGroup struct {
-	Name        string
-	Icon        string
-	Type        string
-	TotalSize   uint64
-	TotalUsed   uint64
-	Available   uint64
-	Disks       []Disk
-	IsPrimary   bool
-	Description string
+	Name        string `json:"name" yaml:"name"`
+	Icon        string `json:"icon" yaml:"icon"`
+	Type        string `json:"type" yaml:"type"`
+	TotalSize   uint64 `json:"total_size_bytes" yaml:"total_size_bytes"`
+	TotalUsed   uint64 `json:"total_used_bytes" yaml:"total_used_bytes"`
+	Available   uint64 `json:"available_bytes" yaml:"available_bytes"`
+	Disks       []Disk `json:"disks" yaml:"disks"`
+	IsPrimary   bool   `json:"is_primary" yaml:"is_primary"`
+	Description string `json:"description" yaml:"description"`
+	// Forecast is set when the usage history allows an estimate
+	Forecast    *Forecast `json:"forecast,omitempty" yaml:"forecast,omitempty"`
+	// Alert is set once thresholds have been checked
+	Alert       *Alert    `json:"alert,omitempty" yaml:"alert,omitempty"`
 }
 
 // GroupDisks groups disks into logical drives for user-friendly display
@@ -215,4 +219,34 @@ func getNetworkDriveName(disk Disk) string {
 		return "Network Drive"
 	}
 	return "Remote Storage"
-}
\ No newline at end of file
+}
+
+// DriveLetter returns a Windows-style letter for the group at index,
+// starting at C: for the first (system) drive like "My Computer" does
+func DriveLetter(index int) string {
+	if index < 0 || index > 'Z'-'C' {
+		return ""
+	}
+	return fmt.Sprintf("%c:", 'C'+index)
+}
+
+// FindGroup looks up a drive group by letter ("D:"), name or mount point
+func FindGroup(groups []DriveGroup, ref string) (*DriveGroup, error) {
+	ref = strings.TrimSpace(ref)
+	for i := range groups {
+		if strings.EqualFold(DriveLetter(i), ref) || strings.EqualFold(DriveLetter(i), ref+":") {
+			return &groups[i], nil
+		}
+	}
+	for i := range groups {
+		if strings.EqualFold(groups[i].Name, ref) {
+			return &groups[i], nil
+		}
+		for _, d := range groups[i].Disks {
+			if d.MountPoint == ref {
+				return &groups[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no drive matches %q", ref)
+}
@@ -0,0 +1,182 @@
+package disk
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// IOStats are the cumulative I/O counters of a block device from
+// /proc/diskstats, counted since boot
+type IOStats struct {
+	ReadsCompleted  uint64 `json:"reads_completed" yaml:"reads_completed"`
+	ReadBytes       uint64 `json:"read_bytes" yaml:"read_bytes"`
+	WritesCompleted uint64 `json:"writes_completed" yaml:"writes_completed"`
+	WrittenBytes    uint64 `json:"written_bytes" yaml:"written_bytes"`
+	// IOTimeMs is how long the device had I/O in flight
+	IOTimeMs uint64 `json:"io_time_ms" yaml:"io_time_ms"`
+}
+
+// diskstatsSector is the unit of /proc/diskstats, whatever the device's
+// real sector size
+const diskstatsSector = 512
+
+// ReadIOStats reads the I/O counters of every block device, keyed by kernel
+// name such as "sda1" or "dm-0"
+func ReadIOStats() (map[string]IOStats, error) {
+	file, err := os.Open("/proc/diskstats")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read I/O counters: %v", err)
+	}
+	defer file.Close()
+
+	stats := map[string]IOStats{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			continue
+		}
+		n := make([]uint64, len(fields))
+		for i := 3; i < 13; i++ {
+			n[i], _ = strconv.ParseUint(fields[i], 10, 64)
+		}
+		stats[fields[2]] = IOStats{
+			ReadsCompleted:  n[3],
+			ReadBytes:       n[5] * diskstatsSector,
+			WritesCompleted: n[7],
+			WrittenBytes:    n[9] * diskstatsSector,
+			IOTimeMs:        n[12],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read I/O counters: %v", err)
+	}
+	return stats, nil
+}
+
+// KernelName is the name /proc/diskstats and /sys/block use for a device
+// path, following symlinks such as /dev/mapper/root to dm-0. It returns ""
+// for devices that are not block devices.
+func KernelName(device string) string {
+	if !strings.HasPrefix(device, "/dev/") {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	return filepath.Base(device)
+}
+
+// DiskHealth is the state of a whole disk holding one or more filesystems
+type DiskHealth struct {
+	// Device is the whole disk, e.g. /dev/sda for /dev/sda1
+	Device string `json:"device" yaml:"device"`
+	Model  string `json:"model" yaml:"model"`
+	// Health is the SMART overall assessment, "" when it could not be read
+	Health string `json:"health" yaml:"health"`
+	// Temperature is in degrees Celsius, nil when the disk does not report it
+	Temperature *float64 `json:"temperature_celsius,omitempty" yaml:"temperature_celsius,omitempty"`
+}
+
+// Healthy reports whether SMART passed the disk. It is only meaningful
+// when Health is not empty.
+func (h DiskHealth) Healthy() bool {
+	return h.Health == "PASSED" || h.Health == "OK"
+}
+
+// LoadHealth looks up the whole disks behind disks and reads their health
+// and temperature. Each disk is listed once, in the order first seen.
+func LoadHealth(disks []Disk) []DiskHealth {
+	result := []DiskHealth{}
+	seen := map[string]bool{}
+	for _, d := range disks {
+		name := KernelName(d.Device)
+		// a disk remounted read-only after errors is the one to look at
+		if name == "" || d.Type == TypeSymlink {
+			continue
+		}
+		devices, err := blockDevices("/dev/" + name)
+		if err != nil {
+			continue
+		}
+		for _, dev := range devices {
+			if dev["TYPE"] != "disk" || seen[dev["NAME"]] {
+				continue
+			}
+			seen[dev["NAME"]] = true
+			h := DiskHealth{Device: "/dev/" + dev["NAME"], Model: dev["MODEL"]}
+			h.Health, h.Temperature = smartReport(h.Device)
+			if h.Temperature == nil {
+				h.Temperature = hwmonTemperature(dev["NAME"])
+			}
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// smartReport asks smartctl for the overall health and the temperature of
+// a whole disk. It usually needs root, so failures just mean "unknown".
+func smartReport(device string) (string, *float64) {
+	output, _ := exec.Command("smartctl", "-H", "-A", device).Output()
+	health := ""
+	var temperature *float64
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		switch {
+		// ATA prints "...test result: PASSED", SCSI and NVMe "SMART Health Status: OK"
+		case strings.Contains(line, "test result:") || strings.Contains(line, "Health Status:"):
+			health = strings.TrimSpace(line[strings.LastIndex(line, ":")+1:])
+		// ATA attribute table: "194 Temperature_Celsius ... RAW_VALUE"
+		case len(fields) >= 10 && (fields[1] == "Temperature_Celsius" || fields[1] == "Airflow_Temperature_Cel"):
+			if temperature == nil {
+				temperature = parseTemperature(fields[9])
+			}
+		// NVMe and SCSI: "Temperature: 35 Celsius", "Current Drive Temperature: 35 C"
+		case (strings.HasPrefix(line, "Temperature:") || strings.HasPrefix(line, "Current Drive Temperature:")) && len(fields) >= 2:
+			temperature = parseTemperature(fields[len(fields)-2])
+		}
+	}
+	return health, temperature
+}
+
+func parseTemperature(s string) *float64 {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return validTemperature(v)
+}
+
+// validTemperature drops the zeros and garbage some drives report
+func validTemperature(celsius float64) *float64 {
+	if celsius <= 0 || celsius > 150 {
+		return nil
+	}
+	return &celsius
+}
+
+// hwmonTemperature reads the temperature the kernel exposes for NVMe drives,
+// and for SATA drives with the drivetemp module, without needing root
+func hwmonTemperature(name string) *float64 {
+	for _, pattern := range []string{"/sys/block/%s/device/hwmon*/temp1_input", "/sys/block/%s/device/hwmon/hwmon*/temp1_input"} {
+		files, _ := filepath.Glob(fmt.Sprintf(pattern, name))
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			if millidegrees, err := strconv.ParseFloat(strings.TrimSpace(string(data)), 64); err == nil {
+				return validTemperature(millidegrees / 1000)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package disk
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Properties describes the device behind a drive group, like the
+// Properties dialog of a file manager. Fields are empty when the
+// information is not available, for example on network drives.
+type Properties struct {
+	Label      string `json:"label" yaml:"label"`
+	UUID       string `json:"uuid" yaml:"uuid"`
+	Filesystem string `json:"filesystem" yaml:"filesystem"`
+	Device     string `json:"device" yaml:"device"`
+	// Parent is the whole disk holding the filesystem, e.g. /dev/sda for /dev/sda1
+	Parent     string `json:"parent" yaml:"parent"`
+	Model      string `json:"model" yaml:"model"`
+	Vendor     string `json:"vendor" yaml:"vendor"`
+	Serial     string `json:"serial" yaml:"serial"`
+	Transport  string `json:"transport" yaml:"transport"`
+	Rotational bool   `json:"rotational" yaml:"rotational"`
+	Removable  bool   `json:"removable" yaml:"removable"`
+	// Health is the SMART overall assessment, "" when it could not be read
+	Health string `json:"health" yaml:"health"`
+}
+
+// blockDevices lists the device and the devices it sits on with lsblk
+func blockDevices(device string) ([]map[string]string, error) {
+	output, err := exec.Command("lsblk", "-bsPo",
+		"NAME,TYPE,PKNAME,LABEL,UUID,FSTYPE,MODEL,VENDOR,SERIAL,TRAN,ROTA,RM", device).Output()
+	if err != nil {
+		return nil, fmt.Errorf("lsblk %s failed: %v", device, err)
+	}
+	return parsePairs(string(output)), nil
+}
+
+// LoadProperties collects the properties of the group's first disk. Only
+// block devices have hardware details; other drives get what /proc/mounts
+// already told us.
+func LoadProperties(group DriveGroup) Properties {
+	if len(group.Disks) == 0 {
+		return Properties{}
+	}
+	d := group.Disks[0]
+	props := Properties{Device: d.Device, Filesystem: d.Filesystem}
+	if !strings.HasPrefix(d.Device, "/dev/") {
+		return props
+	}
+
+	device := d.Device
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	devices, err := blockDevices(device)
+	if err != nil || len(devices) == 0 {
+		return props
+	}
+
+	// lsblk --inverse prints the device first, then the devices it sits on
+	own := devices[0]
+	props.Label = own["LABEL"]
+	props.UUID = own["UUID"]
+	if own["FSTYPE"] != "" {
+		props.Filesystem = own["FSTYPE"]
+	}
+	for _, dev := range devices {
+		if dev["TYPE"] != "disk" {
+			continue
+		}
+		props.Parent = "/dev/" + dev["NAME"]
+		props.Model = dev["MODEL"]
+		props.Vendor = dev["VENDOR"]
+		props.Serial = dev["SERIAL"]
+		props.Transport = dev["TRAN"]
+		props.Rotational = dev["ROTA"] == "1"
+		props.Removable = dev["RM"] == "1"
+		break
+	}
+	if props.Parent != "" {
+		props.Health, _ = smartReport(props.Parent)
+	}
+	return props
+}
+
+// parsePairs parses lsblk --pairs output, one map per line
+func parsePairs(output string) []map[string]string {
+	result := []map[string]string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := map[string]string{}
+		rest := strings.TrimSpace(line)
+		for rest != "" {
+			eq := strings.Index(rest, `="`)
+			if eq < 0 {
+				break
+			}
+			key := rest[:eq]
+			rest = rest[eq+2:]
+			end := strings.Index(rest, `"`)
+			if end < 0 {
+				break
+			}
+			fields[key] = unescapeLsblk(rest[:end])
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+		if len(fields) > 0 {
+			result = append(result, fields)
+		}
+	}
+	return result
+}
+
+// unescapeLsblk decodes the \xNN escapes lsblk uses for unsafe characters
+func unescapeLsblk(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return strings.TrimSpace(s)
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// FilesystemUUIDs maps device paths, with symlinks resolved, to the UUID of
+// the filesystem on them, read from /dev/disk/by-uuid
+func FilesystemUUIDs() map[string]string {
+	uuids := map[string]string{}
+	links, _ := filepath.Glob("/dev/disk/by-uuid/*")
+	for _, link := range links {
+		if device, err := filepath.EvalSymlinks(link); err == nil {
+			uuids[device] = filepath.Base(link)
+		}
+	}
+	return uuids
+}
+
+// FilesystemUUID looks up the UUID of the filesystem on d in uuids, as
+// returned by FilesystemUUIDs, or returns ""
+func FilesystemUUID(d Disk, uuids map[string]string) string {
+	device := d.Device
+	if resolved, err := filepath.EvalSymlinks(device); err == nil {
+		device = resolved
+	}
+	return uuids[device]
+}
@@ -0,0 +1,92 @@
+package disk
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// RAIDArray is a Linux software RAID array from /proc/mdstat
+type RAIDArray struct {
+	// Device is the array, e.g. /dev/md0
+	Device string `json:"device" yaml:"device"`
+	Level  string `json:"level" yaml:"level"`
+	// Members are the component devices, e.g. sda1
+	Members []string `json:"members" yaml:"members"`
+	// Status is the member map, e.g. "UU_" with one member missing
+	Status   string `json:"status" yaml:"status"`
+	Degraded bool   `json:"degraded" yaml:"degraded"`
+}
+
+// mdStatus matches the member map of an array, e.g. "[2/1] [U_]"
+var mdStatus = regexp.MustCompile(`\[(\d+)/(\d+)\] \[([U_]+)\]`)
+
+// ReadRAID lists the software RAID arrays. Without the md driver there are
+// none, which is not an error.
+func ReadRAID() ([]RAIDArray, error) {
+	file, err := os.Open("/proc/mdstat")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RAID status: %v", err)
+	}
+	defer file.Close()
+	return parseMDStat(file)
+}
+
+// parseMDStat reads arrays in the /proc/mdstat format:
+//
+//	md0 : active raid1 sdb1[1] sda1[0](F)
+//	      976630464 blocks super 1.2 [2/1] [U_]
+func parseMDStat(r io.Reader) ([]RAIDArray, error) {
+	var arrays []RAIDArray
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.HasPrefix(fields[0], "md") && fields[1] == ":" {
+			array := RAIDArray{Device: "/dev/" + fields[0]}
+			rest := fields[3:]
+			// Skip notes on the state such as "(auto-read-only)"
+			for len(rest) > 0 && strings.HasPrefix(rest[0], "(") {
+				rest = rest[1:]
+			}
+			if len(rest) > 0 && (strings.HasPrefix(rest[0], "raid") || rest[0] == "linear") {
+				array.Level, rest = rest[0], rest[1:]
+			}
+			for _, member := range rest {
+				name := member[:strings.IndexAny(member+"[", "[")]
+				array.Members = append(array.Members, name)
+				// A failed member is still listed, marked (F)
+				if strings.HasSuffix(member, "(F)") {
+					array.Degraded = true
+				}
+			}
+			// An inactive array has lost too many members to run at all
+			if fields[2] == "inactive" {
+				array.Degraded = true
+			}
+			arrays = append(arrays, array)
+			continue
+		}
+		if len(arrays) == 0 {
+			continue
+		}
+		if m := mdStatus.FindStringSubmatch(line); m != nil {
+			last := &arrays[len(arrays)-1]
+			last.Status = m[3]
+			if m[1] != m[2] || strings.Contains(m[3], "_") {
+				last.Degraded = true
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read RAID status: %v", err)
+	}
+	return arrays, nil
+}
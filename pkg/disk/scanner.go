@@ -117,7 +117,10 @@ func (m *Manager) analyzeDisk(device, mountPoint, filesystem, options string) *D
 		Size:       stat.Blocks * uint64(stat.Bsize),
 		Available:  stat.Bavail * uint64(stat.Bsize),
 		Used:       (stat.Blocks - stat.Bfree) * uint64(stat.Bsize),
+		Inodes:     stat.Files,
+		InodesFree: stat.Ffree,
 		MountPoint: mountPoint,
+		Options:    options,
 		Type:       diskType,
 		LastCheck:  time.Now(),
 	}
@@ -258,6 +261,8 @@ func (m *Manager) AddCustomPath(path string) error {
 		Size:       stat.Blocks * uint64(stat.Bsize),
 		Available:  stat.Bavail * uint64(stat.Bsize),
 		Used:       (stat.Blocks - stat.Bfree) * uint64(stat.Bsize),
+		Inodes:     stat.Files,
+		InodesFree: stat.Ffree,
 		MountPoint: absPath,
 		Type:       TypeManual,
 		Inode:      inode,
@@ -266,4 +271,39 @@ func (m *Manager) AddCustomPath(path string) error {
 
 	m.disks = append(m.disks, disk)
 	return nil
-}
\ No newline at end of file
+}
+
+// DiskForPath returns the scanned disk whose mount point holds path,
+// preferring the deepest mount point
+func (m *Manager) DiskForPath(path string) (*Disk, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		absPath = resolved
+	}
+
+	var best *Disk
+	for i := range m.disks {
+		d := &m.disks[i]
+		if d.Type == TypeSymlink || !isUnder(absPath, d.MountPoint) {
+			continue
+		}
+		if best == nil || len(d.MountPoint) > len(best.MountPoint) {
+			best = d
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no scanned disk contains %s", absPath)
+	}
+	return best, nil
+}
+
+// isUnder reports whether path equals dir or lies inside it
+func isUnder(path, dir string) bool {
+	if dir == "/" || path == dir {
+		return true
+	}
+	return strings.HasPrefix(path, strings.TrimSuffix(dir, "/")+"/")
+}
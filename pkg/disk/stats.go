@@ -7,18 +7,18 @@ import (
 
 // DiskStats holds statistics about the disks
 type DiskStats struct {
-	TotalDisks     int
-	TotalSize      uint64
-	TotalAvailable uint64
-	TotalUsed      uint64
-	DisksByType    map[DiskType]int
-	Hardlinks      map[uint64][]string // inode -> paths
-	Symlinks       []SymlinkInfo
+	TotalDisks     int                 `json:"total_disks" yaml:"total_disks"`
+	TotalSize      uint64              `json:"total_size_bytes" yaml:"total_size_bytes"`
+	TotalAvailable uint64              `json:"total_available_bytes" yaml:"total_available_bytes"`
+	TotalUsed      uint64              `json:"total_used_bytes" yaml:"total_used_bytes"`
+	DisksByType    map[DiskType]int    `json:"disks_by_type" yaml:"disks_by_type"`
+	Hardlinks      map[uint64][]string `json:"hardlinks" yaml:"hardlinks"` // inode -> paths
+	Symlinks       []SymlinkInfo       `json:"symlinks" yaml:"symlinks"`
 }
 
 type SymlinkInfo struct {
-	Source string
-	Target string
+	Source string `json:"source" yaml:"source"`
+	Target string `json:"target" yaml:"target"`
 }
 
 // GetStats analyzes disks and returns statistics
@@ -70,9 +70,9 @@ func (m *Manager) GetStats() DiskStats {
 func (s DiskStats) GetSummary() string {
 	summary := fmt.Sprintf("Storage Summary:\n")
 	summary += fmt.Sprintf("• Total disks: %d\n", s.TotalDisks)
-	summary += fmt.Sprintf("• Total capacity: %s\n", formatBytes(s.TotalSize))
-	summary += fmt.Sprintf("• Used: %s (%.1f%%)\n", formatBytes(s.TotalUsed), float64(s.TotalUsed)/float64(s.TotalSize)*100)
-	summary += fmt.Sprintf("• Available: %s\n", formatBytes(s.TotalAvailable))
+	summary += fmt.Sprintf("• Total capacity: %s\n", FormatBytes(s.TotalSize))
+	summary += fmt.Sprintf("• Used: %s (%.1f%%)\n", FormatBytes(s.TotalUsed), float64(s.TotalUsed)/float64(s.TotalSize)*100)
+	summary += fmt.Sprintf("• Available: %s\n", FormatBytes(s.TotalAvailable))
 
 	if len(s.DisksByType) > 0 {
 		summary += "\nDisk types:\n"
@@ -99,7 +99,8 @@ func (s DiskStats) GetSummary() string {
 	return summary
 }
 
-func formatBytes(bytes uint64) string {
+// FormatBytes formats a size in binary units, such as 1.5 GB
+func FormatBytes(bytes uint64) string {
 	const unit = 1024
 	if bytes < unit {
 		return fmt.Sprintf("%d B", bytes)
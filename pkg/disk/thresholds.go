@@ -0,0 +1,407 @@
+package disk
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"checkpoint/pkg/config"
+)
+
+// Level is how serious a threshold breach is. The values are the exit codes
+// of Nagios and Icinga plugins.
+type Level int
+
+const (
+	LevelOK Level = iota
+	LevelWarning
+	LevelCritical
+	LevelUnknown
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelOK:
+		return "ok"
+	case LevelWarning:
+		return "warning"
+	case LevelCritical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// MarshalText writes the level by name in JSON and YAML
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText reads a level written by MarshalText, e.g. from the daemon
+func (l *Level) UnmarshalText(text []byte) error {
+	for _, level := range []Level{LevelOK, LevelWarning, LevelCritical, LevelUnknown} {
+		if string(text) == level.String() {
+			*l = level
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid alert level %q", text)
+}
+
+// Amount is a threshold value. In the config file it is a number with the
+// unit of its kind, see AmountKind, or "off".
+type Amount float64
+
+// amountOff turns a threshold off, overriding a broader setting
+const amountOff Amount = -1
+
+// set reports whether a threshold applies
+func (a Amount) set() bool {
+	return a > 0
+}
+
+// AmountKind is what a threshold measures, which decides the units it takes
+type AmountKind int
+
+const (
+	// AmountPercent is a share of the size, "90" or "90%"
+	AmountPercent AmountKind = iota
+	// AmountBytes is a size, "512", "20G", "20GB" or "20GiB", in powers of
+	// 1024 like the sizes checkpoint shows
+	AmountBytes
+	// AmountNumber is a plain number, of inodes or days
+	AmountNumber
+)
+
+func (k AmountKind) String() string {
+	switch k {
+	case AmountPercent:
+		return "percentage"
+	case AmountBytes:
+		return "size"
+	}
+	return "number"
+}
+
+func (k AmountKind) example() string {
+	switch k {
+	case AmountPercent:
+		return "e.g. 90%"
+	case AmountBytes:
+		return "e.g. 500M or 20GB"
+	}
+	return "a plain number, e.g. 30"
+}
+
+// ParseAmount parses a threshold of a kind, or "off". A unit the kind does
+// not take is an error, so that "10%" is not taken for 10 bytes.
+func ParseAmount(s string, kind AmountKind) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "off") {
+		return amountOff, nil
+	}
+	number := s
+	multiplier := 1.0
+	switch kind {
+	case AmountPercent:
+		number = strings.TrimSuffix(s, "%")
+	case AmountBytes:
+		number = strings.TrimSuffix(strings.ToUpper(s), "B")
+		unit := strings.TrimSuffix(number, "I")
+		if n := len(unit); n > 0 {
+			if i := strings.IndexByte("KMGTP", unit[n-1]); i >= 0 {
+				multiplier = math.Pow(1024, float64(i+1))
+				number = unit[:n-1]
+			}
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || v < 0 || math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, fmt.Errorf("invalid %s %q, expected %s", kind, s, kind.example())
+	}
+	return Amount(v * multiplier), nil
+}
+
+// Limit is a warning and a critical threshold. Zero leaves a threshold to
+// the broader setting.
+type Limit struct {
+	Warning  Amount `json:"warning,omitempty" yaml:"warning,omitempty"`
+	Critical Amount `json:"critical,omitempty" yaml:"critical,omitempty"`
+}
+
+func (l Limit) merge(o Limit) Limit {
+	if o.Warning != 0 {
+		l.Warning = o.Warning
+	}
+	if o.Critical != 0 {
+		l.Critical = o.Critical
+	}
+	return l
+}
+
+// Thresholds are the limits for one filesystem. Used percent alerts at or
+// above its limits, the others at or below theirs.
+type Thresholds struct {
+	UsedPercent Limit `json:"used_percent,omitempty" yaml:"used_percent,omitempty"`
+	FreeBytes   Limit `json:"free_bytes,omitempty" yaml:"free_bytes,omitempty"`
+	FreeInodes  Limit `json:"free_inodes,omitempty" yaml:"free_inodes,omitempty"`
+	// DaysToFull applies to growing forecasts of medium or high confidence
+	DaysToFull Limit `json:"days_to_full,omitempty" yaml:"days_to_full,omitempty"`
+}
+
+// DefaultThresholds apply where the config file sets nothing
+var DefaultThresholds = Thresholds{
+	UsedPercent: Limit{Warning: 90, Critical: 95},
+	DaysToFull:  Limit{Warning: 30, Critical: 7},
+}
+
+// UnmarshalYAML reads each limit with the units of its kind and names the
+// key of a value it cannot parse
+func (t *Thresholds) UnmarshalYAML(value *yaml.Node) error {
+	var raw map[string]struct {
+		Warning  string `yaml:"warning"`
+		Critical string `yaml:"critical"`
+	}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+	limits := map[string]struct {
+		limit *Limit
+		kind  AmountKind
+	}{
+		"used_percent": {&t.UsedPercent, AmountPercent},
+		"free_bytes":   {&t.FreeBytes, AmountBytes},
+		"free_inodes":  {&t.FreeInodes, AmountNumber},
+		"days_to_full": {&t.DaysToFull, AmountNumber},
+	}
+	// the keys in file order, with their lines for the errors
+	var keys []string
+	lines := map[string]int{}
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		keys = append(keys, value.Content[i].Value)
+		lines[value.Content[i].Value] = value.Content[i].Line
+	}
+	for _, key := range keys {
+		l, ok := limits[key]
+		if !ok {
+			return fmt.Errorf("line %d: unknown threshold %q, expected used_percent, free_bytes, free_inodes or days_to_full", lines[key], key)
+		}
+		for _, v := range []struct {
+			name   string
+			text   string
+			amount *Amount
+		}{
+			{"warning", raw[key].Warning, &l.limit.Warning},
+			{"critical", raw[key].Critical, &l.limit.Critical},
+		} {
+			if v.text == "" {
+				continue
+			}
+			amount, err := ParseAmount(v.text, l.kind)
+			if err != nil {
+				return fmt.Errorf("line %d: %s %s: %v", lines[key], key, v.name, err)
+			}
+			*v.amount = amount
+		}
+	}
+	return nil
+}
+
+func (t Thresholds) merge(o Thresholds) Thresholds {
+	return Thresholds{
+		UsedPercent: t.UsedPercent.merge(o.UsedPercent),
+		FreeBytes:   t.FreeBytes.merge(o.FreeBytes),
+		FreeInodes:  t.FreeInodes.merge(o.FreeInodes),
+		DaysToFull:  t.DaysToFull.merge(o.DaysToFull),
+	}
+}
+
+// Validate checks that critical is past warning for every limit
+func (t Thresholds) Validate() error {
+	u := t.UsedPercent
+	if u.Warning > 100 || u.Critical > 100 {
+		return fmt.Errorf("used_percent thresholds must be at most 100")
+	}
+	if u.Warning.set() && u.Critical.set() && u.Critical < u.Warning {
+		return fmt.Errorf("used_percent critical (%g) is below warning (%g)", u.Critical, u.Warning)
+	}
+	below := map[string]Limit{"free_bytes": t.FreeBytes, "free_inodes": t.FreeInodes, "days_to_full": t.DaysToFull}
+	for _, name := range []string{"free_bytes", "free_inodes", "days_to_full"} {
+		l := below[name]
+		if l.Warning.set() && l.Critical.set() && l.Critical > l.Warning {
+			return fmt.Errorf("%s critical (%g) is above warning (%g)", name, l.Critical, l.Warning)
+		}
+	}
+	return nil
+}
+
+// Alert is the outcome of checking thresholds, with a reason per breach
+type Alert struct {
+	Level   Level    `json:"level" yaml:"level"`
+	Reasons []string `json:"reasons,omitempty" yaml:"reasons,omitempty"`
+}
+
+// Add folds another alert into a, keeping the worse level
+func (a *Alert) Add(o Alert) {
+	if o.Level > a.Level {
+		a.Level = o.Level
+	}
+	a.Reasons = append(a.Reasons, o.Reasons...)
+}
+
+// breach records a reason when value is past a limit. above says whether
+// the limit is a ceiling or a floor.
+func (a *Alert) breach(l Limit, value float64, above bool, describe func(level string, limit float64) string) {
+	past := func(limit Amount) bool {
+		if !limit.set() {
+			return false
+		}
+		if above {
+			return value >= float64(limit)
+		}
+		return value <= float64(limit)
+	}
+	switch {
+	case past(l.Critical):
+		a.Add(Alert{Level: LevelCritical, Reasons: []string{describe("critical", float64(l.Critical))}})
+	case past(l.Warning):
+		a.Add(Alert{Level: LevelWarning, Reasons: []string{describe("warning", float64(l.Warning))}})
+	}
+}
+
+// Monitored reports whether thresholds apply to a disk: symlinks repeat
+// another entry, pseudo filesystems have no size, and filesystems that are
+// read-only by design, such as snap and disc images, are always full. A
+// filesystem remounted read-only after errors is still monitored.
+func Monitored(d Disk) bool {
+	return d.Type != TypeSymlink && d.Size > 0 && !ReadOnlyByDesign(d.Filesystem)
+}
+
+// Check compares a filesystem, and its forecast when there is one, with t
+func (t Thresholds) Check(d Disk, f *Forecast) Alert {
+	alert := Alert{}
+	if !Monitored(d) {
+		return alert
+	}
+
+	used := float64(d.Used) / float64(d.Size) * 100
+	alert.breach(t.UsedPercent, used, true, func(level string, limit float64) string {
+		return fmt.Sprintf("%s %.1f%% used (%s at %g%%)", d.MountPoint, used, level, limit)
+	})
+	alert.breach(t.FreeBytes, float64(d.Available), false, func(level string, limit float64) string {
+		return fmt.Sprintf("%s %s free (%s below %s)", d.MountPoint, FormatBytes(d.Available), level, FormatBytes(uint64(limit)))
+	})
+	if d.Inodes > 0 {
+		alert.breach(t.FreeInodes, float64(d.InodesFree), false, func(level string, limit float64) string {
+			return fmt.Sprintf("%s %d inodes free (%s below %.0f)", d.MountPoint, d.InodesFree, level, limit)
+		})
+	}
+	if f != nil && f.Trend == TrendGrowing && f.Confidence != ConfidenceLow {
+		alert.breach(t.DaysToFull, f.DaysToFull, false, func(level string, limit float64) string {
+			return fmt.Sprintf("%s full in ~%.0f days (%s within %g days)", d.MountPoint, f.DaysToFull, level, limit)
+		})
+	}
+	return alert
+}
+
+// thresholdsFile holds the thresholds in the config directory
+const thresholdsFile = "thresholds.yaml"
+
+// ThresholdConfig is thresholds.yaml: defaults, overridden per mount point
+// (or mount glob) and per filesystem UUID, one limit at a time
+type ThresholdConfig struct {
+	Default Thresholds            `json:"default" yaml:"default"`
+	Mounts  map[string]Thresholds `json:"mounts,omitempty" yaml:"mounts,omitempty"`
+	UUIDs   map[string]Thresholds `json:"uuids,omitempty" yaml:"uuids,omitempty"`
+
+	uuids map[string]string
+}
+
+// LoadThresholds reads thresholds.yaml from the config directory. On error
+// it still returns the defaults.
+func LoadThresholds() (*ThresholdConfig, error) {
+	return loadThresholds(func(c *ThresholdConfig) error {
+		return config.Load(thresholdsFile, c)
+	})
+}
+
+// LoadThresholdsFile reads thresholds from an explicit path, see LoadThresholds
+func LoadThresholdsFile(path string) (*ThresholdConfig, error) {
+	return loadThresholds(func(c *ThresholdConfig) error {
+		return config.LoadFile(path, c)
+	})
+}
+
+func loadThresholds(load func(c *ThresholdConfig) error) (*ThresholdConfig, error) {
+	defaults := &ThresholdConfig{Default: DefaultThresholds}
+	c := &ThresholdConfig{}
+	if err := load(c); err != nil {
+		return defaults, err
+	}
+	c.Default = DefaultThresholds.merge(c.Default)
+	if err := c.Default.Validate(); err != nil {
+		return defaults, fmt.Errorf("invalid default thresholds: %v", err)
+	}
+	for mount, t := range c.Mounts {
+		if _, err := filepath.Match(mount, ""); err != nil {
+			return defaults, fmt.Errorf("invalid mount glob %q: %v", mount, err)
+		}
+		if err := c.Default.merge(t).Validate(); err != nil {
+			return defaults, fmt.Errorf("invalid thresholds for %s: %v", mount, err)
+		}
+	}
+	for uuid, t := range c.UUIDs {
+		if err := c.Default.merge(t).Validate(); err != nil {
+			return defaults, fmt.Errorf("invalid thresholds for UUID %s: %v", uuid, err)
+		}
+	}
+	return c, nil
+}
+
+// For returns the thresholds of a filesystem: the defaults, then the entry
+// for its mount point (an exact match, else the first matching glob in
+// sorted order), then the entry for its UUID
+func (c *ThresholdConfig) For(d Disk) Thresholds {
+	t := c.Default
+	if m, ok := c.Mounts[d.MountPoint]; ok {
+		t = t.merge(m)
+	} else {
+		globs := make([]string, 0, len(c.Mounts))
+		for glob := range c.Mounts {
+			globs = append(globs, glob)
+		}
+		sort.Strings(globs)
+		for _, glob := range globs {
+			if ok, _ := filepath.Match(glob, d.MountPoint); ok {
+				t = t.merge(c.Mounts[glob])
+				break
+			}
+		}
+	}
+
+	if len(c.UUIDs) > 0 {
+		if c.uuids == nil {
+			c.uuids = FilesystemUUIDs()
+		}
+		if u, ok := c.UUIDs[FilesystemUUID(d, c.uuids)]; ok {
+			t = t.merge(u)
+		}
+	}
+	return t
+}
+
+// Apply sets the alert of every group to the worst of its filesystems.
+// forecasts are keyed by mount point and may be nil.
+func (c *ThresholdConfig) Apply(groups []DriveGroup, forecasts map[string]*Forecast) {
+	for i := range groups {
+		alert := &Alert{}
+		for _, d := range groups[i].Disks {
+			alert.Add(c.For(d).Check(d, forecasts[d.MountPoint]))
+		}
+		groups[i].Alert = alert
+	}
+}
@@ -0,0 +1,129 @@
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMonitored(t *testing.T) {
+	tests := []struct {
+		name string
+		disk Disk
+		want bool
+	}{
+		{"writable", Disk{Type: TypePhysical, Filesystem: "ext4", Options: "rw", Size: 100}, true},
+		// the kernel remounts a filesystem read-only after errors, which is
+		// exactly when it needs watching
+		{"remounted read-only", Disk{Type: TypePhysical, Filesystem: "ext4", Options: "ro,relatime", Size: 100}, true},
+		{"squashfs", Disk{Type: TypePhysical, Filesystem: "squashfs", Options: "ro", Size: 100}, false},
+		{"iso9660", Disk{Type: TypePhysical, Filesystem: "iso9660", Options: "ro", Size: 100}, false},
+		{"symlink", Disk{Type: TypeSymlink, Filesystem: "ext4", Size: 100}, false},
+		{"pseudo filesystem", Disk{Type: TypePhysical, Filesystem: "proc"}, false},
+	}
+	for _, tt := range tests {
+		if got := Monitored(tt.disk); got != tt.want {
+			t.Errorf("%s: Monitored = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckReadOnlyFilesystem(t *testing.T) {
+	d := Disk{MountPoint: "/data", Type: TypePhysical, Filesystem: "ext4", Options: "ro", Size: 100, Used: 96, Available: 4}
+	if alert := DefaultThresholds.Check(d, nil); alert.Level != LevelCritical {
+		t.Errorf("read-only and full: got %s, want critical", alert.Level)
+	}
+	d.Filesystem = "squashfs"
+	if alert := DefaultThresholds.Check(d, nil); alert.Level != LevelOK {
+		t.Errorf("squashfs image: got %s, want ok", alert.Level)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		in    string
+		kind  AmountKind
+		want  Amount
+		valid bool
+	}{
+		{"90", AmountPercent, 90, true},
+		{"90%", AmountPercent, 90, true},
+		{"off", AmountPercent, amountOff, true},
+		{"90G", AmountPercent, 0, false},
+		{"512", AmountBytes, 512, true},
+		{"100B", AmountBytes, 100, true},
+		{"20G", AmountBytes, 20 << 30, true},
+		{"20GB", AmountBytes, 20 << 30, true},
+		{"1.5TiB", AmountBytes, 1.5 * (1 << 40), true},
+		{"20gib", AmountBytes, 20 << 30, true},
+		{"OFF", AmountBytes, amountOff, true},
+		{"10%", AmountBytes, 0, false},
+		{"5iB", AmountBytes, 0, false},
+		{"30", AmountNumber, 30, true},
+		{"2.5", AmountNumber, 2.5, true},
+		{"5G", AmountNumber, 0, false},
+		{"5%", AmountNumber, 0, false},
+		{"-1", AmountNumber, 0, false},
+		{"inf", AmountNumber, 0, false},
+		{"", AmountNumber, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.in, tt.kind)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("ParseAmount(%q, %s) = %g, %v", tt.in, tt.kind, got, err)
+		}
+	}
+}
+
+func TestLoadThresholdsFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		error string
+	}{
+		{"valid", `
+default:
+  used_percent: {warning: 80%, critical: 90}
+  free_bytes: {warning: 20GB, critical: 5GB}
+mounts:
+  /boot:
+    used_percent: {warning: off, critical: 98%}
+  "/mnt/*":
+    free_inodes: {warning: 100000, critical: 10000}
+    days_to_full: {warning: 60}
+`, ""},
+		{"percent for bytes", "default:\n  free_bytes: {warning: 10%}\n", `line 2: free_bytes warning: invalid size "10%"`},
+		{"size for days", "mounts:\n  /home:\n    days_to_full: {critical: 5G}\n", `line 3: days_to_full critical: invalid number "5G"`},
+		{"size for inodes", "default:\n  free_inodes: {warning: 10k}\n", `free_inodes warning: invalid number "10k"`},
+		{"unknown key", "default:\n  free_percent: {warning: 10}\n", `unknown threshold "free_percent"`},
+		{"critical below warning", "default:\n  used_percent: {warning: 90, critical: 80}\n", "critical (80) is below warning (90)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "thresholds.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			c, err := LoadThresholdsFile(path)
+			if tt.error == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := c.For(Disk{MountPoint: "/boot"}).UsedPercent; got.Warning != amountOff || got.Critical != 98 {
+					t.Errorf("/boot used_percent = %+v", got)
+				}
+				if got := c.For(Disk{MountPoint: "/mnt/usb"}); got.FreeBytes.Warning != 20<<30 || got.FreeInodes.Critical != 10000 || got.DaysToFull.Warning != 60 {
+					t.Errorf("/mnt/usb = %+v", got)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("error = %v, want %q", err, tt.error)
+			}
+			if c == nil || c.Default != DefaultThresholds {
+				t.Errorf("got %+v, want the defaults", c)
+			}
+		})
+	}
+}
@@ -0,0 +1,381 @@
+package disk
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// TrashDirs returns the trash directories of the current user that live on
+// the given mount point: the home trash when the home directory is on it,
+// and the per-mount .Trash/$uid and .Trash-$uid directories.
+func TrashDirs(mountPoint string) []string {
+	dirs := []string{}
+	uid := strconv.Itoa(os.Getuid())
+
+	if home := homeTrash(); home != "" && onMount(home, mountPoint) {
+		dirs = append(dirs, home)
+	}
+
+	for _, dir := range []string{
+		filepath.Join(mountPoint, ".Trash", uid),
+		filepath.Join(mountPoint, ".Trash-"+uid),
+	} {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// onMount reports whether an existing path lives on the filesystem mounted
+// at mountPoint
+func onMount(path, mountPoint string) bool {
+	var pathStat, mountStat os.FileInfo
+	var err error
+	if pathStat, err = os.Stat(path); err != nil {
+		return false
+	}
+	if mountStat, err = os.Stat(mountPoint); err != nil {
+		return false
+	}
+	return deviceID(pathStat) == deviceID(mountStat)
+}
+
+func deviceID(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Dev)
+	}
+	return 0
+}
+
+// TrashSize returns the bytes and number of items in the given trash
+// directories
+func TrashSize(dirs []string) (uint64, int) {
+	var size uint64
+	items := 0
+	for _, dir := range dirs {
+		entries, _ := os.ReadDir(filepath.Join(dir, "files"))
+		items += len(entries)
+		size += treeSize(filepath.Join(dir, "files"))
+	}
+	return size, items
+}
+
+// EmptyTrash permanently deletes everything in the given trash directories,
+// keeping the directories themselves
+func EmptyTrash(dirs []string) error {
+	for _, dir := range dirs {
+		for _, sub := range []string{"files", "info"} {
+			entries, err := os.ReadDir(filepath.Join(dir, sub))
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				if err := os.RemoveAll(filepath.Join(dir, sub, e.Name())); err != nil {
+					return fmt.Errorf("failed to empty %s: %v", dir, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MoveToTrash moves a file or directory into the trash of the filesystem it
+// lives on, as the freedesktop.org trash specification describes, so it can
+// be restored from any file manager. Files on the home filesystem go to the
+// home trash; others go to $topdir/.Trash/$uid or $topdir/.Trash-$uid.
+func MoveToTrash(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", path, err)
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("failed to trash %s: %v", path, err)
+	}
+
+	trash, topdir, err := trashFor(path, deviceID(info))
+	if err != nil {
+		return fmt.Errorf("failed to trash %s: %v", path, err)
+	}
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(trash, sub), 0o700); err != nil {
+			return fmt.Errorf("failed to create trash %s: %v", trash, err)
+		}
+	}
+
+	// The home trash records absolute paths, the others paths relative to
+	// the top directory, so a drive mounted elsewhere still restores
+	original := path
+	if topdir != "" {
+		if rel, err := filepath.Rel(topdir, path); err == nil {
+			original = rel
+		}
+	}
+	name, infoFile, err := claimTrashName(trash, filepath.Base(path), original)
+	if err != nil {
+		return fmt.Errorf("failed to trash %s: %v", path, err)
+	}
+	if err := os.Rename(path, filepath.Join(trash, "files", name)); err != nil {
+		os.Remove(infoFile)
+		return fmt.Errorf("failed to trash %s: %v", path, err)
+	}
+	return nil
+}
+
+// trashFor picks the trash for a path on device dev, returning the top
+// directory for per-mount trashes and "" for the home trash
+func trashFor(path string, dev uint64) (string, string, error) {
+	home := homeTrash()
+	if home != "" {
+		// The home trash may not exist yet; its parent decides the filesystem
+		for dir := home; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if info, err := os.Stat(dir); err == nil {
+				if deviceID(info) == dev {
+					return home, "", nil
+				}
+				break
+			}
+		}
+	}
+
+	topdir := topDir(path, dev)
+	uid := strconv.Itoa(os.Getuid())
+	// An administrator-made .Trash must be a sticky directory, not a link
+	if info, err := os.Lstat(filepath.Join(topdir, ".Trash")); err == nil &&
+		info.IsDir() && info.Mode()&os.ModeSticky != 0 {
+		return filepath.Join(topdir, ".Trash", uid), topdir, nil
+	}
+	return filepath.Join(topdir, ".Trash-"+uid), topdir, nil
+}
+
+// homeTrash is $XDG_DATA_HOME/Trash, defaulting to ~/.local/share/Trash
+func homeTrash() string {
+	if dataHome := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dataHome) {
+		return filepath.Join(dataHome, "Trash")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".local", "share", "Trash")
+	}
+	return ""
+}
+
+// topDir is the mount point of the filesystem holding path, found as the
+// highest parent still on device dev
+func topDir(path string, dev uint64) string {
+	top := filepath.Dir(path)
+	for dir := top; dir != filepath.Dir(dir); {
+		dir = filepath.Dir(dir)
+		info, err := os.Stat(dir)
+		if err != nil || deviceID(info) != dev {
+			break
+		}
+		top = dir
+	}
+	return top
+}
+
+// claimTrashName creates the .trashinfo file under a free name, which
+// reserves the name in files as well
+func claimTrashName(trash, base, original string) (string, string, error) {
+	content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: original}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	for i := 1; i < 10000; i++ {
+		name := base
+		if i > 1 {
+			name = fmt.Sprintf("%s.%d%s", stem, i, ext)
+		}
+		if _, err := os.Lstat(filepath.Join(trash, "files", name)); err == nil {
+			continue
+		}
+		infoFile := filepath.Join(trash, "info", name+".trashinfo")
+		f, err := os.OpenFile(infoFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		_, err = f.WriteString(content)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(infoFile)
+			return "", "", err
+		}
+		return name, infoFile, nil
+	}
+	return "", "", fmt.Errorf("no free name for %s in %s", base, trash)
+}
+
+// TrashItem is a file or directory in a trash directory
+type TrashItem struct {
+	// Name is the item's name in the trash's files directory, unique in it
+	Name string `json:"name" yaml:"name"`
+	// Trash is the trash directory holding it
+	Trash string `json:"trash" yaml:"trash"`
+	// Path is where it was deleted from
+	Path      string    `json:"path" yaml:"path"`
+	DeletedAt time.Time `json:"deleted_at" yaml:"deleted_at"`
+	Size      uint64    `json:"size_bytes" yaml:"size_bytes"`
+	IsDir     bool      `json:"is_dir" yaml:"is_dir"`
+	// MountPoint is the filesystem the trash is on, when the caller knows it
+	MountPoint string `json:"mount_point,omitempty" yaml:"mount_point,omitempty"`
+}
+
+// GroupTrashDirs returns the trash directories on every mount point of a
+// drive group, each once
+func GroupTrashDirs(group DriveGroup) []string {
+	seen := map[string]bool{}
+	dirs := []string{}
+	for _, d := range group.Disks {
+		for _, dir := range TrashDirs(d.MountPoint) {
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// ListTrash lists the items in the given trash directories, most recently
+// deleted first. Files without a readable .trashinfo are left out, as the
+// specification asks.
+func ListTrash(dirs []string) ([]TrashItem, error) {
+	var items []TrashItem
+	for _, dir := range dirs {
+		// Not a glob: mount points like "Backup [2024]" are not patterns
+		entries, err := os.ReadDir(filepath.Join(dir, "info"))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutSuffix(entry.Name(), ".trashinfo")
+			if !ok || entry.IsDir() {
+				continue
+			}
+			infoFile := filepath.Join(dir, "info", entry.Name())
+			stat, err := os.Lstat(filepath.Join(dir, "files", name))
+			if err != nil {
+				continue
+			}
+			path, deleted, err := readTrashInfo(infoFile)
+			if err != nil {
+				continue
+			}
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(trashTopDir(dir), path)
+			}
+			items = append(items, TrashItem{
+				Name:      name,
+				Trash:     dir,
+				Path:      path,
+				DeletedAt: deleted,
+				Size:      treeSize(filepath.Join(dir, "files", name)),
+				IsDir:     stat.IsDir(),
+			})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// readTrashInfo parses a .trashinfo file
+func readTrashInfo(file string) (string, time.Time, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var path string
+	var deleted time.Time
+	inGroup := false
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inGroup = line == "[Trash Info]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inGroup || !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Path":
+			if path, err = url.PathUnescape(strings.TrimSpace(value)); err != nil {
+				return "", time.Time{}, fmt.Errorf("invalid path in %s: %v", file, err)
+			}
+		case "DeletionDate":
+			// The date is local time without a zone; unparsable dates are
+			// left zero rather than hiding the item
+			deleted, _ = time.ParseInLocation("2006-01-02T15:04:05", strings.TrimSpace(value), time.Local)
+		}
+	}
+	if path == "" {
+		return "", time.Time{}, fmt.Errorf("no Path in %s", file)
+	}
+	return path, deleted, nil
+}
+
+// trashTopDir is the directory relative paths in a per-mount trash start
+// from: the parent of .Trash-$uid, or of .Trash for .Trash/$uid
+func trashTopDir(trash string) string {
+	parent := filepath.Dir(trash)
+	if filepath.Base(parent) == ".Trash" {
+		return filepath.Dir(parent)
+	}
+	return parent
+}
+
+// treeSize adds up the sizes of the files below path
+func treeSize(path string) uint64 {
+	var size uint64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size
+}
+
+// RestoreTrash puts an item back where it was deleted from, recreating
+// missing parent directories. It refuses to overwrite anything there.
+func RestoreTrash(item TrashItem) error {
+	if _, err := os.Lstat(item.Path); err == nil {
+		return fmt.Errorf("cannot restore %s: something else is there now", item.Path)
+	}
+	if err := os.MkdirAll(filepath.Dir(item.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to restore %s: %v", item.Path, err)
+	}
+	if err := os.Rename(filepath.Join(item.Trash, "files", item.Name), item.Path); err != nil {
+		return fmt.Errorf("failed to restore %s: %v", item.Path, err)
+	}
+	if err := os.Remove(filepath.Join(item.Trash, "info", item.Name+".trashinfo")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("restored %s but failed to update the trash: %v", item.Path, err)
+	}
+	return nil
+}
+
+// DeleteTrash permanently deletes an item from the trash
+func DeleteTrash(item TrashItem) error {
+	if err := os.RemoveAll(filepath.Join(item.Trash, "files", item.Name)); err != nil {
+		return fmt.Errorf("failed to delete %s: %v", item.Name, err)
+	}
+	// The info file goes last, so a failure leaves the item listed
+	if err := os.Remove(filepath.Join(item.Trash, "info", item.Name+".trashinfo")); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete %s: %v", item.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package disk
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupTrash points the home trash into a temporary directory, whose name
+// is also a glob pattern, and returns a directory to trash files from
+func setupTrash(t *testing.T) (trash, work string) {
+	t.Helper()
+	root := filepath.Join(t.TempDir(), "Backup [2024]")
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "data"))
+	work = filepath.Join(root, "work")
+	if err := os.MkdirAll(work, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return homeTrash(), work
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func listTrash(t *testing.T, trash string) map[string]TrashItem {
+	t.Helper()
+	items, err := ListTrash([]string{trash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := map[string]TrashItem{}
+	for _, item := range items {
+		byPath[item.Path] = item
+	}
+	return byPath
+}
+
+func TestMoveToTrashAndList(t *testing.T) {
+	trash, work := setupTrash(t)
+	file := filepath.Join(work, "notes 100%.txt")
+	dir := filepath.Join(work, "photos")
+	writeFile(t, file, "hello")
+	writeFile(t, filepath.Join(dir, "a.jpg"), "1234567890")
+
+	for _, path := range []string{file, dir} {
+		if err := MoveToTrash(path); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s is still there: %v", path, err)
+		}
+	}
+
+	items := listTrash(t, trash)
+	if len(items) != 2 {
+		t.Fatalf("got %d items, want 2: %+v", len(items), items)
+	}
+	if got := items[file]; got.Name != "notes 100%.txt" || got.Size != 5 || got.IsDir || got.Trash != trash {
+		t.Errorf("file item = %+v", got)
+	}
+	if got := items[dir]; got.Name != "photos" || got.Size != 10 || !got.IsDir {
+		t.Errorf("directory item = %+v", got)
+	}
+	if size, count := TrashSize([]string{trash}); size != 15 || count != 2 {
+		t.Errorf("TrashSize = %d bytes, %d items, want 15, 2", size, count)
+	}
+}
+
+func TestListTrashSkipsItemsWithoutInfo(t *testing.T) {
+	trash, work := setupTrash(t)
+	file := filepath.Join(work, "a.txt")
+	writeFile(t, file, "a")
+	if err := MoveToTrash(file); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(trash, "files", "orphan.txt"), "x")
+	writeFile(t, filepath.Join(trash, "info", "gone.txt.trashinfo"), "[Trash Info]\nPath=/gone.txt\n")
+	writeFile(t, filepath.Join(trash, "info", "README"), "not an info file")
+
+	items := listTrash(t, trash)
+	if len(items) != 1 || items[file].Name != "a.txt" {
+		t.Errorf("got %+v, want only a.txt", items)
+	}
+}
+
+func TestRestoreTrash(t *testing.T) {
+	trash, work := setupTrash(t)
+	file := filepath.Join(work, "deep", "dir", "report.txt")
+	writeFile(t, file, "numbers")
+	if err := MoveToTrash(file); err != nil {
+		t.Fatal(err)
+	}
+	// the parent directory is gone too and must be recreated
+	if err := os.RemoveAll(filepath.Join(work, "deep")); err != nil {
+		t.Fatal(err)
+	}
+
+	item := listTrash(t, trash)[file]
+	if err := RestoreTrash(item); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(file); err != nil || string(data) != "numbers" {
+		t.Errorf("restored file = %q, %v", data, err)
+	}
+	if len(listTrash(t, trash)) != 0 {
+		t.Error("the restored item is still listed")
+	}
+	if _, err := os.Lstat(filepath.Join(trash, "info", "report.txt.trashinfo")); !os.IsNotExist(err) {
+		t.Errorf("info file left behind: %v", err)
+	}
+}
+
+func TestTrashNameCollision(t *testing.T) {
+	trash, work := setupTrash(t)
+	first := filepath.Join(work, "a", "notes.txt")
+	second := filepath.Join(work, "b", "notes.txt")
+	writeFile(t, first, "first")
+	writeFile(t, second, "second")
+	for _, path := range []string{first, second} {
+		if err := MoveToTrash(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	items := listTrash(t, trash)
+	if items[first].Name != "notes.txt" || items[second].Name != "notes.2.txt" {
+		t.Fatalf("names = %q, %q, want notes.txt, notes.2.txt", items[first].Name, items[second].Name)
+	}
+	for path, want := range map[string]string{first: "first", second: "second"} {
+		if err := RestoreTrash(items[path]); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != want {
+			t.Errorf("%s = %q, want %q", path, data, want)
+		}
+	}
+}
+
+func TestRestoreTrashRefusesToOverwrite(t *testing.T) {
+	trash, work := setupTrash(t)
+	file := filepath.Join(work, "todo.txt")
+	writeFile(t, file, "old")
+	if err := MoveToTrash(file); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, file, "new")
+
+	item := listTrash(t, trash)[file]
+	if err := RestoreTrash(item); err == nil {
+		t.Fatal("restore overwrote a file that took the item's place")
+	}
+	if data, _ := os.ReadFile(file); string(data) != "new" {
+		t.Errorf("%s = %q, want it untouched", file, data)
+	}
+	if len(listTrash(t, trash)) != 1 {
+		t.Error("the item left the trash")
+	}
+}
+
+func TestDeleteAndEmptyTrash(t *testing.T) {
+	trash, work := setupTrash(t)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := filepath.Join(work, name)
+		writeFile(t, path, name)
+		if err := MoveToTrash(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	item := listTrash(t, trash)[filepath.Join(work, "a.txt")]
+	if err := DeleteTrash(item); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		filepath.Join(trash, "files", "a.txt"),
+		filepath.Join(trash, "info", "a.txt.trashinfo"),
+	} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind: %v", path, err)
+		}
+	}
+	if items := listTrash(t, trash); len(items) != 2 {
+		t.Errorf("got %d items after deleting one, want 2", len(items))
+	}
+
+	if err := EmptyTrash([]string{trash}); err != nil {
+		t.Fatal(err)
+	}
+	if items := listTrash(t, trash); len(items) != 0 {
+		t.Errorf("got %d items after emptying", len(items))
+	}
+	if info, err := os.Stat(filepath.Join(trash, "files")); err != nil || !info.IsDir() {
+		t.Errorf("emptying removed the trash itself: %v", err)
+	}
+}
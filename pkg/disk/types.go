@@ -3,18 +3,23 @@ package disk
 import "time"
 
 type Disk struct {
-	Path       string
-	Filesystem string
-	Size       uint64
-	Available  uint64
-	Used       uint64
-	MountPoint string
-	Type       DiskType
-	IsSymlink  bool
-	LinkTarget string
-	Inode      uint64
-	Device     string
-	LastCheck  time.Time
+	Path       string    `json:"path" yaml:"path"`
+	Filesystem string    `json:"filesystem" yaml:"filesystem"`
+	Size       uint64    `json:"size_bytes" yaml:"size_bytes"`
+	Available  uint64    `json:"available_bytes" yaml:"available_bytes"`
+	Used       uint64    `json:"used_bytes" yaml:"used_bytes"`
+	MountPoint string    `json:"mount_point" yaml:"mount_point"`
+	Options    string    `json:"mount_options" yaml:"mount_options"`
+	Type       DiskType  `json:"type" yaml:"type"`
+	IsSymlink  bool      `json:"is_symlink" yaml:"is_symlink"`
+	LinkTarget string    `json:"link_target" yaml:"link_target"`
+	Inode      uint64    `json:"inode" yaml:"inode"`
+	// Inodes and InodesFree count the filesystem's inodes, 0 when it has no
+	// fixed number of them (btrfs, most network filesystems)
+	Inodes     uint64    `json:"inodes" yaml:"inodes"`
+	InodesFree uint64    `json:"inodes_free" yaml:"inodes_free"`
+	Device     string    `json:"device" yaml:"device"`
+	LastCheck  time.Time `json:"last_check" yaml:"last_check"`
 }
 
 type DiskType string
@@ -35,6 +40,10 @@ type Manager struct {
 	disks     []Disk
 	lastScan  time.Time
 	scanCache map[string]bool
+
+	// the scan ClearDisks replaced, kept to tell what changed
+	previous     []Disk
+	previousScan time.Time
 }
 
 func NewManager() *Manager {
@@ -48,11 +57,36 @@ func (m *Manager) GetDisks() []Disk {
 	return m.disks
 }
 
+// LastScan returns when ScanDisks last ran
+func (m *Manager) LastScan() time.Time {
+	return m.lastScan
+}
+
 func (m *Manager) AddDisk(disk Disk) {
 	m.disks = append(m.disks, disk)
 }
 
+// ClearDisks empties the manager for a rescan. The disks of the last scan
+// are kept for Changes.
 func (m *Manager) ClearDisks() {
+	if !m.lastScan.IsZero() {
+		m.previous, m.previousScan = m.disks, m.lastScan
+	}
 	m.disks = make([]Disk, 0)
 	m.scanCache = make(map[string]bool)
+}
+
+// Previous returns the disks of the scan before the last, and when it ran;
+// the time is zero before the first rescan
+func (m *Manager) Previous() ([]Disk, time.Time) {
+	return m.previous, m.previousScan
+}
+
+// Changes compares the last scan with the one before, see CompareMounts.
+// It returns nothing before the first rescan.
+func (m *Manager) Changes() []MountChange {
+	if m.previousScan.IsZero() {
+		return []MountChange{}
+	}
+	return CompareMounts(m.previous, m.disks)
 }
\ No newline at end of file
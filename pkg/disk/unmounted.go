@@ -5,17 +5,18 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 )
 
 // UnmountedDisk represents a disk that is not currently mounted
 type UnmountedDisk struct {
-	Device     string
-	Size       string
-	Type       string
-	Label      string
-	UUID       string
-	Filesystem string
+	Device     string `json:"device" yaml:"device"`
+	Size       uint64 `json:"size_bytes" yaml:"size_bytes"`
+	Type       string `json:"type" yaml:"type"`
+	Label      string `json:"label" yaml:"label"`
+	UUID       string `json:"uuid" yaml:"uuid"`
+	Filesystem string `json:"filesystem" yaml:"filesystem"`
 }
 
 // ScanUnmountedDisks finds disks that are not currently mounted
@@ -23,7 +24,7 @@ func ScanUnmountedDisks() ([]UnmountedDisk, error) {
 	unmounted := []UnmountedDisk{}
 	
 	// Get all block devices using lsblk
-	cmd := exec.Command("lsblk", "-rno", "NAME,SIZE,TYPE,LABEL,UUID,FSTYPE")
+	cmd := exec.Command("lsblk", "-brno", "NAME,SIZE,TYPE,LABEL,UUID,FSTYPE")
 	output, err := cmd.Output()
 	if err != nil {
 		// If lsblk is not available, return empty list
@@ -55,7 +56,7 @@ func ScanUnmountedDisks() ([]UnmountedDisk, error) {
 		}
 
 		name := fields[0]
-		size := fields[1]
+		size, _ := strconv.ParseUint(fields[1], 10, 64)
 		diskType := fields[2]
 		
 		// Skip if not a disk or partition
@@ -102,6 +103,47 @@ func ScanUnmountedDisks() ([]UnmountedDisk, error) {
 	return unmounted, nil
 }
 
+// MountDevice mounts a block device through udisks, which lets desktop users
+// mount removable and secondary disks without sudo. It returns the mount point.
+func MountDevice(device string) (string, error) {
+	if _, err := exec.LookPath("udisksctl"); err != nil {
+		return "", fmt.Errorf("udisksctl not found, mount %s manually with 'sudo mount'", device)
+	}
+
+	output, err := exec.Command("udisksctl", "mount", "--no-user-interaction", "-b", device).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to mount %s: %s", device, strings.TrimSpace(string(output)))
+	}
+
+	// udisksctl prints "Mounted /dev/sdb1 at /media/user/LABEL"
+	message := strings.TrimSpace(string(output))
+	if i := strings.Index(message, " at "); i >= 0 {
+		return strings.TrimSuffix(message[i+4:], "."), nil
+	}
+	return "", nil
+}
+
+// UnmountDevice safely removes a block device through udisks: it unmounts
+// the filesystem and, for removable disks, powers the disk off so it can be
+// unplugged.
+func UnmountDevice(device, parent string, powerOff bool) error {
+	if _, err := exec.LookPath("udisksctl"); err != nil {
+		return fmt.Errorf("udisksctl not found, unmount %s manually with 'sudo umount'", device)
+	}
+
+	output, err := exec.Command("udisksctl", "unmount", "--no-user-interaction", "-b", device).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to unmount %s: %s", device, strings.TrimSpace(string(output)))
+	}
+	if powerOff && parent != "" {
+		output, err := exec.Command("udisksctl", "power-off", "--no-user-interaction", "-b", parent).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("unmounted %s but failed to power off %s: %s", device, parent, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
 // GetMountableDirectories returns directories that could be mount points
 func GetMountableDirectories() []string {
 	suggestions := []string{}
@@ -0,0 +1,51 @@
+package disk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// WatchMounts calls changed whenever a filesystem is mounted or unmounted,
+// until ctx is done. The kernel flags /proc/self/mounts with priority data
+// on every change of the mount table, which epoll can wait for.
+func WatchMounts(ctx context.Context, changed func()) error {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return fmt.Errorf("failed to watch mounts: %v", err)
+	}
+	defer file.Close()
+
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("failed to watch mounts: %v", err)
+	}
+	defer syscall.Close(epfd)
+	fd := int(file.Fd())
+	event := syscall.EpollEvent{Events: syscall.EPOLLPRI | syscall.EPOLLERR, Fd: int32(fd)}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+		return fmt.Errorf("failed to watch mounts: %v", err)
+	}
+
+	events := make([]syscall.EpollEvent, 1)
+	for ctx.Err() == nil {
+		// Wake up every second to notice ctx
+		n, err := syscall.EpollWait(epfd, events, 1000)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to watch mounts: %v", err)
+		}
+		if n == 0 {
+			continue
+		}
+		// The flag stays up until the table is read again from the start
+		file.Seek(0, io.SeekStart)
+		io.Copy(io.Discard, file)
+		changed()
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"checkpoint/pkg/disk"
+)
+
+// Inodes run short below these shares of free inodes
+const (
+	inodesWarning  = 0.05
+	inodesCritical = 0.01
+)
+
+// networkFS are the filesystems reached over the network
+var networkFS = map[string]bool{
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true,
+	"ceph": true, "glusterfs": true, "fuse.sshfs": true, "fuse.glusterfs": true,
+	"fuse.rclone": true, "davfs": true, "9p": true,
+}
+
+// realDevice tells whether a mount is of a block device rather than of a
+// virtual filesystem, a loop image or the network
+func realDevice(device string) bool {
+	return strings.HasPrefix(device, "/dev/") && !strings.HasPrefix(device, "/dev/loop")
+}
+
+// localDisks are the mounted filesystems on block devices
+func localDisks(sys *System) []disk.Disk {
+	var disks []disk.Disk
+	for _, d := range sys.Disks {
+		switch d.Type {
+		case disk.TypePhysical, disk.TypeLVM, disk.TypeBind:
+			if realDevice(d.Device) {
+				disks = append(disks, d)
+			}
+		}
+	}
+	return disks
+}
+
+func checkRootFull(sys *System) ([]Finding, error) {
+	for _, d := range sys.Disks {
+		if d.MountPoint != "/" {
+			continue
+		}
+		// free inodes are the inodes check's business
+		d.Inodes, d.InodesFree = 0, 0
+		alert := sys.Thresholds.For(d).Check(d, nil)
+		if alert.Level == disk.LevelOK {
+			return nil, nil
+		}
+		return []Finding{{
+			Level:   alert.Level,
+			Subject: "/",
+			Problem: fmt.Sprintf("The system drive is %.0f%% full, with %s left. When it fills up, programs can no longer save files, updates fail halfway and the system may not start properly.",
+				disk.UsedPercent(d), disk.FormatBytes(d.Available)),
+			Fix: "Run \"checkpoint clean /\" to find caches, old logs and packages that are safe to remove, or \"checkpoint analyze /\" to see what takes the space.",
+		}}, nil
+	}
+	return nil, fmt.Errorf("/ is not among the scanned filesystems")
+}
+
+func checkInodes(sys *System) ([]Finding, error) {
+	var findings []Finding
+	for _, d := range localDisks(sys) {
+		// read-only images such as squashfs report no free inodes
+		if d.Inodes == 0 || disk.IsReadOnly(d) || disk.ReadOnlyByDesign(d.Filesystem) {
+			continue
+		}
+		free := float64(d.InodesFree) / float64(d.Inodes)
+		level := disk.LevelOK
+		switch {
+		case free < inodesCritical:
+			level = disk.LevelCritical
+		case free < inodesWarning:
+			level = disk.LevelWarning
+		}
+		if level == disk.LevelOK {
+			continue
+		}
+		findings = append(findings, Finding{
+			Level:   level,
+			Subject: d.MountPoint,
+			Problem: fmt.Sprintf("%s has only %d of %d inodes left (%.1f%%). Every file takes one, so new files cannot be created once they run out, even with free space left.",
+				d.MountPoint, d.InodesFree, d.Inodes, free*100),
+			Fix: fmt.Sprintf("Find the folders holding the most files, often caches, mail queues or session files, with \"du --inodes -x %s | sort -n | tail\" and remove what is not needed. The number of inodes is fixed when the filesystem is created.", d.MountPoint),
+		})
+	}
+	return findings, nil
+}
+
+func checkReadOnly(sys *System) ([]Finding, error) {
+	mounts, err := sys.Mounts()
+	if err != nil {
+		return nil, err
+	}
+	fstab, err := sys.Fstab()
+	if err != nil {
+		return nil, err
+	}
+	// fstab says which filesystems should be writable; others count when
+	// they ask the kernel to remount them read-only on errors
+	writableInFstab := map[string]bool{}
+	for _, e := range fstab {
+		writableInFstab[e.MountPoint] = !e.HasOption("ro")
+	}
+	// a filesystem the kernel remounted read-only is read-only everywhere,
+	// so a device also mounted writable was made read-only on purpose
+	writable := map[string]bool{}
+	for _, m := range mounts {
+		if !m.HasOption("ro") {
+			writable[m.Device] = true
+		}
+	}
+
+	var findings []Finding
+	seen := map[string]bool{}
+	for _, m := range mounts {
+		if !m.HasOption("ro") || !realDevice(m.Device) || disk.ReadOnlyByDesign(m.Filesystem) || writable[m.Device] || seen[m.Device] {
+			continue
+		}
+		expected, inFstab := writableInFstab[m.MountPoint]
+		if !expected && (inFstab || !strings.Contains(m.Options, "errors=remount-ro")) {
+			continue
+		}
+		seen[m.Device] = true
+		findings = append(findings, Finding{
+			Level:   disk.LevelCritical,
+			Subject: m.MountPoint,
+			Problem: fmt.Sprintf("%s (%s) is read-only, although it should be writable. The kernel does this to protect a filesystem after errors, often from a failing disk or cable.", m.MountPoint, m.Device),
+			Fix: fmt.Sprintf("Look for the cause with \"dmesg | grep -i %s\" and back up what matters. Then unmount it and repair it with \"fsck %s\" before mounting it again.",
+				filepath.Base(m.Device), m.Device),
+		})
+	}
+	return findings, nil
+}
+
+func checkStaleNetwork(sys *System) ([]Finding, error) {
+	mounts, err := sys.Mounts()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, m := range mounts {
+		if !networkFS[m.Filesystem] {
+			continue
+		}
+		if err := sys.Statfs(m.MountPoint); err != nil {
+			findings = append(findings, Finding{
+				Level:   disk.LevelCritical,
+				Subject: m.MountPoint,
+				Problem: fmt.Sprintf("%s (%s) does not answer: %v. Programs that touch it hang, including file managers and shells listing the folder above.", m.MountPoint, m.Device, err),
+				Fix:     fmt.Sprintf("Check that the server and the network are up. If it does not come back, detach it with \"umount -l %s\" (\"umount -f\" for NFS) and mount it again; the soft or timeo options keep NFS from hanging forever.", m.MountPoint),
+			})
+		}
+	}
+	return findings, nil
+}
+
+func checkMountedOver(sys *System) ([]Finding, error) {
+	var findings []Finding
+	for _, d := range localDisks(sys) {
+		if d.MountPoint == "/" {
+			continue
+		}
+		names, err := sys.Covered(d.MountPoint)
+		if err != nil {
+			return findings, err
+		}
+		if len(names) == 0 {
+			continue
+		}
+		shown := names
+		if len(shown) > 3 {
+			shown = append(shown[:3:3], "…")
+		}
+		findings = append(findings, Finding{
+			Level:   disk.LevelWarning,
+			Subject: d.MountPoint,
+			Problem: fmt.Sprintf("%s is mounted over a folder that is not empty: %d files (%s) are hidden beneath it and still take space on the drive below. This usually means something wrote there while the drive was not mounted.",
+				d.MountPoint, len(names), strings.Join(shown, ", ")),
+			Fix: fmt.Sprintf("Unmount %s, move the files that show up in the folder somewhere else and mount it again. To see them without unmounting, bind the drive below elsewhere, e.g. \"mount --bind / /mnt\".", d.MountPoint),
+		})
+	}
+	return findings, nil
+}
+
+func checkFstabUnmounted(sys *System) ([]Finding, error) {
+	fstab, err := sys.Fstab()
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := sys.Mounts()
+	if err != nil {
+		return nil, err
+	}
+	mounted := map[string]bool{}
+	for _, m := range mounts {
+		mounted[filepath.Clean(m.MountPoint)] = true
+	}
+
+	var findings []Finding
+	for _, e := range fstab {
+		if e.Filesystem == "swap" || !strings.HasPrefix(e.MountPoint, "/") ||
+			e.HasOption("noauto") || mounted[filepath.Clean(e.MountPoint)] {
+			continue
+		}
+		fix := fmt.Sprintf("Try \"mount %s\" to see the error, and \"journalctl -b -u $(systemd-escape -p --suffix=mount %s)\" for what happened at boot. Check that %s is plugged in and that the device in fstab is right.",
+			e.MountPoint, e.MountPoint, e.Device)
+		if !e.HasOption("nofail") {
+			fix += " Without nofail, a missing drive can stop the system from booting."
+		}
+		findings = append(findings, Finding{
+			Level:   disk.LevelWarning,
+			Subject: e.MountPoint,
+			Problem: fmt.Sprintf("%s should be mounted at %s according to /etc/fstab, but is not. Files saved there now land on the drive below instead.", e.Device, e.MountPoint),
+			Fix:     fix,
+		})
+	}
+	return findings, nil
+}
+
+func checkTrim(sys *System) ([]Finding, error) {
+	if sys.TrimScheduled() {
+		return nil, nil
+	}
+	var findings []Finding
+	seen := map[string]bool{}
+	for _, d := range localDisks(sys) {
+		if d.Type == disk.TypeBind || disk.IsReadOnly(d) || seen[d.Device] || !sys.SSD(d.Device) {
+			continue
+		}
+		seen[d.Device] = true
+		if (Mount{Options: d.Options}).HasOption("discard") {
+			continue
+		}
+		findings = append(findings, Finding{
+			Level:   disk.LevelWarning,
+			Subject: d.MountPoint,
+			Problem: fmt.Sprintf("%s is on an SSD that is never told which blocks are free (TRIM). Over time the drive gets slower and wears faster.", d.MountPoint),
+			Fix:     "Trim all SSDs once a week with \"systemctl enable --now fstrim.timer\", or run \"fstrim -av\" from a weekly cron job.",
+		})
+	}
+	return findings, nil
+}
+
+func checkNofail(sys *System) ([]Finding, error) {
+	fstab, err := sys.Fstab()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, e := range fstab {
+		if e.Filesystem == "swap" || e.HasOption("nofail") || e.HasOption("noauto") || !sys.Removable(sys.Resolve(e.Device)) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Level:   disk.LevelWarning,
+			Subject: e.MountPoint,
+			Problem: fmt.Sprintf("%s in /etc/fstab is a removable drive without the nofail option. If it is unplugged at boot, the system stops in emergency mode.", e.MountPoint),
+			Fix:     fmt.Sprintf("Add nofail to the options of %s in /etc/fstab, e.g. \"defaults,nofail\", and maybe x-systemd.device-timeout=10s so boot does not wait long for it.", e.MountPoint),
+		})
+	}
+	return findings, nil
+}
+
+func checkRAID(sys *System) ([]Finding, error) {
+	arrays, err := sys.RAID()
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, a := range arrays {
+		if !a.Degraded {
+			continue
+		}
+		findings = append(findings, Finding{
+			Level:   disk.LevelCritical,
+			Subject: a.Device,
+			Problem: fmt.Sprintf("The %s array %s is degraded [%s]: a member disk failed or is missing, so one more failure can lose data.", a.Level, a.Device, a.Status),
+			Fix:     fmt.Sprintf("Back up the data, see which member failed with \"mdadm --detail %s\", replace it and add the new disk with \"mdadm --manage %s --add /dev/NEWDISK\".", a.Device, a.Device),
+		})
+	}
+	return findings, nil
+}
+
+func checkSMART(sys *System) ([]Finding, error) {
+	var findings []Finding
+	read := 0
+	disks := sys.Health()
+	for _, h := range disks {
+		if h.Health == "" {
+			continue
+		}
+		read++
+		if h.Healthy() {
+			continue
+		}
+		name := h.Device
+		if h.Model != "" {
+			name += " (" + h.Model + ")"
+		}
+		findings = append(findings, Finding{
+			Level:   disk.LevelCritical,
+			Subject: h.Device,
+			Problem: fmt.Sprintf("The disk %s reports SMART health %s: it expects to fail soon.", name, h.Health),
+			Fix:     fmt.Sprintf("Back up everything on it now and replace it. \"smartctl -a %s\" shows what it reports.", h.Device),
+		})
+	}
+	if read == 0 && len(disks) > 0 {
+		return nil, fmt.Errorf("could not read SMART health, which needs smartctl and usually root")
+	}
+	return findings, nil
+}
+
+func checkWorldWritable(sys *System) ([]Finding, error) {
+	var findings []Finding
+	for _, d := range localDisks(sys) {
+		info, err := sys.Stat(d.MountPoint)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		mode := info.Mode()
+		if mode.Perm()&0o002 == 0 || mode&os.ModeSticky != 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Level:   disk.LevelWarning,
+			Subject: d.MountPoint,
+			Problem: fmt.Sprintf("%s can be written by every user (mode %o) without the sticky bit, so anyone can delete or replace anyone else's files on it.", d.MountPoint, mode.Perm()),
+			Fix: fmt.Sprintf("Run \"chmod o-w %s\", or \"chmod +t %s\" if it is shared on purpose, like /tmp. For FAT and NTFS drives, set uid= and umask=022 in the mount options instead.",
+				d.MountPoint, d.MountPoint),
+		})
+	}
+	return findings, nil
+}
@@ -0,0 +1,489 @@
+package doctor
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+// table parses a mount table fixture
+func table(t *testing.T, text string) func() ([]Mount, error) {
+	t.Helper()
+	mounts, err := parseTable(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() ([]Mount, error) { return mounts, nil }
+}
+
+// testSystem is a made-up machine where nothing is wrong. Tests replace
+// what their check looks at.
+func testSystem(t *testing.T) *System {
+	return &System{
+		Thresholds:    &disk.ThresholdConfig{Default: disk.DefaultThresholds},
+		Mounts:        table(t, ""),
+		Fstab:         table(t, ""),
+		Stat:          func(string) (os.FileInfo, error) { return fileInfo{mode: os.ModeDir | 0o755}, nil },
+		Statfs:        func(string) error { return nil },
+		Covered:       func(string) ([]string, error) { return nil, nil },
+		Resolve:       func(spec string) string { return spec },
+		SSD:           func(string) bool { return false },
+		Removable:     func(string) bool { return false },
+		TrimScheduled: func() bool { return true },
+		Health:        func() []disk.DiskHealth { return nil },
+		RAID:          func() ([]disk.RAIDArray, error) { return nil, nil },
+	}
+}
+
+// fileInfo describes a made-up file
+type fileInfo struct{ mode os.FileMode }
+
+func (f fileInfo) Name() string       { return "" }
+func (f fileInfo) Size() int64        { return 0 }
+func (f fileInfo) Mode() os.FileMode  { return f.mode }
+func (f fileInfo) ModTime() time.Time { return time.Time{} }
+func (f fileInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f fileInfo) Sys() interface{}   { return nil }
+
+// mounted is a filesystem of a scan, usedPercent full
+func mounted(device, mountPoint, filesystem, options string, usedPercent uint64) disk.Disk {
+	const size = 100 << 30
+	return disk.Disk{
+		Device:     device,
+		MountPoint: mountPoint,
+		Filesystem: filesystem,
+		Options:    options,
+		Type:       disk.TypePhysical,
+		Size:       size,
+		Used:       size / 100 * usedPercent,
+		Available:  size / 100 * (100 - usedPercent),
+	}
+}
+
+// withInodes sets the inodes of a filesystem, freePerMille of 1000 free
+func withInodes(d disk.Disk, freePerMille uint64) disk.Disk {
+	d.Inodes = 1_000_000
+	d.InodesFree = d.Inodes / 1000 * freePerMille
+	return d
+}
+
+// summary lists findings as "level subject"
+func summary(findings []Finding) []string {
+	var lines []string
+	for _, f := range findings {
+		lines = append(lines, f.Level.String()+" "+f.Subject)
+	}
+	return lines
+}
+
+func TestCheckRootFull(t *testing.T) {
+	tests := []struct {
+		name  string
+		root  disk.Disk
+		want  []string
+		error bool
+	}{
+		{"plenty of space", mounted("/dev/sda2", "/", "ext4", "rw", 50), nil, false},
+		{"warning", mounted("/dev/sda2", "/", "ext4", "rw", 92), []string{"warning /"}, false},
+		{"critical", mounted("/dev/sda2", "/", "ext4", "rw", 97), []string{"critical /"}, false},
+		{"inodes are not this check's business", withInodes(mounted("/dev/sda2", "/", "ext4", "rw", 50), 0), nil, false},
+		// live systems and appliances boot from read-only images that are always full
+		{"squashfs root", mounted("/dev/sr0", "/", "squashfs", "ro", 100), nil, false},
+		{"iso9660 root", mounted("/dev/sr0", "/", "iso9660", "ro", 100), nil, false},
+		// a root remounted read-only is still full, the read-only check says why
+		{"read-only root", mounted("/dev/sda2", "/", "ext4", "ro,relatime", 100), []string{"critical /"}, false},
+		{"no root", mounted("/dev/sda3", "/home", "ext4", "rw", 97), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := testSystem(t)
+			sys.Disks = []disk.Disk{tt.root}
+			findings, err := checkRootFull(sys)
+			if (err != nil) != tt.error {
+				t.Fatalf("error = %v", err)
+			}
+			if got := summary(findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			// the doctor and the threshold checks agree on what is watched
+			if alert := sys.Thresholds.For(tt.root).Check(tt.root, nil); tt.root.MountPoint == "/" && (alert.Level != disk.LevelOK) != (len(findings) > 0) {
+				t.Errorf("thresholds report %s, doctor %v", alert.Level, summary(findings))
+			}
+		})
+	}
+}
+
+func TestCheckInodes(t *testing.T) {
+	loop := withInodes(mounted("/dev/loop3", "/snap/core/1", "ext4", "rw", 100), 0)
+	network := withInodes(mounted("nas:/share", "/mnt/nas", "nfs4", "rw", 50), 0)
+	network.Type = disk.TypeNetwork
+
+	tests := []struct {
+		name string
+		disk disk.Disk
+		want []string
+	}{
+		{"plenty", withInodes(mounted("/dev/sda2", "/", "ext4", "rw", 50), 500), nil},
+		{"warning", withInodes(mounted("/dev/sda2", "/", "ext4", "rw", 50), 30), []string{"warning /"}},
+		{"critical", withInodes(mounted("/dev/sda3", "/var", "ext4", "rw", 50), 5), []string{"critical /var"}},
+		{"no fixed number of inodes", mounted("/dev/sda4", "/data", "btrfs", "rw", 50), nil},
+		{"squashfs has none free", withInodes(mounted("/dev/sdc1", "/media/live", "squashfs", "rw", 100), 0), nil},
+		{"iso9660 has none free", withInodes(mounted("/dev/sr0", "/media/cdrom", "iso9660", "rw", 100), 0), nil},
+		{"read-only", withInodes(mounted("/dev/sdb1", "/media/backup", "ext4", "ro", 100), 0), nil},
+		{"loop image", loop, nil},
+		{"network", network, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := testSystem(t)
+			sys.Disks = []disk.Disk{tt.disk}
+			findings, err := checkInodes(sys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		name   string
+		mounts string
+		fstab  string
+		want   []string
+	}{
+		{
+			name:   "all writable",
+			mounts: "/dev/sda2 / ext4 rw,relatime,errors=remount-ro 0 0",
+			fstab:  "UUID=1234 / ext4 errors=remount-ro 0 1",
+		},
+		{
+			name:   "remounted after errors",
+			mounts: "/dev/sda2 / ext4 ro,relatime,errors=remount-ro 0 0",
+			want:   []string{"critical /"},
+		},
+		{
+			name:   "writable in fstab",
+			mounts: "/dev/sdb1 /srv/my\\040data ext4 ro,relatime 0 0",
+			fstab:  "/dev/sdb1 /srv/my\\040data ext4 defaults 0 2",
+			want:   []string{"critical /srv/my data"},
+		},
+		{
+			name:   "read-only in fstab",
+			mounts: "/dev/sdb1 /srv/archive ext4 ro,relatime,errors=remount-ro 0 0",
+			fstab:  "/dev/sdb1 /srv/archive ext4 ro,errors=remount-ro 0 2",
+		},
+		{
+			name:   "read-only on purpose, not in fstab",
+			mounts: "/dev/vdb /mnt/seed ext4 ro,relatime 0 0",
+		},
+		{
+			name: "images are read-only by design",
+			mounts: "/dev/sr0 /media/cdrom iso9660 ro,nosuid,nodev,errors=remount-ro 0 0\n" +
+				"/dev/sdc1 /media/live squashfs ro,relatime 0 0\n" +
+				"/dev/loop3 /snap/core/1 squashfs ro,nodev,relatime,errors=remount-ro 0 0",
+			fstab: "/dev/sr0 /media/cdrom iso9660 user 0 0\n" +
+				"/dev/sdc1 /media/live squashfs defaults 0 0",
+		},
+		{
+			name: "read-only bind of a writable filesystem",
+			mounts: "/dev/sda2 / ext4 rw,relatime,errors=remount-ro 0 0\n" +
+				"/dev/sda2 /srv/jail ext4 ro,relatime,errors=remount-ro 0 0",
+		},
+		{
+			name: "one finding per device",
+			mounts: "/dev/sda2 / ext4 ro,relatime,errors=remount-ro 0 0\n" +
+				"/dev/sda2 /var/lib/docker ext4 ro,relatime,errors=remount-ro 0 0",
+			want: []string{"critical /"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := testSystem(t)
+			sys.Mounts = table(t, tt.mounts)
+			sys.Fstab = table(t, tt.fstab)
+			findings, err := checkReadOnly(sys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadOnlyImagesAgreeWithThresholds(t *testing.T) {
+	// the doctor and the threshold checks share what counts as read-only by
+	// design, so neither complains about a full image
+	for _, fs := range []string{"squashfs", "iso9660", "udf", "erofs", "cramfs"} {
+		d := withInodes(mounted("/dev/sr0", "/media/image", fs, "ro", 100), 0)
+		if disk.Monitored(d) {
+			t.Errorf("%s is monitored", fs)
+		}
+		sys := testSystem(t)
+		sys.Disks = []disk.Disk{d}
+		sys.Mounts = table(t, "/dev/sr0 /media/image "+fs+" ro,errors=remount-ro 0 0")
+		sys.Fstab = table(t, "/dev/sr0 /media/image "+fs+" defaults 0 0")
+		for _, check := range []func(*System) ([]Finding, error){checkInodes, checkReadOnly} {
+			if findings, err := check(sys); err != nil || len(findings) > 0 {
+				t.Errorf("%s: got %v, %v", fs, summary(findings), err)
+			}
+		}
+	}
+}
+
+func TestCheckStaleNetwork(t *testing.T) {
+	sys := testSystem(t)
+	sys.Mounts = table(t, "/dev/sda2 / ext4 rw 0 0\n"+
+		"nas:/share /mnt/nas nfs4 rw,hard 0 0\n"+
+		"//server/music /mnt/music cifs rw 0 0\n"+
+		"user@host:/ /mnt/ssh fuse.sshfs rw 0 0")
+	sys.Statfs = func(path string) error {
+		if path == "/mnt/nas" || path == "/mnt/ssh" || path == "/" {
+			return errors.New("no answer within 3s")
+		}
+		return nil
+	}
+	findings, err := checkStaleNetwork(sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(findings), []string{"critical /mnt/nas", "critical /mnt/ssh"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckMountedOver(t *testing.T) {
+	sys := testSystem(t)
+	sys.Disks = []disk.Disk{
+		mounted("/dev/sda2", "/", "ext4", "rw", 50),
+		mounted("/dev/sda3", "/home", "ext4", "rw", 50),
+		mounted("/dev/sdb1", "/data", "ext4", "rw", 50),
+	}
+	sys.Covered = func(mountPoint string) ([]string, error) {
+		switch mountPoint {
+		case "/":
+			t.Error("looked beneath /")
+		case "/data":
+			return []string{"a", "b", "c", "d", "e"}, nil
+		}
+		return nil, nil
+	}
+	findings, err := checkMountedOver(sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(findings), []string{"warning /data"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !strings.Contains(findings[0].Problem, "5 files (a, b, c, …)") {
+		t.Errorf("problem = %q", findings[0].Problem)
+	}
+
+	sys.Covered = func(string) ([]string, error) { return nil, errors.New("looking beneath mount points needs root") }
+	if _, err := checkMountedOver(sys); err == nil {
+		t.Error("no error without root")
+	}
+}
+
+func TestCheckFstabUnmounted(t *testing.T) {
+	sys := testSystem(t)
+	sys.Fstab = table(t, `# comment
+UUID=1 / ext4 defaults 0 1
+UUID=2 /home/ ext4 defaults 0 2
+UUID=3 none swap sw 0 0
+UUID=4 /mnt/usb ext4 noauto 0 0
+UUID=5 /data ext4 defaults 0 2
+UUID=6 /backup ext4 defaults,nofail 0 2
+proc /proc proc defaults
+`)
+	sys.Mounts = table(t, "/dev/sda2 / ext4 rw 0 0\n/dev/sda3 /home ext4 rw 0 0\nproc /proc proc rw 0 0")
+	findings, err := checkFstabUnmounted(sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(findings), []string{"warning /data", "warning /backup"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if !strings.Contains(findings[0].Fix, "Without nofail") || strings.Contains(findings[1].Fix, "Without nofail") {
+		t.Errorf("nofail advice in %q and %q", findings[0].Fix, findings[1].Fix)
+	}
+}
+
+func TestCheckTrim(t *testing.T) {
+	bind := mounted("/dev/nvme0n1p2", "/srv/www", "ext4", "rw", 50)
+	bind.Type = disk.TypeBind
+	disks := []disk.Disk{
+		mounted("/dev/nvme0n1p2", "/", "ext4", "rw", 50),
+		bind,
+		mounted("/dev/nvme0n1p3", "/home", "ext4", "rw,discard", 50),
+		mounted("/dev/nvme1n1p1", "/media/backup", "ext4", "ro", 50),
+		mounted("/dev/sda1", "/data", "ext4", "rw", 50),
+	}
+	tests := []struct {
+		name      string
+		scheduled bool
+		want      []string
+	}{
+		{"scheduled", true, nil},
+		{"not scheduled", false, []string{"warning /"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := testSystem(t)
+			sys.Disks = disks
+			sys.TrimScheduled = func() bool { return tt.scheduled }
+			sys.SSD = func(device string) bool { return strings.HasPrefix(device, "/dev/nvme") }
+			findings, err := checkTrim(sys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := summary(findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNofail(t *testing.T) {
+	sys := testSystem(t)
+	sys.Fstab = table(t, `UUID=root / ext4 defaults 0 1
+UUID=usb /media/usb ext4 defaults 0 2
+UUID=usb2 /media/usb2 ext4 defaults,nofail 0 2
+UUID=usb3 /media/usb3 ext4 noauto 0 2
+UUID=usbswap none swap sw 0 0
+`)
+	sys.Resolve = func(spec string) string { return "/dev/" + strings.TrimPrefix(spec, "UUID=") }
+	sys.Removable = func(device string) bool { return strings.HasPrefix(device, "/dev/usb") }
+	findings, err := checkNofail(sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(findings), []string{"warning /media/usb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCheckRAID(t *testing.T) {
+	sys := testSystem(t)
+	sys.RAID = func() ([]disk.RAIDArray, error) {
+		return []disk.RAIDArray{
+			{Device: "/dev/md0", Level: "raid1", Status: "UU"},
+			{Device: "/dev/md1", Level: "raid5", Status: "UU_", Degraded: true},
+		}, nil
+	}
+	findings, err := checkRAID(sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(findings), []string{"critical /dev/md1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	sys.RAID = func() ([]disk.RAIDArray, error) { return nil, errors.New("failed to read /proc/mdstat") }
+	if _, err := checkRAID(sys); err == nil {
+		t.Error("the error was lost")
+	}
+}
+
+func TestCheckSMART(t *testing.T) {
+	tests := []struct {
+		name   string
+		health []disk.DiskHealth
+		want   []string
+		error  bool
+	}{
+		{"no disks", nil, nil, false},
+		{"healthy", []disk.DiskHealth{{Device: "/dev/sda", Health: "PASSED"}, {Device: "/dev/nvme0n1", Health: "OK"}}, nil, false},
+		{"failing", []disk.DiskHealth{{Device: "/dev/sda", Health: "PASSED"}, {Device: "/dev/sdb", Model: "Old Disk", Health: "FAILED!"}}, []string{"critical /dev/sdb"}, false},
+		{"some unreadable", []disk.DiskHealth{{Device: "/dev/sda", Health: "PASSED"}, {Device: "/dev/sdb"}}, nil, false},
+		{"none readable", []disk.DiskHealth{{Device: "/dev/sda"}, {Device: "/dev/sdb"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sys := testSystem(t)
+			sys.Health = func() []disk.DiskHealth { return tt.health }
+			findings, err := checkSMART(sys)
+			if (err != nil) != tt.error {
+				t.Fatalf("error = %v", err)
+			}
+			if got := summary(findings); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWorldWritable(t *testing.T) {
+	modes := map[string]os.FileMode{
+		"/":      os.ModeDir | 0o755,
+		"/data":  os.ModeDir | 0o777,
+		"/share": os.ModeDir | os.ModeSticky | 0o777,
+		"/media": os.ModeSymlink | 0o777,
+	}
+	sys := testSystem(t)
+	for mountPoint := range modes {
+		sys.Disks = append(sys.Disks, mounted("/dev/sda1", mountPoint, "ext4", "rw", 50))
+	}
+	sys.Disks = append(sys.Disks, mounted("/dev/sdb1", "/gone", "ext4", "rw", 50))
+	sys.Stat = func(path string) (os.FileInfo, error) {
+		mode, ok := modes[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return fileInfo{mode: mode}, nil
+	}
+	findings, err := checkWorldWritable(sys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(findings), []string{"warning /data"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{Name: "fine", Run: func(*System) ([]Finding, error) { return nil, nil }},
+		{Name: "mixed", Run: func(*System) ([]Finding, error) {
+			return []Finding{{Level: disk.LevelWarning, Subject: "a"}, {Level: disk.LevelCritical, Subject: "b"}}, nil
+		}},
+		{Name: "unknown", Run: func(*System) ([]Finding, error) { return nil, errors.New("needs root") }},
+	}
+	report := Run(testSystem(t), checks)
+	if report.Level != disk.LevelCritical || report.Problems != 2 || len(report.Results) != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	mixed := report.Results[1]
+	if got, want := summary(mixed.Findings), []string{"critical b", "warning a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the worst first", got)
+	}
+	if mixed.Findings[0].Check != "mixed" {
+		t.Errorf("finding of check %q", mixed.Findings[0].Check)
+	}
+	if report.Results[2].Error != "needs root" {
+		t.Errorf("error = %q", report.Results[2].Error)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	picked, err := Select(Defaults(), []string{"smart", " inodes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(picked) != 2 || picked[0].Name != "inodes" || picked[1].Name != "smart" {
+		t.Errorf("picked %+v, want inodes and smart in their order", picked)
+	}
+	if _, err := Select(Defaults(), []string{"inodes", "nope"}); err == nil || !strings.Contains(err.Error(), `"nope"`) {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+// Package doctor looks for common storage problems, such as a nearly full
+// system drive, a filesystem remounted read-only or a failing disk, and
+// explains each in plain language with a way to fix it.
+//
+// Every check is a Check that learns about the system only through a
+// System, so checks can be added, picked, and run one at a time against a
+// made-up system.
+package doctor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"checkpoint/pkg/disk"
+)
+
+// Finding is one problem a check found
+type Finding struct {
+	Check string     `json:"check" yaml:"check"`
+	Level disk.Level `json:"level" yaml:"level"`
+	// Subject is what the finding is about, a mount point or a device
+	Subject string `json:"subject" yaml:"subject"`
+	Problem string `json:"problem" yaml:"problem"`
+	Fix     string `json:"fix" yaml:"fix"`
+}
+
+// Check looks for one kind of problem
+type Check struct {
+	// Name identifies the check, e.g. "read-only"
+	Name string `json:"name" yaml:"name"`
+	// Title says what is checked, e.g. "Filesystems remounted read-only"
+	Title string `json:"title" yaml:"title"`
+	// Run returns what is wrong, nothing when all is well. An error means
+	// the check could not tell, e.g. for lack of permissions.
+	Run func(sys *System) ([]Finding, error) `json:"-" yaml:"-"`
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Check    string    `json:"check" yaml:"check"`
+	Title    string    `json:"title" yaml:"title"`
+	Findings []Finding `json:"findings" yaml:"findings"`
+	// Error says why the check could not run
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Results []Result `json:"results" yaml:"results"`
+	// Level is the worst level found, ok when nothing was
+	Level    disk.Level `json:"level" yaml:"level"`
+	Problems int        `json:"problems" yaml:"problems"`
+}
+
+// Run runs the checks in order and collects what they found, worst first
+// within each check
+func Run(sys *System, checks []Check) Report {
+	report := Report{Results: []Result{}}
+	for _, c := range checks {
+		res := Result{Check: c.Name, Title: c.Title, Findings: []Finding{}}
+		findings, err := c.Run(sys)
+		if err != nil {
+			res.Error = err.Error()
+		}
+		for _, f := range findings {
+			f.Check = c.Name
+			res.Findings = append(res.Findings, f)
+			if f.Level > report.Level {
+				report.Level = f.Level
+			}
+		}
+		sort.SliceStable(res.Findings, func(i, j int) bool { return res.Findings[i].Level > res.Findings[j].Level })
+		report.Problems += len(res.Findings)
+		report.Results = append(report.Results, res)
+	}
+	return report
+}
+
+// Defaults returns the built-in checks
+func Defaults() []Check {
+	return []Check{
+		{Name: "root-full", Title: "System drive space", Run: checkRootFull},
+		{Name: "inodes", Title: "Free inodes", Run: checkInodes},
+		{Name: "read-only", Title: "Filesystems remounted read-only", Run: checkReadOnly},
+		{Name: "stale-network", Title: "Network mounts that stopped answering", Run: checkStaleNetwork},
+		{Name: "mounted-over", Title: "Mounts hiding files in the folder below", Run: checkMountedOver},
+		{Name: "fstab-unmounted", Title: "fstab entries that are not mounted", Run: checkFstabUnmounted},
+		{Name: "trim", Title: "Periodic TRIM of SSDs", Run: checkTrim},
+		{Name: "nofail", Title: "nofail on removable drives in fstab", Run: checkNofail},
+		{Name: "raid", Title: "Software RAID arrays", Run: checkRAID},
+		{Name: "smart", Title: "SMART health of disks", Run: checkSMART},
+		{Name: "world-writable", Title: "Mount points writable by everyone", Run: checkWorldWritable},
+	}
+}
+
+// Select picks checks by name, keeping their order
+func Select(checks []Check, names []string) ([]Check, error) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[strings.TrimSpace(name)] = true
+	}
+	var picked []Check
+	for _, c := range checks {
+		if wanted[c.Name] {
+			picked = append(picked, c)
+			delete(wanted, c.Name)
+		}
+	}
+	for name := range wanted {
+		var known []string
+		for _, c := range checks {
+			known = append(known, c.Name)
+		}
+		return nil, fmt.Errorf("unknown check %q, expected one of %s", name, strings.Join(known, ", "))
+	}
+	return picked, nil
+}
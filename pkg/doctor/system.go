@@ -0,0 +1,299 @@
+package doctor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+// statfsTimeout is how long a network mount may take to answer
+const statfsTimeout = 3 * time.Second
+
+// Mount is a line of /proc/mounts or /etc/fstab
+type Mount struct {
+	Device     string `json:"device" yaml:"device"`
+	MountPoint string `json:"mount_point" yaml:"mount_point"`
+	Filesystem string `json:"filesystem" yaml:"filesystem"`
+	Options    string `json:"options" yaml:"options"`
+}
+
+// HasOption tells whether the mount has an option, alone or with a value
+func (m Mount) HasOption(name string) bool {
+	for _, o := range strings.Split(m.Options, ",") {
+		if o == name || strings.HasPrefix(o, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// System is what the checks know about the machine. Every field is filled
+// in by NewSystem and can be replaced, e.g. to run a check against a
+// made-up system.
+type System struct {
+	// Disks are the filesystems of a scan
+	Disks      []disk.Disk
+	Thresholds *disk.ThresholdConfig
+
+	// Mounts lists every mount, Fstab the entries of /etc/fstab
+	Mounts func() ([]Mount, error)
+	Fstab  func() ([]Mount, error)
+	// Stat describes a file without following a final symlink
+	Stat func(path string) (os.FileInfo, error)
+	// Statfs asks a filesystem for its size, failing when it does not
+	// answer in time
+	Statfs func(path string) error
+	// Covered lists the files a mount hides in the folder it is mounted on
+	Covered func(mountPoint string) ([]string, error)
+	// Resolve turns an fstab device, such as UUID=..., into a device path
+	Resolve func(spec string) string
+	// SSD tells whether a device is a solid state drive that supports TRIM
+	SSD func(device string) bool
+	// Removable tells whether a device can be unplugged, e.g. a USB drive
+	Removable func(device string) bool
+	// TrimScheduled tells whether the system trims its SSDs periodically
+	TrimScheduled func() bool
+	Health        func() []disk.DiskHealth
+	RAID          func() ([]disk.RAIDArray, error)
+}
+
+// NewSystem describes this machine, using disks from a scan
+func NewSystem(disks []disk.Disk, thresholds *disk.ThresholdConfig) *System {
+	if thresholds == nil {
+		thresholds = &disk.ThresholdConfig{Default: disk.DefaultThresholds}
+	}
+	sys := &System{
+		Disks:         disks,
+		Thresholds:    thresholds,
+		Fstab:         func() ([]Mount, error) { return readTable("/etc/fstab") },
+		Stat:          os.Lstat,
+		Statfs:        statfs,
+		Resolve:       resolve,
+		SSD:           ssd,
+		Removable:     removable,
+		TrimScheduled: trimScheduled,
+		Health:        func() []disk.DiskHealth { return disk.LoadHealth(disks) },
+		RAID:          disk.ReadRAID,
+	}
+	sys.Mounts = func() ([]Mount, error) { return readTable("/proc/mounts") }
+	sys.Covered = func(mountPoint string) ([]string, error) { return covered(sys, mountPoint) }
+	return sys
+}
+
+// readTable reads a mount table in the fstab format
+func readTable(path string) ([]Mount, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && path == "/etc/fstab" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer file.Close()
+	mounts, err := parseTable(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return mounts, nil
+}
+
+// parseTable reads mounts in the fstab format, skipping comments
+func parseTable(r io.Reader) ([]Mount, error) {
+	var mounts []Mount
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		m := Mount{
+			Device:     unescape(fields[0]),
+			MountPoint: unescape(fields[1]),
+			Filesystem: fields[2],
+			Options:    "defaults",
+		}
+		if len(fields) > 3 {
+			m.Options = fields[3]
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+// unescape decodes the octal escapes of mount tables, e.g. \040 for a space
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && isOctal(s[i+1:i+4]) {
+			b.WriteByte((s[i+1]-'0')<<6 | (s[i+2]-'0')<<3 | (s[i+3] - '0'))
+			i += 3
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isOctal(s string) bool {
+	for _, c := range []byte(s) {
+		if c < '0' || c > '7' {
+			return false
+		}
+	}
+	return len(s) == 3
+}
+
+// statfs runs statfs in the background, so that a network mount that
+// stopped answering does not hang the checks
+func statfs(path string) error {
+	done := make(chan error, 1)
+	go func() {
+		var stat syscall.Statfs_t
+		done <- syscall.Statfs(path, &stat)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(statfsTimeout):
+		return fmt.Errorf("no answer within %s", statfsTimeout)
+	}
+}
+
+// covered looks beneath a mount point: it binds the filesystem holding it,
+// without the mounts on top, to a temporary folder and lists the folder
+// there. Binding needs root.
+func covered(sys *System, mountPoint string) ([]string, error) {
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("looking beneath mount points needs root")
+	}
+	mounts, err := sys.Mounts()
+	if err != nil {
+		return nil, err
+	}
+	parent := ""
+	for _, m := range mounts {
+		if m.MountPoint != mountPoint && isUnder(mountPoint, m.MountPoint) && len(m.MountPoint) > len(parent) {
+			parent = m.MountPoint
+		}
+	}
+	if parent == "" {
+		return nil, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "checkpoint-doctor-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a temporary folder: %v", err)
+	}
+	defer os.Remove(tmp)
+	if err := syscall.Mount(parent, tmp, "", syscall.MS_BIND, ""); err != nil {
+		return nil, fmt.Errorf("failed to look beneath %s: %v", mountPoint, err)
+	}
+	defer syscall.Unmount(tmp, syscall.MNT_DETACH)
+
+	rel, _ := filepath.Rel(parent, mountPoint)
+	entries, err := os.ReadDir(filepath.Join(tmp, rel))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look beneath %s: %v", mountPoint, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
+}
+
+// isUnder tells whether path is dir or inside it
+func isUnder(path, dir string) bool {
+	return dir == "/" || path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// resolve finds the device of UUID=, LABEL=, PARTUUID= and PARTLABEL=
+// specs through the links udev keeps in /dev/disk
+func resolve(spec string) string {
+	for prefix, dir := range map[string]string{
+		"UUID=":      "by-uuid",
+		"LABEL=":     "by-label",
+		"PARTUUID=":  "by-partuuid",
+		"PARTLABEL=": "by-partlabel",
+	} {
+		if value, ok := strings.CutPrefix(spec, prefix); ok {
+			spec = filepath.Join("/dev/disk", dir, strings.Trim(value, `"`))
+			break
+		}
+	}
+	if resolved, err := filepath.EvalSymlinks(spec); err == nil {
+		return resolved
+	}
+	return spec
+}
+
+// sysBlock returns the sysfs folder of a device, and that of the whole
+// disk for a partition, which holds the queue settings
+func sysBlock(device string) (string, string) {
+	name := disk.KernelName(device)
+	if name == "" {
+		return "", ""
+	}
+	dir, err := filepath.EvalSymlinks(filepath.Join("/sys/class/block", name))
+	if err != nil {
+		return "", ""
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err == nil {
+		return dir, filepath.Dir(dir)
+	}
+	return dir, dir
+}
+
+func readSys(path string) string {
+	data, _ := os.ReadFile(path)
+	return strings.TrimSpace(string(data))
+}
+
+func ssd(device string) bool {
+	_, whole := sysBlock(device)
+	if whole == "" {
+		return false
+	}
+	discard := readSys(filepath.Join(whole, "queue", "discard_max_bytes"))
+	return readSys(filepath.Join(whole, "queue", "rotational")) == "0" && discard != "" && discard != "0"
+}
+
+func removable(device string) bool {
+	dir, whole := sysBlock(device)
+	if whole == "" {
+		return false
+	}
+	return readSys(filepath.Join(whole, "removable")) == "1" || strings.Contains(dir, "/usb")
+}
+
+// trimScheduled looks for the fstrim timer of util-linux, or a cron job
+func trimScheduled() bool {
+	if out, err := exec.Command("systemctl", "is-enabled", "fstrim.timer").Output(); err == nil && strings.TrimSpace(string(out)) == "enabled" {
+		return true
+	}
+	for _, job := range []string{"/etc/cron.weekly/fstrim", "/etc/cron.daily/fstrim", "/etc/cron.d/fstrim"} {
+		if _, err := os.Stat(job); err == nil {
+			return true
+		}
+	}
+	return false
+}
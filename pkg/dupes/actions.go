@@ -0,0 +1,261 @@
+package dupes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+// Action is what is done with the duplicates of a group
+type Action string
+
+// Actions. Hard links and reflinks keep every path and share the content
+// of the kept copy; they only work within one filesystem. Hard-linked paths
+// also share permissions and ownership, and a change through one path shows
+// in all. Reflinks stay independent files and need btrfs or xfs.
+const (
+	ActionHardlink Action = "hardlink"
+	ActionReflink  Action = "reflink"
+	ActionTrash    Action = "trash"
+)
+
+// ParseAction validates an action name given on the command line
+func ParseAction(name string) (Action, error) {
+	switch a := Action(strings.ToLower(strings.TrimSpace(name))); a {
+	case ActionHardlink, ActionReflink, ActionTrash:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown action %q, expected hardlink, reflink or trash", name)
+}
+
+// Result is what was done, or would be done in a dry run, with one
+// duplicate
+type Result struct {
+	Action Action `json:"action" yaml:"action"`
+	Path   string `json:"path" yaml:"path"`
+	// Kept is the copy that stays and Path now shares or duplicated
+	Kept string `json:"kept" yaml:"kept"`
+	// Freed is the space given back to the filesystem, Trashed the space
+	// moved to the trash, freed once it is emptied
+	Freed   uint64 `json:"freed_bytes" yaml:"freed_bytes"`
+	Trashed uint64 `json:"trashed_bytes" yaml:"trashed_bytes"`
+	DryRun  bool   `json:"dry_run" yaml:"dry_run"`
+	Skipped string `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	Error   string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// ApplyOptions configure Apply
+type ApplyOptions struct {
+	// DryRun only says what would be done
+	DryRun bool
+	// Trash moves a path to the trash, disk.MoveToTrash by default
+	Trash func(path string) error
+}
+
+// Apply deals with the duplicates of each group. The oldest copy is kept,
+// for hard links and reflinks the oldest on each filesystem. Files that
+// changed since the search are left alone.
+func Apply(ctx context.Context, groups []Group, action Action, opts ApplyOptions) []Result {
+	if opts.Trash == nil {
+		opts.Trash = disk.MoveToTrash
+	}
+	var results []Result
+	for _, g := range groups {
+		sets := [][]File{g.Files}
+		if action != ActionTrash {
+			sets = byDevice(g.Files)
+		}
+		for _, files := range sets {
+			files = append([]File(nil), files...)
+			sort.SliceStable(files, func(i, j int) bool { return files[i].ModTime.Before(files[j].ModTime) })
+			keep := files[0]
+			if len(files) == 1 {
+				results = append(results, Result{Action: action, Path: keep.Path, DryRun: opts.DryRun,
+					Skipped: "no other copy on the same filesystem"})
+				continue
+			}
+			for _, dup := range files[1:] {
+				r := Result{Action: action, Path: dup.Path, Kept: keep.Path, DryRun: opts.DryRun}
+				if ctx.Err() != nil {
+					r.Skipped = "interrupted"
+				} else {
+					apply(&r, g.Size, keep, dup, opts)
+				}
+				results = append(results, r)
+			}
+		}
+	}
+	return results
+}
+
+// byDevice splits files by filesystem
+func byDevice(files []File) [][]File {
+	var sets [][]File
+	index := map[uint64]int{}
+	for _, f := range files {
+		i, ok := index[f.Device]
+		if !ok {
+			i = len(sets)
+			index[f.Device] = i
+			sets = append(sets, nil)
+		}
+		sets[i] = append(sets[i], f)
+	}
+	return sets
+}
+
+func apply(r *Result, size uint64, keep, dup File, opts ApplyOptions) {
+	// Every link to the duplicate has to go before its space is freed
+	paths := append([]string{dup.Path}, dup.Links...)
+	if r.Action == ActionReflink && len(dup.Links) > 0 {
+		// The other links would keep the old content alive
+		r.Skipped = "has other hard links"
+		return
+	}
+	for _, f := range []File{keep, dup} {
+		if err := unchanged(f, size); err != nil {
+			r.Skipped = err.Error()
+			return
+		}
+	}
+	if opts.DryRun {
+		if r.Action == ActionTrash {
+			r.Trashed = size
+		} else {
+			r.Freed = size
+		}
+		return
+	}
+
+	var err error
+	switch r.Action {
+	case ActionHardlink:
+		for _, p := range paths {
+			if err = hardlink(keep.Path, p); err != nil {
+				break
+			}
+		}
+	case ActionReflink:
+		err = reflink(keep.Path, dup.Path)
+	case ActionTrash:
+		for _, p := range paths {
+			if err = opts.Trash(p); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		r.Error = err.Error()
+		return
+	}
+	if r.Action == ActionTrash {
+		r.Trashed = size
+	} else {
+		r.Freed = size
+	}
+}
+
+// unchanged checks that a file is still the one the search hashed
+func unchanged(f File, size uint64) error {
+	var st syscall.Stat_t
+	if err := syscall.Lstat(f.Path, &st); err != nil {
+		return fmt.Errorf("%s is gone", f.Path)
+	}
+	if uint64(st.Dev) != f.Device || uint64(st.Ino) != f.Inode || uint64(st.Size) != size ||
+		!time.Unix(st.Mtim.Sec, st.Mtim.Nsec).Equal(f.ModTime) {
+		return fmt.Errorf("%s changed since the search", f.Path)
+	}
+	return nil
+}
+
+// tempName is a free name next to path for building its replacement
+func tempName(path string) string {
+	return filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.checkpoint-%d", filepath.Base(path), os.Getpid()))
+}
+
+// hardlink replaces path with a hard link to keep. The link is made under
+// a temporary name and renamed over path, so path never goes missing.
+func hardlink(keep, path string) error {
+	tmp := tempName(path)
+	if err := os.Link(keep, tmp); err != nil {
+		return fmt.Errorf("failed to link %s: %v", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to link %s: %v", path, err)
+	}
+	return nil
+}
+
+// ficlone is the FICLONE ioctl, which makes a file share the extents of
+// another on filesystems that support it
+const ficlone = 0x40049409
+
+// reflink replaces path with a copy of keep that shares its blocks, keeping
+// the mode, owner and times of path
+func reflink(keep, path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(keep)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := tempName(path)
+	dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to clone %s: %v", path, err)
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	if errno == 0 {
+		err = finishClone(dst, info)
+	} else if errors.Is(errno, syscall.EOPNOTSUPP) || errors.Is(errno, syscall.EXDEV) ||
+		errors.Is(errno, syscall.EINVAL) || errors.Is(errno, syscall.ENOTTY) {
+		err = fmt.Errorf("reflinks are not supported for %s, they need btrfs or xfs", path)
+	} else {
+		err = fmt.Errorf("failed to clone %s: %v", path, errno)
+	}
+	if closeErr := dst.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to clone %s: %v", path, closeErr)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// finishClone gives the clone the metadata of the file it replaces
+func finishClone(dst *os.File, info os.FileInfo) error {
+	if err := dst.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		// Only root may give files away; other users own their files anyway
+		dst.Chown(int(st.Uid), int(st.Gid))
+		atime := time.Unix(st.Atim.Sec, st.Atim.Nsec)
+		return os.Chtimes(dst.Name(), atime, info.ModTime())
+	}
+	return nil
+}
+
+// Totals adds up the space results freed and moved to the trash
+func Totals(results []Result) (freed, trashed uint64) {
+	for _, r := range results {
+		freed += r.Freed
+		trashed += r.Trashed
+	}
+	return freed, trashed
+}
@@ -0,0 +1,155 @@
+package dupes
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"checkpoint/pkg/config"
+)
+
+// cacheTTL drops hashes of files that were not seen for this long
+const cacheTTL = 90 * 24 * time.Hour
+
+// cacheVersion is bumped when the hashes change meaning, which empties
+// older caches
+const cacheVersion = 1
+
+// Cache remembers hashes between runs, keyed by device, inode, size and
+// modification time. Unchanged files are not read again, and a search that
+// was interrupted resumes where it stopped once the cache was saved.
+// A nil *Cache remembers nothing.
+type Cache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	dirty   bool
+}
+
+type cacheEntry struct {
+	Size    uint64 `json:"size"`
+	ModTime int64  `json:"mtime_ns"`
+	Partial string `json:"partial,omitempty"`
+	Full    string `json:"full,omitempty"`
+	// Used is when the entry last matched a file, in Unix seconds
+	Used int64 `json:"used"`
+}
+
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Entries map[string]cacheEntry `json:"entries"`
+}
+
+// DefaultCachePath is dupes.json in the checkpoint cache directory
+func DefaultCachePath() (string, error) {
+	dir, err := config.CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "dupes.json"), nil
+}
+
+// OpenCache reads the cache at path. A missing, damaged or outdated cache
+// starts empty: it only saves work.
+func OpenCache(path string) *Cache {
+	c := &Cache{path: path, entries: map[string]cacheEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var file cacheFile
+	if json.Unmarshal(data, &file) == nil && file.Version == cacheVersion && file.Entries != nil {
+		c.entries = file.Entries
+	}
+	return c
+}
+
+func cacheKey(file *File) string {
+	return fmt.Sprintf("%d:%d", file.Device, file.Inode)
+}
+
+// lookup returns a remembered hash of an unchanged file
+func (c *Cache) lookup(file *File, full bool) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey(file)
+	e, ok := c.entries[key]
+	if !ok || e.Size != file.size || e.ModTime != file.ModTime.UnixNano() {
+		return "", false
+	}
+	sum := e.Partial
+	if full {
+		sum = e.Full
+	}
+	if sum == "" {
+		return "", false
+	}
+	e.Used = time.Now().Unix()
+	c.entries[key] = e
+	c.dirty = true
+	return sum, true
+}
+
+// store remembers a hash, forgetting those of an older version of the file
+func (c *Cache) store(file *File, full bool, sum string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := cacheKey(file)
+	e := c.entries[key]
+	if e.Size != file.size || e.ModTime != file.ModTime.UnixNano() {
+		e = cacheEntry{Size: file.size, ModTime: file.ModTime.UnixNano()}
+	}
+	if full {
+		e.Full = sum
+	} else {
+		e.Partial = sum
+	}
+	e.Used = time.Now().Unix()
+	c.entries[key] = e
+	c.dirty = true
+}
+
+// Save writes the cache if it changed, dropping hashes unused for three
+// months. The file is replaced atomically.
+func (c *Cache) Save() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	cutoff := time.Now().Add(-cacheTTL).Unix()
+	for key, e := range c.entries {
+		if e.Used < cutoff {
+			delete(c.entries, key)
+		}
+	}
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Entries: c.entries})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", c.path, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(c.path), err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", c.path, err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %v", c.path, err)
+	}
+	c.dirty = false
+	return nil
+}
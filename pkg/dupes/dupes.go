@@ -0,0 +1,406 @@
+// Package dupes finds files with the same content. Candidates are narrowed
+// by size, then by a hash of their first bytes, then by a hash of the whole
+// file, so most files are never read in full. Hard links to one file are one
+// file, since they take no extra space.
+package dupes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Defaults used when Options fields are zero
+const (
+	DefaultWorkers = 4
+	DefaultMinSize = 1 << 20
+)
+
+// partialSize is how much of a file the first hash reads. Files up to this
+// size are fully hashed by it.
+const partialSize = 64 << 10
+
+// Options configures a search
+type Options struct {
+	// Roots are the files and directories to search
+	Roots []string
+	// MinSize skips smaller files; small duplicates rarely matter and are
+	// many. Empty files are always skipped.
+	MinSize uint64
+	// CrossFilesystems descends into filesystems mounted below the roots
+	CrossFilesystems bool
+	// Workers bounds how many files are hashed at once
+	Workers int
+	// Cache remembers hashes between runs, nil hashes everything again
+	Cache *Cache
+}
+
+// File is one copy in a Group
+type File struct {
+	Path    string    `json:"path" yaml:"path"`
+	ModTime time.Time `json:"mod_time" yaml:"mod_time"`
+	Device  uint64    `json:"device" yaml:"device"`
+	Inode   uint64    `json:"inode" yaml:"inode"`
+	// Links are further hard links to the same file, found under the roots
+	Links []string `json:"links,omitempty" yaml:"links,omitempty"`
+
+	size    uint64
+	partial string
+	full    string
+	err     bool
+}
+
+// Group is a set of files with the same content
+type Group struct {
+	Size   uint64 `json:"size_bytes" yaml:"size_bytes"`
+	SHA256 string `json:"sha256" yaml:"sha256"`
+	Files  []File `json:"files" yaml:"files"`
+	// Wasted is the space all copies but one take
+	Wasted uint64 `json:"wasted_bytes" yaml:"wasted_bytes"`
+}
+
+// Report is the result of a search, groups with the most wasted space first
+type Report struct {
+	Roots []string `json:"roots" yaml:"roots"`
+	// Files counts the files looked at, hard links once
+	Files int `json:"files" yaml:"files"`
+	// Hashed counts the files read, Cached the hashes taken from the cache
+	Hashed int     `json:"hashed" yaml:"hashed"`
+	Cached int     `json:"cached" yaml:"cached"`
+	Groups []Group `json:"groups" yaml:"groups"`
+	// GroupCount is the number of groups found, which may be more than
+	// Groups holds when the report was trimmed
+	GroupCount int    `json:"group_count" yaml:"group_count"`
+	Wasted     uint64 `json:"wasted_bytes" yaml:"wasted_bytes"`
+	Errors     int    `json:"errors" yaml:"errors"`
+	// Partial is set when the search was cancelled before it finished
+	Partial         bool    `json:"partial" yaml:"partial"`
+	DurationSeconds float64 `json:"duration_seconds" yaml:"duration_seconds"`
+}
+
+// Top trims the report to the n groups wasting the most space, all of
+// them when n is 0
+func (r Report) Top(n int) Report {
+	if n > 0 && len(r.Groups) > n {
+		r.Groups = r.Groups[:n]
+	}
+	return r
+}
+
+// Progress is a snapshot of a running search
+type Progress struct {
+	// Stage is "scanning", "comparing" or "hashing"
+	Stage string
+	Files int64
+	// Hashed counts files hashed in the current stage, out of ToHash
+	Hashed, ToHash int64
+	Bytes          uint64
+}
+
+// Finder searches for duplicates. Progress may be called from other
+// goroutines while Run is in progress.
+type Finder struct {
+	opts Options
+
+	stage                 atomic.Value
+	files, hashed, toHash atomic.Int64
+	bytes                 atomic.Uint64
+	hashedTotal, cached   atomic.Int64
+	errors                atomic.Int64
+}
+
+// NewFinder prepares a search
+func NewFinder(opts Options) *Finder {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultWorkers
+	}
+	if opts.MinSize == 0 {
+		opts.MinSize = 1
+	}
+	f := &Finder{opts: opts}
+	f.stage.Store("scanning")
+	return f
+}
+
+// Progress reports how far the search got
+func (f *Finder) Progress() Progress {
+	return Progress{
+		Stage:  f.stage.Load().(string),
+		Files:  f.files.Load(),
+		Hashed: f.hashed.Load(),
+		ToHash: f.toHash.Load(),
+		Bytes:  f.bytes.Load(),
+	}
+}
+
+type inode struct {
+	dev, ino uint64
+}
+
+// Run searches the roots. Cancelling ctx stops early; the report then
+// holds the groups confirmed so far and has Partial set.
+func (f *Finder) Run(ctx context.Context) (Report, error) {
+	start := time.Now()
+	report := Report{Roots: []string{}, Groups: []Group{}}
+
+	files := map[inode]*File{}
+	for _, root := range f.opts.Roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return report, fmt.Errorf("invalid path %s: %v", root, err)
+		}
+		var st syscall.Stat_t
+		if err := syscall.Stat(abs, &st); err != nil {
+			return report, fmt.Errorf("failed to stat %s: %v", abs, err)
+		}
+		report.Roots = append(report.Roots, abs)
+		f.walk(ctx, abs, uint64(st.Dev), files)
+	}
+
+	// Only files sharing their size with another can be duplicates
+	bySize := map[uint64][]*File{}
+	for _, file := range files {
+		bySize[file.size] = append(bySize[file.size], file)
+	}
+	var candidates []*File
+	for _, same := range bySize {
+		if len(same) > 1 {
+			candidates = append(candidates, same...)
+		}
+	}
+
+	f.stage.Store("comparing")
+	f.hashAll(ctx, candidates, false)
+	var confirmed [][]*File
+	var whole []*File
+	for _, same := range split(candidates, func(file *File) string { return file.partial }) {
+		if same[0].size <= partialSize {
+			confirmed = append(confirmed, same)
+		} else {
+			whole = append(whole, same...)
+		}
+	}
+
+	f.stage.Store("hashing")
+	f.hashAll(ctx, whole, true)
+	confirmed = append(confirmed, split(whole, func(file *File) string { return file.full })...)
+
+	for _, same := range confirmed {
+		report.Groups = append(report.Groups, newGroup(same))
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.Wasted != b.Wasted {
+			return a.Wasted > b.Wasted
+		}
+		return a.Files[0].Path < b.Files[0].Path
+	})
+	for _, g := range report.Groups {
+		report.Wasted += g.Wasted
+	}
+	report.GroupCount = len(report.Groups)
+	report.Files = int(f.files.Load())
+	report.Hashed = int(f.hashedTotal.Load())
+	report.Cached = int(f.cached.Load())
+	report.Errors = int(f.errors.Load())
+	report.Partial = ctx.Err() != nil
+	report.DurationSeconds = time.Since(start).Seconds()
+	return report, nil
+}
+
+// walk collects the regular files below root, once per inode
+func (f *Finder) walk(ctx context.Context, root string, dev uint64, files map[inode]*File) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if err != nil {
+			f.errors.Add(1)
+			return nil
+		}
+		if d.Type()&fs.ModeType != 0 && !d.IsDir() {
+			// Symlinks, devices, sockets and pipes have no content to share
+			return nil
+		}
+		var st syscall.Stat_t
+		if err := syscall.Lstat(path, &st); err != nil {
+			f.errors.Add(1)
+			return nil
+		}
+		if d.IsDir() {
+			if !f.opts.CrossFilesystems && uint64(st.Dev) != dev {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		size := uint64(st.Size)
+		if size == 0 || size < f.opts.MinSize {
+			return nil
+		}
+		key := inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+		if file, ok := files[key]; ok {
+			// A further hard link, or the same file reached from two roots
+			if file.Path != path && !contains(file.Links, path) {
+				file.Links = append(file.Links, path)
+			}
+			return nil
+		}
+		files[key] = &File{
+			Path:    path,
+			ModTime: time.Unix(st.Mtim.Sec, st.Mtim.Nsec),
+			Device:  key.dev,
+			Inode:   key.ino,
+			size:    size,
+		}
+		f.files.Add(1)
+		return nil
+	})
+}
+
+// hashAll hashes files with a pool of workers, the first partialSize
+// bytes or the whole file. Files that cannot be read are marked and left
+// out of the groups.
+func (f *Finder) hashAll(ctx context.Context, files []*File, full bool) {
+	f.hashed.Store(0)
+	f.toHash.Store(int64(len(files)))
+	jobs := make(chan *File)
+	var wg sync.WaitGroup
+	for i := 0; i < f.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				f.hash(ctx, file, full)
+				f.hashed.Add(1)
+			}
+		}()
+	}
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (f *Finder) hash(ctx context.Context, file *File, full bool) {
+	if sum, ok := f.opts.Cache.lookup(file, full); ok {
+		f.setHash(file, full, sum)
+		f.cached.Add(1)
+		return
+	}
+	limit := int64(partialSize)
+	if full {
+		limit = int64(file.size)
+	}
+	sum, err := hashFile(ctx, file.Path, limit, &f.bytes)
+	if err != nil {
+		if ctx.Err() == nil {
+			file.err = true
+			f.errors.Add(1)
+		}
+		return
+	}
+	f.hashedTotal.Add(1)
+	f.setHash(file, full, sum)
+	f.opts.Cache.store(file, full, sum)
+}
+
+// setHash records a hash; the partial hash of a small file is its full hash
+func (f *Finder) setHash(file *File, full bool, sum string) {
+	if full {
+		file.full = sum
+		return
+	}
+	file.partial = sum
+	if file.size <= partialSize {
+		file.full = sum
+	}
+}
+
+// hashFile returns the SHA-256 of the first limit bytes of a file, counting
+// the bytes read
+func hashFile(ctx context.Context, path string, limit int64, read *atomic.Uint64) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	h := sha256.New()
+	buf := make([]byte, 1<<20)
+	r := io.LimitReader(in, limit)
+	for {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		n, err := r.Read(buf)
+		h.Write(buf[:n])
+		read.Add(uint64(n))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// split groups files of the same size by a hash, keeping groups of two or
+// more. Files without the hash, unread or unreadable, are left out.
+func split(files []*File, key func(*File) string) [][]*File {
+	type bucket struct {
+		size uint64
+		hash string
+	}
+	buckets := map[bucket][]*File{}
+	var order []bucket
+	for _, file := range files {
+		if file.err || key(file) == "" {
+			continue
+		}
+		b := bucket{file.size, key(file)}
+		if _, ok := buckets[b]; !ok {
+			order = append(order, b)
+		}
+		buckets[b] = append(buckets[b], file)
+	}
+	var groups [][]*File
+	for _, b := range order {
+		if len(buckets[b]) > 1 {
+			groups = append(groups, buckets[b])
+		}
+	}
+	return groups
+}
+
+func newGroup(files []*File) Group {
+	g := Group{Size: files[0].size, SHA256: files[0].full}
+	for _, file := range files {
+		g.Files = append(g.Files, *file)
+	}
+	sort.Slice(g.Files, func(i, j int) bool { return g.Files[i].Path < g.Files[j].Path })
+	g.Wasted = g.Size * uint64(len(files)-1)
+	return g
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+// Package filetypes sorts files into categories such as videos, images and
+// documents, like the storage settings of Windows, and totals the space each
+// category takes on a drive. Files are classified by their extension, and by
+// their first bytes when the extension says nothing.
+package filetypes
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"checkpoint/pkg/config"
+)
+
+// Other is the category of files no category matches
+const Other = "Other"
+
+// OtherColor is the colour of Other
+const OtherColor = "250"
+
+// configFile holds custom categories in the config directory
+const configFile = "filetypes.yaml"
+
+// Signature is a magic number: bytes a file of some format has at an offset
+type Signature struct {
+	Offset int `json:"offset,omitempty" yaml:"offset,omitempty"`
+	// Hex is the bytes in hexadecimal, e.g. 25504446 for "%PDF"
+	Hex string `json:"hex" yaml:"hex"`
+}
+
+// Category is a kind of file
+type Category struct {
+	Name string `json:"name" yaml:"name"`
+	// Color is an ANSI colour number or a #rrggbb colour
+	Color string `json:"color,omitempty" yaml:"color,omitempty"`
+	// Extensions are matched case-insensitively, without the dot
+	Extensions []string `json:"extensions,omitempty" yaml:"extensions,omitempty"`
+	// Magic identifies files whose extension matches no category
+	Magic []Signature `json:"magic,omitempty" yaml:"magic,omitempty"`
+}
+
+// Defaults returns the built-in categories
+func Defaults() []Category {
+	return []Category{
+		{Name: "Videos", Color: "203",
+			Extensions: []string{"mp4", "m4v", "mkv", "webm", "avi", "mov", "wmv", "flv", "mpg", "mpeg", "m2ts", "mts", "3gp", "vob", "ogv"},
+			Magic: []Signature{
+				{Hex: "1a45dfa3"},            // Matroska and WebM
+				{Offset: 4, Hex: "66747970"}, // ftyp, MP4 and QuickTime
+				{Offset: 8, Hex: "41564920"}, // RIFF AVI
+			}},
+		{Name: "Images", Color: "39",
+			Extensions: []string{"jpg", "jpeg", "png", "gif", "bmp", "tif", "tiff", "webp", "heic", "heif", "avif", "svg", "ico",
+				"raw", "cr2", "cr3", "nef", "arw", "dng", "orf", "psd", "xcf"},
+			Magic: []Signature{
+				{Hex: "ffd8ff"},
+				{Hex: "89504e470d0a1a0a"},
+				{Hex: "47494638"},
+				{Hex: "49492a00"},
+				{Hex: "4d4d002a"},
+				{Offset: 8, Hex: "57454250"}, // RIFF WebP
+			}},
+		{Name: "Music", Color: "170",
+			Extensions: []string{"mp3", "flac", "wav", "ogg", "oga", "opus", "m4a", "aac", "wma", "aiff", "aif", "ape", "mid", "midi"},
+			Magic: []Signature{
+				{Hex: "494433"},              // ID3 tag of MP3
+				{Hex: "664c6143"},            // fLaC
+				{Hex: "4f676753"},            // OggS
+				{Offset: 8, Hex: "57415645"}, // RIFF WAVE
+			}},
+		{Name: "Documents", Color: "220",
+			Extensions: []string{"pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "odt", "ods", "odp", "odg", "rtf", "txt", "md",
+				"epub", "mobi", "tex", "csv", "pages", "numbers"},
+			Magic: []Signature{
+				{Hex: "25504446"},         // %PDF
+				{Hex: "d0cf11e0a1b11ae1"}, // older Office files
+			}},
+		{Name: "Code", Color: "78",
+			Extensions: []string{"go", "c", "h", "cc", "cpp", "hpp", "cxx", "rs", "py", "js", "mjs", "ts", "tsx", "jsx", "java", "kt",
+				"rb", "php", "sh", "bash", "zsh", "cs", "swift", "scala", "lua", "pl", "r", "m", "html", "css", "scss", "vue",
+				"json", "yaml", "yml", "toml", "xml", "sql", "ipynb"},
+			Magic: []Signature{
+				{Hex: "2321"}, // #! of scripts
+			}},
+		{Name: "Archives", Color: "208",
+			Extensions: []string{"zip", "tar", "gz", "tgz", "bz2", "tbz2", "xz", "txz", "zst", "lz4", "lzma", "7z", "rar", "cab",
+				"deb", "rpm", "apk", "jar", "whl", "snap", "flatpak", "appimage"},
+			Magic: []Signature{
+				{Hex: "504b0304"},                // zip
+				{Hex: "1f8b"},                    // gzip
+				{Hex: "425a68"},                  // bzip2
+				{Hex: "fd377a585a00"},            // xz
+				{Hex: "28b52ffd"},                // zstd
+				{Hex: "377abcaf271c"},            // 7z
+				{Hex: "526172211a07"},            // rar
+				{Offset: 257, Hex: "7573746172"}, // ustar
+			}},
+		{Name: "Disk images", Color: "99",
+			Extensions: []string{"iso", "img", "qcow", "qcow2", "vdi", "vmdk", "vhd", "vhdx", "dmg", "ova"},
+			Magic: []Signature{
+				{Hex: "514649fb"},                  // QEMU qcow
+				{Hex: "3c3c3c20"},                  // VirtualBox vdi
+				{Hex: "4b444d56"},                  // VMware vmdk
+				{Offset: 32769, Hex: "4344303031"}, // CD001 of ISO 9660
+			}},
+	}
+}
+
+type fileConfig struct {
+	Categories []Category `yaml:"categories"`
+}
+
+// Load returns the built-in categories with those of filetypes.yaml in the
+// config directory merged in, see Merge. On error it still returns the
+// defaults.
+func Load() ([]Category, error) {
+	var c fileConfig
+	if err := config.Load(configFile, &c); err != nil {
+		return Defaults(), err
+	}
+	merged := Merge(Defaults(), c.Categories)
+	if _, err := NewClassifier(merged); err != nil {
+		return Defaults(), fmt.Errorf("invalid %s: %v", configFile, err)
+	}
+	return merged, nil
+}
+
+// Merge adds custom categories to base. A custom category replaces the one
+// of the same name, and one without extensions and magic removes it.
+// Custom categories come first, so they win extensions claimed twice.
+func Merge(base, custom []Category) []Category {
+	var merged []Category
+	names := map[string]bool{}
+	for _, c := range custom {
+		names[strings.ToLower(c.Name)] = true
+		if len(c.Extensions) > 0 || len(c.Magic) > 0 {
+			merged = append(merged, c)
+		}
+	}
+	for _, c := range base {
+		if !names[strings.ToLower(c.Name)] {
+			merged = append(merged, c)
+		}
+	}
+	return merged
+}
+
+// palette colours custom categories that do not set a colour
+var palette = []string{"204", "45", "113", "228", "177", "215", "81", "167"}
+
+type signature struct {
+	offset   int
+	bytes    []byte
+	category string
+}
+
+// Classifier tells the category of files
+type Classifier struct {
+	categories []Category
+	extensions map[string]string
+	magic      []signature
+}
+
+// NewClassifier checks categories and prepares them for classifying. The
+// first category listing an extension gets it.
+func NewClassifier(categories []Category) (*Classifier, error) {
+	c := &Classifier{extensions: map[string]string{}}
+	seen := map[string]bool{}
+	for _, cat := range categories {
+		name := strings.TrimSpace(cat.Name)
+		if name == "" {
+			return nil, fmt.Errorf("category without a name")
+		}
+		if strings.EqualFold(name, Other) {
+			return nil, fmt.Errorf("%q is for files no category matches and cannot be defined", Other)
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("category %q is defined twice", name)
+		}
+		seen[strings.ToLower(name)] = true
+		cat.Name = name
+		if cat.Color == "" {
+			cat.Color = palette[len(c.categories)%len(palette)]
+		}
+		c.categories = append(c.categories, cat)
+
+		for _, ext := range cat.Extensions {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if _, ok := c.extensions[ext]; !ok && ext != "" {
+				c.extensions[ext] = name
+			}
+		}
+		for _, m := range cat.Magic {
+			b, err := hex.DecodeString(strings.ReplaceAll(m.Hex, " ", ""))
+			if err != nil || len(b) == 0 || m.Offset < 0 {
+				return nil, fmt.Errorf("invalid magic %q at offset %d for %s", m.Hex, m.Offset, name)
+			}
+			c.magic = append(c.magic, signature{offset: m.Offset, bytes: b, category: name})
+		}
+	}
+	return c, nil
+}
+
+// Categories returns the categories in the order they were given
+func (c *Classifier) Categories() []Category {
+	return c.categories
+}
+
+// Color returns the colour of a category, Other included
+func (c *Classifier) Color(name string) string {
+	for _, cat := range c.categories {
+		if cat.Name == name {
+			return cat.Color
+		}
+	}
+	return OtherColor
+}
+
+// ByExtension returns the category of a file name, or "" when its
+// extension matches none
+func (c *Classifier) ByExtension(name string) string {
+	i := strings.LastIndexByte(name, '.')
+	if i < 0 {
+		return ""
+	}
+	return c.extensions[strings.ToLower(name[i+1:])]
+}
+
+// sniffHead is how much of a file is read at once for the signatures; those
+// further in are read on their own
+const sniffHead = 512
+
+// Sniff returns the category of a file from its first bytes, or "" when no
+// signature matches
+func (c *Classifier) Sniff(path string) (string, error) {
+	if len(c.magic) == 0 {
+		return "", nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, sniffHead)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	head = head[:n]
+	for _, m := range c.magic {
+		end := m.offset + len(m.bytes)
+		if end <= len(head) {
+			if bytes.Equal(head[m.offset:end], m.bytes) {
+				return m.category, nil
+			}
+			continue
+		}
+		if len(head) < sniffHead {
+			// The file ends before the signature would
+			continue
+		}
+		buf := make([]byte, len(m.bytes))
+		if _, err := f.ReadAt(buf, int64(m.offset)); err == nil && bytes.Equal(buf, m.bytes) {
+			return m.category, nil
+		}
+	}
+	return "", nil
+}
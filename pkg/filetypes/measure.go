@@ -0,0 +1,134 @@
+package filetypes
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// Total is the space the files of one category take
+type Total struct {
+	Name  string `json:"name" yaml:"name"`
+	Color string `json:"color" yaml:"color"`
+	Files int    `json:"files" yaml:"files"`
+	Bytes uint64 `json:"bytes" yaml:"bytes"`
+}
+
+// Drive is the space each category takes on a drive or below a path
+type Drive struct {
+	Name        string   `json:"name" yaml:"name"`
+	MountPoints []string `json:"mount_points" yaml:"mount_points"`
+	// SizeBytes and UsedBytes are those of the drive, 0 for a path
+	SizeBytes uint64 `json:"size_bytes" yaml:"size_bytes"`
+	UsedBytes uint64 `json:"used_bytes" yaml:"used_bytes"`
+	// Categories are those with files, largest first
+	Categories []Total `json:"categories" yaml:"categories"`
+	// Files and Bytes total every category, which is less than the used
+	// space by what could not be read and what the filesystem keeps
+	Files int    `json:"files" yaml:"files"`
+	Bytes uint64 `json:"total_bytes" yaml:"total_bytes"`
+	// Sniffed counts the files classified by their first bytes
+	Sniffed int `json:"sniffed" yaml:"sniffed"`
+	Errors  int `json:"errors" yaml:"errors"`
+	// Partial is set when the walk was cancelled before it finished
+	Partial bool `json:"partial" yaml:"partial"`
+}
+
+// Options configures Measure
+type Options struct {
+	// Sniff reads the first bytes of files whose extension matches no
+	// category
+	Sniff bool
+	// CrossFilesystems descends into filesystems mounted below the roots
+	CrossFilesystems bool
+}
+
+// Measure walks the roots, the mount points of one drive or any
+// directories, and totals the space taken by each category. Every root
+// stays on its own filesystem unless asked not to, and hard links count
+// once. Cancelling ctx stops early; the result then has Partial set.
+func Measure(ctx context.Context, c *Classifier, roots []string, opts Options) (Drive, error) {
+	d := Drive{MountPoints: []string{}, Categories: []Total{}}
+	totals := map[string]*Total{}
+	seen := map[[2]uint64]bool{}
+
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return d, fmt.Errorf("invalid path %s: %v", root, err)
+		}
+		var rootStat syscall.Stat_t
+		if err := syscall.Stat(abs, &rootStat); err != nil {
+			return d, fmt.Errorf("failed to stat %s: %v", abs, err)
+		}
+		d.MountPoints = append(d.MountPoints, abs)
+
+		filepath.WalkDir(abs, func(path string, entry fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return filepath.SkipAll
+			}
+			if err != nil {
+				d.Errors++
+				return nil
+			}
+			if entry.Type()&fs.ModeType != 0 && !entry.IsDir() {
+				return nil
+			}
+			var st syscall.Stat_t
+			if err := syscall.Lstat(path, &st); err != nil {
+				d.Errors++
+				return nil
+			}
+			if entry.IsDir() {
+				if !opts.CrossFilesystems && st.Dev != rootStat.Dev {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			key := [2]uint64{uint64(st.Dev), uint64(st.Ino)}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+
+			name := c.ByExtension(entry.Name())
+			if name == "" && opts.Sniff && st.Size > 0 {
+				if name, err = c.Sniff(path); err != nil {
+					d.Errors++
+				} else if name != "" {
+					d.Sniffed++
+				}
+			}
+			if name == "" {
+				name = Other
+			}
+			t, ok := totals[name]
+			if !ok {
+				t = &Total{Name: name, Color: c.Color(name)}
+				totals[name] = t
+			}
+			size := uint64(st.Blocks) * 512
+			t.Files++
+			t.Bytes += size
+			d.Files++
+			d.Bytes += size
+			return nil
+		})
+	}
+
+	for _, t := range totals {
+		d.Categories = append(d.Categories, *t)
+	}
+	sort.Slice(d.Categories, func(i, j int) bool {
+		a, b := d.Categories[i], d.Categories[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Name < b.Name
+	})
+	d.Partial = ctx.Err() != nil
+	return d, nil
+}
@@ -0,0 +1,194 @@
+package history
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+const (
+	// forecastWindow is how much recent history a forecast looks at
+	forecastWindow = Month
+	// forecastPoints bounds the samples fed to the O(n²) regression
+	forecastPoints = 200
+	// minForecastSpan is the least history worth extrapolating from
+	minForecastSpan = 6 * time.Hour
+	// flatShare is the monthly growth, as a share of the size, below which
+	// usage counts as flat
+	flatShare = 0.002
+	// maxOneOffDrops is how many big drops still count as one-off
+	// deletions; more of them are regular cleanups and part of the trend
+	maxOneOffDrops = 3
+)
+
+// Predict estimates when the filesystem behind samples runs out of space.
+// It fits a Theil-Sen line (the median slope between every pair of
+// samples) to the last month after taking out one-off deletions, so a big
+// cleanup or a burst of activity does not swing the estimate. It returns nil
+// when there is too little history.
+func Predict(samples []Sample) *disk.Forecast {
+	if len(samples) == 0 {
+		return nil
+	}
+	latest := samples[len(samples)-1]
+	samples = thin(Since(samples, latest.Time.Add(-forecastWindow)), forecastPoints)
+	span := latest.Time.Sub(samples[0].Time)
+	if len(samples) < 3 || span < minForecastSpan {
+		return nil
+	}
+
+	days := make([]float64, len(samples))
+	used := withoutOneOffDrops(samples)
+	for i, s := range samples {
+		days[i] = s.Time.Sub(samples[0].Time).Hours() / 24
+	}
+	slope, intercept := theilSen(days, used)
+
+	f := &disk.Forecast{
+		BytesPerDay: slope,
+		HistoryDays: span.Hours() / 24,
+		Confidence:  confidence(days, used, slope, intercept),
+	}
+	switch {
+	case math.Abs(slope)*forecastWindow.Hours()/24 < flatShare*float64(latest.Size):
+		f.Trend = disk.TrendFlat
+	case slope < 0:
+		f.Trend = disk.TrendShrinking
+	default:
+		f.Trend = disk.TrendGrowing
+		f.DaysToFull = float64(latest.Available) / slope
+		full := latest.Time.Add(time.Duration(f.DaysToFull * 24 * float64(time.Hour)))
+		f.FullAt = &full
+	}
+	return f
+}
+
+// AddForecasts sets the forecast of every group whose first disk has history,
+// with samples keyed by mount point as ForGroups returns them
+func AddForecasts(groups []disk.DriveGroup, samples map[string][]Sample) {
+	for i := range groups {
+		if len(groups[i].Disks) > 0 {
+			groups[i].Forecast = Predict(samples[groups[i].Disks[0].MountPoint])
+		}
+	}
+}
+
+// Forecasts predicts every filesystem in samples, keyed like samples by
+// mount point. Filesystems with too little history are left out.
+func Forecasts(samples map[string][]Sample) map[string]*disk.Forecast {
+	result := map[string]*disk.Forecast{}
+	for mount, s := range samples {
+		if f := Predict(s); f != nil {
+			result[mount] = f
+		}
+	}
+	return result
+}
+
+// thin keeps at most n samples spread evenly over the slice, always
+// keeping the newest
+func thin(samples []Sample, n int) []Sample {
+	if len(samples) <= n {
+		return samples
+	}
+	result := make([]Sample, 0, n)
+	step := float64(len(samples)-1) / float64(n-1)
+	for i := 0; i < n; i++ {
+		result = append(result, samples[int(math.Round(float64(i)*step))])
+	}
+	return result
+}
+
+// withoutOneOffDrops returns the used space with a few unusually large drops
+// taken out, as if the space had never been used: after deleting a 50 GB
+// backup, the disk keeps filling at the same pace as before
+func withoutOneOffDrops(samples []Sample) []float64 {
+	steps := make([]float64, len(samples)-1)
+	for i := 1; i < len(samples); i++ {
+		steps[i-1] = float64(samples[i].Used) - float64(samples[i-1].Used)
+	}
+	center := median(steps)
+	deviations := make([]float64, len(steps))
+	for i, d := range steps {
+		deviations[i] = math.Abs(d - center)
+	}
+	// 1.4826 × MAD estimates the standard deviation of normal noise
+	limit := math.Max(6*1.4826*median(deviations), 0.001*float64(samples[len(samples)-1].Size))
+
+	drops := []int{}
+	for i, d := range steps {
+		if d < 0 && -d > limit {
+			drops = append(drops, i)
+		}
+	}
+	if len(drops) > maxOneOffDrops {
+		drops = nil
+	}
+	for _, i := range drops {
+		steps[i] = center
+	}
+
+	used := make([]float64, len(samples))
+	used[0] = float64(samples[0].Used)
+	for i, d := range steps {
+		used[i+1] = used[i] + d
+	}
+	return used
+}
+
+// theilSen fits y = intercept + slope·x using the median of the slopes
+// between all pairs of points, which ignores up to about 29% outliers
+func theilSen(x, y []float64) (float64, float64) {
+	slopes := make([]float64, 0, len(x)*(len(x)-1)/2)
+	for i := range x {
+		for j := i + 1; j < len(x); j++ {
+			if x[j] != x[i] {
+				slopes = append(slopes, (y[j]-y[i])/(x[j]-x[i]))
+			}
+		}
+	}
+	slope := median(slopes)
+	offsets := make([]float64, len(x))
+	for i := range x {
+		offsets[i] = y[i] - slope*x[i]
+	}
+	return slope, median(offsets)
+}
+
+// confidence grades a fit by how much history it rests on and how far the
+// samples stray from the line compared to the growth over that time
+func confidence(x, y []float64, slope, intercept float64) string {
+	residuals := make([]float64, len(x))
+	for i := range x {
+		residuals[i] = math.Abs(y[i] - (intercept + slope*x[i]))
+	}
+	span := x[len(x)-1]
+	noise := 1.4826 * median(residuals)
+	growth := math.Abs(slope) * span
+	ratio := math.Inf(1)
+	if growth > 0 {
+		ratio = noise / growth
+	}
+	switch {
+	case span >= 7 && len(x) >= 20 && ratio < 0.1:
+		return disk.ConfidenceHigh
+	case span >= 2 && ratio < 0.3:
+		return disk.ConfidenceMedium
+	}
+	return disk.ConfidenceLow
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
@@ -0,0 +1,495 @@
+// Package history remembers how full each filesystem was over time. Every
+// scan adds a sample per filesystem to a small JSON Lines file under
+// $XDG_STATE_HOME/checkpoint/history; old samples are thinned out to one per
+// hour and then one per day, and eventually dropped.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"checkpoint/pkg/config"
+	"checkpoint/pkg/disk"
+)
+
+// Sample is the usage of one filesystem at one moment
+type Sample struct {
+	Time       time.Time `json:"time" yaml:"time"`
+	MountPoint string    `json:"mount_point" yaml:"mount_point"`
+	Size       uint64    `json:"size_bytes" yaml:"size_bytes"`
+	Used       uint64    `json:"used_bytes" yaml:"used_bytes"`
+	Available  uint64    `json:"available_bytes" yaml:"available_bytes"`
+}
+
+// Retention decides how long samples are kept and how densely
+type Retention struct {
+	// Raw keeps every sample this long
+	Raw time.Duration `yaml:"raw"`
+	// Hourly then keeps the last sample of each hour
+	Hourly time.Duration `yaml:"hourly"`
+	// Daily then keeps the last sample of each day; older ones are dropped
+	Daily time.Duration `yaml:"daily"`
+	// MinInterval skips samples taken sooner than this after the previous one
+	MinInterval time.Duration `yaml:"min_interval"`
+}
+
+// DefaultRetention keeps two days of every scan, a month of hours and two
+// years of days: at most a few thousand samples per filesystem
+var DefaultRetention = Retention{
+	Raw:         48 * time.Hour,
+	Hourly:      30 * 24 * time.Hour,
+	Daily:       2 * 365 * 24 * time.Hour,
+	MinInterval: 5 * time.Minute,
+}
+
+// Config is history.yaml in the config directory
+type Config struct {
+	// Disabled stops recording; existing history can still be shown
+	Disabled  bool `yaml:"disabled"`
+	Retention `yaml:",inline"`
+}
+
+// LoadConfig reads history.yaml, falling back to DefaultRetention
+func LoadConfig() (Config, error) {
+	cfg := Config{Retention: DefaultRetention}
+	if err := config.Load("history.yaml", &cfg); err != nil {
+		return Config{Retention: DefaultRetention}, err
+	}
+	return cfg, nil
+}
+
+// Store is a directory of per-filesystem history files. It is safe for
+// concurrent use; writes are also locked against other processes.
+type Store struct {
+	dir string
+	cfg Config
+
+	mu    sync.Mutex
+	uuids map[string]string
+	// last remembers the newest sample per file so frequent scans skip the
+	// file entirely until MinInterval has passed
+	last map[string]time.Time
+}
+
+// Open opens the store in the state directory with the configured retention
+func Open() (*Store, error) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return OpenDir(filepath.Join(dir, "history"), cfg), nil
+}
+
+// OpenDir opens a store in an explicit directory
+func OpenDir(dir string, cfg Config) *Store {
+	return &Store{dir: dir, cfg: cfg, last: map[string]time.Time{}}
+}
+
+// Dir returns the directory holding the history files
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Key names the history of the filesystem a disk is on: its UUID when it has
+// one, so the history follows the filesystem to a new mount point, and the
+// mount point otherwise. Keys are safe to use as file names.
+func (s *Store) Key(d disk.Disk) string {
+	s.mu.Lock()
+	if s.uuids == nil {
+		s.uuids = disk.FilesystemUUIDs()
+	}
+	uuids := s.uuids
+	s.mu.Unlock()
+
+	if uuid := disk.FilesystemUUID(d, uuids); uuid != "" {
+		return fileName("uuid-" + uuid)
+	}
+	return fileName("mount-" + d.MountPoint)
+}
+
+// recorded reports whether a disk is a filesystem of its own rather than a
+// path the user added on top of one
+func recorded(d disk.Disk) bool {
+	switch d.Type {
+	case disk.TypePath, disk.TypeManual, disk.TypeSymlink:
+		return false
+	}
+	return d.Size > 0
+}
+
+// Record adds a sample for every filesystem among disks, once per filesystem
+// even when it is mounted in several places
+func (s *Store) Record(disks []disk.Disk, at time.Time) error {
+	if s.cfg.Disabled {
+		return nil
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", s.dir, err)
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	seen := map[string]bool{}
+	for _, d := range disks {
+		key := s.Key(d)
+		if !recorded(d) || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if err := s.migrate(d, key); err != nil {
+			return err
+		}
+		sample := Sample{Time: at, MountPoint: d.MountPoint, Size: d.Size, Used: d.Used, Available: d.Available}
+		if err := s.add(key, sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add appends one sample to a history file and thins the file out
+func (s *Store) add(key string, sample Sample) error {
+	s.mu.Lock()
+	last, known := s.last[key]
+	s.mu.Unlock()
+	if known && sample.Time.Sub(last) < s.cfg.MinInterval {
+		return nil
+	}
+
+	samples, err := s.load(key)
+	if err != nil {
+		return err
+	}
+	if n := len(samples); n > 0 && sample.Time.Sub(samples[n-1].Time) < s.cfg.MinInterval {
+		s.remember(key, samples[n-1].Time)
+		return nil
+	}
+	samples = Downsample(append(samples, sample), sample.Time, s.cfg.Retention)
+	if err := s.save(key, samples); err != nil {
+		return err
+	}
+	s.remember(key, sample.Time)
+	return nil
+}
+
+func (s *Store) remember(key string, t time.Time) {
+	s.mu.Lock()
+	s.last[key] = t
+	s.mu.Unlock()
+}
+
+// Samples returns the history of the filesystem a disk is on, oldest first
+func (s *Store) Samples(d disk.Disk) ([]Sample, error) {
+	return s.load(s.Key(d))
+}
+
+// ForGroups loads the history of every disk in groups, see ForDisks
+func (s *Store) ForGroups(groups []disk.DriveGroup) map[string][]Sample {
+	disks := []disk.Disk{}
+	for _, g := range groups {
+		disks = append(disks, g.Disks...)
+	}
+	return s.ForDisks(disks)
+}
+
+// ForDisks loads the history of each disk, keyed by its mount point. Disks
+// without history are left out.
+func (s *Store) ForDisks(disks []disk.Disk) map[string][]Sample {
+	result := map[string][]Sample{}
+	for _, d := range disks {
+		if samples, err := s.Samples(d); err == nil && len(samples) > 0 {
+			result[d.MountPoint] = samples
+		}
+	}
+	return result
+}
+
+func (s *Store) path(key string) string {
+	return filepath.Join(s.dir, key+".jsonl")
+}
+
+// fileName makes a key safe to use as a file name. Slashes become
+// underscores, which keeps common names such as mount-_home readable, and
+// every other byte but letters, digits, dashes and dots is escaped as %XX,
+// so that no two keys share a file.
+func fileName(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '.':
+			b.WriteByte(c)
+		case c == '/':
+			b.WriteByte('_')
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// legacyFileName is how fileName used to map keys, turning every unsafe
+// character into _, so that "/mnt/a b" and "/mnt/a_b" shared a file
+func legacyFileName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// migrate moves the samples of a mount point out of the file it may have
+// shared under legacyFileName into its own. The caller holds the lock.
+func (s *Store) migrate(d disk.Disk, key string) error {
+	legacy := legacyFileName("mount-" + d.MountPoint)
+	if key != fileName("mount-"+d.MountPoint) || legacy == key {
+		return nil
+	}
+	if _, err := os.Stat(s.path(key)); err == nil {
+		return nil
+	}
+	samples, err := s.load(legacy)
+	if err != nil || len(samples) == 0 {
+		return err
+	}
+	var mine, others []Sample
+	for _, sample := range samples {
+		if sample.MountPoint == d.MountPoint {
+			mine = append(mine, sample)
+		} else {
+			others = append(others, sample)
+		}
+	}
+	if len(mine) == 0 {
+		return nil
+	}
+	if err := s.save(key, mine); err != nil {
+		return err
+	}
+	if len(others) == 0 {
+		return os.Remove(s.path(legacy))
+	}
+	return s.save(legacy, others)
+}
+
+func (s *Store) load(key string) ([]Sample, error) {
+	path := s.path(key)
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer file.Close()
+
+	samples := []Sample{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var sample Sample
+		// Skip a line a crash left half-written rather than losing the file
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err == nil {
+			samples = append(samples, sample)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	sort.SliceStable(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	return samples, nil
+}
+
+// save rewrites a history file atomically
+func (s *Store) save(key string, samples []Sample) error {
+	path := s.path(key)
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	for _, sample := range samples {
+		if err = enc.Encode(sample); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// lock takes an exclusive lock on the store so a scheduled recording and an
+// open interface do not overwrite each other's samples
+func (s *Store) lock() (func(), error) {
+	path := filepath.Join(s.dir, ".lock")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %v", s.dir, err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock %s: %v", s.dir, err)
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// Downsample applies a retention policy to samples sorted oldest first:
+// recent samples stay as they are, older ones are reduced to the last
+// sample of each hour and then of each day, and the oldest are dropped
+func Downsample(samples []Sample, now time.Time, r Retention) []Sample {
+	result := []Sample{}
+	for i, sample := range samples {
+		age := now.Sub(sample.Time)
+		var bucket time.Duration
+		switch {
+		case age <= r.Raw:
+			result = append(result, sample)
+			continue
+		case age <= r.Hourly:
+			bucket = time.Hour
+		case age <= r.Daily:
+			bucket = 24 * time.Hour
+		default:
+			continue
+		}
+		// Keep the sample unless the next one falls in the same bucket
+		if i+1 < len(samples) && samples[i+1].Time.Truncate(bucket).Equal(sample.Time.Truncate(bucket)) &&
+			now.Sub(samples[i+1].Time) > r.Raw {
+			continue
+		}
+		result = append(result, sample)
+	}
+	return result
+}
+
+// Change is how much the used space changed over a period
+type Change struct {
+	From  Sample `json:"from" yaml:"from"`
+	To    Sample `json:"to" yaml:"to"`
+	Bytes int64  `json:"bytes" yaml:"bytes"`
+	// Partial is set when the history does not reach back the whole period
+	Partial bool `json:"partial" yaml:"partial"`
+}
+
+// Growth compares the newest sample with the one from period ago, or the
+// oldest one when history does not go back that far
+func Growth(samples []Sample, period time.Duration) (Change, bool) {
+	if len(samples) < 2 {
+		return Change{}, false
+	}
+	to := samples[len(samples)-1]
+	since := to.Time.Add(-period)
+	from := samples[0]
+	partial := from.Time.After(since)
+	for _, sample := range samples {
+		if sample.Time.After(since) {
+			break
+		}
+		from = sample
+	}
+	return Change{From: from, To: to, Bytes: int64(to.Used) - int64(from.Used), Partial: partial}, true
+}
+
+// Since returns the samples taken at or after t
+func Since(samples []Sample, t time.Time) []Sample {
+	i := sort.Search(len(samples), func(i int) bool { return !samples[i].Time.Before(t) })
+	return samples[i:]
+}
+
+// Periods compared in a Report
+const (
+	Day   = 24 * time.Hour
+	Week  = 7 * Day
+	Month = 30 * Day
+)
+
+// Report is the history of one filesystem with its growth over the last
+// day, week and month
+type Report struct {
+	Key        string  `json:"key" yaml:"key"`
+	MountPoint string  `json:"mount_point" yaml:"mount_point"`
+	Day        *Change `json:"day,omitempty" yaml:"day,omitempty"`
+	Week       *Change `json:"week,omitempty" yaml:"week,omitempty"`
+	Month      *Change `json:"month,omitempty" yaml:"month,omitempty"`
+	// Forecast is missing when there is too little history
+	Forecast *disk.Forecast `json:"forecast,omitempty" yaml:"forecast,omitempty"`
+	Samples  []Sample       `json:"samples" yaml:"samples"`
+}
+
+// NewReport summarises the samples of one filesystem
+func NewReport(key string, samples []Sample) Report {
+	r := Report{Key: key, Samples: samples}
+	if len(samples) == 0 {
+		r.Samples = []Sample{}
+		return r
+	}
+	r.MountPoint = samples[len(samples)-1].MountPoint
+	for _, p := range []struct {
+		change **Change
+		period time.Duration
+	}{{&r.Day, Day}, {&r.Week, Week}, {&r.Month, Month}} {
+		if c, ok := Growth(samples, p.period); ok {
+			*p.change = &c
+		}
+	}
+	r.Forecast = Predict(samples)
+	return r
+}
+
+// Reports returns the history of every recorded filesystem, by mount point
+func (s *Store) Reports() ([]Report, error) {
+	// Not a glob: the state directory may hold pattern characters
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list %s: %v", s.dir, err)
+	}
+	reports := []Report{}
+	for _, entry := range entries {
+		key, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		samples, err := s.load(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(samples) > 0 {
+			reports = append(reports, NewReport(key, samples))
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].MountPoint < reports[j].MountPoint })
+	return reports, nil
+}
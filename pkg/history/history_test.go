@@ -0,0 +1,109 @@
+package history
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+// mount is a filesystem without a UUID, so its history is keyed by mount
+// point
+func mount(mountPoint string, used uint64) disk.Disk {
+	return disk.Disk{MountPoint: mountPoint, Type: disk.TypeNetwork, Size: 100, Used: used, Available: 100 - used}
+}
+
+func TestReports(t *testing.T) {
+	// the state directory may look like a glob pattern
+	s := OpenDir(filepath.Join(t.TempDir(), "state [1]", "history"), Config{Retention: DefaultRetention})
+	if reports, err := s.Reports(); err != nil || len(reports) != 0 {
+		t.Fatalf("before recording got %v, %v", reports, err)
+	}
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		at := start.Add(time.Duration(i) * time.Hour)
+		if err := s.Record([]disk.Disk{mount("/srv", 10+uint64(i)), mount("/home", 50)}, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reports, err := s.Reports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mounts []string
+	for _, r := range reports {
+		mounts = append(mounts, r.MountPoint)
+	}
+	if want := []string{"/home", "/srv"}; !reflect.DeepEqual(mounts, want) {
+		t.Errorf("reports for %v, want %v", mounts, want)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"mount-/home", "mount-_home"},
+		{"uuid-1b2c3d4e-5f60", "uuid-1b2c3d4e-5f60"},
+		{"mount-/mnt/a b", "mount-_mnt_a%20b"},
+		{"mount-/mnt/a_b", "mount-_mnt_a%5Fb"},
+		{"mount-/mnt/a%b", "mount-_mnt_a%25b"},
+		{"mount-/media/Café", "mount-_media_Caf%C3%A9"},
+	}
+	for _, tt := range tests {
+		if got := fileName(tt.key); got != tt.want {
+			t.Errorf("fileName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMountPointsKeepSeparateHistories(t *testing.T) {
+	s := OpenDir(t.TempDir(), Config{Retention: DefaultRetention})
+	disks := []disk.Disk{mount("/mnt/a b", 10), mount("/mnt/a_b", 20), mount("/mnt/a/b", 30)}
+	if err := s.Record(disks, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range disks {
+		samples, err := s.Samples(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != 1 || samples[0].MountPoint != d.MountPoint || samples[0].Used != d.Used {
+			t.Errorf("%s has samples %+v", d.MountPoint, samples)
+		}
+	}
+}
+
+func TestRecordMovesSamplesOutOfASharedFile(t *testing.T) {
+	s := OpenDir(t.TempDir(), Config{Retention: DefaultRetention})
+	at := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	// both mount points shared one file before it was escaped
+	shared := []Sample{
+		{Time: at, MountPoint: "/mnt/a b", Used: 10},
+		{Time: at, MountPoint: "/mnt/a/b", Used: 30},
+	}
+	if err := s.save(legacyFileName("mount-/mnt/a b"), shared); err != nil {
+		t.Fatal(err)
+	}
+	disks := []disk.Disk{mount("/mnt/a b", 11), mount("/mnt/a/b", 31)}
+	if err := s.Record(disks, at.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range disks {
+		samples, err := s.Samples(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(samples) != 2 {
+			t.Fatalf("%s has samples %+v", d.MountPoint, samples)
+		}
+		for _, sample := range samples {
+			if sample.MountPoint != d.MountPoint {
+				t.Errorf("%s has a sample of %s", d.MountPoint, sample.MountPoint)
+			}
+		}
+	}
+}
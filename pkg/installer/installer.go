@@ -11,6 +11,22 @@ import (
 
 // ExecuteCommand runs an installation command with optional target disk
 func ExecuteCommand(command string, targetDisk *disk.Disk) error {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	return ExecuteArgs(parts, targetDisk)
+}
+
+// ExecuteArgs runs an already split command line with optional target disk.
+// Unlike ExecuteCommand the arguments are passed through untouched, so quoted
+// arguments from a shell survive.
+func ExecuteArgs(parts []string, targetDisk *disk.Disk) error {
+	if len(parts) == 0 {
+		return fmt.Errorf("empty command")
+	}
+	command := strings.Join(parts, " ")
+
 	// Security check: warn about sudo
 	if strings.Contains(command, "sudo") {
 		fmt.Printf("\n⚠️  Warning: Command contains 'sudo'. Consider running without elevated privileges.\n")
@@ -23,11 +39,6 @@ func ExecuteCommand(command string, targetDisk *disk.Disk) error {
 		fmt.Printf("📍 Target location: %s\n", targetDisk.MountPoint)
 	}
 
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return fmt.Errorf("empty command")
-	}
-
 	// Create command without automatic sudo handling
 	cmd := exec.Command(parts[0], parts[1:]...)
 
@@ -59,7 +70,7 @@ func ExecuteCommand(command string, targetDisk *disk.Disk) error {
 	
 	// Check if the error is due to permissions
 	if err != nil && isPermissionError(command, err) {
-		return promptForSudo(command, targetDisk)
+		return promptForSudo(parts, targetDisk)
 	}
 	
 	return err
@@ -85,7 +96,7 @@ func isPermissionError(command string, err error) bool {
 }
 
 // promptForSudo asks the user if they want to retry with sudo
-func promptForSudo(command string, targetDisk *disk.Disk) error {
+func promptForSudo(parts []string, targetDisk *disk.Disk) error {
 	fmt.Printf("\n⚠️  The command failed, likely due to missing permissions.\n")
 	fmt.Printf("\n🔒 SECURITY WARNING:\n")
 	fmt.Printf("   Running commands with sudo gives them full system access.\n")
@@ -98,13 +109,12 @@ func promptForSudo(command string, targetDisk *disk.Disk) error {
 	
 	if response == "yes" || response == "y" {
 		// Prepend sudo if not already present
-		if !strings.HasPrefix(command, "sudo ") {
-			command = "sudo " + command
+		if parts[0] != "sudo" {
+			parts = append([]string{"sudo"}, parts...)
 		}
 		
-		fmt.Printf("\n🚀 Executing with sudo: %s\n", command)
+		fmt.Printf("\n🚀 Executing with sudo: %s\n", strings.Join(parts, " "))
 		
-		parts := strings.Fields(command)
 		cmd := exec.Command(parts[0], parts[1:]...)
 		
 		// Note: When using sudo, we can't set custom environment variables
@@ -0,0 +1,369 @@
+// Package metrics exports disk usage, I/O counters and disk health in the
+// Prometheus text format. Scans run in the background and scrapes are
+// answered from the last one, so a hanging network mount delays fresh data
+// but never a scrape.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+// Defaults for Options
+const (
+	DefaultInterval       = 30 * time.Second
+	DefaultHealthInterval = 10 * time.Minute
+	DefaultTimeout        = 2 * time.Minute
+)
+
+// Options configure an Exporter. Zero values use the defaults.
+type Options struct {
+	// Interval is the time between scans
+	Interval time.Duration
+	// HealthInterval is the time between SMART and temperature readings,
+	// which are slow and rarely change
+	HealthInterval time.Duration
+	// Timeout bounds a scan. A scan that takes longer, usually stuck on a
+	// hanging network mount, counts as failed.
+	Timeout time.Duration
+	// Scan lists the disks, disk.NewManager().ScanDisks by default
+	Scan func() ([]disk.Disk, error)
+	// Health reads the health of the whole disks, disk.LoadHealth by default
+	Health func(disks []disk.Disk) []disk.DiskHealth
+	// IOStats reads the I/O counters, disk.ReadIOStats by default
+	IOStats func() (map[string]disk.IOStats, error)
+}
+
+// snapshot is everything one scrape reports
+type snapshot struct {
+	disks    []disk.Disk
+	groups   map[string]string // mount point -> group name
+	uuids    map[string]string // device -> filesystem UUID
+	io       map[string]disk.IOStats
+	health   []disk.DiskHealth
+	scanned  time.Time
+	duration time.Duration
+}
+
+// Exporter scans periodically and serves the results on /metrics
+type Exporter struct {
+	opts Options
+
+	mu       sync.RWMutex
+	last     snapshot
+	scanning bool
+	// stuck is set while a scan that ran out of time has not returned
+	stuck    bool
+	scans    uint64
+	errors   uint64
+	healthAt time.Time
+}
+
+// NewExporter creates an exporter. Call Run to start scanning.
+func NewExporter(opts Options) *Exporter {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = DefaultHealthInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Scan == nil {
+		opts.Scan = func() ([]disk.Disk, error) {
+			dm := disk.NewManager()
+			if err := dm.ScanDisks(); err != nil {
+				return nil, err
+			}
+			return dm.GetDisks(), nil
+		}
+	}
+	if opts.Health == nil {
+		opts.Health = disk.LoadHealth
+	}
+	if opts.IOStats == nil {
+		opts.IOStats = disk.ReadIOStats
+	}
+	return &Exporter{opts: opts}
+}
+
+// Run scans right away and then every interval until ctx is done. A scan
+// that is still running when the next one is due is not started twice.
+func (e *Exporter) Run(ctx context.Context) {
+	go e.scan()
+	ticker := time.NewTicker(e.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			go e.scan()
+		}
+	}
+}
+
+// scan takes a new snapshot. Errors are counted and the previous snapshot
+// stays in place. A scan that runs out of time is left to finish on its
+// own, and until it does every scan due counts as failed rather than
+// getting stuck behind it.
+func (e *Exporter) scan() {
+	e.mu.Lock()
+	if e.scanning {
+		e.mu.Unlock()
+		return
+	}
+	e.scans++
+	if e.stuck {
+		e.errors++
+		e.mu.Unlock()
+		return
+	}
+	e.scanning = true
+	healthDue := time.Since(e.healthAt) >= e.opts.HealthInterval
+	health := e.last.health
+	e.mu.Unlock()
+
+	type result struct {
+		next   snapshot
+		failed bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		next, err := e.collect(healthDue, health)
+		done <- result{next, err != nil}
+	}()
+	timer := time.NewTimer(e.opts.Timeout)
+	defer timer.Stop()
+	var res result
+	select {
+	case res = <-done:
+	case <-timer.C:
+		e.mu.Lock()
+		e.scanning, e.stuck = false, true
+		e.errors++
+		e.mu.Unlock()
+		go func() {
+			<-done
+			e.mu.Lock()
+			e.stuck = false
+			e.mu.Unlock()
+		}()
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scanning = false
+	if res.failed {
+		e.errors++
+	}
+	if res.next.scanned.IsZero() {
+		return
+	}
+	if healthDue {
+		e.healthAt = res.next.scanned
+	}
+	e.last = res.next
+}
+
+// collect scans the disks and reads their I/O counters, and their health
+// when it is due. Without disks the snapshot has no scan time.
+func (e *Exporter) collect(healthDue bool, health []disk.DiskHealth) (snapshot, error) {
+	start := time.Now()
+	disks, err := e.opts.Scan()
+	if err != nil {
+		return snapshot{}, err
+	}
+	next := snapshot{disks: disks, groups: map[string]string{}, uuids: disk.FilesystemUUIDs()}
+	for _, g := range disk.GroupDisks(disks) {
+		for _, d := range g.Disks {
+			next.groups[d.MountPoint] = g.Name
+		}
+	}
+	next.io, err = e.opts.IOStats()
+	if healthDue {
+		health = e.opts.Health(disks)
+	}
+	next.health = health
+	next.scanned = time.Now()
+	next.duration = next.scanned.Sub(start)
+	return next, err
+}
+
+// ServeHTTP answers scrapes from the last scan
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	e.Write(w)
+}
+
+// Handler serves /metrics and a short index page on /
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, `<html><head><title>checkpoint</title></head><body><h1>checkpoint</h1><p><a href="/metrics">Metrics</a></p></body></html>`)
+	})
+	return mux
+}
+
+// Write writes the metrics of the last scan in the Prometheus text format
+func (e *Exporter) Write(w io.Writer) error {
+	e.mu.RLock()
+	s := e.last
+	scanning, scans, failures := e.scanning || e.stuck, e.scans, e.errors
+	e.mu.RUnlock()
+
+	filesystems := []*family{
+		{name: "checkpoint_filesystem_size_bytes", help: "Filesystem size in bytes.", kind: "gauge"},
+		{name: "checkpoint_filesystem_used_bytes", help: "Used space in bytes.", kind: "gauge"},
+		{name: "checkpoint_filesystem_avail_bytes", help: "Space available to unprivileged users in bytes.", kind: "gauge"},
+		{name: "checkpoint_filesystem_inodes", help: "Total inodes, absent when the filesystem has no fixed number.", kind: "gauge"},
+		{name: "checkpoint_filesystem_inodes_free", help: "Free inodes.", kind: "gauge"},
+	}
+	counters := []*family{
+		{name: "checkpoint_disk_reads_completed_total", help: "Reads completed by the device behind a filesystem.", kind: "counter"},
+		{name: "checkpoint_disk_read_bytes_total", help: "Bytes read by the device behind a filesystem.", kind: "counter"},
+		{name: "checkpoint_disk_writes_completed_total", help: "Writes completed by the device behind a filesystem.", kind: "counter"},
+		{name: "checkpoint_disk_written_bytes_total", help: "Bytes written by the device behind a filesystem.", kind: "counter"},
+		{name: "checkpoint_disk_io_time_seconds_total", help: "Time the device behind a filesystem had I/O in flight.", kind: "counter"},
+	}
+	seenDevices := map[string]bool{}
+	for _, d := range s.disks {
+		// read-only images are exported too, only thresholds skip them
+		if d.Type == disk.TypeSymlink || d.Size == 0 {
+			continue
+		}
+		labels := []label{
+			{"device", d.Device},
+			{"mountpoint", d.MountPoint},
+			{"fstype", d.Filesystem},
+			{"uuid", disk.FilesystemUUID(d, s.uuids)},
+			{"group", s.groups[d.MountPoint]},
+		}
+		filesystems[0].add(labels, float64(d.Size))
+		filesystems[1].add(labels, float64(d.Used))
+		filesystems[2].add(labels, float64(d.Available))
+		if d.Inodes > 0 {
+			filesystems[3].add(labels, float64(d.Inodes))
+			filesystems[4].add(labels, float64(d.InodesFree))
+		}
+
+		// A device mounted twice is counted once, under its first mount point
+		name := disk.KernelName(d.Device)
+		stats, ok := s.io[name]
+		if !ok || seenDevices[name] {
+			continue
+		}
+		seenDevices[name] = true
+		counters[0].add(labels, float64(stats.ReadsCompleted))
+		counters[1].add(labels, float64(stats.ReadBytes))
+		counters[2].add(labels, float64(stats.WritesCompleted))
+		counters[3].add(labels, float64(stats.WrittenBytes))
+		counters[4].add(labels, float64(stats.IOTimeMs)/1000)
+	}
+
+	healthy := &family{name: "checkpoint_disk_smart_healthy", help: "1 when the SMART overall assessment passed, absent when it could not be read.", kind: "gauge"}
+	temperature := &family{name: "checkpoint_disk_temperature_celsius", help: "Drive temperature in degrees Celsius.", kind: "gauge"}
+	for _, h := range s.health {
+		labels := []label{{"device", h.Device}, {"model", h.Model}}
+		if h.Health != "" {
+			value := 0.0
+			if h.Healthy() {
+				value = 1
+			}
+			healthy.add(labels, value)
+		}
+		if h.Temperature != nil {
+			temperature.add(labels, *h.Temperature)
+		}
+	}
+
+	running := 0.0
+	if scanning {
+		running = 1
+	}
+	scan := []*family{
+		{name: "checkpoint_scans_total", help: "Scans started since the exporter started, including those skipped while one is stuck.", kind: "counter"},
+		{name: "checkpoint_scan_errors_total", help: "Scans that failed or ran out of time.", kind: "counter"},
+		{name: "checkpoint_scan_in_progress", help: "1 while a scan is running; a scan stuck on a hanging mount stays at 1.", kind: "gauge"},
+		{name: "checkpoint_scan_duration_seconds", help: "Duration of the last successful scan.", kind: "gauge"},
+		{name: "checkpoint_last_scan_timestamp_seconds", help: "Time of the last successful scan, 0 before the first.", kind: "gauge"},
+	}
+	scan[0].add(nil, float64(scans))
+	scan[1].add(nil, float64(failures))
+	scan[2].add(nil, running)
+	scan[3].add(nil, s.duration.Seconds())
+	lastScan := 0.0
+	if !s.scanned.IsZero() {
+		lastScan = float64(s.scanned.UnixNano()) / 1e9
+	}
+	scan[4].add(nil, lastScan)
+
+	bw := bufio.NewWriter(w)
+	all := append(append(append(filesystems, counters...), healthy, temperature), scan...)
+	for _, f := range all {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+type label struct {
+	name, value string
+}
+
+type sample struct {
+	labels []label
+	value  float64
+}
+
+// family is one metric with its samples
+type family struct {
+	name, help, kind string
+	samples          []sample
+}
+
+func (f *family) add(labels []label, value float64) {
+	f.samples = append(f.samples, sample{labels, value})
+}
+
+// write prints the family, or nothing when it has no samples
+func (f *family) write(w *bufio.Writer) {
+	if len(f.samples) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+	for _, s := range f.samples {
+		w.WriteString(f.name)
+		if len(s.labels) > 0 {
+			sorted := append([]label(nil), s.labels...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+			parts := make([]string, len(sorted))
+			for i, l := range sorted {
+				parts[i] = l.name + `="` + escapeLabel(l.value) + `"`
+			}
+			w.WriteString("{" + strings.Join(parts, ",") + "}")
+		}
+		w.WriteString(" " + strconv.FormatFloat(s.value, 'g', -1, 64) + "\n")
+	}
+}
+
+// escapeLabel escapes a label value as the text format requires
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
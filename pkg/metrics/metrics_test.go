@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+func newTestExporter(scan func() ([]disk.Disk, error)) *Exporter {
+	return NewExporter(Options{
+		Timeout: 50 * time.Millisecond,
+		Scan:    scan,
+		Health:  func([]disk.Disk) []disk.DiskHealth { return nil },
+		IOStats: func() (map[string]disk.IOStats, error) { return nil, nil },
+	})
+}
+
+// metric returns the line of a metric without labels
+func metric(t *testing.T, e *Exporter, name string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := e.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, name+" ") {
+			return line
+		}
+	}
+	t.Fatalf("no %s in\n%s", name, buf.String())
+	return ""
+}
+
+func TestScanCountsSuccessAndFailure(t *testing.T) {
+	fail := false
+	e := newTestExporter(func() ([]disk.Disk, error) {
+		if fail {
+			return nil, errors.New("boom")
+		}
+		return []disk.Disk{{MountPoint: "/", Device: "/dev/sda1", Type: disk.TypePhysical, Size: 100, Used: 40}}, nil
+	})
+	e.scan()
+	fail = true
+	e.scan()
+
+	if got := metric(t, e, "checkpoint_scans_total"); got != "checkpoint_scans_total 2" {
+		t.Errorf("got %q", got)
+	}
+	if got := metric(t, e, "checkpoint_scan_errors_total"); got != "checkpoint_scan_errors_total 1" {
+		t.Errorf("got %q", got)
+	}
+	// the failed scan keeps the last good one
+	if got := metric(t, e, "checkpoint_last_scan_timestamp_seconds"); got == "checkpoint_last_scan_timestamp_seconds 0" {
+		t.Errorf("got %q, want the time of the first scan", got)
+	}
+}
+
+func TestScanTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	e := newTestExporter(func() ([]disk.Disk, error) {
+		<-release
+		return nil, nil
+	})
+
+	e.scan()
+	if got := metric(t, e, "checkpoint_scan_errors_total"); got != "checkpoint_scan_errors_total 1" {
+		t.Errorf("after a timeout got %q", got)
+	}
+	if got := metric(t, e, "checkpoint_scan_in_progress"); got != "checkpoint_scan_in_progress 1" {
+		t.Errorf("while stuck got %q", got)
+	}
+	if got := metric(t, e, "checkpoint_last_scan_timestamp_seconds"); got != "checkpoint_last_scan_timestamp_seconds 0" {
+		t.Errorf("got %q", got)
+	}
+
+	// scans due while the stuck one hangs fail at once
+	start := time.Now()
+	e.scan()
+	if time.Since(start) > 40*time.Millisecond {
+		t.Errorf("scan waited behind the stuck one")
+	}
+	if got := metric(t, e, "checkpoint_scans_total"); got != "checkpoint_scans_total 2" {
+		t.Errorf("got %q", got)
+	}
+	if got := metric(t, e, "checkpoint_scan_errors_total"); got != "checkpoint_scan_errors_total 2" {
+		t.Errorf("got %q", got)
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for metric(t, e, "checkpoint_scan_in_progress") != "checkpoint_scan_in_progress 0" {
+		if time.Now().After(deadline) {
+			t.Fatal("the stuck scan was never released")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,230 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+// DefaultHealthInterval is the time between SMART readings, which are slow
+const DefaultHealthInterval = 10 * time.Minute
+
+// openActions open checkpoint from a notification, by clicking it or its
+// button
+var openActions = []Action{{ActionDefault, "Open checkpoint"}, {ActionOpen, "Open checkpoint"}}
+
+// MonitorOptions configure a Monitor. Zero values use the defaults.
+type MonitorOptions struct {
+	// Health reads the health of the whole disks, disk.LoadHealth by default
+	Health func(disks []disk.Disk) []disk.DiskHealth
+	// HealthInterval is the time between health readings
+	HealthInterval time.Duration
+	// RAID lists the software RAID arrays, disk.ReadRAID by default
+	RAID func() ([]disk.RAIDArray, error)
+	// Now is the clock, time.Now by default
+	Now func() time.Time
+}
+
+// Monitor turns scans into notifications about what changed: a filesystem
+// running low on space, a drive plugged in, a filesystem remounted
+// read-only, a failing drive and a degraded RAID array. Each is reported
+// once until it clears.
+type Monitor struct {
+	opts     MonitorOptions
+	healthAt time.Time
+
+	// devices seen in the previous scan, nil before the first
+	devices  map[string]bool
+	readOnly map[string]bool       // per mount point, nil before the first scan
+	levels   map[string]disk.Level // alert level reported per mount point
+	failing  map[string]bool
+	degraded map[string]bool
+}
+
+// NewMonitor creates a monitor
+func NewMonitor(opts MonitorOptions) *Monitor {
+	if opts.Health == nil {
+		opts.Health = disk.LoadHealth
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = DefaultHealthInterval
+	}
+	if opts.RAID == nil {
+		opts.RAID = disk.ReadRAID
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Monitor{
+		opts:     opts,
+		levels:   map[string]disk.Level{},
+		failing:  map[string]bool{},
+		degraded: map[string]bool{},
+	}
+}
+
+// Observe compares a scan, grouped and checked against thresholds, with the
+// previous one and returns the notifications it calls for
+func (m *Monitor) Observe(groups []disk.DriveGroup) []Notification {
+	var notes []Notification
+	var disks []disk.Disk
+	for _, g := range groups {
+		disks = append(disks, g.Disks...)
+		notes = append(notes, m.lowSpace(g)...)
+	}
+	notes = append(notes, m.newDrives(groups)...)
+	notes = append(notes, m.remounts(disks)...)
+
+	if now := m.opts.Now(); now.Sub(m.healthAt) >= m.opts.HealthInterval {
+		m.healthAt = now
+		notes = append(notes, m.health(m.opts.Health(disks))...)
+	}
+	if arrays, err := m.opts.RAID(); err == nil {
+		notes = append(notes, m.raid(arrays)...)
+	}
+	return notes
+}
+
+// lowSpace reports a group whose alert got worse
+func (m *Monitor) lowSpace(g disk.DriveGroup) []Notification {
+	if len(g.Disks) == 0 {
+		return nil
+	}
+	mount := g.Disks[0].MountPoint
+	level := disk.LevelOK
+	if g.Alert != nil && g.Alert.Level != disk.LevelUnknown {
+		level = g.Alert.Level
+	}
+	previous := m.levels[mount]
+	m.levels[mount] = level
+	if level <= previous {
+		return nil
+	}
+
+	n := Notification{
+		Key:        fmt.Sprintf("low-space:%s:%s", mount, level),
+		Summary:    fmt.Sprintf("%s is running out of space", g.Name),
+		Icon:       "drive-harddisk",
+		Urgency:    UrgencyNormal,
+		MountPoint: mount,
+		Actions:    append(openActions[:len(openActions):len(openActions)], Action{ActionCleanup, "Clean up"}),
+	}
+	if level == disk.LevelCritical {
+		n.Summary = fmt.Sprintf("%s is almost full", g.Name)
+		n.Urgency = UrgencyCritical
+	}
+	n.Body = fmt.Sprintf("%s free of %s.", disk.FormatBytes(g.Available), disk.FormatBytes(g.TotalSize))
+	if len(g.Alert.Reasons) > 0 {
+		n.Body += "\n" + g.Alert.Reasons[0]
+	}
+	return []Notification{n}
+}
+
+// newDrives reports physical drives that were not there in the previous scan
+func (m *Monitor) newDrives(groups []disk.DriveGroup) []Notification {
+	first := m.devices == nil
+	seen := map[string]bool{}
+	var notes []Notification
+	for _, g := range groups {
+		isNew := false
+		for _, d := range g.Disks {
+			if d.Type != disk.TypePhysical || d.Device == "" {
+				continue
+			}
+			seen[d.Device] = true
+			if !first && !m.devices[d.Device] {
+				isNew = true
+			}
+		}
+		if !isNew {
+			continue
+		}
+		notes = append(notes, Notification{
+			Key:        "new-drive:" + g.Disks[0].Device,
+			Summary:    fmt.Sprintf("%s connected", g.Name),
+			Body:       fmt.Sprintf("Mounted at %s, %s free of %s.", g.Disks[0].MountPoint, disk.FormatBytes(g.Available), disk.FormatBytes(g.TotalSize)),
+			Icon:       "drive-removable-media",
+			Urgency:    UrgencyLow,
+			MountPoint: g.Disks[0].MountPoint,
+			Actions:    openActions,
+		})
+	}
+	m.devices = seen
+	return notes
+}
+
+// remounts reports filesystems that were writable in the previous scan and
+// are read-only now, which the kernel does after filesystem errors
+func (m *Monitor) remounts(disks []disk.Disk) []Notification {
+	readOnly := map[string]bool{}
+	var notes []Notification
+	for _, d := range disks {
+		ro := disk.IsReadOnly(d)
+		readOnly[d.MountPoint] = ro
+		if was, known := m.readOnly[d.MountPoint]; ro && known && !was {
+			notes = append(notes, Notification{
+				Key:        "read-only:" + d.MountPoint,
+				Summary:    fmt.Sprintf("%s is read-only", d.MountPoint),
+				Body:       fmt.Sprintf("%s was remounted read-only, which usually follows filesystem errors. Check dmesg and back up its data.", d.Device),
+				Icon:       "dialog-warning",
+				Urgency:    UrgencyCritical,
+				MountPoint: d.MountPoint,
+				Actions:    openActions,
+			})
+		}
+	}
+	m.readOnly = readOnly
+	return notes
+}
+
+// health reports drives whose SMART assessment failed
+func (m *Monitor) health(health []disk.DiskHealth) []Notification {
+	var notes []Notification
+	for _, h := range health {
+		// An unreadable assessment says nothing either way
+		if h.Health == "" {
+			continue
+		}
+		failing := !h.Healthy()
+		if failing && !m.failing[h.Device] {
+			name := h.Model
+			if name == "" {
+				name = h.Device
+			}
+			notes = append(notes, Notification{
+				Key:     "failing:" + h.Device,
+				Summary: fmt.Sprintf("%s is failing", name),
+				Body:    fmt.Sprintf("SMART reports %s for %s. Back up its data and replace it.", h.Health, h.Device),
+				Icon:    "dialog-warning",
+				Urgency: UrgencyCritical,
+				Actions: openActions,
+			})
+		}
+		m.failing[h.Device] = failing
+	}
+	return notes
+}
+
+// raid reports arrays that became degraded
+func (m *Monitor) raid(arrays []disk.RAIDArray) []Notification {
+	var notes []Notification
+	for _, a := range arrays {
+		if a.Degraded && !m.degraded[a.Device] {
+			body := fmt.Sprintf("%s is running without full redundancy", a.Device)
+			if a.Status != "" {
+				body += fmt.Sprintf(" [%s]", a.Status)
+			}
+			notes = append(notes, Notification{
+				Key:     "degraded:" + a.Device,
+				Summary: fmt.Sprintf("RAID array %s is degraded", a.Device),
+				Body:    body + ". Replace the failed member soon.",
+				Icon:    "dialog-warning",
+				Urgency: UrgencyCritical,
+				Actions: openActions,
+			})
+		}
+		m.degraded[a.Device] = a.Degraded
+	}
+	return notes
+}
@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"checkpoint/pkg/disk"
+)
+
+// newTestMonitor creates a monitor with no health or RAID readings
+func newTestMonitor(c *clock) *Monitor {
+	return NewMonitor(MonitorOptions{
+		Health: func([]disk.Disk) []disk.DiskHealth { return nil },
+		RAID:   func() ([]disk.RAIDArray, error) { return nil, nil },
+		Now:    c.Now,
+	})
+}
+
+func group(name, device, mount string, level disk.Level) disk.DriveGroup {
+	g := disk.DriveGroup{
+		Name:      name,
+		TotalSize: 100 << 30,
+		Available: 5 << 30,
+		Disks:     []disk.Disk{{Device: device, MountPoint: mount, Type: disk.TypePhysical, Options: "rw"}},
+	}
+	if level != disk.LevelOK {
+		g.Alert = &disk.Alert{Level: level, Reasons: []string{"below threshold"}}
+	}
+	return g
+}
+
+func noteKeys(notes []Notification) []string {
+	var keys []string
+	for _, n := range notes {
+		keys = append(keys, n.Key)
+	}
+	return keys
+}
+
+func TestObserveLowSpace(t *testing.T) {
+	steps := []struct {
+		level disk.Level
+		want  []string
+	}{
+		{disk.LevelOK, nil},
+		{disk.LevelWarning, []string{"low-space:/:warning"}},
+		{disk.LevelWarning, nil},
+		{disk.LevelCritical, []string{"low-space:/:critical"}},
+		{disk.LevelWarning, nil},
+		{disk.LevelCritical, []string{"low-space:/:critical"}},
+		// an unreadable level counts as recovered, not as worse
+		{disk.LevelUnknown, nil},
+		{disk.LevelOK, nil},
+		{disk.LevelWarning, []string{"low-space:/:warning"}},
+	}
+	m := newTestMonitor(&clock{at(12, 0)})
+	for i, s := range steps {
+		notes := m.Observe([]disk.DriveGroup{group("System", "/dev/sda1", "/", s.level)})
+		if got := noteKeys(notes); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("step %d (%s): got %v, want %v", i, s.level, got, s.want)
+		}
+	}
+}
+
+func TestObserveLowSpaceUrgency(t *testing.T) {
+	m := newTestMonitor(&clock{at(12, 0)})
+	warning := m.Observe([]disk.DriveGroup{group("Data", "/dev/sdb1", "/data", disk.LevelWarning)})
+	critical := m.Observe([]disk.DriveGroup{group("Data", "/dev/sdb1", "/data", disk.LevelCritical)})
+	if len(warning) != 1 || len(critical) != 1 {
+		t.Fatalf("got %v and %v", noteKeys(warning), noteKeys(critical))
+	}
+	if warning[0].Urgency != UrgencyNormal || warning[0].Summary != "Data is running out of space" {
+		t.Errorf("warning = %+v", warning[0])
+	}
+	if critical[0].Urgency != UrgencyCritical || critical[0].Summary != "Data is almost full" {
+		t.Errorf("critical = %+v", critical[0])
+	}
+	if critical[0].Body != "5.0 GB free of 100.0 GB.\nbelow threshold" || critical[0].MountPoint != "/data" {
+		t.Errorf("body = %q", critical[0].Body)
+	}
+}
+
+func TestObserveNewDrives(t *testing.T) {
+	system := group("System", "/dev/sda1", "/", disk.LevelOK)
+	usb := group("USB Stick", "/dev/sdc1", "/media/usb", disk.LevelOK)
+	network := disk.DriveGroup{Name: "NAS", Disks: []disk.Disk{{Device: "nas:/share", MountPoint: "/mnt/nas", Type: disk.TypeNetwork}}}
+
+	steps := []struct {
+		groups []disk.DriveGroup
+		want   []string
+	}{
+		// what is there at start-up is not news
+		{[]disk.DriveGroup{system, usb}, nil},
+		{[]disk.DriveGroup{system}, nil},
+		{[]disk.DriveGroup{system, usb}, []string{"new-drive:/dev/sdc1"}},
+		{[]disk.DriveGroup{system, usb}, nil},
+		// only physical drives are reported
+		{[]disk.DriveGroup{system, usb, network}, nil},
+	}
+	m := newTestMonitor(&clock{at(12, 0)})
+	for i, s := range steps {
+		if got := noteKeys(m.Observe(s.groups)); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("step %d: got %v, want %v", i, got, s.want)
+		}
+	}
+}
+
+func TestObserveRemounts(t *testing.T) {
+	rw := group("System", "/dev/sda1", "/", disk.LevelOK)
+	ro := group("System", "/dev/sda1", "/", disk.LevelOK)
+	ro.Disks = []disk.Disk{{Device: "/dev/sda1", MountPoint: "/", Type: disk.TypePhysical, Options: "ro,relatime"}}
+
+	tests := []struct {
+		name  string
+		scans []disk.DriveGroup
+		want  []string
+	}{
+		{"remounted read-only", []disk.DriveGroup{rw, ro}, []string{"read-only:/"}},
+		{"read-only from the start", []disk.DriveGroup{ro, ro}, nil},
+		{"reported once", []disk.DriveGroup{rw, ro, ro}, []string{"read-only:/"}},
+		{"reported again after recovering", []disk.DriveGroup{rw, ro, rw, ro}, []string{"read-only:/", "read-only:/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMonitor(&clock{at(12, 0)})
+			var got []string
+			for _, g := range tt.scans {
+				got = append(got, noteKeys(m.Observe([]disk.DriveGroup{g}))...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObserveHealth(t *testing.T) {
+	c := &clock{at(12, 0)}
+	health := []disk.DiskHealth{{Device: "/dev/sda", Model: "Disk One", Health: "PASSED"}}
+	readings := 0
+	m := NewMonitor(MonitorOptions{
+		Health: func([]disk.Disk) []disk.DiskHealth {
+			readings++
+			return health
+		},
+		HealthInterval: 10 * time.Minute,
+		RAID:           func() ([]disk.RAIDArray, error) { return nil, nil },
+		Now:            c.Now,
+	})
+	system := []disk.DriveGroup{group("System", "/dev/sda1", "/", disk.LevelOK)}
+
+	steps := []struct {
+		after  time.Duration
+		health string
+		want   []string
+	}{
+		{0, "PASSED", nil},
+		// not read again before the interval
+		{time.Minute, "FAILED", nil},
+		{10 * time.Minute, "FAILED", []string{"failing:/dev/sda"}},
+		{10 * time.Minute, "FAILED", nil},
+		// an unreadable assessment keeps the last one
+		{10 * time.Minute, "", nil},
+		{10 * time.Minute, "PASSED", nil},
+		{10 * time.Minute, "FAILED", []string{"failing:/dev/sda"}},
+	}
+	for i, s := range steps {
+		c.Advance(s.after)
+		health[0].Health = s.health
+		if got := noteKeys(m.Observe(system)); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("step %d: got %v, want %v", i, got, s.want)
+		}
+	}
+	if readings != 6 {
+		t.Errorf("read health %d times, want 6", readings)
+	}
+}
+
+func TestObserveRAID(t *testing.T) {
+	arrays := []disk.RAIDArray{{Device: "/dev/md0", Status: "UU"}}
+	m := NewMonitor(MonitorOptions{
+		Health: func([]disk.Disk) []disk.DiskHealth { return nil },
+		RAID:   func() ([]disk.RAIDArray, error) { return arrays, nil },
+		Now:    (&clock{at(12, 0)}).Now,
+	})
+
+	steps := []struct {
+		status string
+		want   []string
+	}{
+		{"UU", nil},
+		{"U_", []string{"degraded:/dev/md0"}},
+		{"U_", nil},
+		{"UU", nil},
+		{"_U", []string{"degraded:/dev/md0"}},
+	}
+	for i, s := range steps {
+		arrays[0].Status = s.status
+		arrays[0].Degraded = s.status != "UU"
+		notes := m.Observe(nil)
+		if got := noteKeys(notes); !reflect.DeepEqual(got, s.want) {
+			t.Errorf("step %d: got %v, want %v", i, got, s.want)
+		}
+		if len(notes) == 1 && notes[0].Body != "/dev/md0 is running without full redundancy ["+s.status+"]. Replace the failed member soon." {
+			t.Errorf("body = %q", notes[0].Body)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package notify sends desktop notifications about low space and drive
+// events through the freedesktop notification service on D-Bus
+// (org.freedesktop.Notifications), with rate limiting and quiet hours.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"checkpoint/pkg/config"
+)
+
+// Urgency is the freedesktop urgency level of a notification
+type Urgency byte
+
+const (
+	UrgencyLow Urgency = iota
+	UrgencyNormal
+	UrgencyCritical
+)
+
+// Actions checkpoint offers on its notifications
+const (
+	// ActionDefault is what the notification server reports when the
+	// notification itself is clicked
+	ActionDefault = "default"
+	ActionOpen    = "open"
+	ActionCleanup = "cleanup"
+)
+
+// Action is a button on a notification
+type Action struct {
+	Key   string
+	Label string
+}
+
+// Notification is one message to the user
+type Notification struct {
+	// Key identifies what the notification is about, e.g. "low-space:/home",
+	// for rate limiting
+	Key     string
+	Summary string
+	Body    string
+	Icon    string
+	Urgency Urgency
+	Actions []Action
+	// MountPoint is the filesystem the notification is about, if any, for
+	// the action handler
+	MountPoint string
+}
+
+// ActionEvent is a click on a notification button
+type ActionEvent struct {
+	ID     uint32
+	Action string
+}
+
+// Bus delivers notifications. GDBus talks to the real notification server;
+// tests can use a fake.
+type Bus interface {
+	// Notify shows a notification and returns its server id
+	Notify(n Notification) (uint32, error)
+	// Actions streams the buttons the user clicks until ctx is done
+	Actions(ctx context.Context) (<-chan ActionEvent, error)
+}
+
+// configFile holds the notification settings in the config directory
+const configFile = "notify.yaml"
+
+// Config is notify.yaml
+type Config struct {
+	// QuietHours such as "22:00-07:00" hold back all but critical
+	// notifications until they end
+	QuietHours string `json:"quiet_hours,omitempty" yaml:"quiet_hours,omitempty"`
+	// Cooldown is the least time between two notifications with the same key
+	Cooldown time.Duration `json:"cooldown,omitempty" yaml:"cooldown,omitempty"`
+	// MaxPerHour caps notifications of all kinds
+	MaxPerHour int `json:"max_per_hour,omitempty" yaml:"max_per_hour,omitempty"`
+}
+
+// DefaultConfig applies where notify.yaml sets nothing
+var DefaultConfig = Config{
+	Cooldown:   6 * time.Hour,
+	MaxPerHour: 5,
+}
+
+// LoadConfig reads notify.yaml from the config directory. On error it
+// returns the defaults along with the error.
+func LoadConfig() (Config, error) {
+	c := DefaultConfig
+	if err := config.Load(configFile, &c); err != nil {
+		return DefaultConfig, err
+	}
+	if _, _, err := parseQuietHours(c.QuietHours); err != nil {
+		return DefaultConfig, err
+	}
+	return c, nil
+}
+
+// parseQuietHours parses "22:00-07:00" into minutes since midnight. An
+// empty string means no quiet hours, start == end.
+func parseQuietHours(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	start, err1 := parseClock(from)
+	end, err2 := parseClock(to)
+	if !ok || err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("invalid quiet hours %q, expected e.g. 22:00-07:00", s)
+	}
+	return start, end, nil
+}
+
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(s), ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || hours > 24 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return hours*60 + minutes, nil
+}
+
+// Options configure a Notifier
+type Options struct {
+	Bus    Bus
+	Config Config
+	// OnAction runs when the user clicks a button of a notification
+	OnAction func(action string, n Notification)
+	// Now is the clock, time.Now by default
+	Now func() time.Time
+	// Logf reports notifications that were dropped or failed
+	Logf func(format string, args ...interface{})
+}
+
+// maxRemembered bounds the notifications kept for their actions
+const maxRemembered = 64
+
+// Notifier sends notifications, holding back those that come too often or
+// during quiet hours
+type Notifier struct {
+	opts       Options
+	quietStart int
+	quietEnd   int
+
+	mu   sync.Mutex
+	last map[string]time.Time // key -> last sent
+	// sent are the send times within the last hour, oldest first
+	sent []time.Time
+	// held are notifications waiting for quiet hours to end, by key
+	held  map[string]Notification
+	order []string
+	// shown are sent notifications by server id, for their actions
+	shown map[uint32]Notification
+	ids   []uint32
+}
+
+// New creates a notifier. An invalid quiet hours setting is ignored; use
+// LoadConfig to report it.
+func New(opts Options) *Notifier {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...interface{}) {}
+	}
+	if opts.OnAction == nil {
+		opts.OnAction = func(string, Notification) {}
+	}
+	n := &Notifier{
+		opts:  opts,
+		last:  map[string]time.Time{},
+		held:  map[string]Notification{},
+		shown: map[uint32]Notification{},
+	}
+	n.quietStart, n.quietEnd, _ = parseQuietHours(opts.Config.QuietHours)
+	return n
+}
+
+// quiet reports whether t falls in the quiet hours
+func (n *Notifier) quiet(t time.Time) bool {
+	if n.quietStart == n.quietEnd {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if n.quietStart < n.quietEnd {
+		return minute >= n.quietStart && minute < n.quietEnd
+	}
+	// Quiet hours across midnight
+	return minute >= n.quietStart || minute < n.quietEnd
+}
+
+// Send delivers notifications, first any that were held back by quiet hours
+// that have ended. Critical notifications are never held back.
+func (n *Notifier) Send(notes ...Notification) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := n.opts.Now()
+	quiet := n.quiet(now)
+	if !quiet && len(n.order) > 0 {
+		held := n.order
+		n.order = nil
+		for _, key := range held {
+			n.send(n.held[key], now)
+			delete(n.held, key)
+		}
+	}
+	for _, note := range notes {
+		if quiet && note.Urgency != UrgencyCritical {
+			if _, ok := n.held[note.Key]; !ok {
+				n.order = append(n.order, note.Key)
+			}
+			// The latest news about the same thing is the one worth showing
+			n.held[note.Key] = note
+			continue
+		}
+		n.send(note, now)
+	}
+}
+
+// send applies the rate limits and delivers one notification
+func (n *Notifier) send(note Notification, now time.Time) {
+	if last, ok := n.last[note.Key]; ok && now.Sub(last) < n.opts.Config.Cooldown {
+		return
+	}
+	for len(n.sent) > 0 && now.Sub(n.sent[0]) >= time.Hour {
+		n.sent = n.sent[1:]
+	}
+	if n.opts.Config.MaxPerHour > 0 && len(n.sent) >= n.opts.Config.MaxPerHour && note.Urgency != UrgencyCritical {
+		n.opts.Logf("too many notifications, dropped %q", note.Summary)
+		return
+	}
+
+	id, err := n.opts.Bus.Notify(note)
+	if err != nil {
+		n.opts.Logf("failed to notify: %v", err)
+		return
+	}
+	n.last[note.Key] = now
+	n.sent = append(n.sent, now)
+	if len(note.Actions) > 0 {
+		n.shown[id] = note
+		n.ids = append(n.ids, id)
+		if len(n.ids) > maxRemembered {
+			delete(n.shown, n.ids[0])
+			n.ids = n.ids[1:]
+		}
+	}
+}
+
+// Run hands the buttons the user clicks to OnAction until ctx is done
+func (n *Notifier) Run(ctx context.Context) error {
+	events, err := n.opts.Bus.Actions(ctx)
+	if err != nil {
+		return err
+	}
+	for event := range events {
+		n.mu.Lock()
+		note, ok := n.shown[event.ID]
+		n.mu.Unlock()
+		// Other programs' notifications come through the same signal
+		if ok {
+			n.opts.OnAction(event.Action, note)
+		}
+	}
+	return nil
+}
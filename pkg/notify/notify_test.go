@@ -0,0 +1,261 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeBus records notifications instead of showing them
+type fakeBus struct {
+	sent    []Notification
+	fail    bool
+	actions []ActionEvent
+}
+
+func (b *fakeBus) Notify(n Notification) (uint32, error) {
+	if b.fail {
+		return 0, errors.New("no notification server")
+	}
+	b.sent = append(b.sent, n)
+	return uint32(len(b.sent)), nil
+}
+
+func (b *fakeBus) Actions(ctx context.Context) (<-chan ActionEvent, error) {
+	events := make(chan ActionEvent, len(b.actions))
+	for _, a := range b.actions {
+		events <- a
+	}
+	close(events)
+	return events, nil
+}
+
+// keys lists the keys of the notifications the bus showed, in order
+func (b *fakeBus) keys() []string {
+	var keys []string
+	for _, n := range b.sent {
+		keys = append(keys, n.Key)
+	}
+	return keys
+}
+
+// clock is a settable time for Options.Now
+type clock struct{ t time.Time }
+
+func (c *clock) Now() time.Time          { return c.t }
+func (c *clock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// at is a time of day on a fixed date
+func at(hour, minute int) time.Time {
+	return time.Date(2024, 3, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func note(key string, u Urgency) Notification {
+	return Notification{Key: key, Summary: key, Urgency: u}
+}
+
+func TestSendRateLimits(t *testing.T) {
+	type step struct {
+		after time.Duration
+		note  Notification
+	}
+	tests := []struct {
+		name   string
+		config Config
+		steps  []step
+		want   []string
+	}{
+		{
+			name:   "cooldown per key",
+			config: Config{Cooldown: time.Hour},
+			steps: []step{
+				{0, note("a", UrgencyNormal)},
+				{10 * time.Minute, note("a", UrgencyNormal)},
+				{0, note("b", UrgencyNormal)},
+				{50 * time.Minute, note("a", UrgencyNormal)},
+			},
+			want: []string{"a", "b", "a"},
+		},
+		{
+			name:   "cooldown applies to critical notifications too",
+			config: Config{Cooldown: time.Hour},
+			steps: []step{
+				{0, note("a", UrgencyCritical)},
+				{time.Minute, note("a", UrgencyCritical)},
+			},
+			want: []string{"a"},
+		},
+		{
+			name:   "at most per hour",
+			config: Config{MaxPerHour: 2},
+			steps: []step{
+				{0, note("a", UrgencyNormal)},
+				{time.Minute, note("b", UrgencyNormal)},
+				{time.Minute, note("c", UrgencyLow)},
+				{time.Minute, note("d", UrgencyCritical)},
+			},
+			want: []string{"a", "b", "d"},
+		},
+		{
+			name:   "the hour slides",
+			config: Config{MaxPerHour: 2},
+			steps: []step{
+				{0, note("a", UrgencyNormal)},
+				{30 * time.Minute, note("b", UrgencyNormal)},
+				{20 * time.Minute, note("c", UrgencyNormal)},
+				{10 * time.Minute, note("d", UrgencyNormal)},
+				{time.Minute, note("e", UrgencyNormal)},
+			},
+			want: []string{"a", "b", "d"},
+		},
+		{
+			name:   "no limits",
+			config: Config{},
+			steps: []step{
+				{0, note("a", UrgencyNormal)},
+				{0, note("a", UrgencyNormal)},
+				{0, note("a", UrgencyNormal)},
+			},
+			want: []string{"a", "a", "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &fakeBus{}
+			c := &clock{at(12, 0)}
+			n := New(Options{Bus: bus, Config: tt.config, Now: c.Now})
+			for _, s := range tt.steps {
+				c.Advance(s.after)
+				n.Send(s.note)
+			}
+			if got := bus.keys(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFailedSendIsNotCounted(t *testing.T) {
+	bus := &fakeBus{fail: true}
+	c := &clock{at(12, 0)}
+	var logged int
+	n := New(Options{Bus: bus, Config: Config{Cooldown: time.Hour}, Now: c.Now,
+		Logf: func(string, ...interface{}) { logged++ }})
+	n.Send(note("a", UrgencyNormal))
+	bus.fail = false
+	c.Advance(time.Minute)
+	n.Send(note("a", UrgencyNormal))
+	if got := bus.keys(); !reflect.DeepEqual(got, []string{"a"}) || logged != 1 {
+		t.Errorf("sent %v with %d logged, want the retry to go through", got, logged)
+	}
+}
+
+func TestQuietHours(t *testing.T) {
+	tests := []struct {
+		hours string
+		at    time.Time
+		quiet bool
+	}{
+		{"", at(3, 0), false},
+		{"22:00-07:00", at(21, 59), false},
+		{"22:00-07:00", at(22, 0), true},
+		{"22:00-07:00", at(0, 0), true},
+		{"22:00-07:00", at(6, 59), true},
+		{"22:00-07:00", at(7, 0), false},
+		{"13:00-14:30", at(12, 59), false},
+		{"13:00-14:30", at(14, 29), true},
+		{"13:00-14:30", at(14, 30), false},
+		{"08:00-08:00", at(8, 0), false},
+	}
+	for _, tt := range tests {
+		n := New(Options{Bus: &fakeBus{}, Config: Config{QuietHours: tt.hours}})
+		if got := n.quiet(tt.at); got != tt.quiet {
+			t.Errorf("quiet(%q, %s) = %v, want %v", tt.hours, tt.at.Format("15:04"), got, tt.quiet)
+		}
+	}
+}
+
+func TestParseQuietHours(t *testing.T) {
+	tests := []struct {
+		in         string
+		start, end int
+		valid      bool
+	}{
+		{"", 0, 0, true},
+		{"22:00-07:00", 22 * 60, 7 * 60, true},
+		{" 9:30 - 17:05 ", 9*60 + 30, 17*60 + 5, true},
+		{"22:00", 0, 0, false},
+		{"22-07", 0, 0, false},
+		{"25:00-07:00", 0, 0, false},
+		{"22:60-07:00", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, err := parseQuietHours(tt.in)
+		if (err == nil) != tt.valid || start != tt.start || end != tt.end {
+			t.Errorf("parseQuietHours(%q) = %d, %d, %v", tt.in, start, end, err)
+		}
+	}
+}
+
+func TestHeldNotifications(t *testing.T) {
+	bus := &fakeBus{}
+	c := &clock{at(23, 0)}
+	n := New(Options{Bus: bus, Config: Config{QuietHours: "22:00-07:00"}, Now: c.Now})
+
+	n.Send(note("a", UrgencyNormal), note("b", UrgencyLow), note("fire", UrgencyCritical))
+	if got := bus.keys(); !reflect.DeepEqual(got, []string{"fire"}) {
+		t.Fatalf("during quiet hours sent %v, want only the critical one", got)
+	}
+
+	// newer news about the same thing replaces the held one, keeping its place
+	c.Advance(time.Hour)
+	later := note("a", UrgencyNormal)
+	later.Summary = "a again"
+	n.Send(later)
+	if len(bus.sent) != 1 {
+		t.Fatalf("sent %v during quiet hours", bus.keys())
+	}
+
+	// the first send after quiet hours delivers what was held, then the rest
+	c.t = at(7, 30)
+	n.Send(note("c", UrgencyNormal))
+	if got := bus.keys(); !reflect.DeepEqual(got, []string{"fire", "a", "b", "c"}) {
+		t.Fatalf("after quiet hours sent %v", got)
+	}
+	if bus.sent[1].Summary != "a again" {
+		t.Errorf("held %q, want the latest", bus.sent[1].Summary)
+	}
+
+	// held ones are delivered once
+	c.Advance(time.Minute)
+	n.Send()
+	if len(bus.sent) != 4 {
+		t.Errorf("sent %v", bus.keys())
+	}
+}
+
+func TestRunHandsActionsToOnAction(t *testing.T) {
+	bus := &fakeBus{}
+	type click struct {
+		action string
+		key    string
+	}
+	var clicks []click
+	n := New(Options{Bus: bus, OnAction: func(action string, n Notification) {
+		clicks = append(clicks, click{action, n.Key})
+	}})
+	withActions := note("low-space:/", UrgencyNormal)
+	withActions.Actions = openActions
+	n.Send(withActions, note("plain", UrgencyNormal))
+
+	// id 2 has no actions and id 9 belongs to another program
+	bus.actions = []ActionEvent{{1, ActionCleanup}, {2, ActionOpen}, {9, ActionOpen}}
+	if err := n.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []click{{ActionCleanup, "low-space:/"}}; !reflect.DeepEqual(clicks, want) {
+		t.Errorf("got %v, want %v", clicks, want)
+	}
+}
@@ -0,0 +1,308 @@
+// Package output writes checkpoint data in machine-readable formats.
+//
+// Every JSON, YAML and NDJSON document is wrapped in an Envelope carrying
+// SchemaVersion, so scripts can detect incompatible changes. The field names
+// are documented in docs/output.md.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/doctor"
+	"checkpoint/pkg/dupes"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/search"
+	"checkpoint/pkg/snapshot"
+)
+
+// SchemaVersion is bumped whenever a field is renamed, removed or changes meaning.
+// Adding fields does not bump it.
+const SchemaVersion = 1
+
+// Format selects how data is written
+type Format string
+
+// Structured formats, encoded by Write
+const (
+	FormatJSON   Format = "json"
+	FormatYAML   Format = "yaml"
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Presentation formats, drawn by the ui renderers
+const (
+	FormatText     Format = "text"
+	FormatPlain    Format = "plain"
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+)
+
+// Kinds of documents, stored in Envelope.Kind
+const (
+	KindDisks     = "disks"
+	KindGroups    = "groups"
+	KindStats     = "stats"
+	KindUnmounted = "unmounted"
+	KindAnalysis  = "analysis"
+	KindHistory   = "history"
+	KindReclaim   = "reclaimable"
+	KindCleanup   = "cleanup"
+	KindTrash     = "trash"
+	KindDupes     = "duplicates"
+	KindDedupe    = "dedupe"
+	KindFiles     = "files"
+	KindFileTypes = "filetypes"
+	KindSnapshots = "snapshots"
+	KindDiff      = "diff"
+	KindDoctor    = "doctor"
+)
+
+// Envelope wraps every structured document
+type Envelope struct {
+	SchemaVersion int         `json:"schema_version" yaml:"schema_version"`
+	Kind          string      `json:"kind" yaml:"kind"`
+	GeneratedAt   time.Time   `json:"generated_at" yaml:"generated_at"`
+	Data          interface{} `json:"data" yaml:"data"`
+}
+
+// ParseFormat validates a format name given on the command line
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(name))); f {
+	case FormatJSON, FormatYAML, FormatCSV, FormatNDJSON,
+		FormatText, FormatPlain, FormatMarkdown, FormatHTML:
+		return f, nil
+	case "", "terminal":
+		return FormatText, nil
+	case "md":
+		return FormatMarkdown, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (want text, plain, markdown, html, json, yaml, csv or ndjson)", name)
+	}
+}
+
+// Structured reports whether the format is encoded by Write rather than
+// drawn by a ui renderer
+func (f Format) Structured() bool {
+	switch f {
+	case FormatJSON, FormatYAML, FormatCSV, FormatNDJSON:
+		return true
+	}
+	return false
+}
+
+// Write encodes data of the given kind in a structured format. Presentation
+// formats are not handled here, since the views live in the ui package.
+func Write(w io.Writer, format Format, kind string, data interface{}) error {
+	env := Envelope{
+		SchemaVersion: SchemaVersion,
+		Kind:          kind,
+		GeneratedAt:   time.Now().UTC(),
+		Data:          data,
+	}
+
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(env)
+	case FormatNDJSON:
+		// One compact envelope per line, so each watch refresh is one record
+		return json.NewEncoder(w).Encode(env)
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(env); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatCSV:
+		return writeCSV(w, data)
+	default:
+		return fmt.Errorf("format %q cannot encode %s", format, kind)
+	}
+}
+
+// writeCSV flattens data into a header row plus one row per item
+func writeCSV(w io.Writer, data interface{}) error {
+	var rows [][]string
+
+	switch v := data.(type) {
+	case []disk.Disk:
+		rows = append(rows, []string{"path", "device", "filesystem", "type", "mount_point",
+			"size_bytes", "used_bytes", "available_bytes", "inode", "is_symlink", "link_target", "last_check", "mount_options",
+			"inodes", "inodes_free"})
+		for _, d := range v {
+			rows = append(rows, []string{d.Path, d.Device, d.Filesystem, string(d.Type), d.MountPoint,
+				u64(d.Size), u64(d.Used), u64(d.Available), u64(d.Inode),
+				strconv.FormatBool(d.IsSymlink), d.LinkTarget, d.LastCheck.UTC().Format(time.RFC3339), d.Options,
+				u64(d.Inodes), u64(d.InodesFree)})
+		}
+	case []disk.DriveGroup:
+		rows = append(rows, []string{"name", "type", "description", "is_primary",
+			"total_size_bytes", "total_used_bytes", "available_bytes", "mount_points",
+			"forecast_trend", "forecast_days_to_full", "forecast_confidence", "alert_level"})
+		for _, g := range v {
+			mounts := make([]string, 0, len(g.Disks))
+			for _, d := range g.Disks {
+				mounts = append(mounts, d.MountPoint)
+			}
+			trend, days, confidence := "", "", ""
+			if f := g.Forecast; f != nil {
+				trend, confidence = f.Trend, f.Confidence
+				if f.Trend == disk.TrendGrowing {
+					days = strconv.FormatFloat(f.DaysToFull, 'f', 1, 64)
+				}
+			}
+			level := ""
+			if g.Alert != nil {
+				level = g.Alert.Level.String()
+			}
+			rows = append(rows, []string{g.Name, g.Type, g.Description, strconv.FormatBool(g.IsPrimary),
+				u64(g.TotalSize), u64(g.TotalUsed), u64(g.Available), strings.Join(mounts, ";"),
+				trend, days, confidence, level})
+		}
+	case disk.DiskStats:
+		rows = append(rows, []string{"key", "value"},
+			[]string{"total_disks", strconv.Itoa(v.TotalDisks)},
+			[]string{"total_size_bytes", u64(v.TotalSize)},
+			[]string{"total_used_bytes", u64(v.TotalUsed)},
+			[]string{"total_available_bytes", u64(v.TotalAvailable)},
+			[]string{"hardlink_groups", strconv.Itoa(len(v.Hardlinks))},
+			[]string{"symlinks", strconv.Itoa(len(v.Symlinks))})
+		types := make([]string, 0, len(v.DisksByType))
+		for t := range v.DisksByType {
+			types = append(types, string(t))
+		}
+		sort.Strings(types)
+		for _, t := range types {
+			rows = append(rows, []string{"disks_by_type." + t, strconv.Itoa(v.DisksByType[disk.DiskType(t)])})
+		}
+	case analyzer.Report:
+		rows = append(rows, []string{"path", "is_dir", "allocated_bytes", "apparent_bytes", "items", "other_filesystem", "error"})
+		for _, e := range v.Entries {
+			rows = append(rows, []string{e.Path, strconv.FormatBool(e.IsDir), u64(e.AllocatedBytes), u64(e.ApparentBytes),
+				strconv.Itoa(e.Items), strconv.FormatBool(e.OtherFilesystem), e.Error})
+		}
+	case []history.Report:
+		rows = append(rows, []string{"key", "mount_point", "time", "size_bytes", "used_bytes", "available_bytes"})
+		for _, r := range v {
+			for _, s := range r.Samples {
+				rows = append(rows, []string{r.Key, s.MountPoint, s.Time.Format(time.RFC3339),
+					u64(s.Size), u64(s.Used), u64(s.Available)})
+			}
+		}
+	case cleanup.Report:
+		rows = append(rows, []string{"kind", "name", "mount_point", "bytes", "files", "needs_root", "paths", "commands"})
+		for _, item := range v.Items {
+			commands := make([]string, 0, len(item.Commands))
+			for _, c := range item.Commands {
+				commands = append(commands, strings.Join(c, " "))
+			}
+			rows = append(rows, []string{string(item.Kind), item.Name, item.MountPoint, u64(item.Bytes),
+				strconv.Itoa(item.Files), strconv.FormatBool(item.NeedsRoot),
+				strings.Join(item.Paths, ";"), strings.Join(commands, ";")})
+		}
+	case []cleanup.Result:
+		rows = append(rows, []string{"kind", "name", "mount_point", "freed_bytes", "trashed_bytes", "dry_run", "skipped", "error", "steps"})
+		for _, r := range v {
+			rows = append(rows, []string{string(r.Item.Kind), r.Item.Name, r.Item.MountPoint, u64(r.Freed), u64(r.Trashed),
+				strconv.FormatBool(r.DryRun), r.Skipped, r.Error, strings.Join(r.Steps, ";")})
+		}
+	case []disk.TrashItem:
+		rows = append(rows, []string{"name", "trash", "path", "deleted_at", "size_bytes", "is_dir", "mount_point"})
+		for _, item := range v {
+			deleted := ""
+			if !item.DeletedAt.IsZero() {
+				deleted = item.DeletedAt.Format(time.RFC3339)
+			}
+			rows = append(rows, []string{item.Name, item.Trash, item.Path, deleted, u64(item.Size),
+				strconv.FormatBool(item.IsDir), item.MountPoint})
+		}
+	case dupes.Report:
+		rows = append(rows, []string{"group", "sha256", "size_bytes", "wasted_bytes", "path", "mod_time", "links"})
+		for i, g := range v.Groups {
+			for _, f := range g.Files {
+				rows = append(rows, []string{strconv.Itoa(i + 1), g.SHA256, u64(g.Size), u64(g.Wasted), f.Path,
+					f.ModTime.UTC().Format(time.RFC3339), strings.Join(f.Links, ";")})
+			}
+		}
+	case []dupes.Result:
+		rows = append(rows, []string{"action", "path", "kept", "freed_bytes", "trashed_bytes", "dry_run", "skipped", "error"})
+		for _, r := range v {
+			rows = append(rows, []string{string(r.Action), r.Path, r.Kept, u64(r.Freed), u64(r.Trashed),
+				strconv.FormatBool(r.DryRun), r.Skipped, r.Error})
+		}
+	case []filetypes.Drive:
+		rows = append(rows, []string{"name", "mount_points", "category", "files", "bytes", "share"})
+		for _, d := range v {
+			for _, c := range d.Categories {
+				share := 0.0
+				if d.Bytes > 0 {
+					share = float64(c.Bytes) / float64(d.Bytes)
+				}
+				rows = append(rows, []string{d.Name, strings.Join(d.MountPoints, ";"), c.Name, strconv.Itoa(c.Files), u64(c.Bytes),
+					strconv.FormatFloat(share, 'f', 4, 64)})
+			}
+		}
+	case search.Report:
+		rows = append(rows, []string{"path", "size_bytes", "apparent_bytes", "mod_time", "access_time", "owner", "type", "large", "stale"})
+		for _, f := range v.Files {
+			rows = append(rows, []string{f.Path, u64(f.Size), u64(f.Apparent), f.ModTime.UTC().Format(time.RFC3339),
+				f.AccessTime.UTC().Format(time.RFC3339), f.Owner, f.Type, strconv.FormatBool(f.Large), strconv.FormatBool(f.Stale)})
+		}
+	case []snapshot.Snapshot:
+		rows = append(rows, []string{"id", "root", "taken", "min_size_bytes", "size_bytes", "files", "dirs", "partial"})
+		for _, s := range v {
+			rows = append(rows, []string{s.ID, s.Root, s.Taken.UTC().Format(time.RFC3339), u64(s.MinSize), u64(s.Size),
+				strconv.Itoa(s.Files), strconv.Itoa(s.Dirs), strconv.FormatBool(s.Partial)})
+		}
+	case snapshot.Diff:
+		rows = append(rows, []string{"path", "is_dir", "before_bytes", "after_bytes", "delta_bytes", "status"})
+		for _, c := range v.Changes {
+			rows = append(rows, []string{c.Path, strconv.FormatBool(c.IsDir), u64(c.Before), u64(c.After),
+				strconv.FormatInt(c.Delta, 10), c.Status})
+		}
+	case doctor.Report:
+		rows = append(rows, []string{"check", "level", "subject", "problem", "fix"})
+		for _, res := range v.Results {
+			for _, f := range res.Findings {
+				rows = append(rows, []string{f.Check, f.Level.String(), f.Subject, f.Problem, f.Fix})
+			}
+			// a check that could not run gets a row of its own
+			if res.Error != "" {
+				rows = append(rows, []string{res.Check, disk.LevelUnknown.String(), "", res.Error, ""})
+			}
+		}
+	case []disk.UnmountedDisk:
+		rows = append(rows, []string{"device", "type", "filesystem", "label", "uuid", "size_bytes"})
+		for _, ud := range v {
+			rows = append(rows, []string{ud.Device, ud.Type, ud.Filesystem, ud.Label, ud.UUID, u64(ud.Size)})
+		}
+	default:
+		return fmt.Errorf("csv output is not supported for %T", data)
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write csv: %v", err)
+	}
+	return nil
+}
+
+func u64(n uint64) string {
+	return strconv.FormatUint(n, 10)
+}
@@ -0,0 +1,83 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// Move moves a file into dir, which may be on another drive, and returns
+// its new path. It never overwrites: a file of the same name in dir is an
+// error. Across filesystems the file is copied, keeping its mode and
+// times, and only removed once the copy is complete.
+func Move(path, dir string) (string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("cannot move to %s: %v", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("cannot move to %s: not a directory", dir)
+	}
+	dest := filepath.Join(dir, filepath.Base(path))
+	if _, err := os.Lstat(dest); err == nil {
+		return "", fmt.Errorf("cannot move %s: %s already exists", path, dest)
+	}
+
+	err = os.Rename(path, dest)
+	if err == nil {
+		return dest, nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return "", fmt.Errorf("failed to move %s: %v", path, err)
+	}
+	if err := copyFile(path, dest); err != nil {
+		return "", fmt.Errorf("failed to copy %s to %s: %v", path, dir, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return dest, fmt.Errorf("copied %s to %s but failed to remove it: %v", path, dir, err)
+	}
+	return dest, nil
+}
+
+// copyFile copies a regular file to a new path with its mode and times. A
+// failed copy is removed again.
+func copyFile(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(dest, info.Mode().Perm())
+	}
+	if err == nil {
+		atime := info.ModTime()
+		if st, ok := info.Sys().(*syscall.Stat_t); ok {
+			atime = time.Unix(st.Atim.Sec, st.Atim.Nsec)
+		}
+		err = os.Chtimes(dest, atime, info.ModTime())
+	}
+	if err != nil {
+		os.Remove(dest)
+	}
+	return err
+}
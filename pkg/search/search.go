@@ -0,0 +1,279 @@
+// Package search finds large files and files nobody has used for a long
+// time, such as forgotten virtual machine images and old downloads, and
+// groups them by directory and file type.
+package search
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Defaults used by the command line
+const (
+	DefaultMinSize = 1 << 30
+	DefaultStale   = 180 * 24 * time.Hour
+)
+
+// Options configures a search. A file is reported when it is large or
+// stale and passes every filter.
+type Options struct {
+	Roots []string
+	// MinSize makes files at least this big large, 0 turns it off
+	MinSize uint64
+	// StaleAfter makes files neither read nor modified for this long
+	// stale, 0 turns it off
+	StaleAfter time.Duration
+	// Filters: the owner's name or uid, extensions without the dot, and
+	// the time since the last modification
+	Owner      string
+	Extensions []string
+	OlderThan  time.Duration
+	// CrossFilesystems descends into filesystems mounted below the roots
+	CrossFilesystems bool
+	// Now is the time ages are measured from, time.Now() when zero
+	Now time.Time
+}
+
+// File is a large or stale file
+type File struct {
+	Path       string    `json:"path" yaml:"path"`
+	Size       uint64    `json:"size_bytes" yaml:"size_bytes"`
+	Apparent   uint64    `json:"apparent_bytes" yaml:"apparent_bytes"`
+	ModTime    time.Time `json:"mod_time" yaml:"mod_time"`
+	AccessTime time.Time `json:"access_time" yaml:"access_time"`
+	Owner      string    `json:"owner" yaml:"owner"`
+	// Type is the lower-case extension, or "" for none
+	Type  string `json:"type" yaml:"type"`
+	Large bool   `json:"large" yaml:"large"`
+	Stale bool   `json:"stale" yaml:"stale"`
+}
+
+// LastUsed is when the file was last read or modified
+func (f File) LastUsed() time.Time {
+	if f.AccessTime.After(f.ModTime) {
+		return f.AccessTime
+	}
+	return f.ModTime
+}
+
+// Group totals the files in one directory or of one type
+type Group struct {
+	Name  string `json:"name" yaml:"name"`
+	Files int    `json:"files" yaml:"files"`
+	Bytes uint64 `json:"bytes" yaml:"bytes"`
+}
+
+// Report is the result of a search, the largest files first
+type Report struct {
+	Roots     []string `json:"roots" yaml:"roots"`
+	MinSize   uint64   `json:"min_size_bytes" yaml:"min_size_bytes"`
+	StaleDays int      `json:"stale_days" yaml:"stale_days"`
+	Files     []File   `json:"files" yaml:"files"`
+	// FileCount and Bytes cover every file found, which may be more than
+	// Files holds when the report was trimmed
+	FileCount int     `json:"file_count" yaml:"file_count"`
+	Bytes     uint64  `json:"total_bytes" yaml:"total_bytes"`
+	ByDir     []Group `json:"by_directory" yaml:"by_directory"`
+	ByType    []Group `json:"by_type" yaml:"by_type"`
+	// Scanned counts the files looked at, Errors those that could not be
+	Scanned int `json:"scanned" yaml:"scanned"`
+	Errors  int `json:"errors" yaml:"errors"`
+	// Partial is set when the search was cancelled before it finished
+	Partial bool `json:"partial" yaml:"partial"`
+}
+
+// Top trims the report to the n largest files and groups, all of them
+// when n is 0
+func (r Report) Top(n int) Report {
+	if n <= 0 {
+		return r
+	}
+	if len(r.Files) > n {
+		r.Files = r.Files[:n]
+	}
+	if len(r.ByDir) > n {
+		r.ByDir = r.ByDir[:n]
+	}
+	if len(r.ByType) > n {
+		r.ByType = r.ByType[:n]
+	}
+	return r
+}
+
+// Find walks the roots. Cancelling ctx stops early; the report then holds
+// what was found so far and has Partial set.
+func Find(ctx context.Context, opts Options) (Report, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	report := Report{Roots: []string{}, MinSize: opts.MinSize, StaleDays: int(opts.StaleAfter.Hours() / 24),
+		Files: []File{}}
+	extensions := map[string]bool{}
+	for _, e := range opts.Extensions {
+		extensions[strings.ToLower(strings.TrimPrefix(strings.TrimSpace(e), "."))] = true
+	}
+	owners := ownerNames{}
+	seen := map[[2]uint64]bool{}
+
+	for _, root := range opts.Roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return report, fmt.Errorf("invalid path %s: %v", root, err)
+		}
+		var rootStat syscall.Stat_t
+		if err := syscall.Stat(abs, &rootStat); err != nil {
+			return report, fmt.Errorf("failed to stat %s: %v", abs, err)
+		}
+		report.Roots = append(report.Roots, abs)
+
+		filepath.WalkDir(abs, func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return filepath.SkipAll
+			}
+			if err != nil {
+				report.Errors++
+				return nil
+			}
+			if d.Type()&fs.ModeType != 0 && !d.IsDir() {
+				return nil
+			}
+			var st syscall.Stat_t
+			if err := syscall.Lstat(path, &st); err != nil {
+				report.Errors++
+				return nil
+			}
+			if d.IsDir() {
+				if !opts.CrossFilesystems && st.Dev != rootStat.Dev {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// Hard links and roots inside other roots count once
+			key := [2]uint64{uint64(st.Dev), uint64(st.Ino)}
+			if seen[key] {
+				return nil
+			}
+			seen[key] = true
+			report.Scanned++
+
+			f := File{
+				Path:       path,
+				Size:       uint64(st.Blocks) * 512,
+				Apparent:   uint64(st.Size),
+				ModTime:    time.Unix(st.Mtim.Sec, st.Mtim.Nsec),
+				AccessTime: time.Unix(st.Atim.Sec, st.Atim.Nsec),
+				Type:       strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")),
+			}
+			f.Large = opts.MinSize > 0 && f.Size >= opts.MinSize
+			f.Stale = opts.StaleAfter > 0 && now.Sub(f.LastUsed()) >= opts.StaleAfter
+			if !f.Large && !f.Stale && (opts.MinSize > 0 || opts.StaleAfter > 0) {
+				return nil
+			}
+			if len(extensions) > 0 && !extensions[f.Type] {
+				return nil
+			}
+			if opts.OlderThan > 0 && now.Sub(f.ModTime) < opts.OlderThan {
+				return nil
+			}
+			f.Owner = owners.name(st.Uid)
+			if opts.Owner != "" && opts.Owner != f.Owner && opts.Owner != strconv.Itoa(int(st.Uid)) {
+				return nil
+			}
+			report.Files = append(report.Files, f)
+			return nil
+		})
+	}
+
+	sort.SliceStable(report.Files, func(i, j int) bool {
+		a, b := report.Files[i], report.Files[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Path < b.Path
+	})
+	dirs, types := map[string]*Group{}, map[string]*Group{}
+	for _, f := range report.Files {
+		report.Bytes += f.Size
+		add(dirs, filepath.Dir(f.Path), f.Size)
+		add(types, f.Type, f.Size)
+	}
+	report.FileCount = len(report.Files)
+	report.ByDir = sorted(dirs)
+	report.ByType = sorted(types)
+	report.Partial = ctx.Err() != nil
+	return report, nil
+}
+
+func add(groups map[string]*Group, name string, size uint64) {
+	g, ok := groups[name]
+	if !ok {
+		g = &Group{Name: name}
+		groups[name] = g
+	}
+	g.Files++
+	g.Bytes += size
+}
+
+// sorted lists the groups, largest first
+func sorted(groups map[string]*Group) []Group {
+	list := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		list = append(list, *g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Bytes != list[j].Bytes {
+			return list[i].Bytes > list[j].Bytes
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// ownerNames caches user names by uid
+type ownerNames map[uint32]string
+
+func (o ownerNames) name(uid uint32) string {
+	if name, ok := o[uid]; ok {
+		return name
+	}
+	name := strconv.Itoa(int(uid))
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	o[uid] = name
+	return name
+}
+
+// ParseAge parses an age such as "90d", "6m" or "1y": days, weeks, months
+// of 30 days or years of 365 days. A plain number is days.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	unit := 24 * time.Hour
+	number := s
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'd':
+			number = s[:n-1]
+		case 'w':
+			unit, number = 7*24*time.Hour, s[:n-1]
+		case 'm':
+			unit, number = 30*24*time.Hour, s[:n-1]
+		case 'y':
+			unit, number = 365*24*time.Hour, s[:n-1]
+		}
+	}
+	v, err := strconv.ParseFloat(number, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid age %q, expected e.g. 90d, 6m or 1y", s)
+	}
+	return time.Duration(v * float64(unit)), nil
+}
@@ -0,0 +1,425 @@
+// Package service installs checkpoint as systemd user units: timers that
+// record usage history and check thresholds, and optionally the daemon. It
+// drives systemctl --user through a Runner so it can be tried without systemd.
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Unit names
+const (
+	HistoryService = "checkpoint-history.service"
+	HistoryTimer   = "checkpoint-history.timer"
+	CheckService   = "checkpoint-check.service"
+	CheckTimer     = "checkpoint-check.timer"
+	DaemonService  = "checkpoint-daemon.service"
+)
+
+// Default schedules, as systemd calendar expressions
+const (
+	DefaultHistorySchedule = "hourly"
+	DefaultCheckSchedule   = "*:0/15"
+)
+
+// marker is the first line of every unit checkpoint writes. Only files
+// carrying it are ever replaced or removed.
+const marker = "# Installed by checkpoint, 'checkpoint service uninstall' removes it"
+
+// Runner runs a command and returns its combined output
+type Runner interface {
+	Run(name string, args ...string) (string, error)
+}
+
+// ExecRunner runs commands for real
+type ExecRunner struct{}
+
+// Run runs name with args
+func (ExecRunner) Run(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("%s %s failed: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
+
+// Options choose the units to install
+type Options struct {
+	// Executable is the absolute path of checkpoint
+	Executable string
+	// HistorySchedule is when to record usage history, DefaultHistorySchedule
+	// by default
+	HistorySchedule string
+	// CheckSchedule is when to check thresholds, DefaultCheckSchedule by default
+	CheckSchedule string
+	// Daemon also installs the daemon with desktop notifications
+	Daemon bool
+}
+
+func (opts Options) withDefaults() Options {
+	if opts.HistorySchedule == "" {
+		opts.HistorySchedule = DefaultHistorySchedule
+	}
+	if opts.CheckSchedule == "" {
+		opts.CheckSchedule = DefaultCheckSchedule
+	}
+	return opts
+}
+
+// Unit is a systemd unit file
+type Unit struct {
+	Name    string
+	Content string
+}
+
+// Units are the unit files for opts
+func Units(opts Options) []Unit {
+	opts = opts.withDefaults()
+	exe := quoteArg(opts.Executable)
+	units := []Unit{
+		{HistoryService, oneshot("Record disk usage history", exe+" history --record")},
+		{HistoryTimer, timer("Record disk usage history", opts.HistorySchedule)},
+		// check exits non-zero past a threshold, which marks the service failed
+		// for systemctl --failed and OnFailure= hooks
+		{CheckService, oneshot("Check disk space thresholds", exe+" check")},
+		{CheckTimer, timer("Check disk space thresholds", opts.CheckSchedule)},
+	}
+	if opts.Daemon {
+		units = append(units, Unit{DaemonService, marker + `
+[Unit]
+Description=Keep a live disk inventory and send desktop notifications
+
+[Service]
+ExecStart=` + exe + ` daemon --notify
+Restart=on-failure
+RestartSec=10s
+
+[Install]
+WantedBy=default.target
+`})
+	}
+	return units
+}
+
+func oneshot(description, command string) string {
+	return marker + `
+[Unit]
+Description=` + description + `
+
+[Service]
+Type=oneshot
+ExecStart=` + command + `
+`
+}
+
+func timer(description, schedule string) string {
+	return marker + `
+[Unit]
+Description=` + description + ` (` + schedule + `)
+
+[Timer]
+OnCalendar=` + schedule + `
+Persistent=true
+RandomizedDelaySec=1min
+
+[Install]
+WantedBy=timers.target
+`
+}
+
+// quoteArg quotes a path for ExecStart when it has spaces or quotes. A %
+// starts a systemd specifier such as %h, quoted or not, so it is doubled.
+func quoteArg(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// Change is what happened to one unit file
+type Change struct {
+	Unit string
+	// Action is "created", "updated", "unchanged" or "removed"
+	Action string
+}
+
+// Manager installs units into a directory and drives systemctl --user
+type Manager struct {
+	dir    string
+	runner Runner
+}
+
+// UnitDir is where systemd looks for user units,
+// $XDG_CONFIG_HOME/systemd/user, defaulting to ~/.config/systemd/user
+func UnitDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "systemd", "user"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %v", err)
+	}
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+// New creates a manager for the units in dir
+func New(dir string, runner Runner) *Manager {
+	return &Manager{dir: dir, runner: runner}
+}
+
+// Install writes the units for opts and enables them. Running it again
+// changes nothing unless opts changed; units installed before but no
+// longer wanted are removed.
+func (m *Manager) Install(opts Options) ([]Change, error) {
+	if !filepath.IsAbs(opts.Executable) {
+		return nil, fmt.Errorf("executable path %q is not absolute", opts.Executable)
+	}
+	for _, schedule := range schedules(opts) {
+		if err := m.checkSchedule(schedule); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", m.dir, err)
+	}
+
+	// Check every file before writing any, so a conflict leaves no half
+	// installed set behind
+	units := Units(opts)
+	existing := make([][]byte, len(units))
+	for i, u := range units {
+		path := filepath.Join(m.dir, u.Name)
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil && !bytes.HasPrefix(data, []byte(marker)):
+			return nil, fmt.Errorf("%s was not written by checkpoint, remove it first", path)
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			return nil, fmt.Errorf("failed to read %s: %v", path, err)
+		}
+		existing[i] = data
+	}
+
+	var changes []Change
+	wanted := map[string]bool{}
+	var enable, restart []string
+	for i, u := range units {
+		wanted[u.Name] = true
+		if !strings.HasSuffix(u.Name, ".service") || u.Name == DaemonService {
+			enable = append(enable, u.Name)
+		}
+		if string(existing[i]) == u.Content {
+			changes = append(changes, Change{u.Name, "unchanged"})
+			continue
+		}
+		path := filepath.Join(m.dir, u.Name)
+		if err := os.WriteFile(path, []byte(u.Content), 0o644); err != nil {
+			return changes, fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		if existing[i] == nil {
+			changes = append(changes, Change{u.Name, "created"})
+		} else {
+			changes = append(changes, Change{u.Name, "updated"})
+			if u.Name == DaemonService {
+				restart = append(restart, u.Name)
+			}
+		}
+	}
+
+	installed, err := m.installed()
+	if err != nil {
+		return changes, err
+	}
+	var stale []string
+	for _, name := range installed {
+		if !wanted[name] {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		removed, err := m.remove(stale)
+		changes = append(changes, removed...)
+		if err != nil {
+			return changes, err
+		}
+	}
+
+	// Reload even when nothing changed, in case an earlier install failed
+	// after writing the files
+	if err := m.systemctl("daemon-reload"); err != nil {
+		return changes, err
+	}
+	if err := m.systemctl(append([]string{"enable", "--now"}, enable...)...); err != nil {
+		return changes, err
+	}
+	if len(restart) > 0 {
+		if err := m.systemctl(append([]string{"try-restart"}, restart...)...); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// schedules are the distinct schedules of opts
+func schedules(opts Options) []string {
+	opts = opts.withDefaults()
+	if opts.HistorySchedule == opts.CheckSchedule {
+		return []string{opts.HistorySchedule}
+	}
+	return []string{opts.HistorySchedule, opts.CheckSchedule}
+}
+
+// checkSchedule refuses a schedule that is not a calendar expression before
+// it goes into OnCalendar=, where a newline would add directives of its own.
+// systemd-analyze knows the syntax; where it is missing only control
+// characters are refused and systemd reports the rest when loading the timer.
+func (m *Manager) checkSchedule(schedule string) error {
+	if strings.IndexFunc(schedule, unicode.IsControl) >= 0 {
+		return fmt.Errorf("invalid schedule %q: it has control characters", schedule)
+	}
+	_, err := m.runner.Run("systemd-analyze", "calendar", "--", schedule)
+	if err != nil && !errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("invalid schedule %q: %v", schedule, err)
+	}
+	return nil
+}
+
+// Uninstall stops, disables and removes every unit checkpoint installed
+func (m *Manager) Uninstall() ([]Change, error) {
+	installed, err := m.installed()
+	if err != nil || len(installed) == 0 {
+		return nil, err
+	}
+	changes, err := m.remove(installed)
+	if err != nil {
+		return changes, err
+	}
+	return changes, m.systemctl("daemon-reload")
+}
+
+// remove disables and deletes units; the caller reloads systemd
+func (m *Manager) remove(units []string) ([]Change, error) {
+	// Disabling a unit systemd no longer knows fails, which is fine here
+	m.systemctl(append([]string{"disable", "--now"}, units...)...)
+	var changes []Change
+	for _, name := range units {
+		if err := os.Remove(filepath.Join(m.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return changes, fmt.Errorf("failed to remove %s: %v", name, err)
+		}
+		changes = append(changes, Change{name, "removed"})
+	}
+	return changes, nil
+}
+
+// installed lists the unit files checkpoint wrote, sorted
+func (m *Manager) installed() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(m.dir, "checkpoint-*"))
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s: %v", file, err)
+		}
+		if bytes.HasPrefix(data, []byte(marker)) {
+			names = append(names, filepath.Base(file))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *Manager) systemctl(args ...string) error {
+	_, err := m.runner.Run("systemctl", append([]string{"--user"}, args...)...)
+	return err
+}
+
+// UnitStatus is what systemd reports about an installed unit
+type UnitStatus struct {
+	Unit string
+	// Enabled is the unit file state, e.g. "enabled" or "disabled"
+	Enabled string
+	// Active is e.g. "active (waiting)" for a timer or "failed" for a check
+	// that found a drive past a threshold
+	Active string
+	// Result of the last run, e.g. "success" or "exit-code"
+	Result string
+	// NextRun and LastRun are set for timers, as systemd prints them
+	NextRun string
+	LastRun string
+}
+
+// Status asks systemd about every installed unit
+func (m *Manager) Status() ([]UnitStatus, error) {
+	installed, err := m.installed()
+	if err != nil || len(installed) == 0 {
+		return nil, err
+	}
+	args := []string{"--user", "show", "--property=Id,UnitFileState,ActiveState,SubState,Result,NextElapseUSecRealtime,LastTriggerUSec"}
+	output, err := m.runner.Run("systemctl", append(args, installed...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// systemctl show separates the units with a blank line
+	var statuses []UnitStatus
+	for _, block := range strings.Split(strings.TrimSpace(output), "\n\n") {
+		props := map[string]string{}
+		for _, line := range strings.Split(block, "\n") {
+			if key, value, ok := strings.Cut(line, "="); ok {
+				props[key] = value
+			}
+		}
+		if props["Id"] == "" {
+			continue
+		}
+		s := UnitStatus{
+			Unit:    props["Id"],
+			Enabled: props["UnitFileState"],
+			Active:  props["ActiveState"],
+			Result:  props["Result"],
+			NextRun: props["NextElapseUSecRealtime"],
+			LastRun: props["LastTriggerUSec"],
+		}
+		if sub := props["SubState"]; sub != "" && sub != s.Active {
+			s.Active += " (" + sub + ")"
+		}
+		if s.LastRun == "n/a" {
+			s.LastRun = ""
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// Lingering reports whether systemd keeps the user's units running while
+// they are logged out. Without it timers only fire during a session.
+func Lingering(runner Runner) (bool, error) {
+	u, err := user.Current()
+	if err != nil {
+		return false, fmt.Errorf("failed to look up current user: %v", err)
+	}
+	output, err := runner.Run("loginctl", "show-user", u.Username, "--property=Linger", "--value")
+	// logind does not know users who neither linger nor are logged in
+	if err != nil && strings.Contains(output, "not logged in or lingering") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) == "yes", nil
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeRunner records commands instead of running them
+type fakeRunner struct {
+	calls []string
+	// fail makes commands starting with it fail
+	fail string
+	// missing makes commands starting with it fail as if not installed
+	missing string
+}
+
+func (r *fakeRunner) Run(name string, args ...string) (string, error) {
+	call := strings.Join(append([]string{name}, args...), " ")
+	r.calls = append(r.calls, call)
+	if r.fail != "" && strings.HasPrefix(call, r.fail) {
+		return "", errors.New(call + " failed")
+	}
+	if r.missing != "" && strings.HasPrefix(call, r.missing) {
+		return "", fmt.Errorf("%s failed: %w", call, exec.ErrNotFound)
+	}
+	return "", nil
+}
+
+// takeCalls returns the commands run since the last call
+func (r *fakeRunner) takeCalls() []string {
+	calls := r.calls
+	r.calls = nil
+	return calls
+}
+
+func newTestManager(t *testing.T) (*Manager, *fakeRunner, string) {
+	dir := filepath.Join(t.TempDir(), "systemd", "user")
+	runner := &fakeRunner{}
+	return New(dir, runner), runner, dir
+}
+
+// actions lists changes as "unit action"
+func actions(changes []Change) []string {
+	var lines []string
+	for _, c := range changes {
+		lines = append(lines, c.Unit+" "+c.Action)
+	}
+	return lines
+}
+
+func TestInstall(t *testing.T) {
+	m, runner, dir := newTestManager(t)
+	opts := Options{Executable: "/usr/bin/checkpoint"}
+
+	changes, err := m.Install(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		HistoryService + " created",
+		HistoryTimer + " created",
+		CheckService + " created",
+		CheckTimer + " created",
+	}
+	if got := actions(changes); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	calls := []string{
+		"systemd-analyze calendar -- " + DefaultHistorySchedule,
+		"systemd-analyze calendar -- " + DefaultCheckSchedule,
+		"systemctl --user daemon-reload",
+		"systemctl --user enable --now " + HistoryTimer + " " + CheckTimer,
+	}
+	if got := runner.takeCalls(); !reflect.DeepEqual(got, calls) {
+		t.Errorf("calls = %q, want %q", got, calls)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, CheckService))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), marker+"\n") || !strings.Contains(string(data), "\nExecStart=/usr/bin/checkpoint check\n") {
+		t.Errorf("%s =\n%s", CheckService, data)
+	}
+	data, _ = os.ReadFile(filepath.Join(dir, CheckTimer))
+	if !strings.Contains(string(data), "\nOnCalendar="+DefaultCheckSchedule+"\n") {
+		t.Errorf("%s =\n%s", CheckTimer, data)
+	}
+}
+
+func TestInstallTwiceChangesNothing(t *testing.T) {
+	m, runner, _ := newTestManager(t)
+	opts := Options{Executable: "/usr/bin/checkpoint", Daemon: true}
+	if _, err := m.Install(opts); err != nil {
+		t.Fatal(err)
+	}
+	runner.takeCalls()
+
+	changes, err := m.Install(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Action != "unchanged" {
+			t.Errorf("%s %s on a second install", c.Unit, c.Action)
+		}
+	}
+	if len(changes) != 5 {
+		t.Errorf("changes = %v", actions(changes))
+	}
+	// no try-restart of a daemon that did not change
+	calls := []string{
+		"systemd-analyze calendar -- " + DefaultHistorySchedule,
+		"systemd-analyze calendar -- " + DefaultCheckSchedule,
+		"systemctl --user daemon-reload",
+		"systemctl --user enable --now " + HistoryTimer + " " + CheckTimer + " " + DaemonService,
+	}
+	if got := runner.takeCalls(); !reflect.DeepEqual(got, calls) {
+		t.Errorf("calls = %q, want %q", got, calls)
+	}
+}
+
+func TestInstallUpdatesChangedUnits(t *testing.T) {
+	m, runner, dir := newTestManager(t)
+	if _, err := m.Install(Options{Executable: "/usr/bin/checkpoint", Daemon: true}); err != nil {
+		t.Fatal(err)
+	}
+	runner.takeCalls()
+
+	changes, err := m.Install(Options{Executable: "/opt/checkpoint/bin/checkpoint", Daemon: true, CheckSchedule: "hourly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		HistoryService + " updated",
+		HistoryTimer + " unchanged",
+		CheckService + " updated",
+		CheckTimer + " updated",
+		DaemonService + " updated",
+	}
+	if got := actions(changes); !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+	// both schedules are hourly now, which is checked once
+	calls := []string{
+		"systemd-analyze calendar -- hourly",
+		"systemctl --user daemon-reload",
+		"systemctl --user enable --now " + HistoryTimer + " " + CheckTimer + " " + DaemonService,
+		"systemctl --user try-restart " + DaemonService,
+	}
+	if got := runner.takeCalls(); !reflect.DeepEqual(got, calls) {
+		t.Errorf("calls = %q, want %q", got, calls)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, DaemonService))
+	if !strings.Contains(string(data), "\nExecStart=/opt/checkpoint/bin/checkpoint daemon --notify\n") {
+		t.Errorf("%s =\n%s", DaemonService, data)
+	}
+}
+
+func TestInstallRemovesTheDaemonWhenNotWanted(t *testing.T) {
+	m, runner, dir := newTestManager(t)
+	if _, err := m.Install(Options{Executable: "/usr/bin/checkpoint", Daemon: true}); err != nil {
+		t.Fatal(err)
+	}
+	runner.takeCalls()
+
+	changes, err := m.Install(Options{Executable: "/usr/bin/checkpoint"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := actions(changes); got[len(got)-1] != DaemonService+" removed" || len(got) != 5 {
+		t.Errorf("changes = %v, want the daemon removed", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, DaemonService)); !os.IsNotExist(err) {
+		t.Errorf("%s is still there: %v", DaemonService, err)
+	}
+	calls := []string{
+		"systemd-analyze calendar -- " + DefaultHistorySchedule,
+		"systemd-analyze calendar -- " + DefaultCheckSchedule,
+		"systemctl --user disable --now " + DaemonService,
+		"systemctl --user daemon-reload",
+		"systemctl --user enable --now " + HistoryTimer + " " + CheckTimer,
+	}
+	if got := runner.takeCalls(); !reflect.DeepEqual(got, calls) {
+		t.Errorf("calls = %q, want %q", got, calls)
+	}
+}
+
+func TestInstallKeepsUnitsItDidNotWrite(t *testing.T) {
+	m, runner, dir := newTestManager(t)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	mine := "[Unit]\nDescription=My own check\n"
+	if err := os.WriteFile(filepath.Join(dir, CheckService), []byte(mine), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Install(Options{Executable: "/usr/bin/checkpoint"}); err == nil || !strings.Contains(err.Error(), "was not written by checkpoint") {
+		t.Fatalf("error = %v", err)
+	}
+	// nothing is written when one unit is in the way
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("%d files in %s, want only the user's", len(entries), dir)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, CheckService)); string(data) != mine {
+		t.Errorf("%s was overwritten:\n%s", CheckService, data)
+	}
+	for _, call := range runner.takeCalls() {
+		if strings.HasPrefix(call, "systemctl") {
+			t.Errorf("ran %q", call)
+		}
+	}
+
+	// and it is neither listed nor removed as checkpoint's
+	if changes, err := m.Uninstall(); err != nil || len(changes) != 0 {
+		t.Errorf("uninstall = %v, %v", actions(changes), err)
+	}
+}
+
+func TestInstallNeedsAbsoluteExecutable(t *testing.T) {
+	m, runner, _ := newTestManager(t)
+	if _, err := m.Install(Options{Executable: "checkpoint"}); err == nil {
+		t.Error("installed a relative executable")
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("ran %q", runner.calls)
+	}
+}
+
+func TestInstallChecksSchedules(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule string
+		// fail and missing set up systemd-analyze
+		fail, missing bool
+		error         string
+	}{
+		{name: "valid", schedule: "daily"},
+		{name: "newline", schedule: "daily\nExecStartPre=/bin/sh", error: "control characters"},
+		{name: "tab", schedule: "Mon\t10:00", missing: true, error: "control characters"},
+		{name: "rejected", schedule: "every day", fail: true, error: `invalid schedule "every day"`},
+		{name: "no systemd-analyze", schedule: "every day", missing: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, runner, dir := newTestManager(t)
+			if tt.fail {
+				runner.fail = "systemd-analyze calendar -- " + tt.schedule
+			}
+			if tt.missing {
+				runner.missing = "systemd-analyze"
+			}
+			_, err := m.Install(Options{Executable: "/usr/bin/checkpoint", CheckSchedule: tt.schedule})
+			if tt.error == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				data, _ := os.ReadFile(filepath.Join(dir, CheckTimer))
+				if !strings.Contains(string(data), "\nOnCalendar="+tt.schedule+"\n") {
+					t.Errorf("%s =\n%s", CheckTimer, data)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("error = %v, want %q", err, tt.error)
+			}
+			// nothing is written for a bad schedule
+			if _, err := os.Stat(dir); !os.IsNotExist(err) {
+				t.Errorf("%s was created: %v", dir, err)
+			}
+		})
+	}
+}
+
+func TestInstallReportsSystemctlErrors(t *testing.T) {
+	m, runner, _ := newTestManager(t)
+	runner.fail = "systemctl --user enable"
+	changes, err := m.Install(Options{Executable: "/usr/bin/checkpoint"})
+	if err == nil {
+		t.Fatal("no error")
+	}
+	// the files were written; installing again finishes the job
+	if len(changes) != 4 {
+		t.Errorf("changes = %v", actions(changes))
+	}
+}
+
+func TestUninstall(t *testing.T) {
+	m, runner, dir := newTestManager(t)
+	if _, err := m.Install(Options{Executable: "/usr/bin/checkpoint", Daemon: true}); err != nil {
+		t.Fatal(err)
+	}
+	runner.takeCalls()
+
+	changes, err := m.Uninstall()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 5 {
+		t.Errorf("changes = %v", actions(changes))
+	}
+	units := strings.Join([]string{CheckService, CheckTimer, DaemonService, HistoryService, HistoryTimer}, " ")
+	calls := []string{
+		"systemctl --user disable --now " + units,
+		"systemctl --user daemon-reload",
+	}
+	if got := runner.takeCalls(); !reflect.DeepEqual(got, calls) {
+		t.Errorf("calls = %q, want %q", got, calls)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d files left in %s", len(entries), dir)
+	}
+
+	// nothing installed, nothing to do
+	if changes, err := m.Uninstall(); err != nil || len(changes) != 0 || len(runner.calls) != 0 {
+		t.Errorf("second uninstall = %v, %v, ran %q", actions(changes), err, runner.calls)
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/usr/bin/checkpoint", "/usr/bin/checkpoint"},
+		{"/home/me/My Apps/checkpoint", `"/home/me/My Apps/checkpoint"`},
+		{`/opt/it's/checkpoint`, `"/opt/it's/checkpoint"`},
+		{`/opt/a"b\c/checkpoint`, `"/opt/a\"b\\c/checkpoint"`},
+		{"/opt/100%/checkpoint", "/opt/100%%/checkpoint"},
+		{"/opt/100% sure/checkpoint", `"/opt/100%% sure/checkpoint"`},
+	}
+	for _, tt := range tests {
+		if got := quoteArg(tt.in); got != tt.want {
+			t.Errorf("quoteArg(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
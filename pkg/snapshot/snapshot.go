@@ -0,0 +1,268 @@
+// Package snapshot records how much space the directories below a path take
+// at one moment, and compares two such snapshots to tell what grew. Only
+// directories and files of at least a minimum size are listed one by one;
+// smaller ones count towards their directory. That keeps snapshots of whole
+// drives small while still pointing at anything that matters.
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"checkpoint/pkg/analyzer"
+)
+
+// DefaultMinSize is the smallest directory or file a snapshot lists
+const DefaultMinSize = 1 << 20
+
+// version is bumped when the file format changes
+const version = 1
+
+// Entry is a directory or file in a snapshot
+type Entry struct {
+	// Path is relative to the root, "." for the root itself
+	Path  string
+	IsDir bool
+	// Size is the space taken on disk, everything below for directories
+	Size uint64
+}
+
+// Snapshot is the size of a directory tree at one moment
+type Snapshot struct {
+	// ID names a saved snapshot, see Store
+	ID      string    `json:"id,omitempty" yaml:"id,omitempty"`
+	Root    string    `json:"root" yaml:"root"`
+	Taken   time.Time `json:"taken" yaml:"taken"`
+	MinSize uint64    `json:"min_size_bytes" yaml:"min_size_bytes"`
+	Size    uint64    `json:"size_bytes" yaml:"size_bytes"`
+	Files   int       `json:"files" yaml:"files"`
+	Dirs    int       `json:"dirs" yaml:"dirs"`
+	// Partial is set when the scan was stopped before it finished
+	Partial bool    `json:"partial" yaml:"partial"`
+	Entries []Entry `json:"-" yaml:"-"`
+}
+
+// FromResult turns a finished scan into a snapshot, listing the directories
+// and files of at least minSize
+func FromResult(r *analyzer.Result, minSize uint64, taken time.Time) Snapshot {
+	root := r.Root
+	s := Snapshot{
+		Root:    root.Path(),
+		Taken:   taken,
+		MinSize: minSize,
+		Size:    root.Allocated,
+		Files:   r.Files,
+		Dirs:    r.Dirs,
+		Partial: r.Partial,
+		Entries: []Entry{{Path: ".", IsDir: root.IsDir, Size: root.Allocated}},
+	}
+	var visit func(n *analyzer.Node, path string)
+	visit = func(n *analyzer.Node, path string) {
+		for _, c := range n.Children {
+			// Anything below a directory too small to list is smaller still
+			if c.Allocated < minSize || c.OtherFS || c.Hardlink {
+				continue
+			}
+			p := filepath.Join(path, c.Name)
+			s.Entries = append(s.Entries, Entry{Path: p, IsDir: c.IsDir, Size: c.Allocated})
+			if c.IsDir {
+				visit(c, p)
+			}
+		}
+	}
+	visit(root, "")
+	return s
+}
+
+// header is the first line of a snapshot file
+type header struct {
+	Version int `json:"version"`
+	Snapshot
+}
+
+// Write writes a snapshot as gzip-compressed JSON Lines: a header, then one
+// [kind, size, path] array per entry
+func Write(w io.Writer, s Snapshot) error {
+	zw := gzip.NewWriter(w)
+	enc := json.NewEncoder(zw)
+	h := header{Version: version, Snapshot: s}
+	h.ID = ""
+	if err := enc.Encode(h); err != nil {
+		return err
+	}
+	for _, e := range s.Entries {
+		kind := "f"
+		if e.IsDir {
+			kind = "d"
+		}
+		if err := enc.Encode([]interface{}{kind, e.Size, e.Path}); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// Read reads a snapshot written by Write. With headerOnly the entries are
+// not read, which is enough to list snapshots.
+func Read(r io.Reader, headerOnly bool) (Snapshot, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("not a snapshot: %v", err)
+	}
+	defer zr.Close()
+	dec := json.NewDecoder(bufio.NewReader(zr))
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return Snapshot{}, fmt.Errorf("not a snapshot: %v", err)
+	}
+	if h.Version != version {
+		return Snapshot{}, fmt.Errorf("unsupported snapshot version %d", h.Version)
+	}
+	s := h.Snapshot
+	if headerOnly {
+		return s, nil
+	}
+	for dec.More() {
+		var line []interface{}
+		if err := dec.Decode(&line); err != nil {
+			return s, fmt.Errorf("damaged snapshot: %v", err)
+		}
+		if len(line) != 3 {
+			return s, fmt.Errorf("damaged snapshot: invalid entry")
+		}
+		kind, _ := line[0].(string)
+		size, _ := line[1].(float64)
+		path, _ := line[2].(string)
+		if path == "" {
+			return s, fmt.Errorf("damaged snapshot: invalid entry")
+		}
+		s.Entries = append(s.Entries, Entry{Path: path, IsDir: kind == "d", Size: uint64(size)})
+	}
+	return s, nil
+}
+
+// Change is a directory or file that differs between two snapshots
+type Change struct {
+	Path   string `json:"path" yaml:"path"`
+	IsDir  bool   `json:"is_dir" yaml:"is_dir"`
+	Before uint64 `json:"before_bytes" yaml:"before_bytes"`
+	After  uint64 `json:"after_bytes" yaml:"after_bytes"`
+	Delta  int64  `json:"delta_bytes" yaml:"delta_bytes"`
+	// Status is "grew", "shrank", "added" or "removed". Entries below the
+	// minimum size are not listed, so "added" also covers growing past it
+	// and "removed" shrinking below it.
+	Status string `json:"status" yaml:"status"`
+}
+
+// Statuses of a Change
+const (
+	StatusGrew    = "grew"
+	StatusShrank  = "shrank"
+	StatusAdded   = "added"
+	StatusRemoved = "removed"
+)
+
+// Diff is what changed between two snapshots of the same path
+type Diff struct {
+	From Snapshot `json:"from" yaml:"from"`
+	To   Snapshot `json:"to" yaml:"to"`
+	// Delta is the change of the whole tree
+	Delta int64 `json:"delta_bytes" yaml:"delta_bytes"`
+	// Changes are sorted by how much they changed, either way
+	Changes []Change `json:"changes" yaml:"changes"`
+	// ChangeCount may be more than Changes holds when the diff was trimmed
+	ChangeCount int `json:"change_count" yaml:"change_count"`
+}
+
+// Compare lists what grew, shrank, appeared or disappeared from a to b.
+// The root itself is left out; Delta covers it.
+func Compare(a, b Snapshot) (Diff, error) {
+	if a.Root != b.Root {
+		return Diff{}, fmt.Errorf("the snapshots are of different paths: %s and %s", a.Root, b.Root)
+	}
+	d := Diff{From: a, To: b, Delta: int64(b.Size) - int64(a.Size), Changes: []Change{}}
+	before := make(map[string]Entry, len(a.Entries))
+	for _, e := range a.Entries {
+		before[e.Path] = e
+	}
+	add := func(path string, isDir bool, from, to uint64, status string) {
+		d.Changes = append(d.Changes, Change{Path: filepath.Join(a.Root, path), IsDir: isDir, Before: from, After: to,
+			Delta: int64(to) - int64(from), Status: status})
+	}
+	for _, e := range b.Entries {
+		old, ok := before[e.Path]
+		delete(before, e.Path)
+		switch {
+		case e.Path == ".":
+		case !ok:
+			add(e.Path, e.IsDir, 0, e.Size, StatusAdded)
+		case e.Size > old.Size:
+			add(e.Path, e.IsDir, old.Size, e.Size, StatusGrew)
+		case e.Size < old.Size:
+			add(e.Path, e.IsDir, old.Size, e.Size, StatusShrank)
+		}
+	}
+	for _, e := range before {
+		if e.Path != "." {
+			add(e.Path, e.IsDir, e.Size, 0, StatusRemoved)
+		}
+	}
+	sort.Slice(d.Changes, func(i, j int) bool {
+		x, y := abs(d.Changes[i].Delta), abs(d.Changes[j].Delta)
+		if x != y {
+			return x > y
+		}
+		return d.Changes[i].Path < d.Changes[j].Path
+	})
+	d.ChangeCount = len(d.Changes)
+	return d, nil
+}
+
+// Collapse leaves out directories whose change is all in one of the
+// changes listed below them, but for less than the snapshots' minimum size,
+// so a new file does not also show as every directory above it growing by
+// the same amount
+func (d Diff) Collapse() Diff {
+	delta := make(map[string]int64, len(d.Changes))
+	for _, c := range d.Changes {
+		delta[c.Path] = c.Delta
+	}
+	slack := int64(max(d.From.MinSize, d.To.MinSize))
+	explained := map[string]bool{}
+	for _, c := range d.Changes {
+		parent, ok := delta[filepath.Dir(c.Path)]
+		if ok && (parent > 0) == (c.Delta > 0) && abs(parent-c.Delta) < slack {
+			explained[filepath.Dir(c.Path)] = true
+		}
+	}
+	changes := make([]Change, 0, len(d.Changes))
+	for _, c := range d.Changes {
+		if !explained[c.Path] {
+			changes = append(changes, c)
+		}
+	}
+	d.Changes = changes
+	d.ChangeCount = len(changes)
+	return d
+}
+
+// Top trims the diff to the n largest changes, all of them when n is 0
+func (d Diff) Top(n int) Diff {
+	if n > 0 && len(d.Changes) > n {
+		d.Changes = d.Changes[:n]
+	}
+	return d
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,174 @@
+package snapshot
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"checkpoint/pkg/config"
+)
+
+// Retention is how long saved snapshots are kept; older ones of the same
+// path are removed when a new one is saved
+const Retention = 180 * 24 * time.Hour
+
+// extension ends the names of snapshot files
+const extension = ".json.gz"
+
+// Store is a directory of saved snapshots. A snapshot's ID is its file name
+// without the extension: the path it is of and when it was taken.
+type Store struct {
+	dir string
+}
+
+// Open opens the store in the state directory
+func Open() (*Store, error) {
+	dir, err := config.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return OpenDir(filepath.Join(dir, "snapshots")), nil
+}
+
+// OpenDir opens a store in an explicit directory
+func OpenDir(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Dir returns the directory holding the snapshots
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// id names a snapshot by its root and time, e.g. home-alice-20240301-120000
+func id(snap Snapshot) string {
+	name := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.':
+			return r
+		}
+		return '-'
+	}, snap.Root), "-")
+	if name == "" {
+		name = "root"
+	}
+	return name + "-" + snap.Taken.UTC().Format("20060102-150405")
+}
+
+// Save writes a snapshot and returns its ID, then removes snapshots of the
+// same path older than Retention. The file is replaced atomically.
+func (s *Store) Save(snap Snapshot) (string, error) {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %v", s.dir, err)
+	}
+	snap.ID = id(snap)
+	path := filepath.Join(s.dir, snap.ID+extension)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	err = Write(f, snap)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write %s: %v", path, err)
+	}
+
+	if old, err := s.List(snap.Root); err == nil {
+		for _, o := range old {
+			if snap.Taken.Sub(o.Taken) > Retention {
+				os.Remove(filepath.Join(s.dir, o.ID+extension))
+			}
+		}
+	}
+	return snap.ID, nil
+}
+
+// List returns the saved snapshots of root, or of every path when root is
+// "", newest first and without their entries
+func (s *Store) List(root string) ([]Snapshot, error) {
+	files, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Snapshot{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", s.dir, err)
+	}
+	snaps := []Snapshot{}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), extension) {
+			continue
+		}
+		snap, err := s.read(filepath.Join(s.dir, f.Name()), true)
+		if err != nil || (root != "" && snap.Root != root) {
+			continue
+		}
+		snaps = append(snaps, snap)
+	}
+	sort.Slice(snaps, func(i, j int) bool { return snaps[i].Taken.After(snaps[j].Taken) })
+	return snaps, nil
+}
+
+// Load reads a snapshot by its ID, or from a snapshot file at any path
+func (s *Store) Load(ref string) (Snapshot, error) {
+	path := filepath.Join(s.dir, ref+extension)
+	if strings.ContainsRune(ref, os.PathSeparator) || strings.HasSuffix(ref, extension) {
+		path = ref
+	}
+	snap, err := s.read(path, false)
+	if errors.Is(err, os.ErrNotExist) {
+		return snap, fmt.Errorf("no snapshot %s, see \"checkpoint snapshot list\"", ref)
+	}
+	return snap, err
+}
+
+// Delete removes a saved snapshot
+func (s *Store) Delete(id string) error {
+	err := os.Remove(filepath.Join(s.dir, id+extension))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("no snapshot %s, see \"checkpoint snapshot list\"", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete snapshot %s: %v", id, err)
+	}
+	return nil
+}
+
+func (s *Store) read(path string, headerOnly bool) (Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+	snap, err := Read(f, headerOnly)
+	if err != nil {
+		return snap, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	snap.ID = strings.TrimSuffix(filepath.Base(path), extension)
+	return snap, nil
+}
+
+// Baseline picks the snapshot to compare with to see what changed since at:
+// the newest taken by then, else the oldest there is. snaps must be sorted
+// newest first, as List returns them.
+func Baseline(snaps []Snapshot, at time.Time) (Snapshot, bool) {
+	for _, snap := range snaps {
+		if !snap.Taken.After(at) {
+			return snap, true
+		}
+	}
+	if len(snaps) == 0 {
+		return Snapshot{}, false
+	}
+	return snaps[len(snaps)-1], true
+}
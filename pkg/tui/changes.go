@@ -0,0 +1,169 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/snapshot"
+	"checkpoint/pkg/ui"
+)
+
+// changesSince is how far back "What changed" looks
+const changesSince = 7 * 24 * time.Hour
+
+// snapshotEvery is how often a finished usage scan is saved as a snapshot
+const snapshotEvery = 24 * time.Hour
+
+var growStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("203"))
+
+// usageSnapshotMsg is the comparison of a finished scan with the snapshot
+// of a week ago, diff is nil when there is none yet
+type usageSnapshotMsg struct {
+	scanner *analyzer.Scanner
+	diff    *snapshot.Diff
+	err     error
+}
+
+// snapshotCmd saves a finished scan as a snapshot, unless one was saved
+// recently, and compares it with the snapshot of a week ago
+func snapshotCmd(scanner *analyzer.Scanner, result *analyzer.Result) tea.Cmd {
+	return func() tea.Msg {
+		msg := usageSnapshotMsg{scanner: scanner}
+		store, err := snapshot.Open()
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		now := time.Now()
+		snap := snapshot.FromResult(result, snapshot.DefaultMinSize, now)
+		snaps, err := store.List(snap.Root)
+		if err != nil {
+			msg.err = err
+			return msg
+		}
+		if len(snaps) == 0 || now.Sub(snaps[0].Taken) > snapshotEvery {
+			if _, msg.err = store.Save(snap); msg.err != nil {
+				return msg
+			}
+		}
+		if base, ok := snapshot.Baseline(snaps, now.Add(-changesSince)); ok {
+			from, err := store.Load(base.ID)
+			if err != nil {
+				msg.err = err
+				return msg
+			}
+			diff, err := snapshot.Compare(from, snap)
+			if err != nil {
+				msg.err = err
+				return msg
+			}
+			diff = diff.Collapse()
+			msg.diff = &diff
+		}
+		return msg
+	}
+}
+
+func (m model) updateSnapshotResult(msg usageSnapshotMsg) (tea.Model, tea.Cmd) {
+	u := m.usage
+	if u == nil || msg.scanner != u.scanner {
+		return m, nil
+	}
+	if msg.err != nil {
+		m.setError(fmt.Sprintf("❌ Failed to save a snapshot: %v", msg.err))
+		return m, nil
+	}
+	u.changes = msg.diff
+	if u.wantChanges {
+		m.toggleChanges()
+	}
+	return m, nil
+}
+
+// toggleChanges switches between the folders and what changed in them
+func (m *model) toggleChanges() {
+	u := m.usage
+	if u.showChanges {
+		u.showChanges = false
+		return
+	}
+	if u.changes == nil {
+		m.setStatus(fmt.Sprintf("📸 Saved a first snapshot of %s, check back later to see what changed", u.root))
+		return
+	}
+	u.showChanges = true
+	u.changeOffset = 0
+}
+
+func (m model) updateChangesKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	u := m.usage
+	switch msg.String() {
+	case "esc", "w":
+		u.showChanges = false
+		u.wantChanges = false
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		m.scrollChanges(-1)
+	case "down", "j":
+		m.scrollChanges(1)
+	case "pgup":
+		m.scrollChanges(-m.usageRows())
+	case "pgdown":
+		m.scrollChanges(m.usageRows())
+	case "home", "g":
+		u.changeOffset = 0
+	case "end", "G":
+		m.scrollChanges(len(u.changes.Changes))
+	case "r":
+		next, cmd := m.openUsage(u.root)
+		next.(model).usage.wantChanges = true
+		return next, cmd
+	}
+	return m, nil
+}
+
+func (m *model) scrollChanges(delta int) {
+	u := m.usage
+	u.changeOffset = min(max(u.changeOffset+delta, 0), max(len(u.changes.Changes)-m.usageRows(), 0))
+}
+
+func (m model) changesView() string {
+	d := m.usage.changes
+	title := fmt.Sprintf("🕒 What changed in %s since %s", nameStyle.Render(d.From.Root), d.From.Taken.Local().Format("Mon Jan 2"))
+	style := freeStyle
+	if d.Delta > 0 {
+		style = growStyle
+	}
+	title += "  " + style.Render(ui.FormatDelta(d.Delta))
+	if d.From.Partial || d.To.Partial {
+		title += " " + errorStyle.Render("partial")
+	}
+	lines := []string{title, ""}
+	if len(d.Changes) == 0 {
+		lines = append(lines, dimStyle.Render("Nothing of at least "+ui.FormatBytes(d.To.MinSize)+" changed"))
+	}
+
+	end := min(m.usageRows(), len(d.Changes)-m.usage.changeOffset)
+	for _, c := range d.Changes[m.usage.changeOffset : m.usage.changeOffset+end] {
+		style := freeStyle
+		if c.Delta > 0 {
+			style = growStyle
+		}
+		path := strings.TrimPrefix(strings.TrimPrefix(c.Path, d.From.Root), "/")
+		if c.IsDir {
+			path = nameStyle.Render(truncateLeft(path+"/", m.width-24))
+		} else {
+			path = truncateLeft(path, m.width-24)
+		}
+		lines = append(lines, fmt.Sprintf("  %s  %s  %s",
+			style.Render(fmt.Sprintf("%10s", ui.FormatDelta(c.Delta))), dimStyle.Render(fmt.Sprintf("%-7s", c.Status)), path))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/installer"
+)
+
+func (m model) openInput(kind dialogKind, placeholder string) (tea.Model, tea.Cmd) {
+	m.dialog = kind
+	m.input.Reset()
+	m.input.Placeholder = placeholder
+	return m, m.input.Focus()
+}
+
+func (m *model) closeDialog() {
+	m.dialog = dialogNone
+	m.input.Blur()
+	m.loading = false
+}
+
+func (m model) updateDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "esc" {
+		m.closeDialog()
+		m.setStatus("Cancelled")
+		return m, nil
+	}
+
+	switch m.dialog {
+	case dialogMount:
+		return m.updateMountDialog(msg)
+	case dialogConfirm:
+		return m.updateConfirmDialog(msg)
+	case dialogAddPath, dialogInstall, dialogSearch, dialogSavePreset:
+		if msg.String() != "enter" {
+			var cmd tea.Cmd
+			m.input, cmd = m.input.Update(msg)
+			return m, cmd
+		}
+	}
+
+	value := strings.TrimSpace(m.input.Value())
+	kind := m.dialog
+	m.closeDialog()
+	if kind == dialogSearch {
+		m.applySearch(value)
+		return m, nil
+	}
+	if value == "" {
+		m.setStatus("Cancelled")
+		return m, nil
+	}
+
+	switch kind {
+	case dialogAddPath:
+		// Validate on a throwaway manager, the next scan adds it for real
+		if err := disk.NewManager().AddCustomPath(value); err != nil {
+			m.setError(fmt.Sprintf("❌ Error adding disk: %v", err))
+			return m, nil
+		}
+		m.customPaths = append(m.customPaths, value)
+		m.scanning = true
+		m.setStatus("✅ Disk added successfully")
+		return m, m.scanCmd()
+
+	case dialogSavePreset:
+		m.savePreset(value)
+		return m, nil
+
+	case dialogInstall:
+		group := m.selectedGroup()
+		var target *disk.Disk
+		if group != nil && len(group.Disks) > 0 {
+			d := group.Disks[0]
+			target = &d
+		}
+		run := func() error { return installer.ExecuteCommand(value, target) }
+		return m, tea.Exec(&pausedProcess{run: run}, func(err error) tea.Msg {
+			return installedMsg{err: err}
+		})
+	}
+	return m, nil
+}
+
+func (m model) updateMountDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		if m.mountSel > 0 {
+			m.mountSel--
+		}
+	case "down", "j":
+		if m.mountSel < len(m.unmounted)-1 {
+			m.mountSel++
+		}
+	case "enter":
+		if m.loading || len(m.unmounted) == 0 {
+			m.closeDialog()
+			return m, nil
+		}
+		device := m.unmounted[m.mountSel].Device
+		m.loading = true
+		return m, func() tea.Msg {
+			mountPoint, err := disk.MountDevice(device)
+			return mountedMsg{device: device, mountPoint: mountPoint, err: err}
+		}
+	}
+	return m, nil
+}
+
+func (m model) updateConfirmDialog(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "Y", "enter":
+		cmd := m.onConfirm
+		m.closeDialog()
+		m.onConfirm = nil
+		return m, cmd
+	case "n", "N", "q":
+		m.closeDialog()
+		m.onConfirm = nil
+		m.setStatus("Cancelled")
+	}
+	return m, nil
+}
+
+func (m model) updateDialogResult(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case unmountedMsg:
+		m.loading = false
+		m.unmounted = msg.disks
+		if msg.err != nil {
+			m.closeDialog()
+			m.setError(fmt.Sprintf("❌ Error scanning unmounted disks: %v", msg.err))
+		}
+	case mountedMsg:
+		m.closeDialog()
+		if msg.err != nil {
+			m.setError(fmt.Sprintf("❌ %v", msg.err))
+			return m, nil
+		}
+		m.setStatus(fmt.Sprintf("✅ Mounted %s at %s", msg.device, msg.mountPoint))
+		m.scanning = true
+		return m, m.scanCmd()
+	case installedMsg:
+		if msg.err != nil {
+			m.setError(fmt.Sprintf("❌ Error executing command: %v", msg.err))
+		} else {
+			m.setStatus("✅ Command executed successfully")
+		}
+		m.scanning = true
+		return m, m.scanCmd()
+	}
+	return m, nil
+}
+
+// pausedProcess runs an installation or tool while Bubble Tea has handed
+// the terminal back, so it can prompt and stream output as usual, and waits
+// for Enter so the user can read the output
+type pausedProcess struct {
+	run   func() error
+	stdin io.Reader
+}
+
+func (p *pausedProcess) Run() error {
+	err := p.run()
+	fmt.Print("\nPress Enter to return to checkpoint...")
+	in := p.stdin
+	if in == nil {
+		in = os.Stdin
+	}
+	bufio.NewReader(in).ReadString('\n')
+	return err
+}
+
+func (p *pausedProcess) SetStdin(r io.Reader) { p.stdin = r }
+func (p *pausedProcess) SetStdout(io.Writer)  {}
+func (p *pausedProcess) SetStderr(io.Writer)  {}
@@ -0,0 +1,576 @@
+package tui
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/ui"
+)
+
+// Tabs of the properties screen
+const (
+	tabGeneral = iota
+	tabHardware
+	tabMounts
+	tabTrash
+	tabTools
+)
+
+var tabNames = []string{"General", "Hardware", "Mounts", "Trash", "Tools"}
+
+// Tools on the Tools tab
+const (
+	toolUsage = iota
+	toolChanges
+	toolCleanup
+	toolRemove
+)
+
+var (
+	activeTabStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("86")).
+			Underline(true)
+
+	propLabelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("245")).
+			Width(14)
+
+	freeStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("82"))
+)
+
+// Messages produced by the properties screen
+type (
+	propsMsg struct {
+		name  string
+		props disk.Properties
+	}
+	trashMsg struct {
+		dirs  []string
+		size  uint64
+		items int
+		list  []disk.TrashItem
+	}
+	toolMsg struct {
+		status string
+		err    error
+		close  bool // leave the properties screen, e.g. after removing the drive
+	}
+)
+
+func (m model) openProperties() (tea.Model, tea.Cmd) {
+	group := m.selectedGroup()
+	if group == nil {
+		return m, nil
+	}
+	m.properties = true
+	m.tab = tabGeneral
+	m.props = nil
+	m.trash = trashMsg{}
+	m.trashSel = 0
+	m.toolSel = 0
+
+	g := *group
+	load := func() tea.Msg {
+		return propsMsg{name: g.Name, props: disk.LoadProperties(g)}
+	}
+	return m, tea.Batch(load, trashCmd(g))
+}
+
+// trashCmd measures the user's trash on every mount point of the group
+func trashCmd(group disk.DriveGroup) tea.Cmd {
+	return func() tea.Msg {
+		dirs := disk.GroupTrashDirs(group)
+		size, items := disk.TrashSize(dirs)
+		list, _ := disk.ListTrash(dirs)
+		return trashMsg{dirs: dirs, size: size, items: items, list: list}
+	}
+}
+
+func (m model) updatePropertiesKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch key := msg.String(); key {
+	case "esc", "backspace":
+		m.properties = false
+	case "q":
+		return m, tea.Quit
+	case "left", "h", "shift+tab":
+		m.tab = (m.tab + len(tabNames) - 1) % len(tabNames)
+	case "right", "l", "tab":
+		m.tab = (m.tab + 1) % len(tabNames)
+	case "1", "2", "3", "4", "5":
+		m.tab, _ = strconv.Atoi(key)
+		m.tab--
+	case "up", "k":
+		if m.tab == tabTools && m.toolSel > 0 {
+			m.toolSel--
+		}
+		if m.tab == tabTrash && m.trashSel > 0 {
+			m.trashSel--
+		}
+	case "down", "j":
+		if m.tab == tabTools && m.toolSel < toolRemove {
+			m.toolSel++
+		}
+		if m.tab == tabTrash && m.trashSel < len(m.trash.list)-1 {
+			m.trashSel++
+		}
+	case "enter":
+		if m.tab == tabTools {
+			return m.runTool(m.toolSel)
+		}
+		if m.tab == tabTrash {
+			return m.restoreTrash()
+		}
+	case "d", "delete":
+		if m.tab == tabTrash {
+			return m.deleteTrash()
+		}
+	case "r":
+		return m.updateKeys(msg)
+	}
+	return m, nil
+}
+
+func (m model) updatePropertiesResult(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case propsMsg:
+		// Ignore results for a drive the user already left
+		if group := m.selectedGroup(); group != nil && group.Name == msg.name {
+			m.props = &msg.props
+		}
+	case trashMsg:
+		m.trash = msg
+		if m.trashSel >= len(msg.list) {
+			m.trashSel = max(len(msg.list)-1, 0)
+		}
+	case toolMsg:
+		if msg.err != nil {
+			m.setError(fmt.Sprintf("❌ %v", msg.err))
+		} else {
+			m.setStatus(msg.status)
+		}
+		if msg.close {
+			m.properties = false
+		}
+		cmds := []tea.Cmd{}
+		if group := m.selectedGroup(); group != nil && m.properties {
+			cmds = append(cmds, trashCmd(*group))
+		}
+		if !m.scanning {
+			m.scanning = true
+			cmds = append(cmds, m.scanCmd())
+		}
+		return m, tea.Batch(cmds...)
+	}
+	return m, nil
+}
+
+// toolUnavailable explains why a tool cannot be used on the selected drive,
+// or returns "" when it can
+func (m model) toolUnavailable(tool int) string {
+	group := m.selectedGroup()
+	if group == nil {
+		return "No drive selected"
+	}
+	switch tool {
+	case toolCleanup:
+		if m.trash.items == 0 {
+			return "The trash on this drive is empty"
+		}
+	case toolRemove:
+		if group.IsPrimary {
+			return "The system drive cannot be removed"
+		}
+		if len(groupDevices(*group)) == 0 {
+			return "Only local disks can be removed"
+		}
+	}
+	return ""
+}
+
+func (m model) runTool(tool int) (tea.Model, tea.Cmd) {
+	if reason := m.toolUnavailable(tool); reason != "" {
+		m.setError(reason)
+		return m, nil
+	}
+	group := *m.selectedGroup()
+
+	switch tool {
+	case toolUsage:
+		return m.openUsage(group.Disks[0].MountPoint)
+
+	case toolChanges:
+		next, cmd := m.openUsage(group.Disks[0].MountPoint)
+		next.(model).usage.wantChanges = true
+		return next, cmd
+
+	case toolCleanup:
+		trash := m.trash
+		m.confirm = fmt.Sprintf("Permanently delete %d items (%s) from the trash on %s?",
+			trash.items, ui.FormatBytes(trash.size), group.Name)
+		m.onConfirm = func() tea.Msg {
+			if err := disk.EmptyTrash(trash.dirs); err != nil {
+				return toolMsg{err: err}
+			}
+			return toolMsg{status: fmt.Sprintf("✅ Freed %s", ui.FormatBytes(trash.size))}
+		}
+
+	case toolRemove:
+		props := m.props
+		m.confirm = fmt.Sprintf("Unmount %s so it can be removed safely?", group.Name)
+		m.onConfirm = func() tea.Msg {
+			devices := groupDevices(group)
+			parent, powerOff := "", false
+			if props != nil {
+				parent, powerOff = props.Parent, props.Removable
+			}
+			for i, device := range devices {
+				// Power off once, after the last filesystem is unmounted
+				last := i == len(devices)-1
+				if err := disk.UnmountDevice(device, parent, powerOff && last); err != nil {
+					return toolMsg{err: err}
+				}
+			}
+			return toolMsg{status: fmt.Sprintf("✅ %s can now be removed safely", group.Name), close: true}
+		}
+	}
+	m.dialog = dialogConfirm
+	return m, nil
+}
+
+// selectedTrash is the item picked on the Trash tab
+func (m model) selectedTrash() *disk.TrashItem {
+	if m.trashSel < len(m.trash.list) {
+		return &m.trash.list[m.trashSel]
+	}
+	return nil
+}
+
+// restoreTrash puts the selected item back where it was deleted from
+func (m model) restoreTrash() (tea.Model, tea.Cmd) {
+	item := m.selectedTrash()
+	if item == nil {
+		return m, nil
+	}
+	restore := *item
+	return m, func() tea.Msg {
+		if err := disk.RestoreTrash(restore); err != nil {
+			return toolMsg{err: err}
+		}
+		return toolMsg{status: "✅ Restored " + restore.Path}
+	}
+}
+
+// deleteTrash asks before deleting the selected item for good
+func (m model) deleteTrash() (tea.Model, tea.Cmd) {
+	item := m.selectedTrash()
+	if item == nil {
+		return m, nil
+	}
+	remove := *item
+	m.confirm = fmt.Sprintf("Permanently delete %s (%s)?", filepath.Base(remove.Path), ui.FormatBytes(remove.Size))
+	m.onConfirm = func() tea.Msg {
+		if err := disk.DeleteTrash(remove); err != nil {
+			return toolMsg{err: err}
+		}
+		return toolMsg{status: fmt.Sprintf("✅ Freed %s", ui.FormatBytes(remove.Size))}
+	}
+	m.dialog = dialogConfirm
+	return m, nil
+}
+
+// groupDevices lists the distinct block devices mounted by a group
+func groupDevices(group disk.DriveGroup) []string {
+	seen := map[string]bool{}
+	devices := []string{}
+	for _, d := range group.Disks {
+		if strings.HasPrefix(d.Device, "/dev/") && !seen[d.Device] {
+			seen[d.Device] = true
+			devices = append(devices, d.Device)
+		}
+	}
+	return devices
+}
+
+func (m model) propertiesView() string {
+	tabs := []string{}
+	for i, name := range tabNames {
+		if i == m.tab {
+			tabs = append(tabs, activeTabStyle.Render(name))
+		} else {
+			tabs = append(tabs, dimStyle.Render(name))
+		}
+	}
+	tabBar := " " + strings.Join(tabs, dimStyle.Render(" │ "))
+
+	inner := m.width - detailsStyle.GetHorizontalFrameSize()
+	height := m.bodyHeight() - 1 - detailsStyle.GetVerticalFrameSize()
+	var content string
+	switch m.tab {
+	case tabGeneral:
+		content = m.generalTab(inner, height)
+	case tabHardware:
+		content = m.hardwareTab()
+	case tabMounts:
+		content = m.mountsTab()
+	case tabTrash:
+		content = m.trashTab(inner, height)
+	case tabTools:
+		content = m.toolsTab()
+	}
+
+	content = lipgloss.NewStyle().MaxWidth(inner).MaxHeight(height).Render(content)
+	box := detailsStyle.Width(m.width - 2).Height(height).Render(content)
+	return lipgloss.JoinVertical(lipgloss.Left, tabBar, box)
+}
+
+func (m model) generalTab(inner, height int) string {
+	group := m.selectedGroup()
+	props := m.props
+	if props == nil {
+		props = &disk.Properties{}
+	}
+	usedPercent := percent(group.TotalUsed, group.TotalSize)
+	usedStyle := lipgloss.NewStyle().Foreground(levelColor(alertLevel(*group)))
+
+	filesystem := props.Filesystem
+	if filesystem == "" && len(group.Disks) > 0 {
+		filesystem = group.Disks[0].Filesystem
+	}
+	lines := []string{
+		fmt.Sprintf("%s %s  %s", group.Icon, nameStyle.Render(group.Name), letterStyle.Render(disk.DriveLetter(m.selected))),
+		"",
+		prop("Type", group.Description),
+		prop("Label", m.loaded(props.Label)),
+		prop("File system", filesystem),
+		prop("UUID", m.loaded(props.UUID)),
+		"",
+		propLabelStyle.Render("Used space") + usedStyle.Render("■ ") + byteCount(group.TotalUsed),
+		propLabelStyle.Render("Free space") + freeStyle.Render("■ ") + byteCount(group.Available),
+		"",
+		prop("Capacity", byteCount(group.TotalSize)),
+	}
+	text := strings.Join(lines, "\n")
+
+	// Put the pie next to the text when there is room, otherwise skip it:
+	// the numbers above say the same thing
+	radius := (height - 1) / 2
+	if radius > 5 {
+		radius = 5
+	}
+	textWidth := lipgloss.Width(text)
+	if radius < 2 || inner < textWidth+4*radius+6 {
+		return text
+	}
+	pie := pieChart(usedPercent, radius, usedStyle) + "\n" +
+		dimStyle.Render(fmt.Sprintf("%*s", 2*radius+4, fmt.Sprintf("%.1f%% used", usedPercent)))
+	return lipgloss.JoinHorizontal(lipgloss.Top, text, "    ", pie)
+}
+
+func (m model) hardwareTab() string {
+	group := m.selectedGroup()
+	if m.props == nil {
+		return dimStyle.Render("Reading device information...")
+	}
+	props := m.props
+	if props.Parent == "" {
+		device := props.Device
+		if device == "" && len(group.Disks) > 0 {
+			device = group.Disks[0].Device
+		}
+		return strings.Join([]string{
+			prop("Device", device),
+			"",
+			dimStyle.Render("Hardware details are only available for local block devices."),
+		}, "\n")
+	}
+
+	media := "Solid state"
+	if props.Rotational {
+		media = "Hard disk (rotational)"
+	}
+	removable := "No"
+	if props.Removable {
+		removable = "Yes"
+	}
+	return strings.Join([]string{
+		prop("Device", props.Device),
+		prop("Disk", props.Parent),
+		prop("Model", m.loaded(props.Model)),
+		prop("Vendor", m.loaded(props.Vendor)),
+		prop("Serial", m.loaded(props.Serial)),
+		prop("Connection", m.loaded(strings.ToUpper(props.Transport))),
+		prop("Media", media),
+		prop("Removable", removable),
+		propLabelStyle.Render("Health") + healthView(props.Health),
+	}, "\n")
+}
+
+func (m model) mountsTab() string {
+	group := m.selectedGroup()
+	lines := []string{}
+	for i, d := range group.Disks {
+		if i > 0 {
+			lines = append(lines, "")
+		}
+		mount := "📁 " + nameStyle.Render(d.MountPoint)
+		if hasOption(d.Options, "ro") {
+			mount += " " + errorStyle.Render("read-only")
+		}
+		lines = append(lines,
+			mount,
+			prop("Source", d.Device),
+			prop("File system", fmt.Sprintf("%s · %s · %s", d.Filesystem, d.Type, ui.FormatBytes(d.Size))),
+			prop("Options", m.loaded(d.Options)),
+		)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m model) trashTab(width, height int) string {
+	if m.trash.dirs == nil {
+		return dimStyle.Render("Reading the trash...")
+	}
+	if len(m.trash.list) == 0 {
+		return dimStyle.Render("The trash on this drive is empty.")
+	}
+	lines := []string{
+		fmt.Sprintf("%d items, %s", len(m.trash.list), ui.FormatBytes(m.trash.size)) +
+			dimStyle.Render(" · enter restore · d delete for good"),
+		"",
+	}
+	// Two lines per item; keep the selected one in view
+	room := (height - len(lines)) / 2
+	first := 0
+	if m.trashSel >= room && room > 0 {
+		first = m.trashSel - room + 1
+	}
+	for i := first; i < len(m.trash.list) && i < first+room; i++ {
+		item := m.trash.list[i]
+		icon := "📄"
+		if item.IsDir {
+			icon = "📁"
+		}
+		size := ui.FormatBytes(item.Size)
+		name := fmt.Sprintf("  %s %s", icon, filepath.Base(item.Path))
+		if i == m.trashSel {
+			name = nameStyle.Render(fmt.Sprintf("▸ %s %s", icon, filepath.Base(item.Path)))
+		}
+		deleted := "deleted at an unknown time"
+		if !item.DeletedAt.IsZero() {
+			deleted = "deleted " + item.DeletedAt.Format("2006-01-02 15:04")
+		}
+		lines = append(lines,
+			name+"  "+dimStyle.Render(size),
+			dimStyle.Render(ansi.Truncate(fmt.Sprintf("    %s · from %s", deleted, filepath.Dir(item.Path)), width, "…")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m model) toolsTab() string {
+	tools := []struct{ name, desc string }{
+		{"📊 Check usage", "See which folders take up the most space"},
+		{"🕒 What changed since last week?", "See which folders and files grew or shrank since a week ago"},
+		{"🧹 Clean up", fmt.Sprintf("Empty the trash on this drive (%d items, %s)", m.trash.items, ui.FormatBytes(m.trash.size))},
+		{"⏏️  Safely remove", "Unmount the drive so it can be unplugged"},
+	}
+	lines := []string{}
+	for i, tool := range tools {
+		desc := tool.desc
+		if reason := m.toolUnavailable(i); reason != "" {
+			desc = reason
+		}
+		name := "  " + tool.name
+		if i == m.toolSel {
+			name = nameStyle.Render("▸ " + tool.name)
+		}
+		lines = append(lines, name, dimStyle.Render("    "+desc), "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// loaded shows a placeholder while the properties load and for missing values
+func (m model) loaded(value string) string {
+	switch {
+	case value != "":
+		return value
+	case m.props == nil:
+		return dimStyle.Render("...")
+	}
+	return dimStyle.Render("Not available")
+}
+
+func prop(label, value string) string {
+	return propLabelStyle.Render(label) + value
+}
+
+func healthView(health string) string {
+	switch strings.ToUpper(health) {
+	case "":
+		return dimStyle.Render("Unknown (reading SMART data needs smartctl and root)")
+	case "PASSED", "OK":
+		return availableStyle.Render("✅ " + health)
+	}
+	return errorStyle.Render("⚠️  " + health)
+}
+
+func hasOption(options, name string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == name {
+			return true
+		}
+	}
+	return false
+}
+
+// byteCount shows an exact byte count next to the friendly size, the way
+// file manager property dialogs do
+func byteCount(n uint64) string {
+	digits := strconv.FormatUint(n, 10)
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(c)
+	}
+	return fmt.Sprintf("%s bytes  %s", b.String(), dimStyle.Render(ui.FormatBytes(n)))
+}
+
+// pieChart draws a filled circle with the used share swept clockwise from
+// the top. Terminal cells are about twice as tall as wide, so each row
+// spans twice as many columns.
+func pieChart(usedPercent float64, radius int, usedStyle lipgloss.Style) string {
+	r := float64(radius)
+	rows := []string{}
+	for y := -radius; y <= radius; y++ {
+		var row strings.Builder
+		for x := -2 * radius; x <= 2*radius; x++ {
+			dx, dy := float64(x)/2, float64(y)
+			if dx*dx+dy*dy > r*r+0.5 {
+				row.WriteByte(' ')
+				continue
+			}
+			angle := math.Atan2(dx, -dy)
+			if angle < 0 {
+				angle += 2 * math.Pi
+			}
+			if angle/(2*math.Pi)*100 < usedPercent {
+				row.WriteString(usedStyle.Render("█"))
+			} else {
+				row.WriteString(freeStyle.Render("█"))
+			}
+		}
+		rows = append(rows, row.String())
+	}
+	return strings.Join(rows, "\n")
+}
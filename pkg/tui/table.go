@@ -0,0 +1,214 @@
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/ui"
+)
+
+// tableHeaderLines is the column header row plus its bottom border
+const tableHeaderLines = 2
+
+func (m model) updateTableKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "t", "esc":
+		m.technical = false
+	case "up", "k":
+		m.scrollTable(-1)
+	case "down", "j":
+		m.scrollTable(1)
+	case "pgup":
+		m.scrollTable(-m.tableRows())
+	case "pgdown":
+		m.scrollTable(m.tableRows())
+	case "home", "g":
+		m.tableOffset = 0
+	case "end", "G":
+		m.scrollTable(len(m.disks))
+	case "d":
+		m.detailed = !m.detailed
+	case "s":
+		m.filter.Sort = nextSortKey(m.filter.Sort)
+		m.preset = ""
+		m.filterChanged()
+	case "S":
+		m.filter.Reverse = !m.filter.Reverse
+		m.preset = ""
+		m.filterChanged()
+	case "f":
+		m.nextPreset()
+	case "c":
+		m.filter = disk.Filter{}
+		m.preset = ""
+		m.filterChanged()
+	case "/":
+		return m.openInput(dialogSearch, "mount point text, glob such as /mnt/* or /regexp/")
+	case "p":
+		return m.openInput(dialogSavePreset, "preset name")
+	default:
+		return m.updateKeys(msg)
+	}
+	return m, nil
+}
+
+func (m model) updateTableMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		m.scrollTable(-1)
+	case tea.MouseButtonWheelDown:
+		m.scrollTable(1)
+	}
+	return m, nil
+}
+
+// filterChanged scrolls back to the top and shows the active filter
+func (m *model) filterChanged() {
+	m.tableOffset = 0
+	if err := m.filter.Validate(); err != nil {
+		m.setError(fmt.Sprintf("❌ %v", err))
+		return
+	}
+	desc := m.filter.Describe()
+	if m.preset != "" {
+		desc = fmt.Sprintf("preset %s: %s", m.preset, desc)
+	}
+	m.setStatus("🔎 " + desc)
+}
+
+// nextPreset cycles through the presets in name order, then back to none
+func (m *model) nextPreset() {
+	names := disk.PresetNames(m.presets)
+	next := ""
+	if m.preset == "" && len(names) > 0 {
+		next = names[0]
+	}
+	for i, name := range names {
+		if name == m.preset && i+1 < len(names) {
+			next = names[i+1]
+		}
+	}
+	m.preset = next
+	m.filter = m.presets[next]
+	m.filterChanged()
+}
+
+func nextSortKey(current disk.SortKey) disk.SortKey {
+	for i, k := range disk.SortKeys {
+		if k == current {
+			return disk.SortKeys[(i+1)%len(disk.SortKeys)]
+		}
+	}
+	return disk.SortNone
+}
+
+// applySearch turns the search box text into a mount point filter. Text
+// between slashes is a regular expression, text with glob characters is a
+// glob, and anything else matches as a substring of the mount point.
+func (m *model) applySearch(text string) {
+	m.filter.MountGlob = ""
+	m.filter.MountRegexp = ""
+	switch {
+	case len(text) > 1 && strings.HasPrefix(text, "/") && strings.HasSuffix(text, "/"):
+		m.filter.MountRegexp = text[1 : len(text)-1]
+	case strings.ContainsAny(text, "*?["):
+		m.filter.MountGlob = text
+	default:
+		m.filter.MountRegexp = regexp.QuoteMeta(text)
+	}
+	m.preset = ""
+	m.filterChanged()
+}
+
+// savePreset stores the current filter and makes it selectable with f
+func (m *model) savePreset(name string) {
+	if err := disk.SavePreset(name, m.filter); err != nil {
+		m.setError(fmt.Sprintf("❌ Error saving preset: %v", err))
+		return
+	}
+	if m.presets == nil {
+		m.presets = map[string]disk.Filter{}
+	}
+	m.presets[name] = m.filter
+	m.preset = name
+	m.setStatus(fmt.Sprintf("💾 Saved filter preset %q", name))
+}
+
+// tableLines renders the filtered disk table with the shared terminal
+// renderer and splits it into the header and the data rows
+func (m model) tableLines() (header, rows []string, err error) {
+	disks, err := m.filter.Apply(m.disks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	r := ui.NewTerminalRenderer(&buf, ui.Options{Width: m.width, Profile: lipgloss.ColorProfile()})
+	if err := r.Disks(disks, m.detailed); err != nil {
+		return nil, nil, err
+	}
+
+	// Drop the renderer's title, the TUI header already names the view
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(ansi.Strip(lines[0])) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) > 0 {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(ansi.Strip(lines[0])) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) < tableHeaderLines {
+		return lines, nil, nil
+	}
+	return lines[:tableHeaderLines], lines[tableHeaderLines:], nil
+}
+
+// tableRows is how many data rows fit below the table header
+func (m model) tableRows() int {
+	n := m.bodyHeight() - tableHeaderLines
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (m *model) scrollTable(delta int) {
+	_, rows, _ := m.tableLines()
+	m.tableOffset += delta
+	if last := len(rows) - m.tableRows(); m.tableOffset > last {
+		m.tableOffset = last
+	}
+	if m.tableOffset < 0 {
+		m.tableOffset = 0
+	}
+}
+
+func (m model) tableView() string {
+	header, rows, err := m.tableLines()
+	if err != nil {
+		return errorStyle.Render(fmt.Sprintf("❌ %v", err))
+	}
+	if len(rows) == 0 {
+		return strings.Join(header, "\n") + "\n" +
+			dimStyle.Render("No disks match the filter. Press c to clear it.")
+	}
+
+	end := m.tableOffset + m.tableRows()
+	if end > len(rows) {
+		end = len(rows)
+	}
+	start := m.tableOffset
+	if start > end {
+		start = end
+	}
+	return strings.Join(append(header, rows[start:end]...), "\n")
+}
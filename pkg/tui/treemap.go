@@ -0,0 +1,110 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"checkpoint/pkg/ui"
+)
+
+// treemapLayout lays out the current entries in the space below the title
+func (m model) treemapLayout() ([]ui.TreemapItem, []ui.TreemapRect, int, int) {
+	u := m.usage
+	width, height := m.width, m.usageRows()
+	items := []ui.TreemapItem{}
+	for _, n := range u.entries {
+		label := n.Name
+		if u.largest {
+			label = n.Path()
+		}
+		if n.IsDir {
+			label += "/"
+		}
+		items = append(items, ui.TreemapItem{Label: label, Size: n.Size(u.apparent)})
+	}
+	items = ui.TopTreemapItems(items, ui.TreemapMax)
+	return items, ui.LayoutTreemap(items, width, height), width, height
+}
+
+// treemapPos is the position of the selected entry among the rectangles
+func treemapPos(rects []ui.TreemapRect, sel int) int {
+	for i, r := range rects {
+		if r.Index == sel {
+			return i
+		}
+	}
+	return 0
+}
+
+func (m model) updateTreemapKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd, bool) {
+	u := m.usage
+	_, rects, _, _ := m.treemapLayout()
+	move := func(dx, dy int) {
+		if len(rects) > 0 {
+			u.sel = rects[ui.NearestTreemapRect(rects, treemapPos(rects, u.sel), dx, dy)].Index
+		}
+	}
+
+	switch msg.String() {
+	case "up", "k":
+		move(0, -1)
+	case "down", "j":
+		move(0, 1)
+	case "left", "h":
+		move(-1, 0)
+	case "right", "l":
+		move(1, 0)
+	case "tab":
+		if len(rects) > 0 {
+			u.sel = rects[(treemapPos(rects, u.sel)+1)%len(rects)].Index
+		}
+	case "enter":
+		if u.sel >= ui.TreemapMax {
+			// The rectangle holding everything else: list it instead
+			u.treemap = false
+			m.ensureUsageVisible()
+			return m, nil, true
+		}
+		return m, nil, false
+	case "esc", "backspace":
+		if u.largest || u.dir.Parent == nil {
+			return m, nil, false
+		}
+		child := u.dir
+		u.show(u.dir.Parent)
+		u.selectNode(child)
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+func (m model) updateTreemapMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	u := m.usage
+	if msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionPress {
+		return m, nil
+	}
+	_, rects, _, _ := m.treemapLayout()
+	i := ui.TreemapRectAt(rects, msg.X, msg.Y-headerHeight-usageHeaderLines)
+	if i < 0 {
+		return m, nil
+	}
+	if rects[i].Index == u.sel {
+		// A second click zooms in
+		return m.updateUsageKeys(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	u.sel = rects[i].Index
+	return m, nil
+}
+
+func (m model) treemapView() string {
+	u := m.usage
+	items, rects, width, height := m.treemapLayout()
+	if len(rects) == 0 {
+		return dimStyle.Render("Empty")
+	}
+	return ui.RenderTreemap(items, rects, width, height, ui.TreemapOptions{
+		Profile:  lipgloss.ColorProfile(),
+		Selected: u.sel,
+	})
+}
@@ -0,0 +1,540 @@
+// Package tui implements the full-screen, keyboard and mouse driven interface.
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/client"
+	"checkpoint/pkg/daemon"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/ui"
+)
+
+// Options configures the full-screen interface
+type Options struct {
+	// Refresh is the live rescan interval, 0 disables live refresh
+	Refresh time.Duration
+	// Client attaches to a running daemon, which then does the scanning and
+	// pushes its rescans; nil scans locally
+	Client *client.Client
+}
+
+// Run starts the full-screen interface and blocks until the user quits
+func Run(opts Options) error {
+	p := tea.NewProgram(newModel(opts), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	_, err := p.Run()
+	return err
+}
+
+type dialogKind int
+
+const (
+	dialogNone dialogKind = iota
+	dialogAddPath
+	dialogInstall
+	dialogMount
+	dialogSearch
+	dialogSavePreset
+	dialogConfirm
+)
+
+type model struct {
+	opts        Options
+	groups      []disk.DriveGroup
+	disks       []disk.Disk
+	customPaths []string
+	lastScan    time.Time
+	scanning    bool
+	// client is the daemon the interface is attached to, nil when detached
+	client *client.Client
+	events <-chan daemon.Event
+
+	selected int
+	offset   int // first visible drive card
+	width    int
+	height   int
+
+	// properties screen of the selected drive, see properties.go
+	properties bool
+	tab        int
+	props      *disk.Properties
+	trash      trashMsg
+	trashSel   int
+	toolSel    int
+	confirm    string
+	onConfirm  tea.Cmd
+
+	// directory usage analyzer, see usage.go
+	usage *usageState
+
+	// usage history: every scan is recorded, the details panel charts it
+	store         *history.Store
+	history       map[string][]history.Sample
+	historyFailed bool
+
+	// space that could be freed, analysed in the background after the first
+	// scan and each rescan the user asks for
+	reclaim       *cleanup.Report
+	reclaiming    bool
+	reclaimWanted bool
+
+	// thresholdsFailed is set once a broken thresholds.yaml was reported
+	thresholdsFailed bool
+
+	dialog    dialogKind
+	input     textinput.Model
+	unmounted []disk.UnmountedDisk
+	mountSel  int
+	loading   bool
+
+	// technical table mode, see table.go
+	technical   bool
+	detailed    bool
+	filter      disk.Filter
+	presets     map[string]disk.Filter
+	preset      string
+	tableOffset int
+
+	status    string
+	statusErr bool
+}
+
+// Messages produced by background commands
+type (
+	scanMsg struct {
+		disks      []disk.Disk
+		groups     []disk.DriveGroup
+		err        error
+		at         time.Time
+		history    map[string][]history.Sample
+		historyErr error
+		// thresholdsErr is a broken thresholds.yaml; the defaults are used
+		thresholdsErr error
+	}
+	tickMsg       time.Time
+	subscribedMsg struct {
+		events <-chan daemon.Event
+		err    error
+	}
+	// daemonEventMsg is an event from the daemon, or closed when it went away
+	daemonEventMsg struct {
+		event  daemon.Event
+		closed bool
+	}
+	unmountedMsg struct {
+		disks []disk.UnmountedDisk
+		err   error
+	}
+	mountedMsg struct {
+		device     string
+		mountPoint string
+		err        error
+	}
+	installedMsg struct {
+		err error
+	}
+	reclaimMsg struct {
+		report cleanup.Report
+	}
+)
+
+func newModel(opts Options) model {
+	input := textinput.New()
+	input.Prompt = "› "
+	input.CharLimit = 4096
+
+	m := model{
+		opts:     opts,
+		input:    input,
+		width:    80,
+		height:   24,
+		scanning: true,
+		client:   opts.Client,
+
+		reclaimWanted: true,
+	}
+	if m.client != nil {
+		m.setStatus("🔗 Attached to the daemon on " + m.client.Path())
+	}
+	presets, err := disk.LoadPresets()
+	if err != nil {
+		m.setError(fmt.Sprintf("❌ %v", err))
+	}
+	m.presets = presets
+	if m.store, err = history.Open(); err != nil {
+		m.setError(fmt.Sprintf("❌ %v", err))
+	}
+	return m
+}
+
+func (m model) Init() tea.Cmd {
+	if m.client != nil {
+		return tea.Batch(m.scanCmd(), m.subscribeCmd())
+	}
+	return tea.Batch(m.scanCmd(), m.tick())
+}
+
+// scanCmd rescans in the background with a fresh Manager and records the
+// scan in the usage history. When attached, the daemon rescans and records.
+func (m model) scanCmd() tea.Cmd {
+	store, c := m.store, m.client
+	return m.loadCmd(func(msg *scanMsg) *disk.Manager {
+		if c != nil {
+			disks, err := c.Rescan()
+			msg.err = err
+			return managerFor(disks)
+		}
+		dm := disk.NewManager()
+		msg.err = dm.ScanDisks()
+		if store != nil && msg.err == nil {
+			msg.historyErr = store.Record(dm.GetDisks(), dm.LastScan())
+		}
+		return dm
+	})
+}
+
+// loadCmd turns the disks of a scan into drive groups in the background,
+// re-adding the paths the user added by hand
+func (m model) loadCmd(scan func(msg *scanMsg) *disk.Manager) tea.Cmd {
+	paths := append([]string(nil), m.customPaths...)
+	store := m.store
+	return func() tea.Msg {
+		msg := scanMsg{at: time.Now()}
+		dm := scan(&msg)
+		for _, p := range paths {
+			dm.AddCustomPath(p)
+		}
+		msg.disks = dm.GetDisks()
+		msg.groups = disk.GroupDisks(msg.disks)
+		if store != nil {
+			msg.history = store.ForGroups(msg.groups)
+			history.AddForecasts(msg.groups, msg.history)
+		}
+		thresholds, err := disk.LoadThresholds()
+		msg.thresholdsErr = err
+		thresholds.Apply(msg.groups, history.Forecasts(msg.history))
+		return msg
+	}
+}
+
+// reclaimCmd looks for space that could be freed in the background
+func reclaimCmd(disks []disk.Disk) tea.Cmd {
+	return func() tea.Msg {
+		return reclaimMsg{report: cleanup.Analyze(context.Background(), cleanup.Options{Disks: disks})}
+	}
+}
+
+// managerFor holds disks scanned elsewhere
+func managerFor(disks []disk.Disk) *disk.Manager {
+	dm := disk.NewManager()
+	for _, d := range disks {
+		dm.AddDisk(d)
+	}
+	return dm
+}
+
+// subscribeCmd asks the daemon for its scans. The subscription lasts as
+// long as the interface.
+func (m model) subscribeCmd() tea.Cmd {
+	c := m.client
+	return func() tea.Msg {
+		events, err := c.Subscribe(context.Background())
+		return subscribedMsg{events: events, err: err}
+	}
+}
+
+// waitEvent waits for the next event from the daemon
+func waitEvent(events <-chan daemon.Event) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		return daemonEventMsg{event: event, closed: !ok}
+	}
+}
+
+// detach goes back to scanning locally after the daemon went away
+func (m model) detach() (tea.Model, tea.Cmd) {
+	if m.client == nil {
+		return m, nil
+	}
+	m.client = nil
+	m.setError("⚠️  Lost the daemon, scanning locally")
+	m.scanning = true
+	return m, tea.Batch(m.scanCmd(), m.tick())
+}
+
+func (m model) tick() tea.Cmd {
+	// The daemon pushes its rescans instead
+	if m.opts.Refresh <= 0 || m.client != nil {
+		return nil
+	}
+	return tea.Tick(m.opts.Refresh, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.ensureVisible()
+		return m, nil
+
+	case scanMsg:
+		m.scanning = false
+		if msg.err != nil && m.client != nil && !m.client.Available() {
+			return m.detach()
+		}
+		var changes []disk.MountChange
+		if msg.err == nil && !m.lastScan.IsZero() {
+			changes = disk.CompareMounts(m.disks, msg.disks)
+		}
+		m.groups = msg.groups
+		m.disks = msg.disks
+		m.lastScan = msg.at
+		m.history = msg.history
+		if msg.err != nil {
+			m.setError(fmt.Sprintf("Error scanning disks: %v", msg.err))
+		} else if len(changes) > 0 {
+			m.showMountChanges(changes)
+		} else if msg.historyErr != nil && !m.historyFailed {
+			// Say it once rather than on every refresh
+			m.historyFailed = true
+			m.setError(fmt.Sprintf("⚠️  Failed to record usage history: %v", msg.historyErr))
+		} else if msg.thresholdsErr != nil && !m.thresholdsFailed {
+			m.thresholdsFailed = true
+			m.setError(fmt.Sprintf("⚠️  Using default thresholds: %v", msg.thresholdsErr))
+		}
+		if m.selected >= len(m.groups) {
+			m.selected = len(m.groups) - 1
+		}
+		if m.selected < 0 {
+			m.selected = 0
+		}
+		m.ensureVisible()
+		if m.reclaimWanted && !m.reclaiming && msg.err == nil {
+			m.reclaimWanted, m.reclaiming = false, true
+			return m, reclaimCmd(m.disks)
+		}
+		return m, nil
+
+	case reclaimMsg:
+		m.reclaiming = false
+		m.reclaim = &msg.report
+		return m, nil
+
+	case subscribedMsg:
+		if msg.err != nil {
+			return m.detach()
+		}
+		m.events = msg.events
+		return m, waitEvent(m.events)
+
+	case daemonEventMsg:
+		if msg.closed {
+			return m.detach()
+		}
+		if msg.event.Type == daemon.EventError {
+			m.setError(fmt.Sprintf("Error scanning disks: %s", msg.event.Error))
+			return m, waitEvent(m.events)
+		}
+		// The interface compares the scans itself, as it does when detached
+		if msg.event.Type == daemon.EventChanges {
+			return m, waitEvent(m.events)
+		}
+		event := msg.event
+		return m, tea.Batch(m.loadCmd(func(scan *scanMsg) *disk.Manager {
+			scan.at = event.Time
+			return managerFor(event.Disks)
+		}), waitEvent(m.events))
+
+	case tickMsg:
+		// Skip a refresh while the user is typing or a scan is running
+		if m.dialog == dialogNone && !m.scanning {
+			m.scanning = true
+			return m, tea.Batch(m.scanCmd(), m.tick())
+		}
+		return m, m.tick()
+
+	case usageTickMsg, usageDoneMsg:
+		return m.updateUsageResult(msg)
+
+	case usageSnapshotMsg:
+		return m.updateSnapshotResult(msg)
+
+	case propsMsg, trashMsg, toolMsg:
+		return m.updatePropertiesResult(msg)
+
+	case unmountedMsg, mountedMsg, installedMsg:
+		return m.updateDialogResult(msg)
+
+	case tea.MouseMsg:
+		if m.dialog == dialogNone && m.usage != nil {
+			return m.updateUsageMouse(msg)
+		}
+		if m.dialog == dialogNone && m.properties {
+			return m, nil
+		}
+		if m.dialog == dialogNone && m.technical {
+			return m.updateTableMouse(msg)
+		}
+		if m.dialog == dialogNone {
+			return m.updateMouse(msg)
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		if m.dialog != dialogNone {
+			return m.updateDialog(msg)
+		}
+		if m.usage != nil {
+			return m.updateUsageKeys(msg)
+		}
+		if m.properties {
+			return m.updatePropertiesKeys(msg)
+		}
+		if m.technical {
+			return m.updateTableKeys(msg)
+		}
+		return m.updateKeys(msg)
+	}
+
+	return m, nil
+}
+
+func (m model) updateKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "esc":
+		return m, tea.Quit
+	case "up", "k", "left", "h", "shift+tab":
+		m.move(-1)
+	case "down", "j", "right", "l", "tab":
+		m.move(1)
+	case "home", "g":
+		m.selected = 0
+		m.ensureVisible()
+	case "end", "G":
+		m.selected = len(m.groups) - 1
+		m.ensureVisible()
+	case "enter", " ":
+		return m.openProperties()
+	case "u":
+		if group := m.selectedGroup(); group != nil && len(group.Disks) > 0 {
+			return m.openUsage(group.Disks[0].MountPoint)
+		}
+	case "t":
+		m.technical = true
+		m.tableOffset = 0
+	case "r":
+		if !m.scanning {
+			m.scanning = true
+			m.reclaimWanted = true
+			m.setStatus("🔄 Rescanning disks...")
+			return m, m.scanCmd()
+		}
+	case "a":
+		return m.openInput(dialogAddPath, "/path/to/directory")
+	case "i":
+		if len(m.groups) == 0 {
+			m.setError("No drive selected")
+			return m, nil
+		}
+		return m.openInput(dialogInstall, "make install")
+	case "m":
+		m.dialog = dialogMount
+		m.loading = true
+		m.mountSel = 0
+		return m, func() tea.Msg {
+			disks, err := disk.ScanUnmountedDisks()
+			return unmountedMsg{disks: disks, err: err}
+		}
+	}
+	return m, nil
+}
+
+func (m model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.move(-1)
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.move(1)
+	case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+		if msg.X >= m.listWidth() || msg.Y < headerHeight {
+			return m, nil
+		}
+		idx := m.offset + (msg.Y-headerHeight)/cardHeight
+		if idx < len(m.groups) && idx < m.offset+m.visibleCards() {
+			if idx == m.selected {
+				return m.openProperties()
+			}
+			m.selected = idx
+		}
+	}
+	return m, nil
+}
+
+// move changes the selection by delta, clamped to the drive list
+func (m *model) move(delta int) {
+	m.selected += delta
+	if m.selected >= len(m.groups) {
+		m.selected = len(m.groups) - 1
+	}
+	if m.selected < 0 {
+		m.selected = 0
+	}
+	m.ensureVisible()
+}
+
+// ensureVisible scrolls the card list so the selection is on screen
+func (m *model) ensureVisible() {
+	visible := m.visibleCards()
+	if m.selected < m.offset {
+		m.offset = m.selected
+	}
+	if m.selected >= m.offset+visible {
+		m.offset = m.selected - visible + 1
+	}
+	if m.offset < 0 {
+		m.offset = 0
+	}
+}
+
+func (m *model) setStatus(s string) {
+	m.status = s
+	m.statusErr = false
+}
+
+func (m *model) setError(s string) {
+	m.status = s
+	m.statusErr = true
+}
+
+// showMountChanges puts the most important change since the previous scan
+// in the status line, a remount read-only as an error
+func (m *model) showMountChanges(changes []disk.MountChange) {
+	status := ui.DescribeMountChange(changes[0])
+	if len(changes) > 1 {
+		status += fmt.Sprintf(" (and %d more changes)", len(changes)-1)
+	}
+	if changes[0].Kind == disk.ChangeReadOnly {
+		m.setError("⚠️  " + status)
+	} else {
+		m.setStatus("🔄 " + status)
+	}
+}
+
+func (m model) selectedGroup() *disk.DriveGroup {
+	if m.selected < 0 || m.selected >= len(m.groups) {
+		return nil
+	}
+	return &m.groups[m.selected]
+}
@@ -0,0 +1,360 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/snapshot"
+	"checkpoint/pkg/ui"
+)
+
+// usageHeaderLines is the path line plus a blank line above the entries
+const usageHeaderLines = 2
+
+var shareStyle = lipgloss.NewStyle().
+	Foreground(lipgloss.Color("99"))
+
+// usageState is the directory usage analyzer screen
+type usageState struct {
+	root    string
+	scanner *analyzer.Scanner
+	cancel  context.CancelFunc
+	result  *analyzer.Result
+
+	dir      *analyzer.Node
+	entries  []*analyzer.Node // children of dir, or its largest files
+	sel      int
+	offset   int
+	apparent bool
+	largest  bool
+	treemap  bool
+
+	// changes since the snapshot of a week ago, see changes.go
+	changes      *snapshot.Diff
+	showChanges  bool
+	wantChanges  bool // show them as soon as they are known
+	changeOffset int
+}
+
+// Messages produced by the analyzer
+type (
+	usageTickMsg struct{}
+	usageDoneMsg struct {
+		scanner *analyzer.Scanner
+		result  *analyzer.Result
+		err     error
+	}
+)
+
+func usageTick() tea.Cmd {
+	return tea.Tick(150*time.Millisecond, func(time.Time) tea.Msg {
+		return usageTickMsg{}
+	})
+}
+
+// openUsage starts analyzing root in the background
+func (m model) openUsage(root string) (tea.Model, tea.Cmd) {
+	if m.usage != nil && m.usage.cancel != nil {
+		m.usage.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	scanner := analyzer.NewScanner(root, analyzer.Options{})
+	m.usage = &usageState{root: root, scanner: scanner, cancel: cancel}
+
+	run := func() tea.Msg {
+		result, err := scanner.Run(ctx)
+		return usageDoneMsg{scanner: scanner, result: result, err: err}
+	}
+	return m, tea.Batch(run, usageTick())
+}
+
+func (m model) updateUsageResult(msg tea.Msg) (tea.Model, tea.Cmd) {
+	u := m.usage
+	switch msg := msg.(type) {
+	case usageTickMsg:
+		// Keep redrawing the counters until the scan is done
+		if u != nil && u.result == nil {
+			return m, usageTick()
+		}
+	case usageDoneMsg:
+		if u == nil || msg.scanner != u.scanner {
+			return m, nil
+		}
+		u.cancel = nil
+		if msg.err != nil {
+			m.usage = nil
+			m.setError(fmt.Sprintf("❌ %v", msg.err))
+			return m, nil
+		}
+		u.result = msg.result
+		u.show(msg.result.Root)
+		if msg.result.Partial {
+			m.setStatus("⏹ Scan stopped, showing partial results")
+			return m, nil
+		}
+		m.setStatus(fmt.Sprintf("✅ Scanned %d files in %.1fs", msg.result.Files, msg.result.Duration.Seconds()))
+		return m, snapshotCmd(msg.scanner, msg.result)
+	}
+	return m, nil
+}
+
+func (m model) updateUsageKeys(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	u := m.usage
+	if u.result == nil {
+		switch msg.String() {
+		case "esc", "s":
+			// The scan returns what it has so far
+			u.cancel()
+		case "q":
+			u.cancel()
+			return m, tea.Quit
+		}
+		return m, nil
+	}
+
+	if u.showChanges {
+		return m.updateChangesKeys(msg)
+	}
+	if u.treemap {
+		if model, cmd, handled := m.updateTreemapKeys(msg); handled {
+			return model, cmd
+		}
+	}
+	switch msg.String() {
+	case "esc":
+		if u.largest {
+			u.largest = false
+			u.show(u.dir)
+			return m, nil
+		}
+		m.usage = nil
+	case "q":
+		return m, tea.Quit
+	case "up", "k":
+		m.moveUsage(-1)
+	case "down", "j":
+		m.moveUsage(1)
+	case "pgup":
+		m.moveUsage(-m.usageRows())
+	case "pgdown":
+		m.moveUsage(m.usageRows())
+	case "home", "g":
+		m.moveUsage(-len(u.entries))
+	case "end", "G":
+		m.moveUsage(len(u.entries))
+	case "enter", "right", "l":
+		if u.sel < len(u.entries) {
+			if n := u.entries[u.sel]; n.IsDir && !n.OtherFS && len(n.Children) > 0 {
+				u.largest = false
+				u.show(n)
+			}
+		}
+	case "left", "h", "backspace":
+		if u.largest {
+			u.largest = false
+			u.show(u.dir)
+		} else if u.dir.Parent != nil {
+			child := u.dir
+			u.show(u.dir.Parent)
+			u.selectNode(child)
+			m.ensureUsageVisible()
+		}
+	case "a":
+		u.apparent = !u.apparent
+		var current *analyzer.Node
+		if u.sel < len(u.entries) {
+			current = u.entries[u.sel]
+		}
+		u.show(u.dir)
+		u.selectNode(current)
+		m.ensureUsageVisible()
+	case "f":
+		u.largest = !u.largest
+		u.show(u.dir)
+	case "v":
+		u.treemap = !u.treemap
+		if u.treemap && u.sel > ui.TreemapMax {
+			u.sel = ui.TreemapMax
+		}
+		m.ensureUsageVisible()
+	case "w":
+		m.toggleChanges()
+	case "r":
+		return m.openUsage(u.root)
+	}
+	return m, nil
+}
+
+func (m model) updateUsageMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if m.usage.result == nil {
+		return m, nil
+	}
+	if m.usage.showChanges {
+		switch msg.Button {
+		case tea.MouseButtonWheelUp:
+			m.scrollChanges(-1)
+		case tea.MouseButtonWheelDown:
+			m.scrollChanges(1)
+		}
+		return m, nil
+	}
+	if m.usage.treemap {
+		return m.updateTreemapMouse(msg)
+	}
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		m.moveUsage(-1)
+	case tea.MouseButtonWheelDown:
+		m.moveUsage(1)
+	}
+	return m, nil
+}
+
+// show lists dir's children, or its largest files, largest first
+func (u *usageState) show(dir *analyzer.Node) {
+	u.dir = dir
+	if u.largest {
+		u.entries = analyzer.Largest(dir, analyzer.DefaultTop, u.apparent)
+	} else {
+		u.entries = dir.Sorted(u.apparent)
+	}
+	u.sel = 0
+	u.offset = 0
+}
+
+func (u *usageState) selectNode(n *analyzer.Node) {
+	for i, e := range u.entries {
+		if e == n {
+			u.sel = i
+		}
+	}
+}
+
+func (m *model) moveUsage(delta int) {
+	u := m.usage
+	u.sel += delta
+	if u.sel >= len(u.entries) {
+		u.sel = len(u.entries) - 1
+	}
+	if u.sel < 0 {
+		u.sel = 0
+	}
+	m.ensureUsageVisible()
+}
+
+func (m *model) ensureUsageVisible() {
+	u := m.usage
+	rows := m.usageRows()
+	if u.sel < u.offset {
+		u.offset = u.sel
+	}
+	if u.sel >= u.offset+rows {
+		u.offset = u.sel - rows + 1
+	}
+}
+
+func (m model) usageRows() int {
+	n := m.bodyHeight() - usageHeaderLines
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (m model) usageView() string {
+	u := m.usage
+	if u.result == nil {
+		p := u.scanner.Progress()
+		return strings.Join([]string{
+			fmt.Sprintf("🔍 Scanning %s", nameStyle.Render(u.root)),
+			"",
+			fmt.Sprintf("%d files, %d folders, %s so far", p.Files, p.Dirs, ui.FormatBytes(p.Bytes)),
+			"",
+			dimStyle.Render("Press esc to stop and look at what was found so far."),
+		}, "\n")
+	}
+
+	if u.showChanges {
+		return m.changesView()
+	}
+
+	measure := "disk usage"
+	if u.apparent {
+		measure = "apparent size"
+	}
+	title := fmt.Sprintf("%s  %s", nameStyle.Render(u.dir.Path()), ui.FormatBytes(u.dir.Size(u.apparent)))
+	if u.largest {
+		title = fmt.Sprintf("Largest files in %s", nameStyle.Render(u.dir.Path()))
+	}
+	title += dimStyle.Render(" · by " + measure)
+	if u.result.Partial {
+		title += " " + errorStyle.Render("partial")
+	}
+	lines := []string{title, ""}
+	if u.treemap {
+		return strings.Join(append(lines, m.treemapView()), "\n")
+	}
+
+	if len(u.entries) == 0 {
+		lines = append(lines, dimStyle.Render("Empty"))
+	}
+	total := u.dir.Size(u.apparent)
+	nameWidth := m.width - 30
+	end := u.offset + m.usageRows()
+	if end > len(u.entries) {
+		end = len(u.entries)
+	}
+	for i := u.offset; i < end; i++ {
+		n := u.entries[i]
+		share := percent(n.Size(u.apparent), total)
+		name := n.Name
+		if u.largest {
+			name = n.Path()
+		}
+		switch {
+		case n.OtherFS:
+			name += "/ " + dimStyle.Render("(other filesystem)")
+		case n.IsDir:
+			name = nameStyle.Render(truncateLeft(name+"/", nameWidth))
+		default:
+			name = truncateLeft(name, nameWidth)
+		}
+		if n.Err != "" {
+			name += " " + errorStyle.Render("(unreadable)")
+		}
+
+		marker := "  "
+		if i == u.sel {
+			marker = nameStyle.Render("▸ ")
+		}
+		lines = append(lines, fmt.Sprintf("%s%10s  %s %5.1f%%  %s",
+			marker, ui.FormatBytes(n.Size(u.apparent)), shareBar(share, 10), share, name))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// shareBar shows what part of the directory an entry takes
+func shareBar(share float64, width int) string {
+	filled := int(share) * width / 100
+	if filled > width {
+		filled = width
+	}
+	return shareStyle.Render(strings.Repeat("█", filled)) +
+		barEmptyStyle.Render(strings.Repeat("░", width-filled))
+}
+
+// truncateLeft keeps the end of long names, which tells entries apart
+func truncateLeft(s string, width int) string {
+	w := ansi.StringWidth(s)
+	if width < 4 || w <= width {
+		return s
+	}
+	return ansi.TruncateLeft(s, w-width+1, "…")
+}
@@ -0,0 +1,471 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/ui"
+)
+
+const (
+	headerHeight = 2 // title line plus a blank line
+	cardHeight   = 5 // three content lines plus the border
+	narrowWidth  = 80
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("86"))
+
+	dimStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("245"))
+
+	cardStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("241")).
+			Padding(0, 1)
+
+	selectedCardStyle = cardStyle.
+				BorderForeground(lipgloss.Color("86"))
+
+	nameStyle = lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("86"))
+
+	letterStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("214"))
+
+	detailsStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("99")).
+			Padding(0, 1)
+
+	labelStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("245")).
+			Width(11)
+
+	availableStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("82"))
+
+	barEmptyStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("238"))
+
+	// reclaimStyle marks the used space that could be freed
+	reclaimStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("141"))
+
+	historyStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("75"))
+
+	dialogStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("214")).
+			Padding(0, 1)
+
+	statusStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("82"))
+
+	errorStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("196")).
+			Bold(true)
+
+	helpStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("241"))
+)
+
+func (m model) narrow() bool {
+	return m.width < narrowWidth
+}
+
+// listWidth is the width of the drive card column
+func (m model) listWidth() int {
+	if m.narrow() {
+		return m.width
+	}
+	w := m.width / 2
+	if w > 46 {
+		w = 46
+	}
+	return w
+}
+
+func (m model) bodyHeight() int {
+	h := m.height - headerHeight - lipgloss.Height(m.footerView())
+	if h < cardHeight {
+		h = cardHeight
+	}
+	return h
+}
+
+func (m model) visibleCards() int {
+	n := m.bodyHeight() / cardHeight
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (m model) View() string {
+	var body string
+	switch {
+	case m.usage != nil:
+		body = m.usageView()
+	case m.properties && m.selectedGroup() != nil:
+		body = m.propertiesView()
+	case m.technical && len(m.disks) > 0:
+		body = m.tableView()
+	case len(m.groups) == 0 && m.scanning:
+		body = dimStyle.Render("🔄 Scanning disks...")
+	case len(m.groups) == 0:
+		body = dimStyle.Render("No drives found. Press a to add a path or m to mount a disk.")
+	case m.narrow():
+		body = m.listView()
+	default:
+		body = lipgloss.JoinHorizontal(lipgloss.Top,
+			m.listView(), " ", m.detailsView(m.width-m.listWidth()-1))
+	}
+	body = lipgloss.NewStyle().Height(m.bodyHeight()).MaxHeight(m.bodyHeight()).Render(body)
+
+	return lipgloss.JoinVertical(lipgloss.Left, m.headerView(), body, m.footerView())
+}
+
+func (m model) headerView() string {
+	title := titleStyle.Render("💾 My Computer")
+	if m.technical {
+		title = titleStyle.Render("💾 Storage Disks Overview")
+	}
+	if m.usage != nil {
+		title = titleStyle.Render("📂 Disk Usage")
+	} else if group := m.selectedGroup(); m.properties && group != nil {
+		title = titleStyle.Render(fmt.Sprintf("%s %s (%s) Properties", group.Icon, group.Name, disk.DriveLetter(m.selected)))
+	}
+	info := ""
+	if m.scanning {
+		info = "🔄 scanning"
+	} else if !m.lastScan.IsZero() {
+		info = "updated " + m.lastScan.Format("15:04:05")
+	}
+	info = dimStyle.Render(info)
+
+	gap := m.width - lipgloss.Width(title) - lipgloss.Width(info)
+	if gap < 1 {
+		gap = 1
+	}
+	return title + strings.Repeat(" ", gap) + info + "\n"
+}
+
+func (m model) listView() string {
+	width := m.listWidth()
+	cards := []string{}
+	end := m.offset + m.visibleCards()
+	if end > len(m.groups) {
+		end = len(m.groups)
+	}
+	for i := m.offset; i < end; i++ {
+		cards = append(cards, m.cardView(i, width))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, cards...)
+}
+
+func (m model) cardView(i, width int) string {
+	group := m.groups[i]
+	style := cardStyle
+	if i == m.selected {
+		style = selectedCardStyle
+	}
+	inner := width - style.GetHorizontalFrameSize()
+
+	name := fmt.Sprintf("%s %s", group.Icon, nameStyle.Render(group.Name))
+	letter := letterStyle.Render(disk.DriveLetter(i))
+	gap := inner - lipgloss.Width(name) - lipgloss.Width(letter)
+	if gap < 1 {
+		gap = 1
+	}
+	line1 := name + strings.Repeat(" ", gap) + letter
+
+	usedPercent := percent(group.TotalUsed, group.TotalSize)
+	line2 := progressBar(usedPercent, m.reclaimPercent(group), alertLevel(group), inner-7) + fmt.Sprintf(" %5.1f%%", usedPercent)
+	line3 := fmt.Sprintf("%s free of %s",
+		availableStyle.Render(ui.FormatBytes(group.Available)), ui.FormatBytes(group.TotalSize))
+	if trash := m.trashBytes(group); trash > 0 {
+		line3 += dimStyle.Render(" · 🗑️ " + ui.FormatBytes(trash))
+	}
+
+	content := lipgloss.NewStyle().MaxWidth(inner).Render(strings.Join([]string{line1, line2, line3}, "\n"))
+	return style.Width(width - 2).Render(content)
+}
+
+func (m model) detailsView(width int) string {
+	group := m.selectedGroup()
+	if group == nil {
+		return ""
+	}
+	inner := width - detailsStyle.GetHorizontalFrameSize()
+
+	lines := []string{
+		fmt.Sprintf("%s %s  %s", group.Icon, nameStyle.Render(group.Name), letterStyle.Render(disk.DriveLetter(m.selected))),
+		dimStyle.Render(group.Description),
+		"",
+		labelStyle.Render("Capacity") + ui.FormatBytes(group.TotalSize),
+		labelStyle.Render("Used") + fmt.Sprintf("%s (%.1f%%)", ui.FormatBytes(group.TotalUsed), percent(group.TotalUsed, group.TotalSize)),
+		labelStyle.Render("Free") + availableStyle.Render(ui.FormatBytes(group.Available)),
+		"",
+		progressBar(percent(group.TotalUsed, group.TotalSize), m.reclaimPercent(*group), alertLevel(*group), inner),
+	}
+	// Say which thresholds turned the bar orange or red
+	if group.Alert != nil {
+		reasonStyle := lipgloss.NewStyle().Foreground(levelColor(group.Alert.Level))
+		for _, reason := range group.Alert.Reasons {
+			lines = append(lines, reasonStyle.Render("⚠️  "+reason))
+		}
+	}
+	lines = append(lines, "", nameStyle.Render("Locations"))
+	for _, d := range group.Disks {
+		lines = append(lines,
+			fmt.Sprintf("📁 %s", d.MountPoint),
+			dimStyle.Render(fmt.Sprintf("   %s · %s · %s · %s", d.Device, d.Filesystem, d.Type, ui.FormatBytes(d.Size))))
+	}
+	if group.IsPrimary {
+		lines = append(lines, "", availableStyle.Render("⭐ Primary Drive"))
+	}
+	lines = append(lines, m.reclaimLines(group, inner, m.bodyHeight()-2-len(lines))...)
+	lines = append(lines, m.historyLines(group, inner, m.bodyHeight()-2-len(lines))...)
+
+	content := lipgloss.NewStyle().MaxWidth(inner).Render(strings.Join(lines, "\n"))
+	return detailsStyle.Width(width - 2).Height(m.bodyHeight() - 2).Render(content)
+}
+
+// reclaimLines list what could be freed on the drive, explaining each item
+// when there is room
+func (m model) reclaimLines(group *disk.DriveGroup, width, room int) []string {
+	if m.reclaim == nil {
+		if m.reclaiming && room >= 2 {
+			return []string{"", dimStyle.Render("🧹 Looking for space to free…")}
+		}
+		return nil
+	}
+	items, total := m.reclaim.ForMounts(groupMounts(*group)...)
+	if len(items) == 0 || room < 3 {
+		return nil
+	}
+	lines := []string{"", nameStyle.Render("Reclaimable") + dimStyle.Render(" · ") + reclaimStyle.Render(ui.FormatBytes(total)) + dimStyle.Render(" could be freed")}
+	var explained []string
+	explain := dimStyle.Width(width).PaddingLeft(3)
+	for _, item := range items {
+		line := fmt.Sprintf("🧹 %s %s", item.Name, reclaimStyle.Render(ui.FormatBytes(item.Bytes)))
+		if item.NeedsRoot {
+			line += " 🔒"
+		}
+		lines = append(lines, line)
+		explained = append(explained, line)
+		explained = append(explained, strings.Split(explain.Render(item.Explanation), "\n")...)
+	}
+	// Explanations are for newcomers, but not worth hiding the history chart
+	fit := room
+	if len(group.Disks) > 0 && len(m.history[group.Disks[0].MountPoint]) > 1 {
+		fit = room / 2
+	}
+	if 2+len(explained) <= fit {
+		return append(lines[:2], explained...)
+	}
+	if len(lines) > room {
+		lines = lines[:room]
+	}
+	return lines
+}
+
+// reclaimPercent is the share of a drive that could be freed, 0 until the
+// analysis finished
+func (m model) reclaimPercent(group disk.DriveGroup) float64 {
+	if m.reclaim == nil {
+		return 0
+	}
+	_, total := m.reclaim.ForMounts(groupMounts(group)...)
+	return percent(total, group.TotalSize)
+}
+
+// trashBytes is the size of the user's trash on a drive, 0 until the
+// reclaim analysis finished
+func (m model) trashBytes(group disk.DriveGroup) uint64 {
+	if m.reclaim == nil {
+		return 0
+	}
+	items, _ := m.reclaim.ForMounts(groupMounts(group)...)
+	var total uint64
+	for _, item := range items {
+		if item.Kind == cleanup.KindTrash {
+			total += item.Bytes
+		}
+	}
+	return total
+}
+
+// groupMounts are the mount points of a drive group
+func groupMounts(group disk.DriveGroup) []string {
+	mounts := make([]string, 0, len(group.Disks))
+	for _, d := range group.Disks {
+		mounts = append(mounts, d.MountPoint)
+	}
+	return mounts
+}
+
+// historyLines charts how the drive filled up over time in the space left
+// below the details, or shows a sparkline when there is little room
+func (m model) historyLines(group *disk.DriveGroup, width, room int) []string {
+	if len(group.Disks) == 0 {
+		return nil
+	}
+	samples := m.history[group.Disks[0].MountPoint]
+	if len(samples) < 2 || room < 3 {
+		return nil
+	}
+	lines := []string{"", nameStyle.Render("History") + dimStyle.Render(" · "+ui.DescribeGrowth(samples, history.Month))}
+	if f := group.Forecast; f != nil {
+		style := dimStyle
+		if f.Trend == disk.TrendGrowing && f.DaysToFull < 30 {
+			style = errorStyle
+		}
+		lines = append(lines, style.Render(fmt.Sprintf("⏳ %s (%s confidence)", ui.DescribeForecast(f), f.Confidence)))
+	}
+	if chart := ui.HistoryChart(samples, width, room-len(lines)); chart != "" {
+		return append(lines, historyStyle.Render(chart))
+	}
+	recent := history.Since(samples, time.Now().Add(-history.Month))
+	return append(lines, historyStyle.Render(ui.Sparkline(recent, width)))
+}
+
+func (m model) footerView() string {
+	if d := m.dialogView(); d != "" {
+		return d
+	}
+
+	status := ""
+	if m.status != "" {
+		if m.statusErr {
+			status = errorStyle.Render(m.status)
+		} else {
+			status = statusStyle.Render(m.status)
+		}
+	}
+	help := "↑↓ select · enter properties · u usage · t table · a add path · i install · m mount · r rescan · q quit"
+	switch {
+	case m.usage != nil && m.usage.result == nil:
+		help = "esc stop and show partial results · q quit"
+	case m.usage != nil && m.usage.showChanges:
+		help = "↑↓ scroll · w folders · r rescan · esc back · q quit"
+	case m.usage != nil && m.usage.treemap:
+		help = "←↑↓→ select · enter zoom in · backspace zoom out · v list · a apparent size · f largest files · w what changed · q quit"
+	case m.usage != nil:
+		help = "↑↓ select · enter open · ← up · a apparent size · f largest files · v treemap · w what changed · esc back · q quit"
+	case m.properties && m.tab == tabTrash:
+		help = "←→ tabs · ↑↓ select · enter restore · d delete for good · esc back · q quit"
+	case m.properties:
+		help = "←→ tabs · ↑↓ select tool · enter run · esc back · q quit"
+	case m.technical:
+		help = "↑↓ scroll · s/S sort · / search · f preset · p save · c clear · d details · t cards · q quit"
+	}
+	return status + "\n" + helpStyle.Render(ansi.Truncate(help, m.width, "…"))
+}
+
+func (m model) dialogView() string {
+	width := m.width - 2
+	if width > 70 {
+		width = 70
+	}
+
+	var content string
+	switch m.dialog {
+	case dialogAddPath:
+		content = nameStyle.Render("📁 Add disk path") + "\n" + m.input.View() + "\n" +
+			helpStyle.Render("enter add · esc cancel")
+	case dialogInstall:
+		target := ""
+		if group := m.selectedGroup(); group != nil {
+			target = fmt.Sprintf(" on %s %s", group.Icon, group.Name)
+		}
+		content = nameStyle.Render("💻 Installation command"+target) + "\n" + m.input.View() + "\n" +
+			helpStyle.Render("enter run · esc cancel")
+	case dialogSearch:
+		content = nameStyle.Render("🔎 Filter mount points") + "\n" + m.input.View() + "\n" +
+			helpStyle.Render("enter apply · empty clears · esc cancel")
+	case dialogSavePreset:
+		content = nameStyle.Render("💾 Save filter preset") + "\n" +
+			dimStyle.Render(m.filter.Describe()) + "\n" + m.input.View() + "\n" +
+			helpStyle.Render("enter save · esc cancel")
+	case dialogConfirm:
+		content = nameStyle.Render("⚠️  "+m.confirm) + "\n" + helpStyle.Render("y confirm · n cancel")
+	case dialogMount:
+		content = nameStyle.Render("💿 Mount a disk") + "\n"
+		switch {
+		case m.loading:
+			content += dimStyle.Render("Working...")
+		case len(m.unmounted) == 0:
+			content += dimStyle.Render("No unmounted disks found")
+		default:
+			for i, ud := range m.unmounted {
+				line := fmt.Sprintf("%s (%s, %s)", ud.Device, ui.FormatBytes(ud.Size), ud.Filesystem)
+				if ud.Label != "" {
+					line += " " + ud.Label
+				}
+				if i == m.mountSel {
+					line = nameStyle.Render("▸ " + line)
+				} else {
+					line = "  " + line
+				}
+				content += line + "\n"
+			}
+		}
+		content += "\n" + helpStyle.Render("↑↓ select · enter mount · esc cancel")
+	default:
+		return ""
+	}
+	return dialogStyle.Width(width).Render(content)
+}
+
+// progressBar colours the filled part by the drive's threshold level, and
+// the part of it that could be freed apart
+func progressBar(usedPercent, reclaimPercent float64, level disk.Level, width int) string {
+	if width < 1 {
+		return ""
+	}
+	filled, reclaim := ui.BarCells(usedPercent, reclaimPercent, width)
+
+	return lipgloss.NewStyle().Foreground(levelColor(level)).Render(strings.Repeat("█", filled-reclaim)) +
+		reclaimStyle.Render(strings.Repeat("▓", reclaim)) +
+		barEmptyStyle.Render(strings.Repeat("░", width-filled))
+}
+
+// alertLevel is the threshold level of a group, OK when it was not checked
+func alertLevel(group disk.DriveGroup) disk.Level {
+	if group.Alert == nil {
+		return disk.LevelOK
+	}
+	return group.Alert.Level
+}
+
+// levelColor is green, orange past a warning threshold and red past a
+// critical one, the same thresholds "checkpoint check" uses
+func levelColor(level disk.Level) lipgloss.Color {
+	switch level {
+	case disk.LevelCritical:
+		return lipgloss.Color("196")
+	case disk.LevelWarning:
+		return lipgloss.Color("214")
+	}
+	return lipgloss.Color("86")
+}
+
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"checkpoint/pkg/disk"
+)
+
+// DescribeMountChange puts a change between two scans into words, such as
+// "/srv was remounted read-only"
+func DescribeMountChange(c disk.MountChange) string {
+	switch c.Kind {
+	case disk.ChangeReadOnly:
+		return fmt.Sprintf("%s was remounted read-only, which usually follows filesystem errors (see dmesg)", c.MountPoint)
+	case disk.ChangeReadWrite:
+		return fmt.Sprintf("%s is writable again", c.MountPoint)
+	case disk.ChangeMounted:
+		return fmt.Sprintf("%s mounted at %s (%s, %s)", c.Device, c.MountPoint, c.Filesystem, FormatBytes(c.SizeAfter))
+	case disk.ChangeUnmounted:
+		return fmt.Sprintf("%s unmounted from %s", c.Device, c.MountPoint)
+	case disk.ChangeOptions:
+		var options []string
+		for _, o := range c.OptionsAdded {
+			options = append(options, "+"+o)
+		}
+		for _, o := range c.OptionsRemoved {
+			options = append(options, "-"+o)
+		}
+		return fmt.Sprintf("%s mount options changed: %s", c.MountPoint, strings.Join(options, " "))
+	case disk.ChangeSize:
+		if c.SizeBefore != c.SizeAfter {
+			return fmt.Sprintf("%s was resized from %s to %s", c.MountPoint, FormatBytes(c.SizeBefore), FormatBytes(c.SizeAfter))
+		}
+		delta := int64(c.UsedAfter) - int64(c.UsedBefore)
+		verb := "grew"
+		if delta < 0 {
+			verb = "shrank"
+		}
+		return fmt.Sprintf("%s used space %s by %s to %s", c.MountPoint, verb, FormatBytes(uint64(max(delta, -delta))), FormatBytes(c.UsedAfter))
+	}
+	return fmt.Sprintf("%s changed", c.MountPoint)
+}
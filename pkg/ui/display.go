@@ -2,97 +2,96 @@ package ui
 
 import (
 	"fmt"
-	"strings"
+	"io"
 
 	"github.com/charmbracelet/lipgloss"
 	"checkpoint/pkg/disk"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
 )
 
-var (
-	// Define styles
-	titleStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("86")).
-			MarginTop(1).
-			MarginBottom(1)
-
-	headerStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("99")).
-			BorderStyle(lipgloss.NormalBorder()).
-			BorderBottom(true).
-			BorderForeground(lipgloss.Color("241"))
-
-	rowStyle = lipgloss.NewStyle().
-			PaddingRight(2)
-
-	evenRowStyle = rowStyle.Copy().
-			Background(lipgloss.Color("235"))
-
-	diskTypeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("214"))
-
-	symlinkStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("81")).
-			Italic(true)
-
-	hardlinkStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("213"))
-
-	sizeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("86"))
-
-	availableStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("82"))
-
-	usedStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("203"))
-
-	inodeStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("241"))
-
-	totalStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("226")).
-			BorderStyle(lipgloss.NormalBorder()).
-			BorderTop(true).
-			BorderForeground(lipgloss.Color("241")).
-			MarginTop(1).
-			PaddingTop(1)
-
-	legendStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("245")).
-			Italic(true).
-			MarginTop(1)
-
-	menuStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("255")).
-			MarginTop(1)
+// terminalRenderer draws the coloured lipgloss views
+type terminalRenderer struct {
+	p           printer
+	width       int
+	lr          *lipgloss.Renderer
+	st          styles
+	history     map[string][]history.Sample
+	reclaimable map[string]uint64
+	trash       map[string]uint64
+	fileTypes   map[string]filetypes.Drive
+}
 
-	menuItemStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("86"))
+// NewTerminalRenderer creates a renderer for a terminal of the given width and
+// colour profile. Nothing is read from the environment.
+func NewTerminalRenderer(w io.Writer, opts Options) Renderer {
+	lr := lipgloss.NewRenderer(w)
+	lr.SetColorProfile(opts.Profile)
+
+	t := &terminalRenderer{
+		p:           printer{w: w},
+		width:       opts.Width,
+		lr:          lr,
+		st:          newStyles(lr),
+		history:     opts.History,
+		reclaimable: opts.Reclaimable,
+		trash:       opts.Trash,
+		fileTypes:   opts.FileTypes,
+	}
+	if t.width <= 0 {
+		t.width = DefaultWidth
+	}
+	return t
+}
 
-	promptStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("214")).
-			Bold(true)
+// Columns of the technical tables. Device and mount point grow with the
+// terminal, optional columns disappear on narrow terminals.
+var (
+	simpleColumns = []column{
+		{title: "ID", width: 4},
+		{title: "Device", width: 14, flex: 2, max: 32},
+		{title: "Type", width: 10, optional: true},
+		{title: "Size", width: 10},
+		{title: "Available", width: 10},
+		{title: "Mount", width: 14, flex: 3},
+	}
+
+	detailedColumns = []column{
+		{title: "ID", width: 4},
+		{title: "Device", width: 16, flex: 3, max: 42},
+		{title: "Type", width: 15, optional: true},
+		{title: "FS", width: 8, optional: true},
+		{title: "Size", width: 10},
+		{title: "Used", width: 10, optional: true},
+		{title: "Available", width: 10},
+		{title: "Inode", width: 10, optional: true},
+		{title: "Mount", width: 14, flex: 3},
+	}
 )
 
+// DisplayDisks prints the technical disk table to stdout
 func DisplayDisks(disks []disk.Disk, showDetails bool) {
-	title := titleStyle.Render("💾 Storage Disks Overview")
-	fmt.Println(title)
+	stdoutRenderer().Disks(disks, showDetails)
+}
+
+func (t *terminalRenderer) Disks(disks []disk.Disk, showDetails bool) error {
+	t.p.err = nil
+	title := t.st.title.Render("💾 Storage Disks Overview")
+	t.p.println(title)
 
 	if showDetails {
-		displayDetailedView(disks)
+		t.displayDetailedView(disks)
 	} else {
-		displaySimpleView(disks)
+		t.displaySimpleView(disks)
 	}
+	return t.p.err
 }
 
-func displaySimpleView(disks []disk.Disk) {
+func (t *terminalRenderer) displaySimpleView(disks []disk.Disk) {
 	// Simple view - no inode column, condensed display
-	headers := []string{"ID", "Device", "Type", "Size", "Available", "Mount"}
-	headerRow := makeSimpleRow(headers, headerStyle)
-	fmt.Println(headerRow)
+	widths := layoutColumns(simpleColumns, t.width)
+	headerRow := t.makeRowWithWidths(columnTitles(simpleColumns), t.st.header, widths)
+	t.p.println(headerRow)
 
 	// Display only physical and important disks
 	displayCount := 0
@@ -101,97 +100,86 @@ func displaySimpleView(disks []disk.Disk) {
 		if d.Type == disk.TypeSymlink {
 			continue
 		}
-		
+
 		displayCount++
-		rowData := formatSimpleDiskRow(displayCount, d)
-		style := rowStyle
+		rowData := t.formatSimpleDiskRow(displayCount, d, widths)
+		style := t.st.row
 		if displayCount%2 == 0 {
-			style = evenRowStyle
+			style = t.st.evenRow
 		}
-		fmt.Println(makeSimpleStyledRow(rowData, style))
+		t.p.println(t.makeRowWithWidths(rowData, style, widths))
 	}
 }
 
-func displayDetailedView(disks []disk.Disk) {
+func (t *terminalRenderer) displayDetailedView(disks []disk.Disk) {
 	// Detailed view with all information
-	headers := []string{"ID", "Device", "Type", "FS", "Size", "Used", "Available", "Inode", "Mount"}
-	headerRow := makeRow(headers, headerStyle)
-	fmt.Println(headerRow)
+	widths := layoutColumns(detailedColumns, t.width)
+	headerRow := t.makeRowWithWidths(columnTitles(detailedColumns), t.st.header, widths)
+	t.p.println(headerRow)
+
+	hardlinks := hardlinkIndex(disks)
 
-	hardlinks := make(map[uint64][]int)
 	for i, d := range disks {
-		if d.Inode > 0 && d.Type != disk.TypeSymlink {
-			hardlinks[d.Inode] = append(hardlinks[d.Inode], i)
+		rowData := t.formatDiskRow(i+1, d, hardlinks, widths)
+		style := t.st.row
+		if i%2 == 0 {
+			style = t.st.evenRow
 		}
+		t.p.println(t.makeRowWithWidths(rowData, style, widths))
 	}
+}
 
+// hardlinkIndex maps inodes to the positions of the disks sharing them
+func hardlinkIndex(disks []disk.Disk) map[uint64][]int {
+	hardlinks := make(map[uint64][]int)
 	for i, d := range disks {
-		rowData := formatDiskRow(i+1, d, hardlinks)
-		style := rowStyle
-		if i%2 == 0 {
-			style = evenRowStyle
+		if d.Inode > 0 && d.Type != disk.TypeSymlink {
+			hardlinks[d.Inode] = append(hardlinks[d.Inode], i)
 		}
-		fmt.Println(makeStyledRow(rowData, style))
 	}
+	return hardlinks
 }
 
-func formatSimpleDiskRow(id int, d disk.Disk) []string {
-	devicePath := truncatePath(d.Path, 30)
+func (t *terminalRenderer) formatSimpleDiskRow(id int, d disk.Disk, widths []int) []string {
+	devicePath := truncatePath(d.Path, widths[1])
 	typeStr := string(d.Type)
-	
+
 	return []string{
 		fmt.Sprintf("%d", id),
 		devicePath,
-		diskTypeStyle.Render(typeStr),
-		sizeStyle.Render(FormatBytes(d.Size)),
-		availableStyle.Render(FormatBytes(d.Available)),
-		truncatePath(d.MountPoint, 40),
+		t.st.diskType.Render(typeStr),
+		t.st.size.Render(FormatBytes(d.Size)),
+		t.st.available.Render(FormatBytes(d.Available)),
+		truncatePath(d.MountPoint, widths[5]),
 	}
 }
 
-func makeSimpleRow(cols []string, style lipgloss.Style) string {
-	widths := []int{4, 32, 12, 12, 12, 42}
-	return makeRowWithWidths(cols, style, widths)
-}
-
-func makeSimpleStyledRow(cols []string, baseStyle lipgloss.Style) string {
-	widths := []int{4, 32, 12, 12, 12, 42}
-	return makeStyledRowWithWidths(cols, baseStyle, widths)
-}
-
-func makeRowWithWidths(cols []string, style lipgloss.Style, widths []int) string {
-	styledCols := make([]string, len(cols))
+// makeRowWithWidths pads every cell to its column width, skipping columns
+// with no width
+func (t *terminalRenderer) makeRowWithWidths(cols []string, style lipgloss.Style, widths []int) string {
+	cols, widths = visibleCells(cols, widths)
+	styledCols := make([]string, 0, 2*len(cols))
 	for i, col := range cols {
-		if i < len(widths) {
-			styledCols[i] = style.Render(lipgloss.NewStyle().Width(widths[i]).Render(col))
-		} else {
-			styledCols[i] = style.Render(col)
+		if i > 0 {
+			styledCols = append(styledCols, " ")
 		}
+		// Note: col might already be styled, so we just fit and pad it
+		padded := t.st.plain.Width(widths[i]).Render(fitCell(col, widths[i]))
+		styledCols = append(styledCols, style.Render(padded))
 	}
-	return strings.Join(styledCols, " ")
+	// Join side by side, since header cells span two lines with their border
+	return lipgloss.JoinHorizontal(lipgloss.Top, styledCols...)
 }
 
-func makeStyledRowWithWidths(cols []string, baseStyle lipgloss.Style, widths []int) string {
-	styledCols := make([]string, len(cols))
-	for i, col := range cols {
-		if i < len(widths) {
-			padded := lipgloss.NewStyle().Width(widths[i]).Render(col)
-			styledCols[i] = baseStyle.Render(padded)
-		} else {
-			styledCols[i] = baseStyle.Render(col)
-		}
-	}
-	return strings.Join(styledCols, " ")
-}
-
-func formatDiskRow(id int, d disk.Disk, hardlinks map[uint64][]int) []string {
+func (t *terminalRenderer) formatDiskRow(id int, d disk.Disk, hardlinks map[uint64][]int, widths []int) []string {
 	// Format device path
 	devicePath := d.Path
 	if d.IsSymlink && d.LinkTarget != "" {
-		devicePath = fmt.Sprintf("%s → %s", truncatePath(d.Path, 20), truncatePath(d.LinkTarget, 20))
-		devicePath = symlinkStyle.Render(devicePath)
+		half := (widths[1] - 3) / 2
+		devicePath = fmt.Sprintf("%s → %s", truncatePath(d.Path, half), truncatePath(d.LinkTarget, half))
+		devicePath = t.st.symlink.Render(devicePath)
 	} else {
-		devicePath = truncatePath(devicePath, 40)
+		devicePath = truncatePath(devicePath, widths[1])
 	}
 
 	// Format type with icons
@@ -201,9 +189,9 @@ func formatDiskRow(id int, d disk.Disk, hardlinks map[uint64][]int) []string {
 	}
 	if links, ok := hardlinks[d.Inode]; ok && len(links) > 1 && d.Type != disk.TypeSymlink {
 		typeStr = "🔗 " + typeStr
-		typeStr = hardlinkStyle.Render(typeStr)
+		typeStr = t.st.hardlink.Render(typeStr)
 	} else {
-		typeStr = diskTypeStyle.Render(typeStr)
+		typeStr = t.st.diskType.Render(typeStr)
 	}
 
 	// Format inode
@@ -211,22 +199,26 @@ func formatDiskRow(id int, d disk.Disk, hardlinks map[uint64][]int) []string {
 	if d.Inode > 0 {
 		inodeStr = fmt.Sprintf("%d", d.Inode)
 	}
-	inodeStr = inodeStyle.Render(inodeStr)
+	inodeStr = t.st.inode.Render(inodeStr)
 
 	return []string{
 		fmt.Sprintf("%d", id),
 		devicePath,
 		typeStr,
 		d.Filesystem,
-		sizeStyle.Render(FormatBytes(d.Size)),
-		usedStyle.Render(FormatBytes(d.Used)),
-		availableStyle.Render(FormatBytes(d.Available)),
+		t.st.size.Render(FormatBytes(d.Size)),
+		t.st.used.Render(FormatBytes(d.Used)),
+		t.st.available.Render(FormatBytes(d.Available)),
 		inodeStr,
-		truncatePath(d.MountPoint, 30),
+		truncatePath(d.MountPoint, widths[8]),
 	}
 }
 
 func formatDiskType(t disk.DiskType) string {
+	return fmt.Sprintf("%s %s", diskTypeIcon(t), t)
+}
+
+func diskTypeIcon(t disk.DiskType) string {
 	icons := map[disk.DiskType]string{
 		disk.TypePhysical: "💽",
 		disk.TypeLVM:      "🗄️",
@@ -243,59 +235,18 @@ func formatDiskType(t disk.DiskType) string {
 	if !ok {
 		icon = "❓"
 	}
-	return fmt.Sprintf("%s %s", icon, t)
-}
-
-func truncatePath(path string, maxLen int) string {
-	if len(path) <= maxLen {
-		return path
-	}
-	if maxLen < 10 {
-		return path[:maxLen]
-	}
-	return "..." + path[len(path)-(maxLen-3):]
-}
-
-
-func makeRow(cols []string, style lipgloss.Style) string {
-	widths := []int{4, 42, 15, 10, 12, 12, 12, 10, 32}
-	styledCols := make([]string, len(cols))
-	
-	for i, col := range cols {
-		if i < len(widths) {
-			styledCols[i] = style.Render(lipgloss.NewStyle().Width(widths[i]).Render(col))
-		} else {
-			styledCols[i] = style.Render(col)
-		}
-	}
-	
-	return strings.Join(styledCols, " ")
-}
-
-func makeStyledRow(cols []string, baseStyle lipgloss.Style) string {
-	widths := []int{4, 42, 15, 10, 12, 12, 12, 10, 32}
-	styledCols := make([]string, len(cols))
-	
-	for i, col := range cols {
-		if i < len(widths) {
-			// Note: col might already be styled, so we just pad it
-			padded := lipgloss.NewStyle().Width(widths[i]).Render(col)
-			styledCols[i] = baseStyle.Render(padded)
-		} else {
-			styledCols[i] = baseStyle.Render(col)
-		}
-	}
-	
-	return strings.Join(styledCols, " ")
+	return icon
 }
 
+// DisplayMenu prints the basic numbered menu to stdout
 func DisplayMenu() {
-	menu := menuStyle.Render("Options:") + "\n" +
-		menuItemStyle.Render("1.") + " Add a disk path manually\n" +
-		menuItemStyle.Render("2.") + " Execute installation command\n" +
-		menuItemStyle.Render("3.") + " Rescan disks\n" +
-		menuItemStyle.Render("4.") + " Exit"
-	
+	st := newStyles(lipgloss.DefaultRenderer())
+	menu := st.menu.Render("Options:") + "\n" +
+		st.menuItem.Render("1.") + " Add a disk path manually\n" +
+		st.menuItem.Render("2.") + " Execute installation command\n" +
+		st.menuItem.Render("3.") + " Rescan disks\n" +
+		st.menuItem.Render("4.") + " Exit"
+
 	fmt.Println(menu)
-	fmt.Print(promptStyle.Render("Select option: "))
-}
\ No newline at end of file
+	fmt.Print(st.prompt.Render("Select option: "))
+}
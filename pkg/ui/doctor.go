@@ -0,0 +1,54 @@
+package ui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/doctor"
+)
+
+func (t *terminalRenderer) Doctor(report doctor.Report) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("🩺 Doctor"))
+	explain := t.st.plain.Width(t.width).PaddingLeft(3)
+	fix := t.st.driveDesc.Width(t.width).PaddingLeft(3)
+	for _, res := range report.Results {
+		icon := "✅"
+		if res.Error != "" {
+			icon = "❔"
+		}
+		level := disk.LevelOK
+		for _, f := range res.Findings {
+			level = max(level, f.Level)
+		}
+		switch level {
+		case disk.LevelWarning:
+			icon = "⚠️ "
+		case disk.LevelCritical:
+			icon = "❌"
+		}
+		t.p.printf("%s %s  %s\n", icon, t.st.driveName.Render(res.Title), t.st.driveDesc.Render(doctorStatus(res)))
+		for _, f := range res.Findings {
+			t.p.println(explain.Render(t.levelStyle(f.Level).Render("•") + " " + f.Problem))
+			t.p.println(fix.Render("💡 " + f.Fix))
+		}
+	}
+	t.p.println()
+	style := t.st.available
+	if report.Problems > 0 {
+		style = t.levelStyle(report.Level)
+	}
+	t.p.println(style.Render(doctorSummary(report)))
+	return t.p.err
+}
+
+// levelStyle colours text by how serious a problem is
+func (t *terminalRenderer) levelStyle(level disk.Level) lipgloss.Style {
+	switch level {
+	case disk.LevelWarning:
+		return t.st.progressBarWarning.UnsetBackground()
+	case disk.LevelCritical:
+		return t.st.used
+	}
+	return t.st.available
+}
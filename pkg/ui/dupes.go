@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"fmt"
+
+	"checkpoint/pkg/dupes"
+)
+
+func (t *terminalRenderer) Duplicates(r dupes.Report) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("👯 Duplicate files"))
+	summary := t.st.driveDesc.Render(dupesSummary(r))
+	if r.Partial {
+		summary = t.st.used.Render(dupesSummary(r))
+	}
+	t.p.println(summary)
+	for _, g := range r.Groups {
+		t.p.println()
+		t.p.printf("%s %s\n", t.st.driveName.Render(dupesHeading(g)), t.st.driveDesc.Render(g.SHA256[:12]))
+		for _, f := range g.Files {
+			t.p.println("  " + truncatePath(dupesPath(f), t.width-2))
+		}
+	}
+	if more := dupesMore(r); more != "" {
+		t.p.println()
+		t.p.println(t.st.driveDesc.Render(more))
+	}
+	return t.p.err
+}
+
+func (t *terminalRenderer) Dedupe(results []dupes.Result) error {
+	t.p.err = nil
+	title := "👯 Duplicates"
+	if len(results) > 0 && results[0].DryRun {
+		title += " preview (nothing was changed)"
+	}
+	t.p.println(t.st.title.Render(title))
+	for _, r := range results {
+		icon, style := "✅", t.st.available
+		switch {
+		case r.Error != "":
+			icon, style = "❌", t.st.used
+		case r.Skipped != "":
+			icon, style = "⏭️ ", t.st.driveDesc
+		case r.DryRun:
+			icon, style = "🔍", t.st.plain
+		}
+		t.p.printf("%s %s\n", icon, style.Render(truncatePath(r.Path, t.width-3)))
+		detail := dedupeStatus(r)
+		if r.Kept != "" && r.Error == "" && r.Skipped == "" {
+			detail = fmt.Sprintf("%s, same as %s", detail, r.Kept)
+		}
+		t.p.println(t.st.driveDesc.PaddingLeft(3).Render(truncatePath(detail, t.width-3)))
+	}
+	t.p.println()
+	t.p.println(t.st.available.Render(dedupeSummary(results)))
+	return t.p.err
+}
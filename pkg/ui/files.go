@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"fmt"
+
+	"checkpoint/pkg/search"
+)
+
+func (t *terminalRenderer) Files(r search.Report) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("🔎 Large and stale files"))
+	summary := t.st.driveDesc.Render(filesSummary(r))
+	if r.Partial {
+		summary = t.st.used.Render(filesSummary(r))
+	}
+	t.p.println(summary)
+	if criteria := filesCriteria(r); criteria != "" {
+		t.p.println(t.st.driveDesc.Render(criteria))
+	}
+	if len(r.Files) == 0 {
+		return t.p.err
+	}
+
+	t.p.println()
+	_, rows := filesTable(r)
+	for _, row := range rows {
+		// #, size, last used, owner and why take fixed columns; the path
+		// gets the rest
+		fixed := fmt.Sprintf("%3s  %9s  %-14s %-10s %-12s ", row[0], row[1], row[2], truncatePath(row[3], 10), row[4])
+		t.p.printf("%3s  %s  %s %s %s %s\n",
+			row[0],
+			t.st.size.Render(fmt.Sprintf("%9s", row[1])),
+			t.st.driveDesc.Render(fmt.Sprintf("%-14s", row[2])),
+			fmt.Sprintf("%-10s", truncatePath(row[3], 10)),
+			t.st.driveDesc.Render(fmt.Sprintf("%-12s", row[4])),
+			truncatePath(row[5], t.width-len(fixed)))
+	}
+	if more := r.FileCount - len(r.Files); more > 0 {
+		t.p.println(t.st.driveDesc.Render(fmt.Sprintf("… and %d more files, see --top", more)))
+	}
+
+	for _, section := range []struct {
+		title  string
+		groups []search.Group
+	}{
+		{"📁 By folder", r.ByDir},
+		{"🏷️  By type", r.ByType},
+	} {
+		t.p.println(t.st.summaryTitle.MarginTop(1).Render(section.title))
+		for _, g := range section.groups {
+			size := fmt.Sprintf("%9s", FormatBytes(g.Bytes))
+			count := fmt.Sprintf("%10s  ", fileCount(g.Files))
+			t.p.printf("%s%s%s\n", t.st.size.Render(size), t.st.driveDesc.Render(count),
+				truncatePath(fileGroupName(g), t.width-len(size)-len(count)))
+		}
+	}
+	return t.p.err
+}
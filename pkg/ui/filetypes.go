@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"checkpoint/pkg/filetypes"
+)
+
+func (t *terminalRenderer) FileTypes(drives []filetypes.Drive) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("🗂️  Space by file type"))
+	box, _ := t.cardStyle()
+	inner := box.GetWidth() - box.GetHorizontalPadding()
+	for _, d := range drives {
+		content := t.st.driveName.Render(truncatePath(d.Name, inner)) + "\n\n"
+		summary := t.st.driveDesc
+		if d.Partial {
+			summary = t.st.used
+		}
+		content += summary.Width(inner).Render(fileTypesSummary(d)) + "\n\n"
+		content += t.typeBar(d, barWidth(box), t.st.progressBarFull)
+		if d.SizeBytes > 0 {
+			content += fmt.Sprintf(" %.1f%%", percent(d.UsedBytes, d.SizeBytes))
+		}
+		content += "\n\n"
+
+		// Swatch, size, share and file count take fixed columns; the name
+		// gets the rest
+		nameWidth := inner - 2 - 10 - 7 - 13
+		for _, c := range d.Categories {
+			content += fmt.Sprintf("%s %-*s%s%s%s\n",
+				t.typeSwatch(c.Color),
+				nameWidth, truncatePath(c.Name, nameWidth),
+				t.st.size.Render(fmt.Sprintf("%10s", FormatBytes(c.Bytes))),
+				fmt.Sprintf("%7s", fmt.Sprintf("%.1f%%", percent(c.Bytes, d.Bytes))),
+				t.st.driveDesc.Render(fmt.Sprintf("%13s", fileCount(c.Files))))
+		}
+		t.p.println(box.Render(strings.TrimRight(content, "\n")))
+	}
+	return t.p.err
+}
+
+// typeStyle colours a category's part of a bar
+func (t *terminalRenderer) typeStyle(color string) lipgloss.Style {
+	return t.lr.NewStyle().Background(lipgloss.Color(color)).Foreground(lipgloss.Color(color))
+}
+
+// typeSwatch is a square in a category's colour
+func (t *terminalRenderer) typeSwatch(color string) string {
+	return t.lr.NewStyle().Foreground(lipgloss.Color(color)).Render("■")
+}
+
+// typeBar draws the used space of a drive split by file category, the
+// space no category accounts for in fill
+func (t *terminalRenderer) typeBar(d filetypes.Drive, width int, fill lipgloss.Style) string {
+	bar, cells := "", 0
+	for _, seg := range typeSegments(d, width) {
+		style := fill
+		if seg.name != "" {
+			style = t.typeStyle(seg.color)
+		}
+		bar += style.Render(strings.Repeat("█", seg.cells))
+		cells += seg.cells
+	}
+	return bar + t.st.progressBarEmpty.Render(strings.Repeat("░", width-cells))
+}
+
+// typeLegend lists the largest categories of a drive with their colours, as
+// many as fit in width
+func (t *terminalRenderer) typeLegend(d filetypes.Drive, width int) string {
+	legend, used := "", 0
+	for _, c := range d.Categories {
+		label := c.Name + " " + FormatBytes(c.Bytes)
+		w := 2 + lipgloss.Width(label)
+		if used > 0 {
+			w += 2
+		}
+		if used+w > width {
+			break
+		}
+		if used > 0 {
+			legend += "  "
+		}
+		legend += t.typeSwatch(c.Color) + " " + t.st.driveDesc.Render(label)
+		used += w
+	}
+	return legend
+}
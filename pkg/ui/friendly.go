@@ -3,131 +3,262 @@ package ui
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 	"checkpoint/pkg/disk"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
 )
 
-var (
-	driveBoxStyle = lipgloss.NewStyle().
-			Border(lipgloss.RoundedBorder()).
-			BorderForeground(lipgloss.Color("241")).
-			Padding(1).
-			Margin(1, 0).
-			Width(60)
+// compactCardWidth is the card width below which cards lose their padding
+const compactCardWidth = 50
 
-	driveNameStyle = lipgloss.NewStyle().
-			Bold(true).
-			Foreground(lipgloss.Color("86"))
+// historyPeriod is how far back drive cards show the usage trend
+const historyPeriod = 30 * 24 * time.Hour
 
-	driveIconStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("214"))
-
-	driveDescStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("245")).
-			Italic(true)
-
-	progressBarStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("86"))
-
-	progressBarFullStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("86")).
-				Foreground(lipgloss.Color("16"))
-
-	progressBarEmptyStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("238")).
-				Foreground(lipgloss.Color("238"))
-)
+// groupColumns are the columns of the simple drive list
+var groupColumns = []column{
+	{title: "ID", width: 4},
+	{title: "Name", width: 16, flex: 1, max: 36},
+	{title: "Size", width: 10},
+	{title: "Free", width: 10},
+	{title: "Used", width: 7},
+	{title: "Type", width: 14, flex: 1, max: 20, optional: true},
+}
 
 // DisplayFriendlyDisks shows disks in a Windows-like friendly format
 func DisplayFriendlyDisks(groups []disk.DriveGroup) {
-	title := titleStyle.Render("💾 My Computer")
-	fmt.Println(title)
-	fmt.Println()
+	stdoutRenderer().FriendlyDisks(groups)
+}
 
-	for i, group := range groups {
-		displayDriveGroup(i+1, group)
+func (t *terminalRenderer) FriendlyDisks(groups []disk.DriveGroup) error {
+	t.p.err = nil
+	title := t.st.title.Render("💾 My Computer")
+	t.p.println(title)
+	t.p.println()
+
+	// Lay cards out in rows as wide as the terminal allows
+	box, perRow := t.cardStyle()
+	for start := 0; start < len(groups); start += perRow {
+		cards := []string{}
+		for i := start; i < start+perRow && i < len(groups); i++ {
+			if i > start {
+				cards = append(cards, " ")
+			}
+			cards = append(cards, t.displayDriveGroup(i+1, groups[i], box))
+		}
+		t.p.println(lipgloss.JoinHorizontal(lipgloss.Top, cards...))
 	}
+	return t.p.err
 }
 
-func displayDriveGroup(id int, group disk.DriveGroup) {
+// cardStyle sizes the drive card box for the terminal and returns how many
+// cards fit on one row
+func (t *terminalRenderer) cardStyle() (lipgloss.Style, int) {
+	perRow, width := cardColumns(t.width)
+	box := t.st.driveBox.Width(width - 2) // the border is outside the width
+	if width < compactCardWidth {
+		box = box.Padding(0, 1)
+	}
+	return box, perRow
+}
+
+func (t *terminalRenderer) displayDriveGroup(id int, group disk.DriveGroup, box lipgloss.Style) string {
 	// Create drive content
 	content := ""
-	
+
 	// Header with icon and name
 	header := fmt.Sprintf("%s %s %s",
-		driveIconStyle.Render(group.Icon),
-		driveNameStyle.Render(group.Name),
-		driveDescStyle.Render(fmt.Sprintf("(%s)", group.Description)))
+		t.st.driveIcon.Render(group.Icon),
+		t.st.driveName.Render(group.Name),
+		t.st.driveDesc.Render(fmt.Sprintf("(%s)", group.Description)))
 	content += header + "\n\n"
-	
+
 	// Size information
-	usedPercent := float64(group.TotalUsed) / float64(group.TotalSize) * 100
+	usedPercent := percent(group.TotalUsed, group.TotalSize)
 	content += fmt.Sprintf("📊 Space: %s free of %s\n",
-		availableStyle.Render(FormatBytes(group.Available)),
-		sizeStyle.Render(FormatBytes(group.TotalSize)))
-	
-	// Progress bar
-	content += "\n" + createProgressBar(int(usedPercent), 40) + fmt.Sprintf(" %.1f%%", usedPercent) + "\n"
-	
+		t.st.available.Render(FormatBytes(group.Available)),
+		t.st.size.Render(FormatBytes(group.TotalSize)))
+
+	// Progress bar, with the space that could be freed marked when known,
+	// or split by file type when that was measured
+	inner := box.GetWidth() - box.GetHorizontalPadding()
+	reclaimable := t.groupReclaimable(group)
+	bar := t.createSegmentedBar(usedPercent, percent(reclaimable, group.TotalSize), barWidth(box), t.alertStyle(group.Alert))
+	types, measured := t.groupFileTypes(group)
+	if measured {
+		bar = t.typeBar(types, barWidth(box), t.alertStyle(group.Alert))
+	}
+	content += "\n" + bar + fmt.Sprintf(" %.1f%%", usedPercent) + "\n"
+	if measured {
+		content += t.typeLegend(types, inner) + "\n"
+	}
+	if reclaimable > 0 {
+		content += fmt.Sprintf("🧹 %s could be freed\n", t.st.progressBarReclaimable.UnsetBackground().Render(FormatBytes(reclaimable)))
+	}
+	if len(group.Disks) > 0 {
+		if trash := t.trash[group.Disks[0].MountPoint]; trash > 0 {
+			content += fmt.Sprintf("🗑️  Trash: %s\n", t.st.size.Render(FormatBytes(trash)))
+		}
+	}
+
+	// Trend over the last month, when earlier scans were recorded
+	if len(group.Disks) > 0 {
+		all := t.history[group.Disks[0].MountPoint]
+		samples := history.Since(all, time.Now().Add(-historyPeriod))
+		if len(samples) > 1 {
+			growth := DescribeGrowth(all, historyPeriod)
+			width := inner - 4 - len(growth)
+			if width < 8 {
+				width, growth = inner-4, ""
+			}
+			content += fmt.Sprintf("📈 %s %s\n", t.st.progressBarFull.Render(Sparkline(samples, width)), t.st.driveDesc.Render(growth))
+		}
+	}
+	if f := group.Forecast; f != nil {
+		style := t.st.driveDesc
+		if f.Trend == disk.TrendGrowing && f.DaysToFull < forecastSoon {
+			style = t.st.used
+		}
+		line := fmt.Sprintf("⏳ %s (%s confidence)", DescribeForecast(f), f.Confidence)
+		content += style.Render(truncatePath(line, inner)) + "\n"
+	}
+
 	// Mount points
 	if len(group.Disks) == 1 {
-		content += fmt.Sprintf("\n📁 Location: %s", group.Disks[0].MountPoint)
+		content += fmt.Sprintf("\n📁 Location: %s", truncatePath(group.Disks[0].MountPoint, inner-13))
 	} else {
 		content += fmt.Sprintf("\n📁 Locations:")
 		for _, disk := range group.Disks {
-			content += fmt.Sprintf("\n   • %s (%s)", disk.MountPoint, FormatBytes(disk.Size))
+			size := FormatBytes(disk.Size)
+			content += fmt.Sprintf("\n   • %s (%s)", truncatePath(disk.MountPoint, inner-8-len(size)), size)
 		}
 	}
-	
+
 	// Special badges
 	if group.IsPrimary {
-		content += "\n\n" + availableStyle.Render("⭐ Primary Drive")
+		content += "\n\n" + t.st.available.Render("⭐ Primary Drive")
 	}
-	
+
 	// Apply box style
-	box := driveBoxStyle.Render(content)
-	fmt.Println(box)
+	return box.Render(content)
 }
 
-func createProgressBar(percent int, width int) string {
-	if percent < 0 {
-		percent = 0
+// barWidth fits the progress bar and its percentage label inside a card
+func barWidth(box lipgloss.Style) int {
+	width := 40
+	if inner := box.GetWidth() - box.GetHorizontalPadding() - 8; inner < width {
+		width = inner
 	}
-	if percent > 100 {
-		percent = 100
+	if width < 10 {
+		width = 10
 	}
-	
-	filled := width * percent / 100
+	return width
+}
+
+// alertStyle fills a drive's progress bar by its threshold level, so the
+// card agrees with "checkpoint check"
+func (t *terminalRenderer) alertStyle(alert *disk.Alert) lipgloss.Style {
+	if alert != nil {
+		switch alert.Level {
+		case disk.LevelWarning:
+			return t.st.progressBarWarning
+		case disk.LevelCritical:
+			return t.st.progressBarCritical
+		}
+	}
+	return t.st.progressBarFull
+}
+
+func (t *terminalRenderer) createProgressBar(percent int, width int, fill lipgloss.Style) string {
+	return t.createSegmentedBar(float64(percent), 0, width, fill)
+}
+
+// createSegmentedBar draws used space with its reclaimable part, which sits
+// at the end of it, in its own colour
+func (t *terminalRenderer) createSegmentedBar(usedPercent, reclaimPercent float64, width int, fill lipgloss.Style) string {
+	filled, reclaim := BarCells(usedPercent, reclaimPercent, width)
 	empty := width - filled
-	
-	bar := progressBarFullStyle.Render(strings.Repeat("█", filled)) +
-		progressBarEmptyStyle.Render(strings.Repeat("░", empty))
-	
+
+	bar := fill.Render(strings.Repeat("█", filled-reclaim)) +
+		t.st.progressBarReclaimable.Render(strings.Repeat("▓", reclaim)) +
+		t.st.progressBarEmpty.Render(strings.Repeat("░", empty))
+
 	return bar
 }
 
+// groupReclaimable sums the reclaimable bytes on a group's mount points
+func (t *terminalRenderer) groupReclaimable(group disk.DriveGroup) uint64 {
+	var total uint64
+	for _, d := range group.Disks {
+		total += t.reclaimable[d.MountPoint]
+	}
+	return total
+}
+
+// groupFileTypes returns the space by file type of a group, when measured
+func (t *terminalRenderer) groupFileTypes(group disk.DriveGroup) (filetypes.Drive, bool) {
+	if len(group.Disks) == 0 {
+		return filetypes.Drive{}, false
+	}
+	d, ok := t.fileTypes[group.Disks[0].MountPoint]
+	return d, ok
+}
+
 // DisplaySimpleDiskList shows a simplified disk list
 func DisplaySimpleDiskList(groups []disk.DriveGroup) {
-	headers := []string{"ID", "Name", "Size", "Free", "Used", "Type"}
-	headerRow := makeSimpleRow(headers, headerStyle)
-	fmt.Println(headerRow)
+	stdoutRenderer().SimpleDiskList(groups)
+}
+
+func (t *terminalRenderer) SimpleDiskList(groups []disk.DriveGroup) error {
+	t.p.err = nil
+	widths := layoutColumns(groupColumns, t.width)
+	headerRow := t.makeRowWithWidths(columnTitles(groupColumns), t.st.header, widths)
+	t.p.println(headerRow)
 
 	for i, group := range groups {
-		usedPercent := float64(group.TotalUsed) / float64(group.TotalSize) * 100
+		usedPercent := percent(group.TotalUsed, group.TotalSize)
 		rowData := []string{
 			fmt.Sprintf("%d", i+1),
 			fmt.Sprintf("%s %s", group.Icon, group.Name),
-			sizeStyle.Render(FormatBytes(group.TotalSize)),
-			availableStyle.Render(FormatBytes(group.Available)),
-			usedStyle.Render(fmt.Sprintf("%.1f%%", usedPercent)),
+			t.st.size.Render(FormatBytes(group.TotalSize)),
+			t.st.available.Render(FormatBytes(group.Available)),
+			t.st.used.Render(fmt.Sprintf("%.1f%%", usedPercent)),
 			group.Description,
 		}
-		
-		style := rowStyle
+
+		style := t.st.row
 		if i%2 == 0 {
-			style = evenRowStyle
+			style = t.st.evenRow
 		}
-		fmt.Println(makeSimpleStyledRow(rowData, style))
+		t.p.println(t.makeRowWithWidths(rowData, style, widths))
 	}
-}
\ No newline at end of file
+	return t.p.err
+}
+
+// DisplayPathUsage shows which drive holds path and how full it is
+func DisplayPathUsage(path string, d disk.Disk) {
+	stdoutRenderer().PathUsage(path, d)
+}
+
+func (t *terminalRenderer) PathUsage(path string, d disk.Disk) error {
+	t.p.err = nil
+	box, _ := t.cardStyle()
+	inner := box.GetWidth() - box.GetHorizontalPadding()
+	content := fmt.Sprintf("%s %s\n\n",
+		t.st.driveIcon.Render("📁"),
+		t.st.driveName.Render(truncatePath(path, inner-3)))
+
+	content += fmt.Sprintf("💽 Device: %s (%s)\n", truncatePath(d.Device, inner-14-len(d.Filesystem)), d.Filesystem)
+	content += fmt.Sprintf("📍 Mounted at: %s\n", truncatePath(d.MountPoint, inner-15))
+
+	usedPercent := percent(d.Used, d.Size)
+	content += fmt.Sprintf("📊 Space: %s free of %s\n",
+		t.st.available.Render(FormatBytes(d.Available)),
+		t.st.size.Render(FormatBytes(d.Size)))
+	content += "\n" + t.createProgressBar(int(usedPercent), barWidth(box), t.st.progressBarFull) + fmt.Sprintf(" %.1f%%", usedPercent)
+
+	t.p.println(box.Render(content))
+	return t.p.err
+}
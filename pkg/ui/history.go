@@ -0,0 +1,272 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/history"
+)
+
+// historyColumns are the columns of the usage history table
+var historyColumns = []column{
+	{title: "Mount", width: 12, flex: 1, max: 30},
+	{title: "Used", width: 10},
+	{title: "Day", width: 10},
+	{title: "Week", width: 10, optional: true},
+	{title: "Month", width: 10},
+	{title: "Full in", width: 18, optional: true},
+	{title: "Last 30 days", width: 12, flex: 1, max: 30, optional: true},
+}
+
+// historyChartHeight is the height of the chart shown for one filesystem
+const historyChartHeight = 12
+
+func (t *terminalRenderer) History(reports []history.Report) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("📈 Usage history"))
+	if len(reports) == 0 {
+		t.p.println(t.st.driveDesc.Render("Nothing recorded yet: every scan adds a sample."))
+		return t.p.err
+	}
+
+	widths := layoutColumns(historyColumns, t.width)
+	t.p.println(t.makeRowWithWidths(columnTitles(historyColumns), t.st.header, widths))
+	_, rows := historyTable(reports)
+	since := time.Now().Add(-history.Month)
+	for i, row := range rows {
+		cells := []string{
+			truncatePath(row[0], widths[0]),
+			t.st.size.Render(row[1]),
+			t.changeCell(row[2]),
+			t.changeCell(row[3]),
+			t.changeCell(row[4]),
+			row[6],
+			t.st.progressBarFull.Render(Sparkline(history.Since(reports[i].Samples, since), widths[6])),
+		}
+		style := t.st.row
+		if i%2 == 1 {
+			style = t.st.evenRow
+		}
+		t.p.println(t.makeRowWithWidths(cells, style, widths))
+	}
+	if note := historyNote(reports); note != "" {
+		t.p.println(t.st.driveDesc.MarginTop(1).Render(note))
+	}
+
+	// One filesystem gets the whole picture
+	if len(reports) == 1 && len(reports[0].Samples) > 0 {
+		r := reports[0]
+		t.p.println()
+		t.p.println(t.st.summaryTitle.Render("📁 " + r.MountPoint + " · " + DescribeGrowth(r.Samples, history.Month)))
+		if r.Forecast != nil {
+			t.p.println(t.st.driveDesc.Render(fmt.Sprintf("⏳ %s (%s confidence, from %.0f days of history)",
+				DescribeForecast(r.Forecast), r.Forecast.Confidence, r.Forecast.HistoryDays)))
+		}
+		t.p.println(t.st.progressBarFull.Render(HistoryChart(r.Samples, t.width, historyChartHeight)))
+	}
+	return t.p.err
+}
+
+// changeCell colours growth like used space and shrinking like free space
+func (t *terminalRenderer) changeCell(cell string) string {
+	switch {
+	case strings.HasPrefix(cell, "+"):
+		return t.st.used.Render(cell)
+	case strings.HasPrefix(cell, "-") && cell != "-":
+		return t.st.available.Render(cell)
+	}
+	return cell
+}
+
+// sparkBlocks are the eighths used for sparklines and the tops of chart bars
+var sparkBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// historyValues spreads samples over width equal time buckets, oldest on
+// the left, taking the last used size in each bucket. Buckets without a
+// sample repeat the one before, so gaps in the history read as "no change".
+func historyValues(samples []history.Sample, width int) []float64 {
+	if len(samples) == 0 || width <= 0 {
+		return nil
+	}
+	start, end := samples[0].Time, samples[len(samples)-1].Time
+	span := end.Sub(start)
+	values := make([]float64, width)
+	filled := make([]bool, width)
+	for _, s := range samples {
+		i := width - 1
+		if span > 0 {
+			i = int(float64(s.Time.Sub(start)) / float64(span) * float64(width-1))
+		}
+		values[i] = float64(s.Used)
+		filled[i] = true
+	}
+	for i := range values {
+		if !filled[i] && i > 0 {
+			values[i] = values[i-1]
+		}
+	}
+	// Leading buckets before the first sample cannot happen: it lands in 0
+	return values
+}
+
+// valueRange returns the lowest and highest value, widened a little when
+// they are equal so a flat history draws as a flat line
+func valueRange(values []float64) (float64, float64) {
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		if v < lo {
+			lo = v
+		}
+		if v > hi {
+			hi = v
+		}
+	}
+	if hi == lo {
+		lo -= 1
+		hi += 1
+	}
+	return lo, hi
+}
+
+// Sparkline draws the used space of samples as one line of width blocks,
+// scaled between the lowest and highest value so small trends show
+func Sparkline(samples []history.Sample, width int) string {
+	values := historyValues(samples, width)
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := valueRange(values)
+	var b strings.Builder
+	for _, v := range values {
+		level := 1 + int((v-lo)/(hi-lo)*float64(len(sparkBlocks)-2))
+		b.WriteRune(sparkBlocks[level])
+	}
+	return b.String()
+}
+
+// HistoryChart draws used space over time as bars height lines tall, with
+// the size range on the left and the dates below
+func HistoryChart(samples []history.Sample, width, height int) string {
+	const axis = 10 // "999.9 GB ┤"
+	if len(samples) == 0 || height < 3 || width < axis+10 {
+		return ""
+	}
+	plotW, plotH := width-axis, height-1
+	values := historyValues(samples, plotW)
+	lo, hi := valueRange(values)
+	// Leave room below the lowest value so its bar is visible
+	lo -= (hi - lo) / 4
+	if lo < 0 {
+		lo = 0
+	}
+
+	lines := make([]string, 0, height)
+	for row := plotH - 1; row >= 0; row-- {
+		label := ""
+		switch row {
+		case plotH - 1:
+			label = FormatBytes(uint64(hi))
+		case 0:
+			label = FormatBytes(uint64(lo))
+		}
+		var b strings.Builder
+		b.WriteString(fmt.Sprintf("%8s ┤", label))
+		for _, v := range values {
+			// How many eighths of this row the bar covers
+			eighths := int((v-lo)/(hi-lo)*float64(plotH*8)) - row*8
+			if eighths < 0 {
+				eighths = 0
+			}
+			if eighths > 8 {
+				eighths = 8
+			}
+			b.WriteRune(sparkBlocks[eighths])
+		}
+		lines = append(lines, b.String())
+	}
+
+	first, last := chartDate(samples[0].Time), chartDate(samples[len(samples)-1].Time)
+	gap := plotW - len(first) - len(last)
+	if gap < 1 {
+		lines = append(lines, strings.Repeat(" ", axis)+first)
+	} else {
+		lines = append(lines, strings.Repeat(" ", axis)+first+strings.Repeat(" ", gap)+last)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func chartDate(t time.Time) string {
+	if t.Year() != time.Now().Year() {
+		return t.Format("2 Jan 2006")
+	}
+	return t.Format("2 Jan 15:04")
+}
+
+// FormatChange formats a change in bytes with its sign
+func FormatChange(bytes int64) string {
+	if bytes < 0 {
+		return "-" + FormatBytes(uint64(-bytes))
+	}
+	return "+" + FormatBytes(uint64(bytes))
+}
+
+// DescribeGrowth says how the used space changed over period, such as
+// "grew 40.0 GB in 30 days"
+func DescribeGrowth(samples []history.Sample, period time.Duration) string {
+	change, ok := history.Growth(samples, period)
+	if !ok {
+		return "no history yet"
+	}
+	when := "in " + formatPeriod(period)
+	if change.Partial {
+		when = "since " + change.From.Time.Format("2 Jan")
+	}
+	switch {
+	case change.Bytes > 0:
+		return fmt.Sprintf("grew %s %s", FormatBytes(uint64(change.Bytes)), when)
+	case change.Bytes < 0:
+		return fmt.Sprintf("shrank %s %s", FormatBytes(uint64(-change.Bytes)), when)
+	}
+	return "unchanged " + when
+}
+
+// DescribeForecast puts a forecast into words, such as "At current rate,
+// full in ~23 days", or returns "" without one
+func DescribeForecast(f *disk.Forecast) string {
+	if f == nil {
+		return ""
+	}
+	switch f.Trend {
+	case disk.TrendFlat:
+		return "Usage is flat"
+	case disk.TrendShrinking:
+		return "Usage is shrinking"
+	}
+	days := int(math.Round(f.DaysToFull))
+	switch {
+	case days < 1:
+		return "At current rate, full within a day"
+	case days > 365:
+		return "At current rate, full in over a year"
+	case days == 1:
+		return "At current rate, full in ~1 day"
+	}
+	return fmt.Sprintf("At current rate, full in ~%d days", days)
+}
+
+// forecastSoon is when a forecast becomes a warning
+const forecastSoon = 30
+
+func formatPeriod(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	switch {
+	case days == 1:
+		return "a day"
+	case days > 1:
+		return fmt.Sprintf("%d days", days)
+	}
+	return d.String()
+}
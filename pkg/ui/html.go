@@ -0,0 +1,367 @@
+package ui
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/doctor"
+	"checkpoint/pkg/dupes"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/search"
+	"checkpoint/pkg/snapshot"
+)
+
+// htmlRenderer writes HTML fragments with "checkpoint-" classes for styling
+type htmlRenderer struct {
+	p printer
+}
+
+// NewHTMLRenderer creates a renderer producing embeddable HTML fragments
+func NewHTMLRenderer(w io.Writer) Renderer {
+	return &htmlRenderer{p: printer{w: w}}
+}
+
+func (r *htmlRenderer) Disks(disks []disk.Disk, showDetails bool) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-disks">`)
+	r.p.println("<h2>💾 Storage Disks Overview</h2>")
+	r.table(diskTable(disks, showDetails))
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) FriendlyDisks(groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-drives">`)
+	r.p.println("<h2>💾 My Computer</h2>")
+	for _, group := range groups {
+		class := "checkpoint-drive"
+		if group.IsPrimary {
+			class += " checkpoint-primary"
+		}
+		if group.Alert != nil && group.Alert.Level > disk.LevelOK {
+			class += " checkpoint-" + group.Alert.Level.String()
+		}
+		r.p.printf("<article class=\"%s\">\n", class)
+		r.p.printf("<h3>%s %s <small>(%s)</small></h3>\n",
+			html.EscapeString(group.Icon), html.EscapeString(group.Name), html.EscapeString(group.Description))
+		r.usage(group.TotalUsed, group.TotalSize, group.Available)
+		r.p.println("<ul>")
+		for _, l := range groupLocations(group) {
+			r.p.printf("<li>📁 <code>%s</code></li>\n", html.EscapeString(l))
+		}
+		r.p.println("</ul>")
+		r.p.println("</article>")
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) SimpleDiskList(groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.table(groupTable(groups))
+	return r.p.err
+}
+
+func (r *htmlRenderer) Summary(stats disk.DiskStats, disks []disk.Disk) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-summary">`)
+	r.p.println("<h2>📊 Storage Summary</h2>")
+	r.p.println("<dl>")
+	for _, row := range summaryRows(stats) {
+		r.p.printf("<dt>%s</dt><dd>%s</dd>\n", html.EscapeString(row[0]), html.EscapeString(row[1]))
+	}
+	r.p.println("</dl>")
+
+	main, more := mainDisks(disks, 5)
+	if len(main) > 0 {
+		r.p.println("<h3>Main Storage</h3>")
+		r.p.println("<ul>")
+		for _, d := range main {
+			r.p.printf("<li>%s</li>\n", html.EscapeString(mainDiskLine(d)))
+		}
+		if more > 0 {
+			r.p.printf("<li>... and %d more</li>\n", more)
+		}
+		r.p.println("</ul>")
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) PathUsage(path string, d disk.Disk) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-path">`)
+	r.p.printf("<h3>📁 <code>%s</code></h3>\n", html.EscapeString(path))
+	r.p.printf("<p>Device: <code>%s</code> (%s), mounted at <code>%s</code></p>\n",
+		html.EscapeString(d.Device), html.EscapeString(d.Filesystem), html.EscapeString(d.MountPoint))
+	r.usage(d.Used, d.Size, d.Available)
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) DirUsage(report analyzer.Report) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-dir-usage">`)
+	r.p.printf("<h2>📂 <code>%s</code></h2>\n", html.EscapeString(report.Root))
+	r.p.printf("<p>%s</p>\n", html.EscapeString(usageSummary(report)))
+	r.table(usageTable(report))
+	if len(report.Largest) > 0 {
+		r.p.println("<h3>📄 Largest files</h3>")
+		r.table(largestTable(report))
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) History(reports []history.Report) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-history">`)
+	r.p.println("<h2>📈 Usage history</h2>")
+	r.table(historyTable(reports))
+	if note := historyNote(reports); note != "" {
+		r.p.printf("<p>%s</p>\n", html.EscapeString(note))
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) Reclaimable(report cleanup.Report, groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-reclaimable">`)
+	r.p.println("<h2>🧹 Reclaimable space</h2>")
+	r.p.printf("<p>%s could be freed</p>\n", FormatBytes(report.Total))
+	found, _ := reclaimGroups(report, groups)
+	for _, rg := range found {
+		usedPercent := percent(rg.group.TotalUsed, rg.group.TotalSize)
+		reclaimPercent := percent(rg.bytes, rg.group.TotalSize)
+		r.p.println(`<div class="checkpoint-drive">`)
+		r.p.printf("<h3>%s %s</h3>\n", rg.group.Icon, html.EscapeString(rg.group.Name))
+		r.p.printf("<p>%s free of %s, %s could be freed</p>\n",
+			FormatBytes(rg.group.Available), FormatBytes(rg.group.TotalSize), FormatBytes(rg.bytes))
+		// A progress element has one value, so the segments are two spans
+		r.p.printf(`<div class="checkpoint-bar"><span class="checkpoint-used" style="width: %.1f%%"></span>`+
+			`<span class="checkpoint-reclaimable" style="width: %.1f%%"></span></div> %.1f%%`+"\n",
+			usedPercent-reclaimPercent, reclaimPercent, usedPercent)
+		r.p.println("</div>")
+	}
+	if len(report.Items) > 0 {
+		r.table(reclaimTable(report))
+		r.p.println("<dl>")
+		for _, note := range reclaimNotes(report) {
+			r.p.printf("<dt>%s</dt><dd>%s</dd>\n", html.EscapeString(note[0]), html.EscapeString(note[1]))
+		}
+		r.p.println("</dl>")
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) Cleanup(results []cleanup.Result) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-cleanup">`)
+	r.p.println("<h2>🧹 Cleanup</h2>")
+	r.table(cleanupTable(results))
+	for _, res := range results {
+		if !res.DryRun || len(res.Steps) == 0 || res.Skipped != "" {
+			continue
+		}
+		r.p.printf("<h3>%s</h3>\n<ul>\n", html.EscapeString(res.Item.Name))
+		for _, step := range cleanupSteps(res) {
+			r.p.printf("<li><code>%s</code></li>\n", html.EscapeString(step))
+		}
+		r.p.println("</ul>")
+	}
+	r.p.printf("<p>%s</p>\n", html.EscapeString(cleanupSummary(results)))
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) Trash(items []disk.TrashItem) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-trash">`)
+	r.p.println("<h2>🗑️ Trash</h2>")
+	if len(items) > 0 {
+		r.table(trashTable(items))
+	}
+	r.p.printf("<p>%s</p>\n", html.EscapeString(trashSummary(items)))
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) Duplicates(report dupes.Report) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-duplicates">`)
+	r.p.println("<h2>👯 Duplicate files</h2>")
+	r.p.printf("<p>%s</p>\n", html.EscapeString(dupesSummary(report)))
+	for _, g := range report.Groups {
+		r.p.printf("<h3>%s</h3>\n<p>SHA-256 <code>%s</code></p>\n<ul>\n", html.EscapeString(dupesHeading(g)), g.SHA256)
+		for _, f := range g.Files {
+			r.p.printf("<li><code>%s</code></li>\n", html.EscapeString(dupesPath(f)))
+		}
+		r.p.println("</ul>")
+	}
+	if more := dupesMore(report); more != "" {
+		r.p.printf("<p>%s</p>\n", html.EscapeString(more))
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) Dedupe(results []dupes.Result) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-dedupe">`)
+	r.p.println("<h2>👯 Duplicates</h2>")
+	r.table(dedupeTable(results))
+	r.p.printf("<p>%s</p>\n", html.EscapeString(dedupeSummary(results)))
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) Files(report search.Report) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-files">`)
+	r.p.println("<h2>🔎 Large and stale files</h2>")
+	r.p.printf("<p>%s</p>\n", html.EscapeString(filesSummary(report)))
+	if criteria := filesCriteria(report); criteria != "" {
+		r.p.printf("<p>%s</p>\n", html.EscapeString(criteria))
+	}
+	if len(report.Files) > 0 {
+		r.table(filesTable(report))
+		r.p.println("<h3>📁 By folder</h3>")
+		r.table(fileGroupTable("Folder", report.ByDir))
+		r.p.println("<h3>🏷️ By type</h3>")
+		r.table(fileGroupTable("Type", report.ByType))
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) FileTypes(drives []filetypes.Drive) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-file-types">`)
+	r.p.println("<h2>🗂️ Space by file type</h2>")
+	for _, d := range drives {
+		r.p.println(`<div class="checkpoint-drive">`)
+		r.p.printf("<h3>%s</h3>\n", html.EscapeString(d.Name))
+		r.p.printf("<p>%s</p>\n", html.EscapeString(fileTypesSummary(d)))
+		// One span per category, as wide as its share of the bar
+		r.p.print(`<div class="checkpoint-bar">`)
+		for _, seg := range typeSegments(d, 1000) {
+			if seg.name == "" {
+				r.p.printf(`<span class="checkpoint-used" style="width: %.1f%%"></span>`, float64(seg.cells)/10)
+				continue
+			}
+			r.p.printf(`<span class="checkpoint-file-type" title="%s" style="width: %.1f%%; background: %s"></span>`,
+				html.EscapeString(seg.name), float64(seg.cells)/10, cssColor(seg.color))
+		}
+		r.p.println("</div>")
+		r.table(fileTypesTable(d))
+		r.p.println("</div>")
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+// cssColor turns an ANSI colour number into a CSS colour; #rrggbb colours
+// are kept
+func cssColor(c string) string {
+	n, err := strconv.Atoi(c)
+	if err != nil || n < 0 || n > 255 {
+		return html.EscapeString(c)
+	}
+	switch {
+	case n < 16:
+		basic := []string{"#000000", "#800000", "#008000", "#808000", "#000080", "#800080", "#008080", "#c0c0c0",
+			"#808080", "#ff0000", "#00ff00", "#ffff00", "#0000ff", "#ff00ff", "#00ffff", "#ffffff"}
+		return basic[n]
+	case n < 232:
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		n -= 16
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	default:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
+
+func (r *htmlRenderer) Snapshots(snaps []snapshot.Snapshot) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-snapshots">`)
+	r.p.println("<h2>📸 Snapshots</h2>")
+	if len(snaps) > 0 {
+		r.table(snapshotTable(snaps))
+	}
+	r.p.printf("<p>%s</p>\n", html.EscapeString(snapshotSummary(snaps)))
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) Diff(d snapshot.Diff) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-diff">`)
+	r.p.println("<h2>🕒 What changed</h2>")
+	r.p.printf("<p>%s</p>\n", html.EscapeString(diffSummary(d)))
+	if len(d.Changes) > 0 {
+		r.table(diffTable(d))
+	}
+	if more := diffMore(d); more != "" {
+		r.p.printf("<p>%s</p>\n", html.EscapeString(more))
+	}
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) Doctor(report doctor.Report) error {
+	r.p.err = nil
+	r.p.println(`<section class="checkpoint-doctor">`)
+	r.p.println("<h2>🩺 Doctor</h2>")
+	r.table(doctorTable(report))
+	if findings := doctorFindings(report); len(findings) > 0 {
+		r.p.println("<dl>")
+		for _, f := range findings {
+			r.p.printf("<dt class=\"checkpoint-%s\">%s: %s</dt><dd>%s</dd><dd>Fix: %s</dd>\n", f.Level, f.Level,
+				html.EscapeString(f.Subject), html.EscapeString(f.Problem), html.EscapeString(f.Fix))
+		}
+		r.p.println("</dl>")
+	}
+	r.p.printf("<p>%s</p>\n", html.EscapeString(doctorSummary(report)))
+	r.p.println("</section>")
+	return r.p.err
+}
+
+func (r *htmlRenderer) usage(used, size, available uint64) {
+	usedPercent := percent(used, size)
+	r.p.printf("<p>%s free of %s</p>\n", FormatBytes(available), FormatBytes(size))
+	r.p.printf("<progress max=\"100\" value=\"%.1f\">%.1f%%</progress> %.1f%%\n", usedPercent, usedPercent, usedPercent)
+}
+
+func (r *htmlRenderer) table(header []string, rows [][]string) {
+	r.p.println(`<table class="checkpoint-table">`)
+	r.p.print("<thead><tr>")
+	for _, h := range header {
+		r.p.printf("<th>%s</th>", html.EscapeString(h))
+	}
+	r.p.println("</tr></thead>")
+	r.p.println("<tbody>")
+	for _, row := range rows {
+		r.p.print("<tr>")
+		for _, c := range row {
+			r.p.printf("<td>%s</td>", html.EscapeString(c))
+		}
+		r.p.println("</tr>")
+	}
+	r.p.println("</tbody>")
+	r.p.println("</table>")
+}
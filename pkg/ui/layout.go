@@ -0,0 +1,150 @@
+package ui
+
+import (
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Layout limits shared by the terminal views
+const (
+	// compactWidth is the terminal width below which views drop optional
+	// columns and card padding
+	compactWidth = 80
+	// cardWidth is the preferred drive card width including its border
+	cardWidth = 62
+	// minCardWidth keeps cards usable on very small terminals
+	minCardWidth = 30
+	// cellPadding is the right padding the row styles add to every cell
+	cellPadding = 2
+	// minFlexWidth is the narrowest a flexible column gets
+	minFlexWidth = 8
+)
+
+// column describes one table column. Fixed columns always get width;
+// flexible columns (flex > 0) start at width and share whatever is left,
+// up to max when it is set.
+type column struct {
+	title    string
+	width    int
+	flex     int
+	max      int
+	optional bool // dropped in compact mode
+}
+
+// layoutColumns fits cols into total terminal cells. It returns the width of
+// every column, with 0 for columns dropped because the terminal is too narrow.
+func layoutColumns(cols []column, total int) []int {
+	widths := make([]int, len(cols))
+	compact := total < compactWidth || total < minTableWidth(cols, false)
+
+	used := 0
+	flexTotal := 0
+	for i, c := range cols {
+		if compact && c.optional {
+			continue
+		}
+		widths[i] = c.width
+		used += c.width + cellPadding + 1
+		flexTotal += c.flex
+	}
+
+	spare := total - used
+	if flexTotal == 0 {
+		return widths
+	}
+	if spare < 0 {
+		// Still too wide: take the difference out of the flexible columns
+		for i, c := range cols {
+			if widths[i] == 0 || c.flex == 0 {
+				continue
+			}
+			widths[i] += spare * c.flex / flexTotal
+			if widths[i] < minFlexWidth {
+				widths[i] = minFlexWidth
+			}
+		}
+		return widths
+	}
+
+	// Hand out spare cells by flex share, capping at max; capped leftovers
+	// are not redistributed, which keeps the arithmetic predictable
+	for i, c := range cols {
+		if widths[i] == 0 || c.flex == 0 {
+			continue
+		}
+		extra := spare * c.flex / flexTotal
+		widths[i] += extra
+		if c.max > 0 && widths[i] > c.max {
+			widths[i] = c.max
+		}
+	}
+	return widths
+}
+
+// minTableWidth is the width needed to show the columns at their minimum
+func minTableWidth(cols []column, compact bool) int {
+	total := 0
+	for _, c := range cols {
+		if compact && c.optional {
+			continue
+		}
+		total += c.width + cellPadding + 1
+	}
+	return total
+}
+
+// visibleCells keeps the cells whose column has a width
+func visibleCells(cells []string, widths []int) ([]string, []int) {
+	outCells := make([]string, 0, len(cells))
+	outWidths := make([]int, 0, len(widths))
+	for i, c := range cells {
+		if i < len(widths) && widths[i] > 0 {
+			outCells = append(outCells, c)
+			outWidths = append(outWidths, widths[i])
+		}
+	}
+	return outCells, outWidths
+}
+
+// columnTitles returns the header row of cols
+func columnTitles(cols []column) []string {
+	titles := make([]string, len(cols))
+	for i, c := range cols {
+		titles[i] = c.title
+	}
+	return titles
+}
+
+// truncatePath shortens path to maxWidth terminal cells, keeping the end,
+// which is usually the interesting part. Widths are measured per grapheme
+// cluster, so multi-byte names and emoji are never cut in half.
+func truncatePath(path string, maxWidth int) string {
+	width := ansi.StringWidth(path)
+	if width <= maxWidth {
+		return path
+	}
+	if maxWidth < 10 {
+		return ansi.Truncate(path, maxWidth, "")
+	}
+	return ansi.TruncateLeft(path, width-(maxWidth-3), "...")
+}
+
+// fitCell hard-limits a possibly styled cell to width cells
+func fitCell(cell string, width int) string {
+	if ansi.StringWidth(cell) <= width {
+		return cell
+	}
+	return ansi.Truncate(cell, width, "…")
+}
+
+// cardColumns returns how many drive cards fit side by side and how wide
+// each card is, including its border
+func cardColumns(total int) (int, int) {
+	if total < cardWidth {
+		if total < minCardWidth {
+			return 1, minCardWidth
+		}
+		return 1, total
+	}
+	cols := (total + 1) / (cardWidth + 1) // one space between cards
+	return cols, cardWidth
+}
@@ -0,0 +1,307 @@
+package ui
+
+import (
+	"io"
+	"strings"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/doctor"
+	"checkpoint/pkg/dupes"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/search"
+	"checkpoint/pkg/snapshot"
+)
+
+// markdownRenderer writes GitHub-flavoured markdown for reports and wikis
+type markdownRenderer struct {
+	p printer
+}
+
+// NewMarkdownRenderer creates a renderer producing markdown headings and tables
+func NewMarkdownRenderer(w io.Writer) Renderer {
+	return &markdownRenderer{p: printer{w: w}}
+}
+
+func (r *markdownRenderer) Disks(disks []disk.Disk, showDetails bool) error {
+	r.p.err = nil
+	r.p.println("## 💾 Storage Disks Overview")
+	r.p.println()
+	r.table(diskTable(disks, showDetails))
+	return r.p.err
+}
+
+func (r *markdownRenderer) FriendlyDisks(groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.p.println("## 💾 My Computer")
+	for _, group := range groups {
+		r.p.println()
+		r.p.printf("### %s %s\n\n", group.Icon, mdEscape(group.Name))
+		r.p.printf("_%s_", mdEscape(group.Description))
+		if group.IsPrimary {
+			r.p.print(" ⭐ Primary Drive")
+		}
+		r.p.println()
+		r.p.println()
+		r.usage(group.TotalUsed, group.TotalSize, group.Available)
+		for _, l := range groupLocations(group) {
+			r.p.printf("- 📁 `%s`\n", l)
+		}
+	}
+	return r.p.err
+}
+
+func (r *markdownRenderer) SimpleDiskList(groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.table(groupTable(groups))
+	return r.p.err
+}
+
+func (r *markdownRenderer) Summary(stats disk.DiskStats, disks []disk.Disk) error {
+	r.p.err = nil
+	r.p.println("## 📊 Storage Summary")
+	r.p.println()
+	for _, row := range summaryRows(stats) {
+		r.p.printf("- **%s:** %s\n", mdEscape(row[0]), mdEscape(row[1]))
+	}
+
+	main, more := mainDisks(disks, 5)
+	if len(main) > 0 {
+		r.p.println()
+		r.p.println("### Main Storage")
+		r.p.println()
+		for _, d := range main {
+			r.p.printf("- %s\n", mdEscape(mainDiskLine(d)))
+		}
+		if more > 0 {
+			r.p.printf("- ... and %d more\n", more)
+		}
+	}
+	return r.p.err
+}
+
+func (r *markdownRenderer) PathUsage(path string, d disk.Disk) error {
+	r.p.err = nil
+	r.p.printf("### 📁 `%s`\n\n", path)
+	r.p.printf("- Device: `%s` (%s)\n", d.Device, mdEscape(d.Filesystem))
+	r.p.printf("- Mounted at: `%s`\n", d.MountPoint)
+	r.usage(d.Used, d.Size, d.Available)
+	return r.p.err
+}
+
+func (r *markdownRenderer) DirUsage(report analyzer.Report) error {
+	r.p.err = nil
+	r.p.printf("## 📂 `%s`\n\n", report.Root)
+	r.p.printf("%s\n\n", mdEscape(usageSummary(report)))
+	r.table(usageTable(report))
+	if len(report.Largest) > 0 {
+		r.p.println()
+		r.p.println("### 📄 Largest files")
+		r.p.println()
+		r.table(largestTable(report))
+	}
+	return r.p.err
+}
+
+func (r *markdownRenderer) History(reports []history.Report) error {
+	r.p.err = nil
+	r.p.println("## 📈 Usage history")
+	r.p.println()
+	r.table(historyTable(reports))
+	if note := historyNote(reports); note != "" {
+		r.p.println()
+		r.p.printf("%s\n", mdEscape(note))
+	}
+	return r.p.err
+}
+
+func (r *markdownRenderer) Reclaimable(report cleanup.Report, groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.p.println("## 🧹 Reclaimable space")
+	r.p.println()
+	r.p.printf("**%s** could be freed.\n", FormatBytes(report.Total))
+	found, _ := reclaimGroups(report, groups)
+	for _, rg := range found {
+		usedPercent := percent(rg.group.TotalUsed, rg.group.TotalSize)
+		r.p.println()
+		r.p.printf("### %s %s\n\n", rg.group.Icon, mdEscape(rg.group.Name))
+		r.p.printf("- Space: **%s** free of %s, **%s** could be freed\n",
+			FormatBytes(rg.group.Available), FormatBytes(rg.group.TotalSize), FormatBytes(rg.bytes))
+		r.p.printf("- Used: `%s` %.1f%%\n", reclaimBar(usedPercent, percent(rg.bytes, rg.group.TotalSize), 20), usedPercent)
+	}
+	if len(report.Items) == 0 {
+		return r.p.err
+	}
+	r.p.println()
+	r.table(reclaimTable(report))
+	r.p.println()
+	for _, note := range reclaimNotes(report) {
+		r.p.printf("- **%s**: %s\n", mdEscape(note[0]), mdEscape(note[1]))
+	}
+	return r.p.err
+}
+
+func (r *markdownRenderer) Cleanup(results []cleanup.Result) error {
+	r.p.err = nil
+	r.p.println("## 🧹 Cleanup")
+	r.p.println()
+	r.table(cleanupTable(results))
+	for _, res := range results {
+		if !res.DryRun || len(res.Steps) == 0 || res.Skipped != "" {
+			continue
+		}
+		r.p.printf("\n### %s\n\n", mdEscape(res.Item.Name))
+		for _, step := range cleanupSteps(res) {
+			r.p.printf("- `%s`\n", step)
+		}
+	}
+	r.p.println()
+	r.p.printf("%s\n", mdEscape(cleanupSummary(results)))
+	return r.p.err
+}
+
+func (r *markdownRenderer) Trash(items []disk.TrashItem) error {
+	r.p.err = nil
+	r.p.println("## 🗑️ Trash")
+	r.p.println()
+	if len(items) > 0 {
+		r.table(trashTable(items))
+		r.p.println()
+	}
+	r.p.printf("%s\n", mdEscape(trashSummary(items)))
+	return r.p.err
+}
+
+func (r *markdownRenderer) Duplicates(report dupes.Report) error {
+	r.p.err = nil
+	r.p.println("## 👯 Duplicate files")
+	r.p.println()
+	r.p.printf("%s\n", mdEscape(dupesSummary(report)))
+	for _, g := range report.Groups {
+		r.p.printf("\n### %s\n\n", mdEscape(dupesHeading(g)))
+		r.p.printf("SHA-256 `%s`\n\n", g.SHA256)
+		for _, f := range g.Files {
+			r.p.printf("- `%s`\n", dupesPath(f))
+		}
+	}
+	if more := dupesMore(report); more != "" {
+		r.p.printf("\n%s\n", mdEscape(more))
+	}
+	return r.p.err
+}
+
+func (r *markdownRenderer) Dedupe(results []dupes.Result) error {
+	r.p.err = nil
+	r.p.println("## 👯 Duplicates")
+	r.p.println()
+	r.table(dedupeTable(results))
+	r.p.println()
+	r.p.printf("%s\n", mdEscape(dedupeSummary(results)))
+	return r.p.err
+}
+
+func (r *markdownRenderer) Files(report search.Report) error {
+	r.p.err = nil
+	r.p.println("## 🔎 Large and stale files")
+	r.p.println()
+	r.p.printf("%s\n", mdEscape(filesSummary(report)))
+	if criteria := filesCriteria(report); criteria != "" {
+		r.p.printf("\n%s\n", mdEscape(criteria))
+	}
+	if len(report.Files) == 0 {
+		return r.p.err
+	}
+	r.p.println()
+	r.table(filesTable(report))
+	r.p.println("\n### 📁 By folder")
+	r.p.println()
+	r.table(fileGroupTable("Folder", report.ByDir))
+	r.p.println("\n### 🏷️ By type")
+	r.p.println()
+	r.table(fileGroupTable("Type", report.ByType))
+	return r.p.err
+}
+
+func (r *markdownRenderer) FileTypes(drives []filetypes.Drive) error {
+	r.p.err = nil
+	r.p.println("## 🗂️ Space by file type")
+	for _, d := range drives {
+		r.p.printf("\n### %s\n\n", mdEscape(d.Name))
+		r.p.printf("%s\n\n", mdEscape(fileTypesSummary(d)))
+		r.p.printf("`%s`", typeBar(d, 20))
+		if d.SizeBytes > 0 {
+			r.p.printf(" %.1f%%", percent(d.UsedBytes, d.SizeBytes))
+		}
+		r.p.printf(" (`%s`)\n\n", typeLegend(d))
+		r.table(fileTypesTable(d))
+	}
+	return r.p.err
+}
+
+func (r *markdownRenderer) Snapshots(snaps []snapshot.Snapshot) error {
+	r.p.err = nil
+	r.p.println("## 📸 Snapshots")
+	r.p.println()
+	if len(snaps) > 0 {
+		r.table(snapshotTable(snaps))
+		r.p.println()
+	}
+	r.p.printf("%s\n", mdEscape(snapshotSummary(snaps)))
+	return r.p.err
+}
+
+func (r *markdownRenderer) Diff(d snapshot.Diff) error {
+	r.p.err = nil
+	r.p.println("## 🕒 What changed")
+	r.p.println()
+	r.p.printf("%s\n", mdEscape(diffSummary(d)))
+	if len(d.Changes) > 0 {
+		r.p.println()
+		r.table(diffTable(d))
+	}
+	if more := diffMore(d); more != "" {
+		r.p.printf("\n%s\n", mdEscape(more))
+	}
+	return r.p.err
+}
+
+func (r *markdownRenderer) Doctor(report doctor.Report) error {
+	r.p.err = nil
+	r.p.println("## 🩺 Doctor")
+	r.p.println()
+	r.table(doctorTable(report))
+	if findings := doctorFindings(report); len(findings) > 0 {
+		r.p.println()
+		for _, f := range findings {
+			r.p.printf("- **%s** %s: %s\n  *Fix:* %s\n", f.Level, mdEscape(f.Subject), mdEscape(f.Problem), mdEscape(f.Fix))
+		}
+	}
+	r.p.println()
+	r.p.printf("%s\n", mdEscape(doctorSummary(report)))
+	return r.p.err
+}
+
+func (r *markdownRenderer) usage(used, size, available uint64) {
+	usedPercent := percent(used, size)
+	r.p.printf("- Space: **%s** free of %s\n", FormatBytes(available), FormatBytes(size))
+	r.p.printf("- Used: `%s` %.1f%%\n", textBar(usedPercent, 20), usedPercent)
+}
+
+func (r *markdownRenderer) table(header []string, rows [][]string) {
+	r.p.printf("| %s |\n", strings.Join(header, " | "))
+	r.p.printf("|%s\n", strings.Repeat(" --- |", len(header)))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, c := range row {
+			cells[i] = mdEscape(c)
+		}
+		r.p.printf("| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+// mdEscape keeps user data from breaking tables or emphasis
+func mdEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`").Replace(s)
+}
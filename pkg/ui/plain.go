@@ -0,0 +1,318 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/doctor"
+	"checkpoint/pkg/dupes"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/search"
+	"checkpoint/pkg/snapshot"
+)
+
+// plainRenderer writes uncoloured text, suitable for logs and e-mail reports
+type plainRenderer struct {
+	p printer
+}
+
+// NewPlainRenderer creates a renderer without colours or box drawing
+func NewPlainRenderer(w io.Writer) Renderer {
+	return &plainRenderer{p: printer{w: w}}
+}
+
+func (r *plainRenderer) Disks(disks []disk.Disk, showDetails bool) error {
+	r.p.err = nil
+	r.p.println("Storage Disks Overview")
+	r.p.println()
+	r.table(diskTable(disks, showDetails))
+	return r.p.err
+}
+
+func (r *plainRenderer) FriendlyDisks(groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.p.println("My Computer")
+	for _, group := range groups {
+		r.p.println()
+		r.p.printf("%s (%s)\n", group.Name, group.Description)
+		r.usage(group.TotalUsed, group.TotalSize, group.Available)
+		locations := groupLocations(group)
+		if len(locations) == 1 {
+			r.p.printf("  Location: %s\n", locations[0])
+		} else {
+			r.p.println("  Locations:")
+			for _, l := range locations {
+				r.p.printf("    - %s\n", l)
+			}
+		}
+		if group.IsPrimary {
+			r.p.println("  Primary drive")
+		}
+	}
+	return r.p.err
+}
+
+func (r *plainRenderer) SimpleDiskList(groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.table(groupTable(groups))
+	return r.p.err
+}
+
+func (r *plainRenderer) Summary(stats disk.DiskStats, disks []disk.Disk) error {
+	r.p.err = nil
+	r.p.println("Storage Summary")
+	r.p.println()
+	for _, row := range summaryRows(stats) {
+		r.p.printf("%s: %s\n", row[0], row[1])
+	}
+
+	main, more := mainDisks(disks, 5)
+	if len(main) > 0 {
+		r.p.println()
+		r.p.println("Main Storage:")
+		for _, d := range main {
+			r.p.printf("  %s\n", mainDiskLine(d))
+		}
+		if more > 0 {
+			r.p.printf("  ... and %d more\n", more)
+		}
+	}
+	return r.p.err
+}
+
+func (r *plainRenderer) PathUsage(path string, d disk.Disk) error {
+	r.p.err = nil
+	r.p.println(path)
+	r.p.printf("  Device: %s (%s)\n", d.Device, d.Filesystem)
+	r.p.printf("  Mounted at: %s\n", d.MountPoint)
+	r.usage(d.Used, d.Size, d.Available)
+	return r.p.err
+}
+
+func (r *plainRenderer) DirUsage(report analyzer.Report) error {
+	r.p.err = nil
+	r.p.println(report.Root)
+	r.p.println(usageSummary(report))
+	r.p.println()
+	r.table(usageTable(report))
+	if len(report.Largest) > 0 {
+		r.p.println()
+		r.p.println("Largest files")
+		r.p.println()
+		r.table(largestTable(report))
+	}
+	return r.p.err
+}
+
+func (r *plainRenderer) History(reports []history.Report) error {
+	r.p.err = nil
+	r.p.println("Usage history")
+	r.p.println()
+	r.table(historyTable(reports))
+	if note := historyNote(reports); note != "" {
+		r.p.println()
+		r.p.println(note)
+	}
+	return r.p.err
+}
+
+func (r *plainRenderer) Reclaimable(report cleanup.Report, groups []disk.DriveGroup) error {
+	r.p.err = nil
+	r.p.println("Reclaimable space")
+	r.p.println()
+	r.p.printf("%s could be freed\n", FormatBytes(report.Total))
+	found, _ := reclaimGroups(report, groups)
+	for _, rg := range found {
+		usedPercent := percent(rg.group.TotalUsed, rg.group.TotalSize)
+		r.p.println()
+		r.p.printf("%s (%s)\n", rg.group.Name, rg.group.Description)
+		r.p.printf("  Space: %s free of %s, %s could be freed\n",
+			FormatBytes(rg.group.Available), FormatBytes(rg.group.TotalSize), FormatBytes(rg.bytes))
+		r.p.printf("  %s %.1f%%\n", reclaimBar(usedPercent, percent(rg.bytes, rg.group.TotalSize), 40), usedPercent)
+	}
+	if len(report.Items) == 0 {
+		return r.p.err
+	}
+	r.p.println()
+	r.table(reclaimTable(report))
+	r.p.println()
+	for _, note := range reclaimNotes(report) {
+		r.p.printf("%s: %s\n", note[0], note[1])
+	}
+	return r.p.err
+}
+
+func (r *plainRenderer) Cleanup(results []cleanup.Result) error {
+	r.p.err = nil
+	r.p.println("Cleanup")
+	r.p.println()
+	r.table(cleanupTable(results))
+	for _, res := range results {
+		if !res.DryRun || len(res.Steps) == 0 || res.Skipped != "" {
+			continue
+		}
+		r.p.println()
+		r.p.printf("%s:\n", res.Item.Name)
+		for _, step := range cleanupSteps(res) {
+			r.p.printf("  %s\n", step)
+		}
+	}
+	r.p.println()
+	r.p.println(cleanupSummary(results))
+	return r.p.err
+}
+
+func (r *plainRenderer) Trash(items []disk.TrashItem) error {
+	r.p.err = nil
+	r.p.println("Trash")
+	r.p.println()
+	if len(items) > 0 {
+		r.table(trashTable(items))
+		r.p.println()
+	}
+	r.p.println(trashSummary(items))
+	return r.p.err
+}
+
+func (r *plainRenderer) Duplicates(report dupes.Report) error {
+	r.p.err = nil
+	r.p.println("Duplicate files")
+	r.p.println()
+	r.p.println(dupesSummary(report))
+	for _, g := range report.Groups {
+		r.p.println()
+		r.p.printf("%s (sha256 %s)\n", dupesHeading(g), g.SHA256)
+		for _, f := range g.Files {
+			r.p.printf("  %s\n", dupesPath(f))
+		}
+	}
+	if more := dupesMore(report); more != "" {
+		r.p.println()
+		r.p.println(more)
+	}
+	return r.p.err
+}
+
+func (r *plainRenderer) Dedupe(results []dupes.Result) error {
+	r.p.err = nil
+	r.p.println("Duplicates")
+	r.p.println()
+	r.table(dedupeTable(results))
+	r.p.println()
+	r.p.println(dedupeSummary(results))
+	return r.p.err
+}
+
+func (r *plainRenderer) Files(report search.Report) error {
+	r.p.err = nil
+	r.p.println("Large and stale files")
+	r.p.println()
+	r.p.println(filesSummary(report))
+	if criteria := filesCriteria(report); criteria != "" {
+		r.p.println(criteria)
+	}
+	if len(report.Files) == 0 {
+		return r.p.err
+	}
+	r.p.println()
+	r.table(filesTable(report))
+	r.p.println()
+	r.table(fileGroupTable("Folder", report.ByDir))
+	r.p.println()
+	r.table(fileGroupTable("Type", report.ByType))
+	return r.p.err
+}
+
+func (r *plainRenderer) FileTypes(drives []filetypes.Drive) error {
+	r.p.err = nil
+	r.p.println("Space by file type")
+	for _, d := range drives {
+		r.p.println()
+		r.p.println(d.Name)
+		r.p.printf("  %s\n", fileTypesSummary(d))
+		r.p.printf("  %s", typeBar(d, 40))
+		if d.SizeBytes > 0 {
+			r.p.printf(" %.1f%%", percent(d.UsedBytes, d.SizeBytes))
+		}
+		r.p.printf("\n  %s\n\n", typeLegend(d))
+		r.table(fileTypesTable(d))
+	}
+	return r.p.err
+}
+
+func (r *plainRenderer) Snapshots(snaps []snapshot.Snapshot) error {
+	r.p.err = nil
+	r.p.println("Snapshots")
+	r.p.println()
+	if len(snaps) > 0 {
+		r.table(snapshotTable(snaps))
+		r.p.println()
+	}
+	r.p.println(snapshotSummary(snaps))
+	return r.p.err
+}
+
+func (r *plainRenderer) Diff(d snapshot.Diff) error {
+	r.p.err = nil
+	r.p.println("What changed")
+	r.p.println()
+	r.p.println(diffSummary(d))
+	if len(d.Changes) > 0 {
+		r.p.println()
+		r.table(diffTable(d))
+	}
+	if more := diffMore(d); more != "" {
+		r.p.println(more)
+	}
+	return r.p.err
+}
+
+func (r *plainRenderer) Doctor(report doctor.Report) error {
+	r.p.err = nil
+	r.p.println("Doctor")
+	r.p.println()
+	r.table(doctorTable(report))
+	for _, f := range doctorFindings(report) {
+		r.p.println()
+		r.p.printf("%s %s: %s\n", f.Level, f.Subject, f.Problem)
+		r.p.printf("  Fix: %s\n", f.Fix)
+	}
+	r.p.println()
+	r.p.println(doctorSummary(report))
+	return r.p.err
+}
+
+func (r *plainRenderer) usage(used, size, available uint64) {
+	usedPercent := percent(used, size)
+	r.p.printf("  Space: %s free of %s\n", FormatBytes(available), FormatBytes(size))
+	r.p.printf("  %s %.1f%%\n", textBar(usedPercent, 40), usedPercent)
+}
+
+func (r *plainRenderer) table(header []string, rows [][]string) {
+	tw := tabwriter.NewWriter(r.p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil && r.p.err == nil {
+		r.p.err = err
+	}
+}
+
+// textBar draws a progress bar with ASCII characters only
+func textBar(usedPercent float64, width int) string {
+	return reclaimBar(usedPercent, 0, width)
+}
+
+// reclaimBar is a textBar marking the reclaimable part of the used space
+// with "="
+func reclaimBar(usedPercent, reclaimPercent float64, width int) string {
+	filled, reclaim := BarCells(usedPercent, reclaimPercent, width)
+	return "[" + strings.Repeat("#", filled-reclaim) + strings.Repeat("=", reclaim) + strings.Repeat(".", width-filled) + "]"
+}
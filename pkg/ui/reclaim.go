@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/disk"
+)
+
+func (t *terminalRenderer) Reclaimable(report cleanup.Report, groups []disk.DriveGroup) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("🧹 Reclaimable space"))
+	if len(report.Items) == 0 {
+		t.p.println(t.st.driveDesc.Render("Nothing to clean up: no caches, trash or old logs worth mentioning."))
+		return t.p.err
+	}
+	t.p.printf("%s could be freed in total.\n", t.st.available.Render(FormatBytes(report.Total)))
+
+	box, _ := t.cardStyle()
+	inner := box.GetWidth() - box.GetHorizontalPadding()
+	found, other := reclaimGroups(report, groups)
+	for _, rg := range found {
+		g := rg.group
+		content := fmt.Sprintf("%s %s %s\n\n",
+			t.st.driveIcon.Render(g.Icon),
+			t.st.driveName.Render(g.Name),
+			t.st.driveDesc.Render(fmt.Sprintf("(%s)", g.Description)))
+		usedPercent := percent(g.TotalUsed, g.TotalSize)
+		content += fmt.Sprintf("📊 Space: %s free of %s, %s could be freed\n\n",
+			t.st.available.Render(FormatBytes(g.Available)),
+			t.st.size.Render(FormatBytes(g.TotalSize)),
+			t.st.progressBarReclaimable.UnsetBackground().Render(FormatBytes(rg.bytes)))
+		content += t.createSegmentedBar(usedPercent, percent(rg.bytes, g.TotalSize), barWidth(box), t.alertStyle(g.Alert)) +
+			fmt.Sprintf(" %.1f%%\n", usedPercent)
+		content += t.alertStyle(g.Alert).Render("█") + t.st.driveDesc.Render(" used  ") +
+			t.st.progressBarReclaimable.Render("▓") + t.st.driveDesc.Render(" could be freed  ") +
+			t.st.progressBarEmpty.Render("░") + t.st.driveDesc.Render(" free") + "\n"
+		content += t.reclaimItems(rg.items, inner)
+		t.p.println(box.Render(strings.TrimRight(content, "\n")))
+	}
+	if len(other) > 0 {
+		content := t.st.driveName.Render("📁 Elsewhere") + "\n"
+		content += t.reclaimItems(other, inner)
+		t.p.println(box.Render(strings.TrimRight(content, "\n")))
+	}
+	return t.p.err
+}
+
+// reclaimItems lists items with their size and explanation
+func (t *terminalRenderer) reclaimItems(items []cleanup.Item, inner int) string {
+	explain := t.st.driveDesc.Width(inner).PaddingLeft(3)
+	content := ""
+	for _, item := range items {
+		size := FormatBytes(item.Bytes)
+		name := item.Name
+		if item.NeedsRoot {
+			name += " 🔒"
+		}
+		content += fmt.Sprintf("\n• %s %s\n", truncatePath(name, inner-3-len(size)), t.st.size.Render(size))
+		explanation := item.Explanation
+		if item.NeedsRoot {
+			explanation += " 🔒 Needs administrator rights."
+		}
+		content += explain.Render(explanation) + "\n"
+	}
+	return content
+}
+
+func (t *terminalRenderer) Cleanup(results []cleanup.Result) error {
+	t.p.err = nil
+	title := "🧹 Cleanup"
+	if len(results) > 0 && results[0].DryRun {
+		title += " preview (nothing was changed)"
+	}
+	t.p.println(t.st.title.Render(title))
+	for _, r := range results {
+		icon, style := "✅", t.st.available
+		switch {
+		case r.Error != "":
+			icon, style = "❌", t.st.used
+		case r.Skipped != "":
+			icon, style = "⏭️ ", t.st.driveDesc
+		case r.DryRun:
+			icon, style = "🔍", t.st.plain
+		}
+		size := ""
+		if r.Freed > 0 {
+			size += " " + t.st.size.Render(FormatBytes(r.Freed))
+		}
+		if r.Trashed > 0 {
+			size += " " + t.st.size.Render(FormatBytes(r.Trashed)+" to the trash")
+		}
+		t.p.printf("%s %s%s\n", icon, style.Render(r.Item.Name), size)
+		switch {
+		case r.Error != "" || r.Skipped != "":
+			t.p.println(style.PaddingLeft(3).Render(cleanupStatus(r)))
+		case r.DryRun:
+			for _, step := range cleanupSteps(r) {
+				t.p.println(t.st.driveDesc.PaddingLeft(3).Render(truncatePath(step, t.width-3)))
+			}
+		}
+	}
+	t.p.println()
+	t.p.println(t.st.available.Render(cleanupSummary(results)))
+	return t.p.err
+}
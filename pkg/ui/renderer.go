@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/doctor"
+	"checkpoint/pkg/dupes"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/search"
+	"checkpoint/pkg/snapshot"
+)
+
+// Renderer draws checkpoint views to the writer it was created with
+type Renderer interface {
+	Disks(disks []disk.Disk, showDetails bool) error
+	FriendlyDisks(groups []disk.DriveGroup) error
+	SimpleDiskList(groups []disk.DriveGroup) error
+	Summary(stats disk.DiskStats, disks []disk.Disk) error
+	PathUsage(path string, d disk.Disk) error
+	DirUsage(report analyzer.Report) error
+	History(reports []history.Report) error
+	Reclaimable(report cleanup.Report, groups []disk.DriveGroup) error
+	Cleanup(results []cleanup.Result) error
+	Trash(items []disk.TrashItem) error
+	Duplicates(report dupes.Report) error
+	Dedupe(results []dupes.Result) error
+	Files(report search.Report) error
+	FileTypes(drives []filetypes.Drive) error
+	Snapshots(snaps []snapshot.Snapshot) error
+	Diff(d snapshot.Diff) error
+	Doctor(report doctor.Report) error
+}
+
+// DefaultWidth is used when the terminal width is unknown
+const DefaultWidth = 80
+
+// Options configures the terminal renderer
+type Options struct {
+	// Width is the terminal width in columns, 0 means DefaultWidth
+	Width int
+	// Profile is the colour profile, termenv.Ascii disables colour
+	Profile termenv.Profile
+	// History holds usage samples by mount point; drive cards whose first
+	// mount point has some show a sparkline of the last month
+	History map[string][]history.Sample
+	// Reclaimable holds the bytes that could be freed by mount point; drive
+	// cards show them as a segment of the used space
+	Reclaimable map[string]uint64
+	// Trash holds the bytes in the user's trash by the first mount point of
+	// a group; drive cards show them when there are any
+	Trash map[string]uint64
+	// FileTypes holds the space each file category takes by the first
+	// mount point of a group; drive cards show it as a stacked bar
+	FileTypes map[string]filetypes.Drive
+}
+
+// DetectOptions reads the width and colour profile of a terminal
+func DetectOptions(f *os.File) Options {
+	opts := Options{
+		Width:   DefaultWidth,
+		Profile: lipgloss.NewRenderer(f).ColorProfile(),
+	}
+	if w, _, err := term.GetSize(f.Fd()); err == nil && w > 0 {
+		opts.Width = w
+	}
+	return opts
+}
+
+// NewRenderer returns the renderer for a format name:
+// text (terminal), plain, markdown or html
+func NewRenderer(format string, w io.Writer, opts Options) (Renderer, error) {
+	switch format {
+	case "text", "terminal", "":
+		return NewTerminalRenderer(w, opts), nil
+	case "plain":
+		return NewPlainRenderer(w), nil
+	case "markdown":
+		return NewMarkdownRenderer(w), nil
+	case "html":
+		return NewHTMLRenderer(w), nil
+	default:
+		return nil, fmt.Errorf("unknown renderer %q", format)
+	}
+}
+
+// stdoutRenderer backs the Display* helpers
+func stdoutRenderer() Renderer {
+	return NewTerminalRenderer(os.Stdout, DetectOptions(os.Stdout))
+}
+
+// printer remembers the first write error so views can print freely
+type printer struct {
+	w   io.Writer
+	err error
+}
+
+func (p *printer) print(a ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprint(p.w, a...)
+	}
+}
+
+func (p *printer) println(a ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintln(p.w, a...)
+	}
+}
+
+func (p *printer) printf(format string, a ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, a...)
+	}
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"fmt"
+
+	"checkpoint/pkg/snapshot"
+)
+
+func (t *terminalRenderer) Snapshots(snaps []snapshot.Snapshot) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("📸 Snapshots"))
+	_, rows := snapshotTable(snaps)
+	for _, row := range rows {
+		note := ""
+		if row[4] != "" {
+			note = " " + t.st.used.Render(row[4])
+		}
+		t.p.printf("%s  %s%s\n", t.st.driveName.Render(row[0]), t.st.size.Render(row[3]), note)
+		t.p.println(t.st.driveDesc.PaddingLeft(3).Render(truncatePath(row[1]+" · taken "+row[2], t.width-3)))
+	}
+	if len(snaps) > 0 {
+		t.p.println()
+	}
+	t.p.println(t.st.available.Render(snapshotSummary(snaps)))
+	return t.p.err
+}
+
+func (t *terminalRenderer) Diff(d snapshot.Diff) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("🕒 What changed"))
+	t.p.println(t.st.driveDesc.Width(t.width).Render(diffSummary(d)))
+	if len(d.Changes) > 0 {
+		t.p.println()
+	}
+	for _, c := range d.Changes {
+		// Growth is what fills a disk, so it stands out
+		style := t.st.available
+		if c.Delta > 0 {
+			style = t.st.used
+		}
+		delta := fmt.Sprintf("%10s", FormatDelta(c.Delta))
+		status := fmt.Sprintf("  %-8s ", c.Status)
+		t.p.printf("%s%s%s\n", style.Render(delta), t.st.driveDesc.Render(status),
+			truncatePath(diffPath(c), t.width-len(delta)-len(status)))
+	}
+	if more := diffMore(d); more != "" {
+		t.p.println(t.st.driveDesc.Render(more))
+	}
+	return t.p.err
+}
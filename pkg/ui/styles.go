@@ -0,0 +1,157 @@
+package ui
+
+import "github.com/charmbracelet/lipgloss"
+
+// styles holds every lipgloss style used by the terminal renderer. They are
+// created from one lipgloss.Renderer so the colour profile can be injected
+// instead of being detected from stdout.
+type styles struct {
+	title     lipgloss.Style
+	header    lipgloss.Style
+	row       lipgloss.Style
+	evenRow   lipgloss.Style
+	diskType  lipgloss.Style
+	symlink   lipgloss.Style
+	hardlink  lipgloss.Style
+	size      lipgloss.Style
+	available lipgloss.Style
+	used      lipgloss.Style
+	inode     lipgloss.Style
+	menu      lipgloss.Style
+	menuItem  lipgloss.Style
+	prompt    lipgloss.Style
+	plain     lipgloss.Style
+
+	driveBox         lipgloss.Style
+	driveName        lipgloss.Style
+	driveIcon        lipgloss.Style
+	driveDesc        lipgloss.Style
+	progressBarFull  lipgloss.Style
+	progressBarEmpty lipgloss.Style
+	// progressBarWarning and progressBarCritical fill the bars of drives
+	// past their thresholds
+	progressBarWarning  lipgloss.Style
+	progressBarCritical lipgloss.Style
+	// progressBarReclaimable marks the used space that could be freed
+	progressBarReclaimable lipgloss.Style
+
+	summaryBox   lipgloss.Style
+	summaryTitle lipgloss.Style
+	summaryItem  lipgloss.Style
+	summaryValue lipgloss.Style
+}
+
+func newStyles(r *lipgloss.Renderer) styles {
+	s := styles{
+		title: r.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("86")).
+			MarginTop(1).
+			MarginBottom(1),
+
+		header: r.NewStyle().
+			Bold(true).
+			PaddingRight(cellPadding).
+			Foreground(lipgloss.Color("99")).
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderBottom(true).
+			BorderForeground(lipgloss.Color("241")),
+
+		row: r.NewStyle().
+			PaddingRight(cellPadding),
+
+		diskType: r.NewStyle().
+			Foreground(lipgloss.Color("214")),
+
+		symlink: r.NewStyle().
+			Foreground(lipgloss.Color("81")).
+			Italic(true),
+
+		hardlink: r.NewStyle().
+			Foreground(lipgloss.Color("213")),
+
+		size: r.NewStyle().
+			Foreground(lipgloss.Color("86")),
+
+		available: r.NewStyle().
+			Foreground(lipgloss.Color("82")),
+
+		used: r.NewStyle().
+			Foreground(lipgloss.Color("203")),
+
+		inode: r.NewStyle().
+			Foreground(lipgloss.Color("241")),
+
+		menu: r.NewStyle().
+			Foreground(lipgloss.Color("255")).
+			MarginTop(1),
+
+		menuItem: r.NewStyle().
+			Foreground(lipgloss.Color("86")),
+
+		prompt: r.NewStyle().
+			Foreground(lipgloss.Color("214")).
+			Bold(true),
+
+		plain: r.NewStyle(),
+
+		driveBox: r.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("241")).
+			Padding(1).
+			Margin(1, 0).
+			Width(60),
+
+		driveName: r.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("86")),
+
+		driveIcon: r.NewStyle().
+			Foreground(lipgloss.Color("214")),
+
+		driveDesc: r.NewStyle().
+			Foreground(lipgloss.Color("245")).
+			Italic(true),
+
+		progressBarFull: r.NewStyle().
+			Background(lipgloss.Color("86")).
+			Foreground(lipgloss.Color("16")),
+
+		progressBarWarning: r.NewStyle().
+			Background(lipgloss.Color("214")).
+			Foreground(lipgloss.Color("214")),
+
+		progressBarCritical: r.NewStyle().
+			Background(lipgloss.Color("196")).
+			Foreground(lipgloss.Color("196")),
+
+		progressBarReclaimable: r.NewStyle().
+			Background(lipgloss.Color("141")).
+			Foreground(lipgloss.Color("141")),
+
+		progressBarEmpty: r.NewStyle().
+			Background(lipgloss.Color("238")).
+			Foreground(lipgloss.Color("238")),
+
+		summaryBox: r.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("86")).
+			Padding(1, 2).
+			MarginTop(1).
+			MarginBottom(1),
+
+		summaryTitle: r.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("214")).
+			MarginBottom(1),
+
+		summaryItem: r.NewStyle().
+			Foreground(lipgloss.Color("255")),
+
+		summaryValue: r.NewStyle().
+			Foreground(lipgloss.Color("86")).
+			Bold(true),
+	}
+	s.evenRow = s.row.Background(lipgloss.Color("235"))
+	return s
+}
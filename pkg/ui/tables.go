@@ -0,0 +1,895 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"checkpoint/pkg/analyzer"
+	"checkpoint/pkg/cleanup"
+	"checkpoint/pkg/disk"
+	"checkpoint/pkg/doctor"
+	"checkpoint/pkg/dupes"
+	"checkpoint/pkg/filetypes"
+	"checkpoint/pkg/history"
+	"checkpoint/pkg/search"
+	"checkpoint/pkg/snapshot"
+)
+
+// The helpers below produce uncoloured cells shared by the plain, markdown
+// and HTML renderers, so every report format lists the same columns.
+
+// diskTable returns the technical table as header and rows
+func diskTable(disks []disk.Disk, showDetails bool) ([]string, [][]string) {
+	rows := [][]string{}
+	if !showDetails {
+		header := []string{"ID", "Device", "Type", "Size", "Available", "Mount"}
+		for _, d := range disks {
+			if d.Type == disk.TypeSymlink {
+				continue
+			}
+			rows = append(rows, []string{
+				fmt.Sprintf("%d", len(rows)+1),
+				d.Path,
+				string(d.Type),
+				FormatBytes(d.Size),
+				FormatBytes(d.Available),
+				d.MountPoint,
+			})
+		}
+		return header, rows
+	}
+
+	header := []string{"ID", "Device", "Type", "FS", "Size", "Used", "Available", "Inode", "Mount"}
+	hardlinks := hardlinkIndex(disks)
+	for i, d := range disks {
+		device := d.Path
+		if d.IsSymlink && d.LinkTarget != "" {
+			device = fmt.Sprintf("%s → %s", d.Path, d.LinkTarget)
+		}
+		typeStr := string(d.Type)
+		if links := hardlinks[d.Inode]; len(links) > 1 && d.Type != disk.TypeSymlink {
+			typeStr += " (hard link)"
+		}
+		inode := "-"
+		if d.Inode > 0 {
+			inode = fmt.Sprintf("%d", d.Inode)
+		}
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", i+1),
+			device,
+			typeStr,
+			d.Filesystem,
+			FormatBytes(d.Size),
+			FormatBytes(d.Used),
+			FormatBytes(d.Available),
+			inode,
+			d.MountPoint,
+		})
+	}
+	return header, rows
+}
+
+// groupTable returns the simple drive list as header and rows
+func groupTable(groups []disk.DriveGroup) ([]string, [][]string) {
+	header := []string{"ID", "Name", "Size", "Free", "Used", "Type"}
+	rows := make([][]string, 0, len(groups))
+	for i, g := range groups {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", i+1),
+			g.Name,
+			FormatBytes(g.TotalSize),
+			FormatBytes(g.Available),
+			fmt.Sprintf("%.1f%%", percent(g.TotalUsed, g.TotalSize)),
+			g.Description,
+		})
+	}
+	return header, rows
+}
+
+// summaryRows returns the label/value lines of the storage summary
+func summaryRows(stats disk.DiskStats) [][2]string {
+	rows := [][2]string{
+		{"Total Disks", fmt.Sprintf("%d", stats.TotalDisks)},
+		{"Total Capacity", FormatBytes(stats.TotalSize)},
+		{"Used Space", fmt.Sprintf("%s (%.1f%%)", FormatBytes(stats.TotalUsed), percent(stats.TotalUsed, stats.TotalSize))},
+		{"Available", FormatBytes(stats.TotalAvailable)},
+	}
+
+	types := make([]string, 0, len(stats.DisksByType))
+	for t := range stats.DisksByType {
+		if t != disk.TypeSymlink {
+			types = append(types, string(t))
+		}
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		rows = append(rows, [2]string{"Type " + t, fmt.Sprintf("%d", stats.DisksByType[disk.DiskType(t)])})
+	}
+
+	if len(stats.Hardlinks) > 0 {
+		rows = append(rows, [2]string{"Hard link groups", fmt.Sprintf("%d", len(stats.Hardlinks))})
+	}
+	if len(stats.Symlinks) > 0 {
+		rows = append(rows, [2]string{"Symbolic links", fmt.Sprintf("%d", len(stats.Symlinks))})
+	}
+	return rows
+}
+
+// mainDiskLine describes one entry of the "Main Storage" list
+func mainDiskLine(d disk.Disk) string {
+	return fmt.Sprintf("%s: %s (%s free, %.0f%% used)",
+		d.Path, FormatBytes(d.Size), FormatBytes(d.Available), percent(d.Used, d.Size))
+}
+
+// groupLocations lists the mount points of a drive group
+func groupLocations(group disk.DriveGroup) []string {
+	locations := make([]string, 0, len(group.Disks))
+	for _, d := range group.Disks {
+		if len(group.Disks) == 1 {
+			locations = append(locations, d.MountPoint)
+		} else {
+			locations = append(locations, fmt.Sprintf("%s (%s)", d.MountPoint, FormatBytes(d.Size)))
+		}
+	}
+	return locations
+}
+
+// usageTable returns the entries of a directory usage report as header and
+// rows, showing both the space used on disk and the apparent size
+func usageTable(r analyzer.Report) ([]string, [][]string) {
+	header := []string{"Disk Usage", "Apparent", "Share", "Items", "Name"}
+	rows := make([][]string, 0, len(r.Entries))
+	for _, e := range r.Entries {
+		rows = append(rows, []string{
+			FormatBytes(e.AllocatedBytes),
+			FormatBytes(e.ApparentBytes),
+			fmt.Sprintf("%.1f%%", percent(e.Size(r.Apparent), r.Size())),
+			fmt.Sprintf("%d", e.Items),
+			entryName(e),
+		})
+	}
+	return header, rows
+}
+
+// largestTable returns the largest files of a usage report
+func largestTable(r analyzer.Report) ([]string, [][]string) {
+	header := []string{"Size", "Path"}
+	rows := make([][]string, 0, len(r.Largest))
+	for _, e := range r.Largest {
+		rows = append(rows, []string{FormatBytes(e.Size(r.Apparent)), e.Path})
+	}
+	return header, rows
+}
+
+// usageSummary describes the totals of a usage report in one line
+func usageSummary(r analyzer.Report) string {
+	line := fmt.Sprintf("%s on disk (%s apparent) in %d files and %d folders, scanned in %.1fs",
+		FormatBytes(r.AllocatedBytes), FormatBytes(r.ApparentBytes), r.Files, r.Dirs, r.DurationSeconds)
+	if r.Errors > 0 {
+		line += fmt.Sprintf(", %d unreadable", r.Errors)
+	}
+	if r.Partial {
+		line += " (cancelled, partial results)"
+	}
+	return line
+}
+
+// entryName is the last path element, with a slash for directories and a
+// note for entries that were not measured
+func entryName(e analyzer.Entry) string {
+	name := filepath.Base(e.Path)
+	if e.IsDir {
+		name += "/"
+	}
+	switch {
+	case e.OtherFilesystem:
+		name += " (other filesystem)"
+	case e.Error != "":
+		name += " (unreadable)"
+	}
+	return name
+}
+
+// historyTable returns how much each filesystem grew over the last day,
+// week and month as header and rows
+func historyTable(reports []history.Report) ([]string, [][]string) {
+	header := []string{"Mount", "Used", "Day", "Week", "Month", "Since", "Forecast"}
+	rows := make([][]string, 0, len(reports))
+	for _, r := range reports {
+		used, since := "-", "-"
+		if n := len(r.Samples); n > 0 {
+			used = FormatBytes(r.Samples[n-1].Used)
+			since = r.Samples[0].Time.Format("2006-01-02")
+		}
+		rows = append(rows, []string{
+			r.MountPoint,
+			used,
+			changeCell(r.Day),
+			changeCell(r.Week),
+			changeCell(r.Month),
+			since,
+			forecastCell(r.Forecast),
+		})
+	}
+	return header, rows
+}
+
+// changeCell shows a change, marking those that cover less than the period
+func changeCell(c *history.Change) string {
+	if c == nil {
+		return "-"
+	}
+	if c.Partial {
+		return FormatChange(c.Bytes) + "*"
+	}
+	return FormatChange(c.Bytes)
+}
+
+// forecastCell shows when a filesystem will be full, such as "~23 days (high)"
+func forecastCell(f *disk.Forecast) string {
+	switch {
+	case f == nil:
+		return "-"
+	case f.Trend != disk.TrendGrowing:
+		return f.Trend
+	case f.DaysToFull > 365:
+		return fmt.Sprintf("over a year (%s)", f.Confidence)
+	}
+	return fmt.Sprintf("~%.0f days (%s)", f.DaysToFull, f.Confidence)
+}
+
+// historyNote explains the marker changeCell adds, or is empty when no
+// change needs it
+func historyNote(reports []history.Report) string {
+	for _, r := range reports {
+		for _, c := range []*history.Change{r.Day, r.Week, r.Month} {
+			if c != nil && c.Partial {
+				return "* history does not reach back the whole period yet"
+			}
+		}
+	}
+	return ""
+}
+
+// reclaimTable returns the reclaimable items as header and rows
+func reclaimTable(r cleanup.Report) ([]string, [][]string) {
+	header := []string{"Item", "Drive", "Size", "Files", "Admin", "Freed by"}
+	rows := make([][]string, 0, len(r.Items))
+	for _, item := range r.Items {
+		admin := "no"
+		if item.NeedsRoot {
+			admin = "yes"
+		}
+		mount := item.MountPoint
+		if mount == "" {
+			mount = "-"
+		}
+		rows = append(rows, []string{
+			item.Name,
+			mount,
+			FormatBytes(item.Bytes),
+			fmt.Sprintf("%d", item.Files),
+			admin,
+			freedBy(item),
+		})
+	}
+	return header, rows
+}
+
+// freedBy says how an item's space is freed
+func freedBy(item cleanup.Item) string {
+	if len(item.Commands) == 0 {
+		return "deleting the files"
+	}
+	commands := make([]string, 0, len(item.Commands))
+	for _, c := range item.Commands {
+		commands = append(commands, strings.Join(c, " "))
+	}
+	if len(commands) > 2 {
+		return fmt.Sprintf("%s and %d more", commands[0], len(commands)-1)
+	}
+	return strings.Join(commands, "; ")
+}
+
+// reclaimNotes are the explanations of the items, once per kind of item
+func reclaimNotes(r cleanup.Report) [][2]string {
+	var notes [][2]string
+	seen := map[string]bool{}
+	for _, item := range r.Items {
+		if !seen[item.Name] {
+			seen[item.Name] = true
+			notes = append(notes, [2]string{item.Name, item.Explanation})
+		}
+	}
+	return notes
+}
+
+// reclaimGroup is a drive with the space that could be freed on it
+type reclaimGroup struct {
+	group disk.DriveGroup
+	items []cleanup.Item
+	bytes uint64
+}
+
+// reclaimGroups matches the items to the drives holding them, leaving out
+// drives with nothing to free. Items on filesystems that were not scanned
+// are returned apart.
+func reclaimGroups(r cleanup.Report, groups []disk.DriveGroup) ([]reclaimGroup, []cleanup.Item) {
+	var found []reclaimGroup
+	matched := map[string]bool{}
+	for _, g := range groups {
+		mounts := make([]string, 0, len(g.Disks))
+		for _, d := range g.Disks {
+			mounts = append(mounts, d.MountPoint)
+			matched[d.MountPoint] = true
+		}
+		if items, bytes := r.ForMounts(mounts...); len(items) > 0 {
+			found = append(found, reclaimGroup{g, items, bytes})
+		}
+	}
+	var other []cleanup.Item
+	for _, item := range r.Items {
+		if !matched[item.MountPoint] {
+			other = append(other, item)
+		}
+	}
+	return found, other
+}
+
+// cleanupTable returns what cleaning up did, or would do, as header and rows
+func cleanupTable(results []cleanup.Result) ([]string, [][]string) {
+	header := []string{"Item", "Drive", "Freed", "To trash", "Status"}
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		mount := r.Item.MountPoint
+		if mount == "" {
+			mount = "-"
+		}
+		rows = append(rows, []string{
+			r.Item.Name,
+			mount,
+			FormatBytes(r.Freed),
+			FormatBytes(r.Trashed),
+			cleanupStatus(r),
+		})
+	}
+	return header, rows
+}
+
+// cleanupStatus says how cleaning one item went
+func cleanupStatus(r cleanup.Result) string {
+	switch {
+	case r.Error != "":
+		return "failed: " + r.Error
+	case r.Skipped != "":
+		return "skipped: " + r.Skipped
+	case r.DryRun:
+		return "would run"
+	}
+	return "done"
+}
+
+// cleanupSummary totals the results in one sentence
+func cleanupSummary(results []cleanup.Result) string {
+	freed, trashed := cleanup.Totals(results)
+	return freedSummary(freed, trashed, len(results) > 0 && results[0].DryRun)
+}
+
+// freedSummary says how much space was freed and moved to the trash
+func freedSummary(freed, trashed uint64, dryRun bool) string {
+	verb, trashVerb := "Freed", "moved"
+	if dryRun {
+		verb, trashVerb = "Would free", "move"
+	}
+	summary := fmt.Sprintf("%s %s", verb, FormatBytes(freed))
+	if trashed > 0 {
+		summary += fmt.Sprintf(" and %s %s to the trash, freed once it is emptied", trashVerb, FormatBytes(trashed))
+	}
+	return summary + "."
+}
+
+// maxSteps bounds the steps listed per item
+const maxSteps = 5
+
+// cleanupSteps lists the steps of an item, the first few of a long list
+func cleanupSteps(r cleanup.Result) []string {
+	if len(r.Steps) <= maxSteps {
+		return r.Steps
+	}
+	return append(r.Steps[:maxSteps:maxSteps], fmt.Sprintf("… and %d more", len(r.Steps)-maxSteps))
+}
+
+// trashTable returns the trashed items as header and rows
+func trashTable(items []disk.TrashItem) ([]string, [][]string) {
+	header := []string{"Name", "Original location", "Deleted", "Size", "Drive"}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		mount := item.MountPoint
+		if mount == "" {
+			mount = "-"
+		}
+		rows = append(rows, []string{
+			item.Name,
+			item.Path,
+			trashDate(item),
+			FormatBytes(item.Size),
+			mount,
+		})
+	}
+	return header, rows
+}
+
+// trashDate is when an item was deleted, to the minute
+func trashDate(item disk.TrashItem) string {
+	if item.DeletedAt.IsZero() {
+		return "unknown"
+	}
+	return item.DeletedAt.Format("2006-01-02 15:04")
+}
+
+// trashSummary counts the items and their size in one sentence
+func trashSummary(items []disk.TrashItem) string {
+	if len(items) == 0 {
+		return "The trash is empty."
+	}
+	var size uint64
+	for _, item := range items {
+		size += item.Size
+	}
+	noun := "items"
+	if len(items) == 1 {
+		noun = "item"
+	}
+	return fmt.Sprintf("%d %s, %s in the trash.", len(items), noun, FormatBytes(size))
+}
+
+// dupesSummary describes a duplicate search in one sentence
+func dupesSummary(r dupes.Report) string {
+	sets := "sets of duplicates waste"
+	if r.GroupCount == 1 {
+		sets = "set of duplicates wastes"
+	}
+	summary := fmt.Sprintf("%d %s %s, among %d files compared", r.GroupCount, sets, FormatBytes(r.Wasted), r.Files)
+	if r.Cached > 0 {
+		summary += fmt.Sprintf(" (%d hashes from the cache)", r.Cached)
+	}
+	if r.GroupCount == 0 {
+		summary = fmt.Sprintf("No duplicates among %d files compared", r.Files)
+	}
+	if r.Errors > 0 {
+		summary += fmt.Sprintf(", %d could not be read", r.Errors)
+	}
+	if r.Partial {
+		summary = "Stopped early: " + strings.ToLower(summary[:1]) + summary[1:]
+	}
+	return summary + "."
+}
+
+// dupesHeading describes one set of duplicates
+func dupesHeading(g dupes.Group) string {
+	return fmt.Sprintf("%d copies of %s, %s wasted", len(g.Files), FormatBytes(g.Size), FormatBytes(g.Wasted))
+}
+
+// dupesPath is a copy's path, with its further hard links counted
+func dupesPath(f dupes.File) string {
+	switch len(f.Links) {
+	case 0:
+		return f.Path
+	case 1:
+		return f.Path + " (+1 hard link)"
+	}
+	return fmt.Sprintf("%s (+%d hard links)", f.Path, len(f.Links))
+}
+
+// dupesMore notes the sets left out of a trimmed report
+func dupesMore(r dupes.Report) string {
+	if more := r.GroupCount - len(r.Groups); more > 0 {
+		return fmt.Sprintf("… and %d more sets, see --top", more)
+	}
+	return ""
+}
+
+// dedupeTable returns what was done with the duplicates as header and rows
+func dedupeTable(results []dupes.Result) ([]string, [][]string) {
+	header := []string{"Duplicate", "Kept", "Freed", "To trash", "Status"}
+	rows := make([][]string, 0, len(results))
+	for _, r := range results {
+		kept := r.Kept
+		if kept == "" {
+			kept = "-"
+		}
+		rows = append(rows, []string{r.Path, kept, FormatBytes(r.Freed), FormatBytes(r.Trashed), dedupeStatus(r)})
+	}
+	return header, rows
+}
+
+// dedupeStatus says how dealing with one duplicate went
+func dedupeStatus(r dupes.Result) string {
+	switch {
+	case r.Error != "":
+		return "failed: " + r.Error
+	case r.Skipped != "":
+		return "skipped: " + r.Skipped
+	case r.DryRun:
+		return "would " + dedupeVerb(r.Action)
+	}
+	return "done"
+}
+
+// dedupeVerb says what an action does to a duplicate
+func dedupeVerb(action dupes.Action) string {
+	switch action {
+	case dupes.ActionHardlink:
+		return "hard link"
+	case dupes.ActionReflink:
+		return "reflink"
+	}
+	return "move to trash"
+}
+
+// dedupeSummary totals the results in one sentence
+func dedupeSummary(results []dupes.Result) string {
+	freed, trashed := dupes.Totals(results)
+	return freedSummary(freed, trashed, len(results) > 0 && results[0].DryRun)
+}
+
+// filesSummary describes a large and stale file search in one sentence
+func filesSummary(r search.Report) string {
+	summary := fmt.Sprintf("Nothing found among %d files scanned", r.Scanned)
+	if r.FileCount > 0 {
+		summary = fmt.Sprintf("%s taking %s, among %d files scanned", fileCount(r.FileCount), FormatBytes(r.Bytes), r.Scanned)
+	}
+	if r.Errors > 0 {
+		summary += fmt.Sprintf(", %d could not be read", r.Errors)
+	}
+	if r.Partial {
+		summary = "Stopped early: " + summary
+	}
+	return summary + "."
+}
+
+// filesCriteria says what made a file large or stale
+func filesCriteria(r search.Report) string {
+	var parts []string
+	if r.MinSize > 0 {
+		parts = append(parts, "large files take at least "+FormatBytes(r.MinSize))
+	}
+	if r.StaleDays > 0 {
+		parts = append(parts, fmt.Sprintf("stale files were not read or modified for %d days", r.StaleDays))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	criteria := strings.Join(parts, "; ")
+	return strings.ToUpper(criteria[:1]) + criteria[1:] + "."
+}
+
+// filesTable returns the files as header and rows, numbered so they can
+// be picked by number
+func filesTable(r search.Report) ([]string, [][]string) {
+	header := []string{"#", "Size", "Last used", "Owner", "Found as", "Path"}
+	rows := make([][]string, 0, len(r.Files))
+	now := time.Now()
+	for i, f := range r.Files {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", i+1),
+			FormatBytes(f.Size),
+			formatAge(now.Sub(f.LastUsed())),
+			f.Owner,
+			foundAs(f),
+			f.Path,
+		})
+	}
+	return header, rows
+}
+
+// foundAs says why a file was reported
+func foundAs(f search.File) string {
+	switch {
+	case f.Large && f.Stale:
+		return "large, stale"
+	case f.Large:
+		return "large"
+	case f.Stale:
+		return "stale"
+	}
+	return "-"
+}
+
+// fileGroupTable returns directory or type totals as header and rows
+func fileGroupTable(title string, groups []search.Group) ([]string, [][]string) {
+	header := []string{title, "Files", "Size"}
+	rows := make([][]string, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, []string{fileGroupName(g), fmt.Sprintf("%d", g.Files), FormatBytes(g.Bytes)})
+	}
+	return header, rows
+}
+
+// fileGroupName names a group, the type group of files without extension
+// included
+func fileGroupName(g search.Group) string {
+	if g.Name == "" {
+		return "(no extension)"
+	}
+	return g.Name
+}
+
+// fileCount says how many files there are
+func fileCount(n int) string {
+	if n == 1 {
+		return "1 file"
+	}
+	return fmt.Sprintf("%d files", n)
+}
+
+// formatAge says how long ago something happened, roughly
+func formatAge(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	switch {
+	case days < 1:
+		return "today"
+	case days == 1:
+		return "yesterday"
+	case days < 60:
+		return fmt.Sprintf("%d days ago", days)
+	case days < 730:
+		return fmt.Sprintf("%d months ago", days/30)
+	}
+	return fmt.Sprintf("%d years ago", days/365)
+}
+
+// fileTypesSummary says how much the categories add up to on a drive
+func fileTypesSummary(d filetypes.Drive) string {
+	summary := fmt.Sprintf("%s in %s", FormatBytes(d.Bytes), fileCount(d.Files))
+	if d.UsedBytes > d.Bytes {
+		summary += fmt.Sprintf(", %s of the used space is filesystem data or was not readable", FormatBytes(d.UsedBytes-d.Bytes))
+	}
+	if d.Errors > 0 {
+		summary += fmt.Sprintf(", %d files or folders could not be read", d.Errors)
+	}
+	if d.Partial {
+		summary = "Stopped early: " + summary
+	}
+	return summary + "."
+}
+
+// fileTypesTable returns the categories of a drive as header and rows
+func fileTypesTable(d filetypes.Drive) ([]string, [][]string) {
+	header := []string{"Category", "Size", "Share", "Files"}
+	rows := make([][]string, 0, len(d.Categories))
+	for _, c := range d.Categories {
+		rows = append(rows, []string{c.Name, FormatBytes(c.Bytes), fmt.Sprintf("%.1f%%", percent(c.Bytes, d.Bytes)),
+			fmt.Sprintf("%d", c.Files)})
+	}
+	return header, rows
+}
+
+// typeSegment is the part of a stacked bar one category takes. The segment
+// without a name is used space no category accounts for.
+type typeSegment struct {
+	name, color string
+	cells       int
+}
+
+// typeSegments splits a bar width cells wide between the categories of a
+// drive, in proportion to the drive's size, or to the files found below a
+// path. Boundaries are rounded from running totals, so rounding never adds
+// up past the used space.
+func typeSegments(d filetypes.Drive, width int) []typeSegment {
+	scale := d.SizeBytes
+	if scale == 0 {
+		scale = d.Bytes
+	}
+	if scale == 0 {
+		return nil
+	}
+	cellsAt := func(bytes uint64) int {
+		if bytes > scale {
+			bytes = scale
+		}
+		return int(float64(bytes)/float64(scale)*float64(width) + 0.5)
+	}
+	var segments []typeSegment
+	var sum uint64
+	done := 0
+	for _, c := range d.Categories {
+		sum += c.Bytes
+		end := cellsAt(sum)
+		segments = append(segments, typeSegment{name: c.Name, color: c.Color, cells: end - done})
+		done = end
+	}
+	if d.UsedBytes > sum {
+		end := cellsAt(d.UsedBytes)
+		segments = append(segments, typeSegment{cells: end - done})
+	}
+	return segments
+}
+
+// typeMarks are the characters that tell categories apart in text bars,
+// one per category in the order of the drive's categories
+const typeMarks = "#*=+%@&$ox"
+
+// typeMark returns the text bar character of the i-th category
+func typeMark(i int) string {
+	return string(typeMarks[i%len(typeMarks)])
+}
+
+// typeBar is a text stacked bar, "-" marking used space no category
+// accounts for and "." free space
+func typeBar(d filetypes.Drive, width int) string {
+	bar, cells := "", 0
+	for i, seg := range typeSegments(d, width) {
+		mark := "-"
+		if seg.name != "" {
+			mark = typeMark(i)
+		}
+		bar += strings.Repeat(mark, seg.cells)
+		cells += seg.cells
+	}
+	return "[" + bar + strings.Repeat(".", width-cells) + "]"
+}
+
+// typeLegend explains the characters of typeBar
+func typeLegend(d filetypes.Drive) string {
+	parts := make([]string, 0, len(d.Categories))
+	for i, c := range d.Categories {
+		parts = append(parts, typeMark(i)+" "+c.Name)
+	}
+	if d.UsedBytes > d.Bytes {
+		parts = append(parts, "- not measured")
+	}
+	if d.SizeBytes > 0 {
+		parts = append(parts, ". free")
+	}
+	return strings.Join(parts, "  ")
+}
+
+// snapshotTable returns saved snapshots as header and rows
+func snapshotTable(snaps []snapshot.Snapshot) ([]string, [][]string) {
+	header := []string{"ID", "Path", "Taken", "Size", "Note"}
+	rows := make([][]string, 0, len(snaps))
+	for _, s := range snaps {
+		note := ""
+		if s.Partial {
+			note = "partial"
+		}
+		rows = append(rows, []string{s.ID, s.Root, s.Taken.Local().Format("2006-01-02 15:04"), FormatBytes(s.Size), note})
+	}
+	return header, rows
+}
+
+// snapshotSummary counts snapshots in one sentence
+func snapshotSummary(snaps []snapshot.Snapshot) string {
+	switch len(snaps) {
+	case 0:
+		return "No snapshots yet, save one with \"checkpoint snapshot save\"."
+	case 1:
+		return "1 snapshot."
+	}
+	return fmt.Sprintf("%d snapshots.", len(snaps))
+}
+
+// diffSummary says how the whole tree changed between the snapshots
+func diffSummary(d snapshot.Diff) string {
+	when := fmt.Sprintf("between %s and %s", d.From.Taken.Local().Format("2006-01-02 15:04"),
+		d.To.Taken.Local().Format("2006-01-02 15:04"))
+	var summary string
+	switch {
+	case d.Delta > 0:
+		summary = fmt.Sprintf("%s grew by %s %s, to %s", d.From.Root, FormatBytes(uint64(d.Delta)), when, FormatBytes(d.To.Size))
+	case d.Delta < 0:
+		summary = fmt.Sprintf("%s shrank by %s %s, to %s", d.From.Root, FormatBytes(uint64(-d.Delta)), when, FormatBytes(d.To.Size))
+	default:
+		summary = fmt.Sprintf("%s kept its size of %s %s", d.From.Root, FormatBytes(d.To.Size), when)
+	}
+	switch d.ChangeCount {
+	case 0:
+		summary += "; nothing of at least " + FormatBytes(max(d.From.MinSize, d.To.MinSize)) + " changed"
+	case 1:
+		summary += "; 1 change"
+	default:
+		summary += fmt.Sprintf("; %d changes", d.ChangeCount)
+	}
+	if d.From.Partial || d.To.Partial {
+		summary += ". One of the scans was stopped early, so some changes may be missing"
+	}
+	return summary + "."
+}
+
+// diffTable returns the changes as header and rows
+func diffTable(d snapshot.Diff) ([]string, [][]string) {
+	header := []string{"Change", "Before", "After", "Status", "Path"}
+	rows := make([][]string, 0, len(d.Changes))
+	for _, c := range d.Changes {
+		rows = append(rows, []string{FormatDelta(c.Delta), FormatBytes(c.Before), FormatBytes(c.After), c.Status, diffPath(c)})
+	}
+	return header, rows
+}
+
+// diffPath marks directories with a trailing slash
+func diffPath(c snapshot.Change) string {
+	if c.IsDir {
+		return strings.TrimSuffix(c.Path, "/") + "/"
+	}
+	return c.Path
+}
+
+// diffMore says how many changes were left out by --top
+func diffMore(d snapshot.Diff) string {
+	if more := d.ChangeCount - len(d.Changes); more > 0 {
+		return fmt.Sprintf("… and %d smaller changes, see --top", more)
+	}
+	return ""
+}
+
+// doctorStatus sums up the outcome of one check, e.g. "2 problems"
+func doctorStatus(res doctor.Result) string {
+	status := "ok"
+	switch len(res.Findings) {
+	case 0:
+		if res.Error != "" {
+			return "could not check: " + res.Error
+		}
+	case 1:
+		status = "1 problem"
+	default:
+		status = fmt.Sprintf("%d problems", len(res.Findings))
+	}
+	if res.Error != "" {
+		status += ", then stopped: " + res.Error
+	}
+	return status
+}
+
+// doctorSummary counts what the checks found in one sentence
+func doctorSummary(r doctor.Report) string {
+	failed := 0
+	for _, res := range r.Results {
+		if res.Error != "" {
+			failed++
+		}
+	}
+	var summary string
+	switch r.Problems {
+	case 0:
+		summary = "No problems found"
+		if len(r.Results) > 1 {
+			summary += fmt.Sprintf(" by %d checks", len(r.Results))
+		}
+	case 1:
+		summary = "1 problem found"
+	default:
+		summary = fmt.Sprintf("%d problems found", r.Problems)
+	}
+	switch failed {
+	case 0:
+	case 1:
+		summary += "; 1 check could not run"
+	default:
+		summary += fmt.Sprintf("; %d checks could not run", failed)
+	}
+	return summary + "."
+}
+
+// doctorTable returns the outcome of every check as header and rows
+func doctorTable(r doctor.Report) ([]string, [][]string) {
+	header := []string{"Check", "Title", "Result"}
+	rows := make([][]string, 0, len(r.Results))
+	for _, res := range r.Results {
+		rows = append(rows, []string{res.Check, res.Title, doctorStatus(res)})
+	}
+	return header, rows
+}
+
+// doctorFindings lists every finding with the check it came from
+func doctorFindings(r doctor.Report) []doctor.Finding {
+	var findings []doctor.Finding
+	for _, res := range r.Results {
+		findings = append(findings, res.Findings...)
+	}
+	return findings
+}
@@ -0,0 +1,34 @@
+package ui
+
+import (
+	"path/filepath"
+
+	"checkpoint/pkg/disk"
+)
+
+func (t *terminalRenderer) Trash(items []disk.TrashItem) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("🗑️  Trash"))
+	for _, item := range items {
+		icon := "📄"
+		if item.IsDir {
+			icon = "📁"
+		}
+		size := FormatBytes(item.Size)
+		t.p.printf("%s %s %s\n", icon, truncatePath(item.Path, t.width-4-len(size)), t.st.size.Render(size))
+		detail := "deleted " + trashDate(item)
+		if item.Name != filepath.Base(item.Path) {
+			// Restoring by path is ambiguous when the same path was deleted twice
+			detail += " · in the trash as " + item.Name
+		}
+		if item.MountPoint != "" {
+			detail += " · on " + item.MountPoint
+		}
+		t.p.println(t.st.driveDesc.PaddingLeft(3).Render(truncatePath(detail, t.width-3)))
+	}
+	if len(items) > 0 {
+		t.p.println()
+	}
+	t.p.println(t.st.available.Render(trashSummary(items)))
+	return t.p.err
+}
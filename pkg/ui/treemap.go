@@ -0,0 +1,351 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+	"github.com/rivo/uniseg"
+
+	"checkpoint/pkg/analyzer"
+)
+
+// TreemapItem is one rectangle of a treemap
+type TreemapItem struct {
+	Label string
+	Size  uint64
+}
+
+// TreemapRect is where an item landed, in terminal cells
+type TreemapRect struct {
+	Index      int // position in the items passed to LayoutTreemap
+	X, Y, W, H int
+}
+
+// TreemapOptions configures RenderTreemap
+type TreemapOptions struct {
+	// Profile selects filled colour blocks, or outlined boxes for
+	// termenv.Ascii so the map stays readable without colour
+	Profile termenv.Profile
+	// Selected is the index of the highlighted item, -1 for none
+	Selected int
+}
+
+// TreemapMax is how many items get their own rectangle before the rest
+// are folded into one
+const TreemapMax = 24
+
+// treemapPalette holds dark backgrounds that keep white labels readable
+var treemapPalette = []string{"24", "29", "54", "94", "23", "88", "60", "58", "25", "66", "53", "30"}
+
+// TopTreemapItems keeps the first max items, which must be sorted largest
+// first, and folds the rest into one "N more" item
+func TopTreemapItems(items []TreemapItem, max int) []TreemapItem {
+	if len(items) <= max {
+		return items
+	}
+	top := append([]TreemapItem(nil), items[:max]...)
+	var rest uint64
+	for _, it := range items[max:] {
+		rest += it.Size
+	}
+	return append(top, TreemapItem{Label: fmt.Sprintf("%d more", len(items)-max), Size: rest})
+}
+
+// WriteTreemap draws the entries of a report as a treemap height lines tall
+func WriteTreemap(w io.Writer, r analyzer.Report, height int, opts Options) error {
+	t := NewTerminalRenderer(w, opts).(*terminalRenderer)
+	t.p.println(t.st.title.Render("📂 " + truncatePath(r.Root, t.width-3)))
+	t.p.println(t.st.driveDesc.Render(usageSummary(r)))
+	t.p.println()
+
+	items := []TreemapItem{}
+	for _, e := range r.Entries {
+		items = append(items, TreemapItem{Label: entryName(e), Size: e.Size(r.Apparent)})
+	}
+	items = TopTreemapItems(items, TreemapMax)
+	rects := LayoutTreemap(items, t.width, height)
+	if len(rects) == 0 {
+		t.p.println(t.st.driveDesc.Render("Empty"))
+		return t.p.err
+	}
+	t.p.println(RenderTreemap(items, rects, t.width, height, TreemapOptions{Profile: opts.Profile, Selected: -1}))
+	return t.p.err
+}
+
+// LayoutTreemap places items, which must be sorted largest first, into a
+// width×height area with the squarified algorithm (Bruls, Huizing and van
+// Wijk), which keeps rectangles close to square so they are easy to compare.
+// Items too small to get a cell are left out.
+func LayoutTreemap(items []TreemapItem, width, height int) []TreemapRect {
+	var total float64
+	for _, it := range items {
+		total += float64(it.Size)
+	}
+	if width <= 0 || height <= 0 || total == 0 {
+		return nil
+	}
+
+	// Terminal cells are about twice as tall as wide, so lay out on a grid
+	// with doubled height to get rectangles that look square
+	area := float64(width) * float64(height) * 2
+	values := []float64{}
+	indexes := []int{}
+	for i, it := range items {
+		if it.Size > 0 {
+			values = append(values, float64(it.Size)/total*area)
+			indexes = append(indexes, i)
+		}
+	}
+
+	rects := []TreemapRect{}
+	for i, r := range squarify(values, frect{0, 0, float64(width), float64(height) * 2}) {
+		x0, x1 := int(math.Round(r.x)), int(math.Round(r.x+r.w))
+		y0, y1 := int(math.Round(r.y/2)), int(math.Round((r.y+r.h)/2))
+		if x1 > x0 && y1 > y0 {
+			rects = append(rects, TreemapRect{Index: indexes[i], X: x0, Y: y0, W: x1 - x0, H: y1 - y0})
+		}
+	}
+	return rects
+}
+
+type frect struct {
+	x, y, w, h float64
+}
+
+// squarify lays values out in rows along the shorter side of the remaining
+// area, adding items to a row while that improves its worst aspect ratio
+func squarify(values []float64, r frect) []frect {
+	out := make([]frect, 0, len(values))
+	for len(values) > 0 {
+		short := math.Min(r.w, r.h)
+		n := 1
+		for n < len(values) && worstRatio(values[:n+1], short) <= worstRatio(values[:n], short) {
+			n++
+		}
+
+		var sum float64
+		for _, v := range values[:n] {
+			sum += v
+		}
+		if r.w >= r.h {
+			// A column on the left
+			colW := sum / r.h
+			y := r.y
+			for _, v := range values[:n] {
+				h := v / colW
+				out = append(out, frect{r.x, y, colW, h})
+				y += h
+			}
+			r.x += colW
+			r.w -= colW
+		} else {
+			// A row along the top
+			rowH := sum / r.w
+			x := r.x
+			for _, v := range values[:n] {
+				w := v / rowH
+				out = append(out, frect{x, r.y, w, rowH})
+				x += w
+			}
+			r.y += rowH
+			r.h -= rowH
+		}
+		values = values[n:]
+	}
+	return out
+}
+
+// worstRatio is the largest aspect ratio in a row laid along side
+func worstRatio(row []float64, side float64) float64 {
+	var sum float64
+	minV, maxV := math.Inf(1), 0.0
+	for _, v := range row {
+		sum += v
+		minV = math.Min(minV, v)
+		maxV = math.Max(maxV, v)
+	}
+	s2, w2 := sum*sum, side*side
+	return math.Max(w2*maxV/s2, s2/(w2*minV))
+}
+
+// treemapCell is one terminal cell of the map; wide graphemes take their
+// cell and leave the next one empty
+type treemapCell struct {
+	text  string
+	style int
+}
+
+// RenderTreemap draws a layout from LayoutTreemap. Each rectangle shows its
+// label and size when it is large enough.
+func RenderTreemap(items []TreemapItem, rects []TreemapRect, width, height int, opts TreemapOptions) string {
+	lr := lipgloss.NewRenderer(io.Discard)
+	lr.SetColorProfile(opts.Profile)
+	mono := opts.Profile == termenv.Ascii
+
+	// Style 0 is the empty background, style i+1 belongs to rectangle i
+	styles := []lipgloss.Style{lr.NewStyle()}
+	grid := make([][]treemapCell, height)
+	for y := range grid {
+		grid[y] = make([]treemapCell, width)
+		for x := range grid[y] {
+			grid[y][x] = treemapCell{text: " "}
+		}
+	}
+
+	for i, r := range rects {
+		style := lr.NewStyle().
+			Background(lipgloss.Color(treemapPalette[i%len(treemapPalette)])).
+			Foreground(lipgloss.Color("255"))
+		selected := r.Index == opts.Selected
+		if selected {
+			style = style.Foreground(lipgloss.Color("226")).Bold(true)
+		}
+		if mono {
+			style = lr.NewStyle()
+		}
+		styles = append(styles, style)
+		id := len(styles) - 1
+
+		fill := " "
+		if mono && (r.W < 3 || r.H < 2) {
+			// Too small for an outline
+			fill = "░"
+			if selected {
+				fill = "▓"
+			}
+		}
+		for y := r.Y; y < r.Y+r.H && y < height; y++ {
+			for x := r.X; x < r.X+r.W && x < width; x++ {
+				grid[y][x] = treemapCell{text: fill, style: id}
+			}
+		}
+
+		// Outline every box in monochrome, and the selection in colour
+		inset := 0
+		if r.W >= 3 && r.H >= 2 && (mono || selected) {
+			box := lipgloss.NormalBorder()
+			if selected {
+				box = lipgloss.DoubleBorder()
+			}
+			outline(grid, r, box, id)
+			inset = 1
+		}
+
+		// The label is shortened to fit, the size is shown whole or not at all
+		item := items[r.Index]
+		labelW := r.W - 2*inset - 1
+		size := FormatBytes(item.Size)
+		y := r.Y + inset
+		if labelW >= 1 && y < r.Y+r.H-inset && y < height {
+			put(grid[y], r.X+inset+1, ansi.Truncate(item.Label, labelW, "…"), id)
+			if y+1 < r.Y+r.H-inset && y+1 < height && ansi.StringWidth(size) <= labelW {
+				put(grid[y+1], r.X+inset+1, size, id)
+			}
+		}
+	}
+
+	var b strings.Builder
+	for y, row := range grid {
+		if y > 0 {
+			b.WriteByte('\n')
+		}
+		start := 0
+		for x := 1; x <= len(row); x++ {
+			if x == len(row) || row[x].style != row[start].style {
+				var run strings.Builder
+				for _, c := range row[start:x] {
+					run.WriteString(c.text)
+				}
+				b.WriteString(styles[row[start].style].Render(run.String()))
+				start = x
+			}
+		}
+	}
+	return b.String()
+}
+
+// outline draws a border along the edges of r
+func outline(grid [][]treemapCell, r TreemapRect, box lipgloss.Border, style int) {
+	x1, y1 := r.X+r.W-1, r.Y+r.H-1
+	set := func(x, y int, s string) {
+		if y < len(grid) && x < len(grid[y]) {
+			grid[y][x] = treemapCell{text: s, style: style}
+		}
+	}
+	for x := r.X + 1; x < x1; x++ {
+		set(x, r.Y, box.Top)
+		set(x, y1, box.Bottom)
+	}
+	for y := r.Y + 1; y < y1; y++ {
+		set(r.X, y, box.Left)
+		set(x1, y, box.Right)
+	}
+	set(r.X, r.Y, box.TopLeft)
+	set(x1, r.Y, box.TopRight)
+	set(r.X, y1, box.BottomLeft)
+	set(x1, y1, box.BottomRight)
+}
+
+// put writes text into a grid row cell by cell, grapheme by grapheme
+func put(row []treemapCell, x int, text string, style int) {
+	g := uniseg.NewGraphemes(ansi.Strip(text))
+	for g.Next() && x < len(row) {
+		cluster := g.Str()
+		w := g.Width()
+		if w == 0 {
+			continue
+		}
+		if x+w > len(row) {
+			return
+		}
+		row[x] = treemapCell{text: cluster, style: style}
+		for i := 1; i < w; i++ {
+			row[x+i] = treemapCell{text: "", style: style}
+		}
+		x += w
+	}
+}
+
+// NearestTreemapRect finds the rectangle next to the one at position from
+// in the direction (dx, dy), for moving the selection with arrow keys. It
+// returns from when there is nothing in that direction.
+func NearestTreemapRect(rects []TreemapRect, from, dx, dy int) int {
+	if from < 0 || from >= len(rects) {
+		return 0
+	}
+	cur := rects[from]
+	cx, cy := float64(cur.X)+float64(cur.W)/2, float64(cur.Y)+float64(cur.H)/2
+	best, bestScore := from, math.Inf(1)
+	for i, r := range rects {
+		if i == from {
+			continue
+		}
+		rx, ry := float64(r.X)+float64(r.W)/2, float64(r.Y)+float64(r.H)/2
+		// Distance along the direction must be positive; sideways
+		// distance counts double so the move feels straight
+		along := (rx-cx)*float64(dx) + (ry-cy)*float64(dy)*2
+		side := math.Abs((rx-cx)*float64(dy)) + math.Abs((ry-cy)*float64(dx))*2
+		if along <= 0 {
+			continue
+		}
+		if score := along + 2*side; score < bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// TreemapRectAt returns the position of the rectangle containing a cell, or -1
+func TreemapRectAt(rects []TreemapRect, x, y int) int {
+	for i, r := range rects {
+		if x >= r.X && x < r.X+r.W && y >= r.Y && y < r.Y+r.H {
+			return i
+		}
+	}
+	return -1
+}
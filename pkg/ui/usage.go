@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"fmt"
+
+	"checkpoint/pkg/analyzer"
+)
+
+// usageColumns are the columns of the directory usage view
+var usageColumns = []column{
+	{title: "Disk Usage", width: 10},
+	{title: "Apparent", width: 10, optional: true},
+	{title: "Share", width: 17},
+	{title: "Items", width: 8, optional: true},
+	{title: "Name", width: 14, flex: 1},
+}
+
+// largestColumns are the columns of the largest files list
+var largestColumns = []column{
+	{title: "Size", width: 10},
+	{title: "Path", width: 20, flex: 1},
+}
+
+// DisplayDirUsage shows what takes up the space below a directory
+func DisplayDirUsage(report analyzer.Report) {
+	stdoutRenderer().DirUsage(report)
+}
+
+func (t *terminalRenderer) DirUsage(r analyzer.Report) error {
+	t.p.err = nil
+	t.p.println(t.st.title.Render("📂 " + truncatePath(r.Root, t.width-3)))
+	summary := t.st.driveDesc.Render(usageSummary(r))
+	if r.Partial {
+		summary = t.st.used.Render(usageSummary(r))
+	}
+	t.p.println(summary)
+	t.p.println()
+
+	widths := layoutColumns(usageColumns, t.width)
+	t.p.println(t.makeRowWithWidths(columnTitles(usageColumns), t.st.header, widths))
+	for i, e := range r.Entries {
+		share := percent(e.Size(r.Apparent), r.Size())
+		name := truncatePath(entryName(e), widths[4])
+		if e.IsDir {
+			name = t.st.driveName.Render(name)
+		}
+		row := []string{
+			t.st.size.Render(FormatBytes(e.AllocatedBytes)),
+			t.st.inode.Render(FormatBytes(e.ApparentBytes)),
+			t.createProgressBar(int(share), 10, t.st.progressBarFull) + fmt.Sprintf(" %5.1f%%", share),
+			fmt.Sprintf("%d", e.Items),
+			name,
+		}
+		style := t.st.row
+		if i%2 == 1 {
+			style = t.st.evenRow
+		}
+		t.p.println(t.makeRowWithWidths(row, style, widths))
+	}
+
+	if len(r.Largest) > 0 {
+		t.p.println(t.st.summaryTitle.MarginTop(1).Render("📄 Largest files"))
+		widths := layoutColumns(largestColumns, t.width)
+		t.p.println(t.makeRowWithWidths(columnTitles(largestColumns), t.st.header, widths))
+		for i, e := range r.Largest {
+			row := []string{
+				t.st.size.Render(FormatBytes(e.Size(r.Apparent))),
+				truncatePath(e.Path, widths[1]),
+			}
+			style := t.st.row
+			if i%2 == 1 {
+				style = t.st.evenRow
+			}
+			t.p.println(t.makeRowWithWidths(row, style, widths))
+		}
+	}
+	return t.p.err
+}
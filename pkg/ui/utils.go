@@ -1,17 +1,50 @@
 package ui
 
-import "fmt"
+import "checkpoint/pkg/disk"
 
-// FormatBytes is shared between display.go and friendly.go
+// FormatBytes is shared between all renderers
 func FormatBytes(bytes uint64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+	return disk.FormatBytes(bytes)
+}
+
+// FormatDelta formats a change in size with its sign
+func FormatDelta(delta int64) string {
+	switch {
+	case delta > 0:
+		return "+" + FormatBytes(uint64(delta))
+	case delta < 0:
+		return "-" + FormatBytes(uint64(-delta))
+	}
+	return "0 B"
+}
+
+// percent returns part as a percentage of total, 0 when total is 0
+func percent(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
+
+// BarCells splits a bar width cells wide into the cells filled by used space
+// and, among them, the cells of the reclaimable part. Reclaimable space too
+// small for a cell of its own still gets one, so it is never hidden.
+func BarCells(usedPercent, reclaimPercent float64, width int) (filled, reclaim int) {
+	filled = int(usedPercent) * width / 100
+	if filled < 0 {
+		filled = 0
+	}
+	if filled > width {
+		filled = width
 	}
-	div, exp := uint64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	if reclaimPercent > 0 {
+		reclaim = int(reclaimPercent*float64(width)/100 + 0.5)
+		if reclaim < 1 {
+			reclaim = 1
+		}
+		if reclaim > filled {
+			reclaim = filled
+		}
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
\ No newline at end of file
+	return filled, reclaim
+}